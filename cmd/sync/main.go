@@ -5,55 +5,149 @@ import (
 	"ai_teach_system/models"
 	"ai_teach_system/services"
 	"ai_teach_system/tasks"
+	"ai_teach_system/utils"
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
 
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
+// 退出码
+const (
+	exitOK            = 0 // 同步完成且所有题目处理成功
+	exitTaskFailed    = 1 // 任务失败，可使用 resume 子命令继续
+	exitUsage         = 2 // 参数错误
+	exitPartialFailed = 3 // 任务完成，但部分题目处理失败
 )
 
+const usage = `用法:
+  sync [problems] [flags]   同步题目内容和标签（默认全量同步）
+  sync tags [flags]         只同步题目标签
+  sync resume -task <id>    从失败或已取消任务的断点处继续同步
+
+problems / tags 支持的参数:
+  -slug string    只同步指定 slug 的题目，多个以逗号分隔
+  -from int       题号范围下限（包含）
+  -to int         题号范围上限（包含）
+  -dry-run        只打印将要发生的变化，不写入数据库
+
+退出码: 0 成功，1 任务失败，2 参数错误，3 部分题目同步失败
+`
+
 func main() {
-	config.LoadConfig()
+	os.Exit(run(os.Args[1:]))
+}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.DB.DBUser,
-		config.DB.DBPassword,
-		config.DB.DBHost,
-		config.DB.DBPort,
-		config.DB.DBName,
-	)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("连接数据库失败: %v", err)
+func run(args []string) int {
+	command := "problems"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	now := time.Now()
-	taskRecord := &models.TaskRecord{
-		TaskType:  "leetcode_sync",
-		Status:    models.TaskStatusPending,
-		StartTime: &now,
+	var opts tasks.SyncOptions
+	var resumeTaskID uint
+
+	switch command {
+	case "problems", "tags":
+		fs := flag.NewFlagSet(command, flag.ContinueOnError)
+		fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+		slugs := fs.String("slug", "", "")
+		fs.IntVar(&opts.FromID, "from", 0, "")
+		fs.IntVar(&opts.ToID, "to", 0, "")
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "")
+		if err := fs.Parse(args); err != nil {
+			return exitUsage
+		}
+		if *slugs != "" {
+			for _, slug := range strings.Split(*slugs, ",") {
+				if slug = strings.TrimSpace(slug); slug != "" {
+					opts.Slugs = append(opts.Slugs, slug)
+				}
+			}
+		}
+		if opts.ToID != 0 && opts.FromID > opts.ToID {
+			fmt.Fprintln(os.Stderr, "-from 不能大于 -to")
+			return exitUsage
+		}
+		opts.TagsOnly = command == "tags"
+	case "resume":
+		fs := flag.NewFlagSet(command, flag.ContinueOnError)
+		fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+		taskID := fs.Uint("task", 0, "")
+		if err := fs.Parse(args); err != nil {
+			return exitUsage
+		}
+		if *taskID == 0 {
+			fmt.Fprintln(os.Stderr, "请使用 -task 指定要恢复的任务ID")
+			return exitUsage
+		}
+		resumeTaskID = *taskID
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n%s", command, usage)
+		return exitUsage
 	}
-	if err := db.Create(taskRecord).Error; err != nil {
-		log.Fatalf("创建任务记录失败: %v", err)
+
+	config.LoadConfig()
+	db := utils.InitDB()
+
+	// 收到中断信号时取消同步，任务记录会被标记为已取消，之后可以 resume
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	task := tasks.NewTasksManager(db, services.NewLeetCodeService(db))
+
+	var (
+		taskRecord *models.TaskRecord
+		report     *tasks.SyncReport
+		err        error
+	)
+	if resumeTaskID != 0 {
+		taskRecord, report, err = task.ResumeSync(ctx, resumeTaskID)
+	} else {
+		taskRecord, report, err = task.RunSync(ctx, opts)
 	}
 
-	leetcodeService := services.NewLeetCodeService(db)
-	task := tasks.NewTasksManager(db, leetcodeService)
-	task.SyncLeetCodeProblems()
+	if report != nil {
+		printReport(report, opts.DryRun)
+	}
 
 	if err != nil {
-		log.Printf("同步任务失败: %v", err)
-		db.Model(taskRecord).Updates(map[string]interface{}{
-			"status":        models.TaskStatusFailed,
-			"error_message": err.Error(),
-			"end_time":      time.Now(),
-		})
+		if taskRecord != nil && taskRecord.ID != 0 {
+			log.Printf("同步任务 %d 失败: %v，可使用 `sync resume -task %d` 继续", taskRecord.ID, err, taskRecord.ID)
+		} else {
+			log.Printf("同步任务失败: %v", err)
+		}
+		return exitTaskFailed
+	}
+
+	if opts.DryRun {
+		log.Println("dry-run 完成，未写入数据库")
 	} else {
-		log.Println("同步任务完成")
-		db.Model(taskRecord).Updates(map[string]interface{}{
-			"status":   models.TaskStatusCompleted,
-			"end_time": time.Now(),
-		})
+		log.Printf("同步任务 %d 完成: 共 %d 题，成功 %d 题", taskRecord.ID, taskRecord.TotalCount, taskRecord.SuccessCount)
+	}
+
+	if report.Failed > 0 {
+		return exitPartialFailed
+	}
+	return exitOK
+}
+
+func printReport(report *tasks.SyncReport, dryRun bool) {
+	if dryRun {
+		for _, change := range report.Changes {
+			if change.Action == "create" {
+				fmt.Printf("+ %d %s\n", change.LeetcodeID, change.TitleSlug)
+			} else {
+				fmt.Printf("~ %d %s (%s)\n", change.LeetcodeID, change.TitleSlug, strings.Join(change.Fields, ", "))
+			}
+		}
 	}
+	fmt.Printf("新增: %d，更新: %d，未变化: %d，失败: %d\n", report.Created, report.Updated, report.Unchanged, report.Failed)
 }
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"ai_teach_system/constants"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -30,10 +35,114 @@ type leetcodeConfig struct {
 	LeetcodeSession string
 }
 
+// JobConfig 定时任务配置，通过 JOB_<任务名>_<配置项> 环境变量覆盖任务的默认值
+type JobConfig struct {
+	Schedule string // cron 表达式（包含秒），对应 JOB_<NAME>_SCHEDULE
+	Enabled  *bool  // 是否启用，对应 JOB_<NAME>_ENABLED
+	Overlap  string // 上一次执行未结束时的策略：skip / delay，对应 JOB_<NAME>_OVERLAP
+	Missed   string // 错过执行时间时的策略：skip / run_once，对应 JOB_<NAME>_MISSED
+}
+
+type schedulerConfig struct {
+	InstanceID string               // 当前实例标识，用于分布式租约
+	LeaseTTL   time.Duration        // 租约有效期，任务运行期间会定期续约
+	Jobs       map[string]JobConfig // 以任务名（小写）为键
+}
+
+// LLMProviderConfig 兼容 OpenAI 接口的大模型服务配置
+type LLMProviderConfig struct {
+	Name        string
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Temperature *float64 // 为空时使用服务端默认值
+	MaxTokens   int      // 0 表示不限制
+	Timeout     time.Duration
+	// 每千 tokens 的价格（元），用于估算费用
+	PromptPrice     float64
+	CompletionPrice float64
+}
+
+type llmConfig struct {
+	Providers []LLMProviderConfig
+	Features  map[string][]string // AI 功能 -> provider 名称，多模型对比的功能按顺序配置多个
+	// 多轮对话发送给模型的历史消息 token 预算，超出部分压缩为摘要
+	ChatHistoryTokens int
+	// 启用响应缓存的 AI 功能及其缓存有效期，不在其中的功能不缓存
+	CacheTTL map[string]time.Duration
+	// 各角色每个用户每天可使用的 token 数，0 表示不限制，可被数据库中按班级、课程配置的额度覆盖
+	DailyTokenQuota map[string]int
+	// AI 助教回答与参考代码的相似度达到该值时视为泄露答案（0-1）
+	IntegritySimilarity float64
+	// 结构化输出不符合格式时要求模型重新输出的次数
+	StructuredRetries int
+	// 误区聚类：统计最近多少天的失败作答、代码相似度达到多少时归为一类、
+	// 少于多少条的聚类合并为同类错误的其他作答，以及每门课程最多总结多少个聚类
+	ClusterWindowDays   int
+	ClusterSimilarity   float64
+	ClusterMinSize      int
+	ClusterMaxPerCourse int
+	// 学习报告中近期失败作答和提示使用的统计天数
+	ReportWindowDays int
+	// 课程资料检索：切分片段的字符数和相邻片段重叠的字符数、每次检索的片段数量，以及相似度低于多少时不引用
+	MaterialChunkSize    int
+	MaterialChunkOverlap int
+	MaterialTopK         int
+	MaterialMinScore     float64
+	// AI 调用记录（完整的提示词和模型输出）保留的天数，0 表示永久保留
+	LogRetentionDays int
+	// 提交失败后的自动分析：最多尝试的次数、第一次重试的等待时间（之后每次翻倍）和同时执行的分析数量
+	AutoAnalyzeMaxAttempts int
+	AutoAnalyzeRetryDelay  time.Duration
+	AutoAnalyzeConcurrency int
+}
+
+// embeddingConfig 题目语义检索使用的向量模型，BaseURL 为空时使用本地哈希向量（仅适合开发环境）
+type embeddingConfig struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	Dimensions int // 本地哈希向量的维度
+	BatchSize  int // 每次请求向量化的文本数量
+	Timeout    time.Duration
+}
+
 var DB dbConfig
 var JWT jwtConfig
 var OSS ossConfig
 var Leetcode leetcodeConfig
+var Scheduler schedulerConfig
+var LLM llmConfig
+var Embedding embeddingConfig
+
+// 内置 provider 的默认配置，未设置 LLM_PROVIDERS 时使用
+var defaultLLMProviders = map[string]LLMProviderConfig{
+	"qwen": {
+		BaseURL: constants.QwenHost,
+		Model:   "qwen2.5-14b-instruct-1m",
+	},
+	"deepseek": {
+		BaseURL: constants.DeepseekHost,
+		Model:   "deepseek-chat",
+	},
+}
+
+// 各 AI 功能默认使用的 provider
+var defaultLLMFeatures = map[string]string{
+	"hint":            "deepseek",
+	"chat":            "deepseek",
+	"chat_summary":    "deepseek",
+	"correct_code":    "qwen,deepseek",
+	"analyze_code":    "qwen,deepseek",
+	"suggest_tags":    "qwen",
+	"judge":           "qwen",
+	"draft_problem":   "deepseek",
+	"classify_errors": "qwen",
+	"misconceptions":  "deepseek",
+	"learning_report": "deepseek",
+	"syllabus_plan":   "deepseek",
+	"review_code":     "qwen",
+}
 
 func LoadConfig() {
 	// 加载 .env 文件
@@ -64,6 +173,145 @@ func LoadConfig() {
 	Leetcode = leetcodeConfig{
 		LeetcodeSession: getEnv("LEETCODE_SESSION", ""),
 	}
+
+	hostname, _ := os.Hostname()
+	Scheduler = schedulerConfig{
+		InstanceID: getEnv("SCHEDULER_INSTANCE_ID", fmt.Sprintf("%s-%d", hostname, os.Getpid())),
+		LeaseTTL:   time.Duration(getEnvInt("SCHEDULER_LEASE_TTL_SECONDS", 60)) * time.Second,
+		Jobs:       loadJobConfigs(),
+	}
+
+	LLM = llmConfig{
+		Providers: loadLLMProviders(),
+		Features:  loadLLMFeatures(),
+
+		ChatHistoryTokens: getEnvInt("AI_CHAT_HISTORY_TOKENS", 4000),
+		CacheTTL:          loadAICacheTTL(),
+		DailyTokenQuota: map[string]int{
+			"USER":  getEnvInt("AI_QUOTA_USER_DAILY_TOKENS", 0),
+			"ADMIN": getEnvInt("AI_QUOTA_ADMIN_DAILY_TOKENS", 0),
+		},
+		IntegritySimilarity: getEnvFloat("AI_INTEGRITY_SIMILARITY", 0.6),
+		StructuredRetries:   getEnvInt("AI_STRUCTURED_RETRIES", 2),
+		ClusterWindowDays:   getEnvInt("AI_CLUSTER_WINDOW_DAYS", 7),
+		ClusterSimilarity:   getEnvFloat("AI_CLUSTER_SIMILARITY", 0.5),
+		ClusterMinSize:      getEnvInt("AI_CLUSTER_MIN_SIZE", 2),
+		ClusterMaxPerCourse: getEnvInt("AI_CLUSTER_MAX_PER_COURSE", 20),
+		ReportWindowDays:    getEnvInt("AI_REPORT_WINDOW_DAYS", 7),
+
+		MaterialChunkSize:    getEnvInt("AI_MATERIAL_CHUNK_SIZE", 800),
+		MaterialChunkOverlap: getEnvInt("AI_MATERIAL_CHUNK_OVERLAP", 100),
+		MaterialTopK:         getEnvInt("AI_MATERIAL_TOP_K", 3),
+		MaterialMinScore:     getEnvFloat("AI_MATERIAL_MIN_SCORE", 0.2),
+
+		LogRetentionDays: getEnvInt("AI_LOG_RETENTION_DAYS", 180),
+
+		AutoAnalyzeMaxAttempts: getEnvInt("AI_AUTO_ANALYZE_MAX_ATTEMPTS", 3),
+		AutoAnalyzeRetryDelay:  time.Duration(getEnvInt("AI_AUTO_ANALYZE_RETRY_SECONDS", 60)) * time.Second,
+		AutoAnalyzeConcurrency: getEnvInt("AI_AUTO_ANALYZE_CONCURRENCY", 2),
+	}
+
+	Embedding = embeddingConfig{
+		BaseURL:    getEnv("EMBEDDING_BASE_URL", ""),
+		APIKey:     getEnv("EMBEDDING_API_KEY", ""),
+		Model:      getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		Dimensions: getEnvInt("EMBEDDING_LOCAL_DIMENSIONS", 512),
+		BatchSize:  getEnvInt("EMBEDDING_BATCH_SIZE", 16),
+		Timeout:    time.Duration(getEnvInt("EMBEDDING_TIMEOUT_SECONDS", 30)) * time.Second,
+	}
+}
+
+// loadLLMProviders 读取 LLM_PROVIDERS 中列出的 provider，每个 provider 通过 LLM_<NAME>_* 环境变量配置
+func loadLLMProviders() []LLMProviderConfig {
+	var providers []LLMProviderConfig
+	for _, name := range splitList(getEnv("LLM_PROVIDERS", "qwen,deepseek")) {
+		prefix := "LLM_" + strings.ToUpper(name) + "_"
+		provider := defaultLLMProviders[name]
+		provider.Name = name
+		provider.BaseURL = getEnv(prefix+"BASE_URL", provider.BaseURL)
+		// 兼容旧的 QWEN_API_KEY / DEEPSEEK_API_KEY
+		provider.APIKey = getEnv(prefix+"API_KEY", os.Getenv(strings.ToUpper(name)+"_API_KEY"))
+		provider.Model = getEnv(prefix+"MODEL", provider.Model)
+		if value, err := strconv.ParseFloat(os.Getenv(prefix+"TEMPERATURE"), 64); err == nil {
+			provider.Temperature = &value
+		}
+		provider.MaxTokens = getEnvInt(prefix+"MAX_TOKENS", 0)
+		provider.Timeout = time.Duration(getEnvInt(prefix+"TIMEOUT_SECONDS", 120)) * time.Second
+		provider.PromptPrice = getEnvFloat(prefix+"PROMPT_PRICE", 0)
+		provider.CompletionPrice = getEnvFloat(prefix+"COMPLETION_PRICE", 0)
+
+		if provider.BaseURL == "" || provider.Model == "" {
+			log.Printf("LLM provider %s 缺少 BASE_URL 或 MODEL 配置，已忽略", name)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// loadLLMFeatures 读取 AI_FEATURE_<FEATURE> 配置的功能与 provider 的映射
+func loadLLMFeatures() map[string][]string {
+	features := make(map[string][]string)
+	for feature, providers := range defaultLLMFeatures {
+		features[feature] = splitList(getEnv("AI_FEATURE_"+strings.ToUpper(feature), providers))
+	}
+	return features
+}
+
+// loadAICacheTTL 读取 AI_CACHE_FEATURES 中启用缓存的功能，有效期由 AI_CACHE_<FEATURE>_TTL_SECONDS 单独设置，
+// 未设置时使用 AI_CACHE_TTL_SECONDS
+func loadAICacheTTL() map[string]time.Duration {
+	defaultTTL := getEnvInt("AI_CACHE_TTL_SECONDS", 7*24*3600)
+	ttl := make(map[string]time.Duration)
+	for _, feature := range splitList(getEnv("AI_CACHE_FEATURES", "analyze_code,correct_code,classify_errors")) {
+		seconds := getEnvInt("AI_CACHE_"+strings.ToUpper(feature)+"_TTL_SECONDS", defaultTTL)
+		if seconds > 0 {
+			ttl[feature] = time.Duration(seconds) * time.Second
+		}
+	}
+	return ttl
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.ToLower(item))
+		}
+	}
+	return items
+}
+
+func loadJobConfigs() map[string]JobConfig {
+	jobs := make(map[string]JobConfig)
+	for _, env := range os.Environ() {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(key, "JOB_") || value == "" {
+			continue
+		}
+		key = strings.TrimPrefix(key, "JOB_")
+
+		for _, suffix := range []string{"_SCHEDULE", "_ENABLED", "_OVERLAP", "_MISSED"} {
+			if !strings.HasSuffix(key, suffix) {
+				continue
+			}
+			name := strings.ToLower(strings.TrimSuffix(key, suffix))
+			job := jobs[name]
+			switch suffix {
+			case "_SCHEDULE":
+				job.Schedule = value
+			case "_ENABLED":
+				enabled := value == "true" || value == "1"
+				job.Enabled = &enabled
+			case "_OVERLAP":
+				job.Overlap = strings.ToLower(value)
+			case "_MISSED":
+				job.Missed = strings.ToLower(value)
+			}
+			jobs[name] = job
+		}
+	}
+	return jobs
 }
 
 func getEnv(key, defaultValue string) string {
@@ -73,3 +321,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
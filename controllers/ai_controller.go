@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"ai_teach_system/models"
 	"ai_teach_system/services"
 	"ai_teach_system/utils"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -24,28 +27,48 @@ type GenerateCodeRequest struct {
 	Title           string `json:"title" binding:"required"`
 	Content         string `json:"content" binding:"required"`
 	SampleTestcases string `json:"sample_testcases" binding:"required"`
-	ModelType       string `json:"model_type" binding:"required"`
+	ProblemID       uint   `json:"problem_id"` // 可选，传入时使用题目所属课程的提示词并计入课程用量
+	ModelType       string `json:"model_type"` // 为空时使用功能配置的模型
 }
 
+// CorrectCodeRequest 指定 record_id 时处理作答记录提交的代码并把结果保存到作答记录，未指定时处理 typed_code
 type CorrectCodeRequest struct {
 	RecordID  uint   `json:"record_id"`
 	ProblemID uint   `json:"problem_id" binding:"required"`
 	Language  string `json:"language" binding:"required"`
-	TypedCode string `json:"typed_code" binding:"required"`
+	TypedCode string `json:"typed_code"`
 }
 
+// ReviewCodeRequest 指定 record_id 时审阅作答记录提交的代码，批注会保存到作答记录，编辑器之后可以重新获取；
+// 未指定时审阅 typed_code
+type ReviewCodeRequest struct {
+	RecordID  uint   `json:"record_id"`
+	ProblemID uint   `json:"problem_id" binding:"required"`
+	Language  string `json:"language" binding:"required"`
+	TypedCode string `json:"typed_code"`
+	ModelType string `json:"model_type"` // 为空时使用功能配置的模型
+}
+
+// AnalyzeCodeRequest 与 CorrectCodeRequest 相同，指定 record_id 时分析作答记录提交的代码
 type AnalyzeCodeRequest struct {
 	RecordID  uint   `json:"record_id"`
 	ProblemID uint   `json:"problem_id" binding:"required"`
 	Language  string `json:"language" binding:"required"`
-	TypedCode string `json:"typed_code" binding:"required"`
+	TypedCode string `json:"typed_code"`
 }
 
 type ChatRequest struct {
 	ProblemID uint   `json:"problem_id" binding:"required"`
 	Question  string `json:"question" binding:"required"`
 	TypedCode string `json:"typed_code" binding:"required"`
-	ModelType string `json:"model_type" binding:"required"`
+	ModelType string `json:"model_type"` // 为空时使用功能配置的模型
+}
+
+type DraftProblemRequest struct {
+	Difficulty  models.ProblemDifficulty `json:"difficulty" binding:"required"`
+	Constraints string                   `json:"constraints"` // 对题目的额外要求，如数据范围、输入形式
+	Language    string                   `json:"language"`    // 参考解答的语言，默认 cpp
+	ModelType   string                   `json:"model_type"`  // 为空时使用功能配置的模型
 }
 
 type JudgeCodeRequest struct {
@@ -62,10 +85,10 @@ func (c *AIController) GenerateHint(ctx *gin.Context) {
 		return
 	}
 
-	code, err := c.Service.GenerateHint(req.Title, req.Content, req.SampleTestcases, req.ModelType)
+	code, err := c.Service.GenerateHint(aiContext(ctx), req.ProblemID, req.Title, req.Content, req.SampleTestcases, req.ModelType)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
 		return
 	}
 
@@ -81,16 +104,111 @@ func (c *AIController) CorrectCode(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.Service.CorrectCode(req.RecordID, req.ProblemID, req.Language, req.TypedCode)
+	if req.RecordID == 0 && req.TypedCode == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("请指定作答记录或代码"))
+		return
+	}
+
+	response, err := c.Service.CorrectCode(aiContext(ctx), req.RecordID, req.ProblemID, req.Language, req.TypedCode)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(response))
+}
+
+// ReviewCode 返回按行定位的审阅批注（行号范围、严重程度、说明和替换代码），供编辑器在代码行上显示标记
+func (c *AIController) ReviewCode(ctx *gin.Context) {
+	var req ReviewCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
 		return
 	}
 
+	if req.RecordID == 0 && req.TypedCode == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("请指定作答记录或代码"))
+		return
+	}
+
+	// 学生只能审阅自己的作答记录
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+	}
+
+	response, err := c.Service.ReviewCode(aiContext(ctx), userID, req.RecordID, req.ProblemID, req.Language, req.TypedCode, req.ModelType)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("代码审阅失败: %v", err)))
+		return
+	}
 	ctx.JSON(http.StatusOK, utils.Success(response))
 }
 
+// GetReviewComments 获取作答记录已保存的审阅批注，学生只能获取自己的作答记录
+func (c *AIController) GetReviewComments(ctx *gin.Context) {
+	recordID, err := strconv.ParseUint(ctx.Param("record_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的作答记录ID"))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+	}
+
+	comments, err := c.Service.GetReviewComments(userID, uint(recordID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(fmt.Sprintf("获取审阅批注失败: %v", err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.Success(comments))
+}
+
+// autoAnalysisParams 解析作答记录ID，学生只能访问自己的作答记录，管理员返回的用户ID为 0
+func autoAnalysisParams(ctx *gin.Context) (uint, uint, bool) {
+	recordID, err := strconv.ParseUint(ctx.Param("record_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的作答记录ID"))
+		return 0, 0, false
+	}
+
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+	}
+	return userID, uint(recordID), true
+}
+
+// GetAutoAnalysis 获取提交失败后自动进行的代码分析的状态和结果
+func (c *AIController) GetAutoAnalysis(ctx *gin.Context) {
+	userID, recordID, ok := autoAnalysisParams(ctx)
+	if !ok {
+		return
+	}
+
+	analysis, err := c.Service.GetAutoAnalysis(userID, recordID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(err.Error()))
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.Success(analysis))
+}
+
+// AutoAnalysisStream 以 Server-Sent Events 的形式推送自动分析的状态变化，分析完成时推送结果
+func (c *AIController) AutoAnalysisStream(ctx *gin.Context) {
+	userID, recordID, ok := autoAnalysisParams(ctx)
+	if !ok {
+		return
+	}
+
+	streamSSE(ctx, "获取自动分析结果失败", func(reqCtx context.Context, onEvent func(event string, data map[string]interface{})) error {
+		return c.Service.WaitAutoAnalysis(reqCtx, userID, recordID, onEvent)
+	})
+}
+
 func (c *AIController) AnalyzeCode(ctx *gin.Context) {
 	var req AnalyzeCodeRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -98,9 +216,14 @@ func (c *AIController) AnalyzeCode(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.Service.AnalyzeCode(req.RecordID, req.ProblemID, req.Language, req.TypedCode)
+	if req.RecordID == 0 && req.TypedCode == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("请指定作答记录或代码"))
+		return
+	}
+
+	response, err := c.Service.AnalyzeCode(aiContext(ctx), req.RecordID, req.ProblemID, req.Language, req.TypedCode)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成代码失败: %v", err)))
 		return
 	}
 	ctx.JSON(http.StatusOK, utils.Success(response))
@@ -113,13 +236,14 @@ func (c *AIController) Chat(ctx *gin.Context) {
 		return
 	}
 
-	message, err := c.Service.Chat(req.ProblemID, req.TypedCode, req.Question, req.ModelType)
+	message, citations, err := c.Service.Chat(aiContext(ctx), req.ProblemID, req.TypedCode, req.Question, req.ModelType)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("问答异常: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("问答异常: %v", err)))
 		return
 	}
 	ctx.JSON(http.StatusOK, utils.Success(gin.H{
-		"message": message,
+		"message":   message,
+		"citations": citations,
 	}))
 }
 
@@ -130,9 +254,9 @@ func (c *AIController) SuggestKnowledgePointTags(ctx *gin.Context) {
 		return
 	}
 
-	suggestedTags, err := c.Service.SuggestKnowledgePointTags(uint(knowledgePointID))
+	suggestedTags, err := c.Service.SuggestKnowledgePointTags(aiContext(ctx), uint(knowledgePointID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("推荐标签失败: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("推荐标签失败: %v", err)))
 		return
 	}
 
@@ -141,6 +265,36 @@ func (c *AIController) SuggestKnowledgePointTags(ctx *gin.Context) {
 	}))
 }
 
+// DraftProblem 根据知识点生成题目草稿，教师修改后再发布为自定义题目
+func (c *AIController) DraftProblem(ctx *gin.Context) {
+	knowledgePointID, err := strconv.ParseUint(ctx.Param("knowledge_point_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的知识点ID"))
+		return
+	}
+
+	var req DraftProblemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	if req.Difficulty != models.ProblemDifficultyEasy &&
+		req.Difficulty != models.ProblemDifficultyMedium &&
+		req.Difficulty != models.ProblemDifficultyHard {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的难度值"))
+		return
+	}
+
+	draft, err := c.Service.DraftProblem(aiContext(ctx), ctx.GetUint("userID"), uint(knowledgePointID), req.Difficulty, req.Constraints, req.Language, req.ModelType)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成题目草稿失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(draft))
+}
+
 func (c *AIController) JudgeCode(ctx *gin.Context) {
 	var req JudgeCodeRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -148,11 +302,121 @@ func (c *AIController) JudgeCode(ctx *gin.Context) {
 		return
 	}
 
-	result, err := c.Service.JudgeCode(req.ProblemID, req.Language, req.Code, req.Test)
+	result, err := c.Service.JudgeCode(aiContext(ctx), req.ProblemID, req.Language, req.Code, req.Test)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("判题失败: %v", err)))
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("判题失败: %v", err)))
 		return
 	}
 
 	ctx.JSON(http.StatusOK, utils.Success(result))
 }
+
+// ListModels 列出已配置的模型及其用于哪些 AI 功能
+func (c *AIController) ListModels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, utils.Success(c.Service.ListModels()))
+}
+
+// aiContext 将当前用户附加到请求的 context 上，用于记录 AI 用量和检查额度
+func aiContext(ctx *gin.Context) context.Context {
+	return services.WithAIUser(ctx.Request.Context(), ctx.GetUint("userID"))
+}
+
+// aiErrorStatus AI 调用失败时返回的状态码，额度用完时返回 429
+func aiErrorStatus(err error) int {
+	if errors.Is(err, services.ErrAIQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// streamSSE 以 Server-Sent Events 的形式推送 AI 生成的内容，客户端断开连接时取消上游请求
+func streamSSE(ctx *gin.Context, errMsg string, run func(reqCtx context.Context, onEvent func(event string, data map[string]interface{})) error) {
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	err := run(aiContext(ctx), func(event string, data map[string]interface{}) {
+		ctx.SSEvent(event, data)
+		ctx.Writer.Flush()
+	})
+	if err != nil && ctx.Request.Context().Err() == nil {
+		ctx.SSEvent("error", utils.Error(fmt.Sprintf("%s: %v", errMsg, err)))
+		ctx.Writer.Flush()
+	}
+}
+
+func (c *AIController) GenerateHintStream(ctx *gin.Context) {
+	var req GenerateCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	streamSSE(ctx, "生成代码失败", func(reqCtx context.Context, onEvent func(event string, data map[string]interface{})) error {
+		return c.Service.GenerateHintStream(reqCtx, req.ProblemID, req.Title, req.Content, req.SampleTestcases, req.ModelType, onEvent)
+	})
+}
+
+func (c *AIController) AnalyzeCodeStream(ctx *gin.Context) {
+	var req AnalyzeCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	if req.RecordID == 0 && req.TypedCode == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("请指定作答记录或代码"))
+		return
+	}
+
+	streamSSE(ctx, "生成代码失败", func(reqCtx context.Context, onEvent func(event string, data map[string]interface{})) error {
+		return c.Service.AnalyzeCodeStream(reqCtx, req.RecordID, req.ProblemID, req.Language, req.TypedCode, onEvent)
+	})
+}
+
+func (c *AIController) ChatStream(ctx *gin.Context) {
+	var req ChatRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	streamSSE(ctx, "问答异常", func(reqCtx context.Context, onEvent func(event string, data map[string]interface{})) error {
+		return c.Service.ChatStream(reqCtx, req.ProblemID, req.TypedCode, req.Question, req.ModelType, onEvent)
+	})
+}
+
+// GetCacheStats 获取 AI 响应缓存的命中统计
+func (c *AIController) GetCacheStats(ctx *gin.Context) {
+	stats, err := c.Service.CacheStats()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取缓存统计失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(stats))
+}
+
+// PurgeCache 清除 AI 响应缓存，可按功能和题目过滤
+func (c *AIController) PurgeCache(ctx *gin.Context) {
+	var problemID uint64
+	if problemIDStr := ctx.Query("problem_id"); problemIDStr != "" {
+		var err error
+		problemID, err = strconv.ParseUint(problemIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 problem_id 参数"))
+			return
+		}
+	}
+
+	deleted, err := c.Service.PurgeCache(ctx.Query("feature"), uint(problemID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("清除缓存失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(gin.H{
+		"deleted": deleted,
+	}))
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"ai_teach_system/services"
+	"ai_teach_system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AIRatingController struct {
+	ratingService *services.AIRatingService
+}
+
+func NewAIRatingController(service *services.AIRatingService) *AIRatingController {
+	return &AIRatingController{
+		ratingService: service,
+	}
+}
+
+// RateAIOutputRequest 评价分级提示时只需要 hint_record_id，评价多轮对话时只需要 chat_message_id，
+// 其余功能需要提供作答记录和 AI 接口返回的模型
+type RateAIOutputRequest struct {
+	Feature       string `json:"feature"`
+	Model         string `json:"model"`
+	RecordID      uint   `json:"record_id"`
+	HintRecordID  uint   `json:"hint_record_id"`
+	ChatMessageID uint   `json:"chat_message_id"`
+	Helpful       *bool  `json:"helpful" binding:"required"`
+	Reason        string `json:"reason"`
+}
+
+// RateAIOutput 学生评价一次 AI 输出是否有帮助
+func (c *AIRatingController) RateAIOutput(ctx *gin.Context) {
+	var req RateAIOutputRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	rating, err := c.ratingService.Rate(ctx.GetUint("userID"), services.RatingInput{
+		Feature:       req.Feature,
+		Provider:      req.Model,
+		RecordID:      req.RecordID,
+		HintRecordID:  req.HintRecordID,
+		ChatMessageID: req.ChatMessageID,
+		Helpful:       *req.Helpful,
+		Reason:        req.Reason,
+	})
+	if errors.Is(err, services.ErrInvalidRating) {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("提交评价失败: %v", err)))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("提交评价失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(rating))
+}
+
+// ratingFilter 解析课程评价列表和报表共用的查询参数
+func ratingFilter(ctx *gin.Context) (services.RatingFilter, bool) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return services.RatingFilter{}, false
+	}
+
+	filter := services.RatingFilter{
+		CourseID: uint(courseID),
+		Feature:  ctx.Query("feature"),
+		Provider: ctx.Query("model"),
+	}
+	filter.Page, _ = strconv.Atoi(ctx.Query("page"))
+	filter.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	if helpful := ctx.Query("helpful"); helpful != "" {
+		value, err := strconv.ParseBool(helpful)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 helpful 参数"))
+			return filter, false
+		}
+		filter.Helpful = &value
+	}
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的开始日期"))
+			return filter, false
+		}
+		filter.From = &t
+	}
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的结束日期"))
+			return filter, false
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+	return filter, true
+}
+
+// GetRatingList 分页查看课程内学生的评价及理由
+func (c *AIRatingController) GetRatingList(ctx *gin.Context) {
+	filter, ok := ratingFilter(ctx)
+	if !ok {
+		return
+	}
+
+	result, err := c.ratingService.ListRatings(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取评价列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+// GetRatingReport 按功能、模型和提示词版本对比课程内的评价
+func (c *AIRatingController) GetRatingReport(ctx *gin.Context) {
+	filter, ok := ratingFilter(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := c.ratingService.Report(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取评价报表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(report))
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AIUsageController struct {
+	usageService *services.AIUsageService
+}
+
+func NewAIUsageController(service *services.AIUsageService) *AIUsageController {
+	return &AIUsageController{
+		usageService: service,
+	}
+}
+
+type AIQuotaRequest struct {
+	Role        models.Role `json:"role"`         // 为空时对所有角色生效
+	ClassID     uint        `json:"class_id"`     // 为 0 时对所有班级生效
+	CourseID    uint        `json:"course_id"`    // 为 0 时限制当天的总用量
+	DailyTokens int64       `json:"daily_tokens"` // 0 表示不限制
+}
+
+// GetMyUsage 获取当前用户今日的 AI 用量和额度
+func (c *AIUsageController) GetMyUsage(ctx *gin.Context) {
+	usage, err := c.usageService.GetUserUsage(ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取AI用量失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(usage))
+}
+
+// GetUsageReport 按班级和功能统计 AI 用量和估算费用
+func (c *AIUsageController) GetUsageReport(ctx *gin.Context) {
+	var filter services.UsageFilter
+	if courseIDStr := ctx.Query("course_id"); courseIDStr != "" {
+		courseID, err := strconv.ParseUint(courseIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+			return
+		}
+		filter.CourseID = uint(courseID)
+	}
+	if classIDStr := ctx.Query("class_id"); classIDStr != "" {
+		classID, err := strconv.ParseUint(classIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的班级ID"))
+			return
+		}
+		filter.ClassID = uint(classID)
+	}
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的开始日期"))
+			return
+		}
+		filter.From = &t
+	}
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的结束日期"))
+			return
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+
+	report, err := c.usageService.Report(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取AI用量报表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(report))
+}
+
+// aiLogFilter 从请求参数中读取调用日志的过滤条件，参数无效时直接返回 400
+func aiLogFilter(ctx *gin.Context) (services.AILogFilter, bool) {
+	filter := services.AILogFilter{
+		Feature: ctx.Query("feature"),
+	}
+	filter.Page, _ = strconv.Atoi(ctx.Query("page"))
+	filter.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的用户ID"))
+			return filter, false
+		}
+		filter.UserID = uint(userID)
+	}
+	if courseIDStr := ctx.Query("course_id"); courseIDStr != "" {
+		courseID, err := strconv.ParseUint(courseIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+			return filter, false
+		}
+		filter.CourseID = uint(courseID)
+	}
+	if problemIDStr := ctx.Query("problem_id"); problemIDStr != "" {
+		problemID, err := strconv.ParseUint(problemIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目ID"))
+			return filter, false
+		}
+		filter.ProblemID = uint(problemID)
+	}
+	if recordIDStr := ctx.Query("record_id"); recordIDStr != "" {
+		recordID, err := strconv.ParseUint(recordIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的作答记录ID"))
+			return filter, false
+		}
+		filter.RecordID = uint(recordID)
+	}
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的开始日期"))
+			return filter, false
+		}
+		filter.From = &t
+	}
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的结束日期"))
+			return filter, false
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+	return filter, true
+}
+
+// GetAILogList 按学生、课程、题目、作答记录、功能和日期范围查询 AI 调用日志
+func (c *AIUsageController) GetAILogList(ctx *gin.Context) {
+	filter, ok := aiLogFilter(ctx)
+	if !ok {
+		return
+	}
+
+	result, err := c.usageService.ListAILogs(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取AI调用日志失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+// GetAILogDetail 获取一次 AI 调用发送给模型的完整消息和模型的原始输出
+func (c *AIUsageController) GetAILogDetail(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的日志ID"))
+		return
+	}
+
+	aiLog, err := c.usageService.GetAILog(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(aiLog))
+}
+
+func (c *AIUsageController) GetQuotaList(ctx *gin.Context) {
+	quotas, err := c.usageService.ListQuotas()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取AI额度失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(quotas))
+}
+
+func (c *AIUsageController) CreateQuota(ctx *gin.Context) {
+	var req AIQuotaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	quota := models.AIQuota{
+		Role:        req.Role,
+		ClassID:     req.ClassID,
+		CourseID:    req.CourseID,
+		DailyTokens: req.DailyTokens,
+	}
+	if err := c.usageService.CreateQuota(&quota); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("创建AI额度失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(quota))
+}
+
+func (c *AIUsageController) UpdateQuota(ctx *gin.Context) {
+	quotaID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的额度ID"))
+		return
+	}
+
+	var req AIQuotaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	quota, err := c.usageService.UpdateQuota(uint(quotaID), &models.AIQuota{
+		Role:        req.Role,
+		ClassID:     req.ClassID,
+		CourseID:    req.CourseID,
+		DailyTokens: req.DailyTokens,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("更新AI额度失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(quota))
+}
+
+func (c *AIUsageController) DeleteQuota(ctx *gin.Context) {
+	quotaID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的额度ID"))
+		return
+	}
+
+	if err := c.usageService.DeleteQuota(uint(quotaID)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("删除AI额度失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StartChatSessionRequest struct {
+	ProblemID uint   `json:"problem_id" binding:"required"`
+	Question  string `json:"question" binding:"required"`
+	TypedCode string `json:"typed_code"`
+	ModelType string `json:"model_type"` // 为空时使用功能配置的模型
+}
+
+type ContinueChatSessionRequest struct {
+	Question  string `json:"question" binding:"required"`
+	TypedCode string `json:"typed_code"`
+}
+
+func (c *AIController) StartChatSession(ctx *gin.Context) {
+	var req StartChatSessionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	session, err := c.Service.StartChatSession(aiContext(ctx), ctx.GetUint("userID"), req.ProblemID, req.Question, req.TypedCode, req.ModelType)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("问答异常: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(session))
+}
+
+func (c *AIController) ContinueChatSession(ctx *gin.Context) {
+	sessionID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的会话ID"))
+		return
+	}
+
+	var req ContinueChatSessionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	messages, err := c.Service.ContinueChatSession(aiContext(ctx), ctx.GetUint("userID"), uint(sessionID), req.Question, req.TypedCode)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("问答异常: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(gin.H{
+		"messages": messages,
+	}))
+}
+
+// GetChatSessionList 获取会话列表，教师可以通过 user_id 查看学生的会话
+func (c *AIController) GetChatSessionList(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+		if role, _ := ctx.Get("role"); role != models.RoleAdmin {
+			ctx.JSON(http.StatusForbidden, utils.Error("没有权限访问"))
+			return
+		}
+		id, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 user_id 参数"))
+			return
+		}
+		userID = uint(id)
+	}
+
+	var problemID uint64
+	if problemIDStr := ctx.Query("problem_id"); problemIDStr != "" {
+		var err error
+		problemID, err = strconv.ParseUint(problemIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 problem_id 参数"))
+			return
+		}
+	}
+
+	sessions, err := c.Service.ListChatSessions(userID, uint(problemID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取会话列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(sessions))
+}
+
+// GetChatSessionDetail 获取会话的全部消息，教师可以查看任意学生的会话
+func (c *AIController) GetChatSessionDetail(ctx *gin.Context) {
+	sessionID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的会话ID"))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+	}
+
+	session, err := c.Service.GetChatSession(userID, uint(sessionID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(fmt.Sprintf("获取会话详情失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(session))
+}
+
+func (c *AIController) DeleteChatSession(ctx *gin.Context) {
+	sessionID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的会话ID"))
+		return
+	}
+
+	if err := c.Service.DeleteChatSession(ctx.GetUint("userID"), uint(sessionID)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("删除会话失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -143,3 +144,203 @@ func (c *CourseController) GetCourseClassStats(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, utils.Success(stats))
 }
+
+type UpdateCourseAIConfigRequest struct {
+	HintDiscountEnabled *bool   `json:"hint_discount_enabled"`
+	HintDiscounts       *string `json:"hint_discounts"`
+	CodePolicy          *string `json:"code_policy"` // allow_code / pseudo_code / no_code
+	AutoAnalyzeEnabled  *bool   `json:"auto_analyze_enabled"`
+}
+
+func (c *CourseController) GetAIConfig(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	cfg, err := c.courseService.GetAIConfig(uint(courseID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取课程AI配置失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(cfg))
+}
+
+// UpdateAIConfig 更新课程的 AI 配置，只修改请求中提供的字段
+func (c *CourseController) UpdateAIConfig(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	var req UpdateCourseAIConfigRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.HintDiscountEnabled != nil {
+		updates["hint_discount_enabled"] = *req.HintDiscountEnabled
+	}
+	if req.HintDiscounts != nil {
+		updates["hint_discounts"] = *req.HintDiscounts
+	}
+	if req.CodePolicy != nil {
+		updates["code_policy"] = *req.CodePolicy
+	}
+	if req.AutoAnalyzeEnabled != nil {
+		updates["auto_analyze_enabled"] = *req.AutoAnalyzeEnabled
+	}
+	if len(updates) == 0 {
+		ctx.JSON(http.StatusBadRequest, utils.Error("没有需要更新的配置"))
+		return
+	}
+
+	cfg, err := c.courseService.UpdateAIConfig(uint(courseID), updates)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("更新课程AI配置失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(cfg))
+}
+
+// GetAIViolations 查看课程内 AI 助教回答违反代码策略的记录
+func (c *CourseController) GetAIViolations(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	pageSize, _ := strconv.Atoi(ctx.Query("page_size"))
+
+	result, err := c.courseService.ListAIViolations(uint(courseID), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取违规记录失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+// codeErrorFilter 从请求参数中读取错误列表和统计的过滤条件，参数无效时直接返回 400
+func codeErrorFilter(ctx *gin.Context) (services.CodeErrorFilter, bool) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return services.CodeErrorFilter{}, false
+	}
+
+	filter := services.CodeErrorFilter{
+		CourseID: uint(courseID),
+		Category: ctx.Query("category"),
+	}
+	filter.Page, _ = strconv.Atoi(ctx.Query("page"))
+	filter.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	if knowledgePointIDStr := ctx.Query("knowledge_point_id"); knowledgePointIDStr != "" {
+		knowledgePointID, err := strconv.ParseUint(knowledgePointIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的知识点ID"))
+			return filter, false
+		}
+		filter.KnowledgePointID = uint(knowledgePointID)
+	}
+	if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的用户ID"))
+			return filter, false
+		}
+		filter.UserID = uint(userID)
+	}
+	if problemIDStr := ctx.Query("problem_id"); problemIDStr != "" {
+		problemID, err := strconv.ParseUint(problemIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目ID"))
+			return filter, false
+		}
+		filter.ProblemID = uint(problemID)
+	}
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的开始日期"))
+			return filter, false
+		}
+		filter.From = &t
+	}
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的结束日期"))
+			return filter, false
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+	return filter, true
+}
+
+// GetCodeErrors 分页查看课程内 AI 代码分析归类出的错误
+func (c *CourseController) GetCodeErrors(ctx *gin.Context) {
+	filter, ok := codeErrorFilter(ctx)
+	if !ok {
+		return
+	}
+
+	result, err := c.courseService.ListCodeErrors(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取错误列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+// GetCodeErrorStats 按错误类型和知识点统计课程内的错误
+func (c *CourseController) GetCodeErrorStats(ctx *gin.Context) {
+	filter, ok := codeErrorFilter(ctx)
+	if !ok {
+		return
+	}
+
+	stats, err := c.courseService.GetCodeErrorStats(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取错误统计失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(stats))
+}
+
+// GetMisconceptionStats 查看课程最近一次误区聚类的结果，可按知识点过滤
+func (c *CourseController) GetMisconceptionStats(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	var knowledgePointID uint64
+	if knowledgePointIDStr := ctx.Query("knowledge_point_id"); knowledgePointIDStr != "" {
+		knowledgePointID, err = strconv.ParseUint(knowledgePointIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的知识点ID"))
+			return
+		}
+	}
+
+	result, err := c.courseService.GetMisconceptionClusters(uint(courseID), uint(knowledgePointID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取误区聚类失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
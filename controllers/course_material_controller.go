@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"ai_teach_system/services"
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CourseMaterialController struct {
+	materialService *services.CourseMaterialService
+}
+
+func NewCourseMaterialController(service *services.CourseMaterialService) *CourseMaterialController {
+	return &CourseMaterialController{
+		materialService: service,
+	}
+}
+
+// UploadMaterialRequest 资料内容可以直接填写，也可以以 multipart 表单的 file 字段上传 txt 或 md 文件
+type UploadMaterialRequest struct {
+	Title            string `json:"title" form:"title"`
+	KnowledgePointID uint   `json:"knowledge_point_id" form:"knowledge_point_id"` // 为 0 时资料用于整门课程
+	Content          string `json:"content" form:"content"`
+}
+
+// materialParams 解析路径中的课程 ID 和资料 ID
+func materialParams(ctx *gin.Context) (uint, uint, bool) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return 0, 0, false
+	}
+	materialID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的资料ID"))
+		return 0, 0, false
+	}
+	return uint(courseID), uint(materialID), true
+}
+
+// GetMaterialList 获取课程资料列表，可按 knowledge_point_id 过滤
+func (c *CourseMaterialController) GetMaterialList(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+	var knowledgePointID uint64
+	if knowledgePointIDStr := ctx.Query("knowledge_point_id"); knowledgePointIDStr != "" {
+		knowledgePointID, err = strconv.ParseUint(knowledgePointIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 knowledge_point_id 参数"))
+			return
+		}
+	}
+
+	materials, err := c.materialService.ListMaterials(uint(courseID), uint(knowledgePointID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(materials))
+}
+
+// UploadMaterial 上传课程资料，切分并建立检索索引后 AI 助教和代码分析即可引用
+func (c *CourseMaterialController) UploadMaterial(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	var req UploadMaterialRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	var fileName string
+	if ctx.ContentType() == gin.MIMEMultipartPOSTForm {
+		if file, err := ctx.FormFile("file"); err == nil {
+			content, err := services.ReadTextFile(file)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+				return
+			}
+			req.Content = content
+			fileName = file.Filename
+		} else if err != http.ErrMissingFile {
+			ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("读取上传文件失败: %v", err)))
+			return
+		}
+	}
+
+	material, err := c.materialService.UploadMaterial(ctx.Request.Context(), ctx.GetUint("userID"), uint(courseID), req.KnowledgePointID, req.Title, fileName, req.Content)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("上传课程资料失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(material))
+}
+
+func (c *CourseMaterialController) GetMaterial(ctx *gin.Context) {
+	courseID, materialID, ok := materialParams(ctx)
+	if !ok {
+		return
+	}
+
+	material, err := c.materialService.GetMaterial(courseID, materialID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(material))
+}
+
+func (c *CourseMaterialController) DeleteMaterial(ctx *gin.Context) {
+	courseID, materialID, ok := materialParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.materialService.DeleteMaterial(courseID, materialID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("删除课程资料失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
+
+// ReindexMaterial 重新切分资料并生成向量，更换向量模型后需要对已有资料执行
+func (c *CourseMaterialController) ReindexMaterial(ctx *gin.Context) {
+	courseID, materialID, ok := materialParams(ctx)
+	if !ok {
+		return
+	}
+
+	material, err := c.materialService.ReindexMaterial(ctx.Request.Context(), courseID, materialID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("重建资料索引失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(material))
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RequestHintRequest struct {
+	ProblemID        uint   `json:"problem_id" binding:"required"`
+	KnowledgePointID uint   `json:"knowledge_point_id" binding:"required"`
+	Level            int    `json:"level"`      // 为空时解锁下一级提示
+	ModelType        string `json:"model_type"` // 为空时使用功能配置的模型
+}
+
+// GetHints 获取学生在题目上已解锁的提示
+func (c *AIController) GetHints(ctx *gin.Context) {
+	problemID, err := strconv.ParseUint(ctx.Query("problem_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的 problem_id 参数"))
+		return
+	}
+	knowledgePointID, err := strconv.ParseUint(ctx.Query("knowledge_point_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的 knowledge_point_id 参数"))
+		return
+	}
+
+	hints, err := c.Service.GetHints(ctx.GetUint("userID"), uint(problemID), uint(knowledgePointID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取提示失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(hints))
+}
+
+// RequestHint 按顺序解锁提示：方向提示、关键思路、详细步骤、伪代码
+func (c *AIController) RequestHint(ctx *gin.Context) {
+	var req RequestHintRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	hint, err := c.Service.RequestHint(aiContext(ctx), ctx.GetUint("userID"), req.ProblemID, req.KnowledgePointID, req.Level, req.ModelType)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("获取提示失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(hint))
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenerateLearningReportRequest struct {
+	UserID uint `json:"user_id"` // 教师为学生生成报告时指定，学生只能生成自己的报告
+}
+
+// GenerateLearningReport 根据学生在课程中的做题情况生成学习报告
+func (c *AIController) GenerateLearningReport(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	var req GenerateLearningReportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if req.UserID != 0 && req.UserID != userID {
+		if role, _ := ctx.Get("role"); role != models.RoleAdmin {
+			ctx.JSON(http.StatusForbidden, utils.Error("没有权限访问"))
+			return
+		}
+		userID = req.UserID
+	}
+
+	report, err := c.Service.GenerateLearningReport(aiContext(ctx), userID, uint(courseID), models.LearningReportTriggerManual)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成学习报告失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(report))
+}
+
+// GetLearningReportList 获取课程中的历史学习报告，教师可以通过 user_id 查看学生的报告，不指定时返回全部学生的报告
+func (c *AIController) GetLearningReportList(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+		if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+			id, err := strconv.ParseUint(userIDStr, 10, 32)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, utils.Error("无效的 user_id 参数"))
+				return
+			}
+			userID = uint(id)
+		}
+	}
+
+	reports, err := c.Service.ListLearningReports(userID, uint(courseID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取学习报告列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(reports))
+}
+
+// GetLearningReportDetail 获取学习报告详情，教师可以查看任意学生的报告
+func (c *AIController) GetLearningReportDetail(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("course_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+		return
+	}
+	reportID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的报告ID"))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if role, _ := ctx.Get("role"); role == models.RoleAdmin {
+		userID = 0
+	}
+
+	report, err := c.Service.GetLearningReport(userID, uint(courseID), uint(reportID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(fmt.Sprintf("获取学习报告详情失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(report))
+}
@@ -86,3 +86,29 @@ func (c *LeetCodeController) Check(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, utils.Success(result))
 }
+
+// CheckStream 以 Server-Sent Events 的形式推送判题状态变化，客户端无需反复轮询 /check/
+func (c *LeetCodeController) CheckStream(ctx *gin.Context) {
+	runCodeID := ctx.Param("run_code_id")
+	if runCodeID == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的提交ID"))
+		return
+	}
+	test := ctx.Query("test") == "true"
+	userID := ctx.GetUint("userID")
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	// 客户端断开连接时 Request.Context 会被取消，轮询随之结束
+	err := c.service.CheckStream(ctx.Request.Context(), userID, runCodeID, test, func(event string, data map[string]interface{}) {
+		ctx.SSEvent(event, data)
+		ctx.Writer.Flush()
+	})
+	if err != nil {
+		ctx.SSEvent("error", utils.Error(fmt.Sprintf("检查代码运行结果失败: %v", err)))
+		ctx.Writer.Flush()
+	}
+}
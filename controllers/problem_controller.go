@@ -46,6 +46,35 @@ type CreateCustomProblemRequest struct {
 	MemoryLimit     int                      `json:"memory_limit" binding:"required"`
 }
 
+// UpdateProblemRequest 未传的字段不修改，传空字符串可以清空对应内容；tag_ids 传空数组时清空标签
+type UpdateProblemRequest struct {
+	Title           *string                   `json:"title"`
+	TitleCn         *string                   `json:"title_cn"`
+	Content         *string                   `json:"content"`
+	ContentCn       *string                   `json:"content_cn"`
+	Difficulty      *models.ProblemDifficulty `json:"difficulty"`
+	SampleTestcases *string                   `json:"sample_testcases"`
+	TestCases       *string                   `json:"test_cases"`
+	TagIDs          []uint                    `json:"tag_ids"`
+	TimeLimit       *int                      `json:"time_limit"`
+	MemoryLimit     *int                      `json:"memory_limit"`
+}
+
+type UpdateProblemDraftRequest struct {
+	Title             string                   `json:"title"`
+	TitleCn           string                   `json:"title_cn"`
+	Content           string                   `json:"content"`
+	ContentCn         string                   `json:"content_cn"`
+	Difficulty        models.ProblemDifficulty `json:"difficulty"`
+	SampleTestcases   string                   `json:"sample_testcases"`
+	TestCases         string                   `json:"test_cases"`
+	ReferenceSolution string                   `json:"reference_solution"`
+	ReferenceLanguage string                   `json:"reference_language"`
+	TagIDs            []uint                   `json:"tag_ids"`
+	TimeLimit         int                      `json:"time_limit"`
+	MemoryLimit       int                      `json:"memory_limit"`
+}
+
 type SetKnowledgePointProblemsRequest struct {
 	ProblemIDs []uint `json:"problem_ids" binding:"required"`
 }
@@ -73,6 +102,27 @@ func (c *ProblemController) GetCourseProblemList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, utils.Success(response))
 }
 
+// SearchProblems 按自然语言描述检索题目，如 "two pointers on sorted array"
+func (c *ProblemController) SearchProblems(ctx *gin.Context) {
+	difficulty := models.ProblemDifficulty(ctx.Query("difficulty"))
+	if difficulty != "" &&
+		difficulty != models.ProblemDifficultyEasy &&
+		difficulty != models.ProblemDifficultyMedium &&
+		difficulty != models.ProblemDifficultyHard {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的难度值"))
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+
+	problems, err := c.service.SearchProblems(ctx.Request.Context(), ctx.Query("q"), difficulty, limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("检索题目失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(problems))
+}
+
 func (c *ProblemController) GetProblemDetail(ctx *gin.Context) {
 	problemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
@@ -213,3 +263,273 @@ func (c *ProblemController) CreateCustomProblem(ctx *gin.Context) {
 		"difficulty": createdProblem.Difficulty,
 	}))
 }
+
+func (c *ProblemController) UpdateProblem(ctx *gin.Context) {
+	problemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目id"))
+		return
+	}
+
+	var req UpdateProblemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	if req.Title != nil && *req.Title == "" {
+		ctx.JSON(http.StatusBadRequest, utils.Error("题目标题不能为空"))
+		return
+	}
+	if req.Difficulty != nil &&
+		*req.Difficulty != models.ProblemDifficultyEasy &&
+		*req.Difficulty != models.ProblemDifficultyMedium &&
+		*req.Difficulty != models.ProblemDifficultyHard {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的难度值"))
+		return
+	}
+	if (req.TimeLimit != nil && *req.TimeLimit <= 0) || (req.MemoryLimit != nil && *req.MemoryLimit <= 0) {
+		ctx.JSON(http.StatusBadRequest, utils.Error("时间和内存限制必须大于0"))
+		return
+	}
+
+	// 构建更新字段，只包含请求中出现的字段
+	updates := make(map[string]interface{})
+	if req.Title != nil {
+		updates["title"] = *req.Title
+	}
+	if req.TitleCn != nil {
+		updates["title_cn"] = *req.TitleCn
+	}
+	if req.Content != nil {
+		updates["content"] = *req.Content
+	}
+	if req.ContentCn != nil {
+		updates["content_cn"] = *req.ContentCn
+	}
+	if req.Difficulty != nil {
+		updates["difficulty"] = *req.Difficulty
+	}
+	if req.SampleTestcases != nil {
+		updates["sample_testcases"] = *req.SampleTestcases
+	}
+	if req.TestCases != nil {
+		updates["test_cases"] = *req.TestCases
+	}
+	if req.TimeLimit != nil {
+		updates["time_limit"] = *req.TimeLimit
+	}
+	if req.MemoryLimit != nil {
+		updates["memory_limit"] = *req.MemoryLimit
+	}
+
+	userID := ctx.GetUint("userID")
+	problem, err := c.service.UpdateProblem(uint(problemID), userID, updates, req.TagIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("更新题目失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(problem))
+}
+
+func (c *ProblemController) GetProblemRevisions(ctx *gin.Context) {
+	problemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目id"))
+		return
+	}
+
+	revisions, err := c.service.GetProblemRevisions(uint(problemID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取题目修订记录失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(revisions))
+}
+
+func (c *ProblemController) GetProblemRevision(ctx *gin.Context) {
+	problemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目id"))
+		return
+	}
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的版本号"))
+		return
+	}
+
+	revision, err := c.service.GetProblemRevision(uint(problemID), version)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取题目版本失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(revision))
+}
+
+func (c *ProblemController) DiffProblemRevisions(ctx *gin.Context) {
+	problemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的题目id"))
+		return
+	}
+
+	var from, to int
+	if v := ctx.Query("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的版本号"))
+			return
+		}
+	}
+	if v := ctx.Query("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的版本号"))
+			return
+		}
+	}
+
+	diff, err := c.service.DiffProblemRevisions(uint(problemID), from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("比较题目版本失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(diff))
+}
+
+func (c *ProblemController) GetProblemDraftList(ctx *gin.Context) {
+	var knowledgePointID uint64
+	if knowledgePointIDStr := ctx.Query("knowledge_point_id"); knowledgePointIDStr != "" {
+		var err error
+		knowledgePointID, err = strconv.ParseUint(knowledgePointIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的知识点ID"))
+			return
+		}
+	}
+
+	drafts, err := c.service.ListProblemDrafts(uint(knowledgePointID), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取题目草稿失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(drafts))
+}
+
+func (c *ProblemController) GetProblemDraft(ctx *gin.Context) {
+	draftID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的草稿ID"))
+		return
+	}
+
+	draft, err := c.service.GetProblemDraft(uint(draftID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(draft))
+}
+
+func (c *ProblemController) UpdateProblemDraft(ctx *gin.Context) {
+	draftID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的草稿ID"))
+		return
+	}
+
+	var req UpdateProblemDraftRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	if req.Difficulty != "" &&
+		req.Difficulty != models.ProblemDifficultyEasy &&
+		req.Difficulty != models.ProblemDifficultyMedium &&
+		req.Difficulty != models.ProblemDifficultyHard {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的难度值"))
+		return
+	}
+
+	// 构建更新字段
+	updates := make(map[string]interface{})
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.TitleCn != "" {
+		updates["title_cn"] = req.TitleCn
+	}
+	if req.Content != "" {
+		updates["content"] = req.Content
+	}
+	if req.ContentCn != "" {
+		updates["content_cn"] = req.ContentCn
+	}
+	if req.Difficulty != "" {
+		updates["difficulty"] = req.Difficulty
+	}
+	if req.SampleTestcases != "" {
+		updates["sample_testcases"] = req.SampleTestcases
+	}
+	if req.TestCases != "" {
+		updates["test_cases"] = req.TestCases
+	}
+	if req.ReferenceSolution != "" {
+		updates["reference_solution"] = req.ReferenceSolution
+	}
+	if req.ReferenceLanguage != "" {
+		updates["reference_language"] = req.ReferenceLanguage
+	}
+	if req.TimeLimit != 0 {
+		updates["time_limit"] = req.TimeLimit
+	}
+	if req.MemoryLimit != 0 {
+		updates["memory_limit"] = req.MemoryLimit
+	}
+
+	draft, err := c.service.UpdateProblemDraft(uint(draftID), updates, req.TagIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("更新题目草稿失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(draft))
+}
+
+func (c *ProblemController) DeleteProblemDraft(ctx *gin.Context) {
+	draftID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的草稿ID"))
+		return
+	}
+
+	if err := c.service.DeleteProblemDraft(uint(draftID)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("删除题目草稿失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
+
+// PublishProblemDraft 将草稿发布为自定义题目并关联到草稿所属的知识点
+func (c *ProblemController) PublishProblemDraft(ctx *gin.Context) {
+	draftID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的草稿ID"))
+		return
+	}
+
+	problem, err := c.service.PublishProblemDraft(uint(draftID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("发布题目草稿失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(problem))
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"ai_teach_system/services"
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromptController struct {
+	promptService *services.PromptService
+}
+
+func NewPromptController(service *services.PromptService) *PromptController {
+	return &PromptController{
+		promptService: service,
+	}
+}
+
+type CreatePromptTemplateRequest struct {
+	Key          string `json:"key" binding:"required"`
+	CourseID     uint   `json:"course_id"` // 为 0 时创建全局模板
+	SystemPrompt string `json:"system_prompt"`
+	Content      string `json:"content" binding:"required"`
+	Comment      string `json:"comment"`
+}
+
+type UpdatePromptTemplateRequest struct {
+	SystemPrompt string `json:"system_prompt"`
+	Content      string `json:"content" binding:"required"`
+	Comment      string `json:"comment"`
+}
+
+type RollbackPromptTemplateRequest struct {
+	Version int `json:"version" binding:"required"`
+}
+
+type PreviewPromptRequest struct {
+	Key          string `json:"key" binding:"required"`
+	CourseID     uint   `json:"course_id"`
+	ProblemID    uint   `json:"problem_id"`
+	SystemPrompt string `json:"system_prompt"`
+	Content      string `json:"content"` // 为空时预览当前生效的模板
+	Language     string `json:"language"`
+	Code         string `json:"code"`
+	Question     string `json:"question"`
+}
+
+func (c *PromptController) GetTemplateList(ctx *gin.Context) {
+	var courseID uint64
+	if courseIDStr := ctx.Query("course_id"); courseIDStr != "" {
+		var err error
+		courseID, err = strconv.ParseUint(courseIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的课程ID"))
+			return
+		}
+	}
+
+	result, err := c.promptService.ListTemplates(uint(courseID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取模板列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+func (c *PromptController) GetTemplateDetail(ctx *gin.Context) {
+	templateID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的模板ID"))
+		return
+	}
+
+	tmpl, err := c.promptService.GetTemplate(uint(templateID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(fmt.Sprintf("获取模板详情失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(tmpl))
+}
+
+func (c *PromptController) CreateTemplate(ctx *gin.Context) {
+	var req CreatePromptTemplateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	tmpl, err := c.promptService.CreateTemplate(ctx.GetUint("userID"), req.Key, req.CourseID, req.SystemPrompt, req.Content, req.Comment)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("创建模板失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(tmpl))
+}
+
+func (c *PromptController) UpdateTemplate(ctx *gin.Context) {
+	templateID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的模板ID"))
+		return
+	}
+
+	var req UpdatePromptTemplateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	tmpl, err := c.promptService.UpdateTemplate(ctx.GetUint("userID"), uint(templateID), req.SystemPrompt, req.Content, req.Comment)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("修改模板失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(tmpl))
+}
+
+func (c *PromptController) RollbackTemplate(ctx *gin.Context) {
+	templateID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的模板ID"))
+		return
+	}
+
+	var req RollbackPromptTemplateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	tmpl, err := c.promptService.RollbackTemplate(ctx.GetUint("userID"), uint(templateID), req.Version)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("回滚模板失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(tmpl))
+}
+
+func (c *PromptController) DeleteTemplate(ctx *gin.Context) {
+	templateID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的模板ID"))
+		return
+	}
+
+	if err := c.promptService.DeleteTemplate(uint(templateID)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("删除模板失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
+
+// PreviewTemplate 使用示例题目渲染模板，可以在保存前预览修改后的内容
+func (c *PromptController) PreviewTemplate(ctx *gin.Context) {
+	var req PreviewPromptRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	result, err := c.promptService.Preview(services.PreviewRequest{
+		Key:          req.Key,
+		CourseID:     req.CourseID,
+		ProblemID:    req.ProblemID,
+		SystemPrompt: req.SystemPrompt,
+		Content:      req.Content,
+		Language:     req.Language,
+		Code:         req.Code,
+		Question:     req.Question,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("预览模板失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
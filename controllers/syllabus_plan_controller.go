@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DraftSyllabusPlanRequest 课程大纲可以直接填写，也可以以 multipart 表单的 file 字段上传 txt 或 md 文件
+type DraftSyllabusPlanRequest struct {
+	CourseID   uint   `json:"course_id" form:"course_id"`     // 应用到已有课程时指定
+	CourseName string `json:"course_name" form:"course_name"` // 未指定课程时应用计划会新建该课程
+	Syllabus   string `json:"syllabus" form:"syllabus"`
+}
+
+type UpdateSyllabusPlanRequest struct {
+	CourseName string                     `json:"course_name"`
+	Points     []models.SyllabusPlanPoint `json:"points"` // 为空时不修改知识点
+}
+
+// DraftSyllabusPlan 根据课程大纲生成知识点计划，教师审阅修改后再应用到课程
+func (c *AIController) DraftSyllabusPlan(ctx *gin.Context) {
+	var req DraftSyllabusPlanRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	if ctx.ContentType() == gin.MIMEMultipartPOSTForm {
+		if file, err := ctx.FormFile("file"); err == nil {
+			syllabus, err := services.ReadTextFile(file)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+				return
+			}
+			req.Syllabus = syllabus
+		} else if err != http.ErrMissingFile {
+			ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("读取上传文件失败: %v", err)))
+			return
+		}
+	}
+
+	plan, err := c.Service.DraftSyllabusPlan(aiContext(ctx), ctx.GetUint("userID"), req.CourseID, req.CourseName, req.Syllabus)
+	if err != nil {
+		ctx.JSON(aiErrorStatus(err), utils.Error(fmt.Sprintf("生成知识点计划失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(plan))
+}
+
+// GetSyllabusPlanList 获取知识点计划列表，可按 course_id 和 status 过滤
+func (c *CourseController) GetSyllabusPlanList(ctx *gin.Context) {
+	var courseID uint64
+	if courseIDStr := ctx.Query("course_id"); courseIDStr != "" {
+		var err error
+		courseID, err = strconv.ParseUint(courseIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的 course_id 参数"))
+			return
+		}
+	}
+
+	plans, err := c.courseService.ListSyllabusPlans(uint(courseID), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(plans))
+}
+
+func (c *CourseController) GetSyllabusPlan(ctx *gin.Context) {
+	planID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的计划ID"))
+		return
+	}
+
+	plan, err := c.courseService.GetSyllabusPlan(uint(planID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, utils.Error(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(plan))
+}
+
+func (c *CourseController) UpdateSyllabusPlan(ctx *gin.Context) {
+	planID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的计划ID"))
+		return
+	}
+
+	var req UpdateSyllabusPlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	plan, err := c.courseService.UpdateSyllabusPlan(uint(planID), req.CourseName, req.Points)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("更新知识点计划失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(plan))
+}
+
+func (c *CourseController) DeleteSyllabusPlan(ctx *gin.Context) {
+	planID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的计划ID"))
+		return
+	}
+
+	if err := c.courseService.DeleteSyllabusPlan(uint(planID)); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("删除知识点计划失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
+
+// ApplySyllabusPlan 按计划创建课程知识点并关联标签
+func (c *CourseController) ApplySyllabusPlan(ctx *gin.Context) {
+	planID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的计划ID"))
+		return
+	}
+
+	result, err := c.courseService.ApplySyllabusPlan(uint(planID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("应用知识点计划失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
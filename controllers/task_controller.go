@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/tasks"
+	"ai_teach_system/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TaskController struct {
+	taskService  *services.TaskService
+	tasksManager *tasks.TasksManager
+}
+
+func NewTaskController(service *services.TaskService, manager *tasks.TasksManager) *TaskController {
+	return &TaskController{
+		taskService:  service,
+		tasksManager: manager,
+	}
+}
+
+type StartSyncRequest struct {
+	Slugs    []string `json:"slugs"`
+	FromID   int      `json:"from_id"`
+	ToID     int      `json:"to_id"`
+	TagsOnly bool     `json:"tags_only"`
+}
+
+type StartClusterMisconceptionsRequest struct {
+	CourseID uint `json:"course_id"` // 为 0 时处理全部课程
+	Days     int  `json:"days"`      // 统计最近多少天的失败作答，为 0 时使用默认配置
+}
+
+func (c *TaskController) GetTaskList(ctx *gin.Context) {
+	filter := services.TaskFilter{
+		TaskType: ctx.Query("task_type"),
+		Status:   models.TaskStatus(ctx.Query("status")),
+	}
+	filter.Page, _ = strconv.Atoi(ctx.Query("page"))
+	filter.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的开始日期"))
+			return
+		}
+		filter.From = &t
+	}
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.Error("无效的结束日期"))
+			return
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+
+	result, err := c.taskService.ListTasks(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取任务列表失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(result))
+}
+
+func (c *TaskController) GetTaskDetail(ctx *gin.Context) {
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的任务ID"))
+		return
+	}
+
+	record, err := c.taskService.GetTask(uint(taskID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, utils.Error("任务不存在"))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("获取任务详情失败: %v", err)))
+		return
+	}
+
+	// 题目总数在抓取过程中逐页累加，任务结束前无法得知，此时进度为 null
+	var progress *float64
+	finished := record.Status != models.TaskStatusPending && record.Status != models.TaskStatusRunning
+	if finished && record.TotalCount > 0 {
+		value := float64(record.SuccessCount) / float64(record.TotalCount) * 100
+		progress = &value
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(gin.H{
+		"task":     record,
+		"progress": progress,
+		"running":  c.tasksManager.IsRunning(record.ID),
+	}))
+}
+
+func (c *TaskController) StartSync(ctx *gin.Context) {
+	var req StartSyncRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	record, err := c.tasksManager.StartSync(tasks.SyncOptions{
+		Slugs:    req.Slugs,
+		FromID:   req.FromID,
+		ToID:     req.ToID,
+		TagsOnly: req.TagsOnly,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("启动同步任务失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(record))
+}
+
+// StartClusterMisconceptions 在后台对课程最近的失败作答进行误区聚类
+func (c *TaskController) StartClusterMisconceptions(ctx *gin.Context) {
+	var req StartClusterMisconceptionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, utils.Error(err.Error()))
+		return
+	}
+
+	record, err := c.tasksManager.StartClusterMisconceptions(tasks.MisconceptionOptions{
+		CourseID: req.CourseID,
+		Days:     req.Days,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("启动误区聚类任务失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(record))
+}
+
+// StartBackfillEmbeddings 在后台为新增和内容变化的题目生成向量
+func (c *TaskController) StartBackfillEmbeddings(ctx *gin.Context) {
+	record, err := c.tasksManager.StartBackfillEmbeddings()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("启动题目向量回填任务失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(record))
+}
+
+func (c *TaskController) CancelTask(ctx *gin.Context) {
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的任务ID"))
+		return
+	}
+
+	err = c.tasksManager.CancelTask(uint(taskID))
+	if errors.Is(err, tasks.ErrTaskNotFound) {
+		ctx.JSON(http.StatusNotFound, utils.Error("任务不存在"))
+		return
+	}
+	if errors.Is(err, tasks.ErrTaskNotRunning) {
+		ctx.JSON(http.StatusConflict, utils.Error(fmt.Sprintf("取消任务失败: %v", err)))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.Error(fmt.Sprintf("取消任务失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(nil))
+}
+
+func (c *TaskController) RetryTask(ctx *gin.Context) {
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error("无效的任务ID"))
+		return
+	}
+
+	record, err := c.tasksManager.StartResume(uint(taskID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.Error(fmt.Sprintf("重试任务失败: %v", err)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.Success(record))
+}
@@ -1,3 +1,4 @@
 #!/bin/sh
-./sync &
+# 题目同步由 main 中的定时任务负责（首次启动或错过执行时间时会自动补跑），
+# 多副本部署时通过数据库租约保证同一时间只有一个实例执行
 ./main
@@ -1,6 +1,7 @@
 module ai_teach_system
 
-go 1.20
+// github.com/openai/openai-go 要求 go >= 1.21，镜像中的 Go 版本需与此保持一致（见 Dockerfile）
+go 1.21
 
 require (
 	github.com/gin-gonic/gin v1.10.0
@@ -32,7 +33,7 @@ require (
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.20.0 // indirect
-	github.com/go-sql-driver/mysql v1.7.0 // indirect
+	github.com/go-sql-driver/mysql v1.7.0
 	github.com/goccy/go-json v0.10.2 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/jinzhu/inflection v1.0.0 // indirect
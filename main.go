@@ -30,7 +30,7 @@ func main() {
 	defer tasksManager.Stop()
 
 	r := gin.Default()
-	routes.SetupRoutes(r, db)
+	routes.SetupRoutes(r, db, tasksManager)
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("服务器启动失败：", err)
 	}
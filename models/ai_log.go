@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 每次调用模型的完整记录，用于学生对 AI 结论有异议时追溯发送给模型的内容和模型的原始输出
+type AILog struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	UsageID          uint      `json:"usage_id" gorm:"index"` // 对应的用量记录
+	UserID           uint      `json:"user_id" gorm:"index:idx_ai_log_user_time"`
+	CourseID         uint      `json:"course_id" gorm:"index"`
+	Feature          string    `json:"feature" gorm:"type:varchar(64);index"`
+	ProblemID        uint      `json:"problem_id" gorm:"index"`
+	RecordID         uint      `json:"record_id" gorm:"index"`
+	Provider         string    `json:"provider" gorm:"type:varchar(64)"`
+	ModelName        string    `json:"model_name" gorm:"type:varchar(128)"`
+	Messages         string    `json:"messages,omitempty" gorm:"type:longtext"` // 发送给模型的完整消息列表（JSON）
+	Response         string    `json:"response,omitempty" gorm:"type:longtext"` // 模型的原始输出
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Error            string    `json:"error" gorm:"type:text"`
+	CacheID          uint      `json:"cache_id" gorm:"index"` // 非 0 时输出来自该缓存记录，没有调用模型
+	CreatedAt        time.Time `json:"created_at" gorm:"index:idx_ai_log_user_time;index"`
+}
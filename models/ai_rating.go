@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// 学生对一次 AI 输出的评价，同一学生对同一输出重复评价时覆盖之前的评价
+type AIRating struct {
+	gorm.Model
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	CourseID uint   `json:"course_id" gorm:"index"`
+	Feature  string `json:"feature" gorm:"type:varchar(64);index"`
+	Provider string `json:"provider" gorm:"type:varchar(64)"`
+	// 生成该输出时使用的提示词模板，TemplateID 为 0 表示内置模板
+	TemplateID    uint   `json:"template_id"`
+	PromptVersion int    `json:"prompt_version"`
+	RecordID      uint   `json:"record_id" gorm:"index"`       // 作答记录ID
+	HintRecordID  uint   `json:"hint_record_id" gorm:"index"`  // 评价分级提示时的提示记录ID
+	ChatMessageID uint   `json:"chat_message_id" gorm:"index"` // 评价多轮对话时的助教消息ID
+	Helpful       bool   `json:"helpful"`
+	Reason        string `json:"reason" gorm:"type:text"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AI 响应缓存，CacheKey 由功能、模型、提示词版本、题目和规范化后的代码哈希计算得到
+type AIResponseCache struct {
+	gorm.Model
+	CacheKey      string    `json:"cache_key" gorm:"type:char(64);uniqueIndex;not null"`
+	Feature       string    `json:"feature" gorm:"type:varchar(64);index"`
+	Provider      string    `json:"provider" gorm:"type:varchar(64)"`
+	ModelName     string    `json:"model_name" gorm:"type:varchar(128)"`
+	TemplateID    uint      `json:"template_id"`
+	PromptVersion int       `json:"prompt_version"`
+	ProblemID     uint      `json:"problem_id" gorm:"index"`
+	CodeHash      string    `json:"code_hash" gorm:"type:char(64)"`
+	Content       string    `json:"content" gorm:"type:longtext"`
+	HitCount      int       `json:"hit_count"`
+	ExpiresAt     time.Time `json:"expires_at" gorm:"index"`
+}
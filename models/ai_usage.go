@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 每次调用模型的 token 用量，班级记录的是调用时用户所在的班级
+type AIUsage struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	UserID           uint      `json:"user_id" gorm:"index:idx_ai_usage_user_time"`
+	ClassID          uint      `json:"class_id" gorm:"index"`
+	CourseID         uint      `json:"course_id" gorm:"index"`
+	Feature          string    `json:"feature" gorm:"type:varchar(64);index"`
+	Provider         string    `json:"provider" gorm:"type:varchar(64)"`
+	ModelName        string    `json:"model_name" gorm:"type:varchar(128)"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Estimated        bool      `json:"estimated"` // 模型未返回用量时按文本长度估算
+	Cost             float64   `json:"cost"`      // 按调用时的价格估算的费用（元）
+	LatencyMs        int64     `json:"latency_ms"`
+	Success          bool      `json:"success"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index:idx_ai_usage_user_time"`
+}
+
+// AI 用量额度，限制每个用户每天可使用的 token 数。Role / ClassID 为空时对所有角色 / 班级生效，
+// CourseID 为 0 时限制用户当天的总用量，否则只统计该课程下的用量
+type AIQuota struct {
+	gorm.Model
+	Role        Role  `json:"role" gorm:"type:varchar(16)"`
+	ClassID     uint  `json:"class_id" gorm:"index"`
+	CourseID    uint  `json:"course_id" gorm:"index"`
+	DailyTokens int64 `json:"daily_tokens" gorm:"not null"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "gorm.io/gorm"
+
+// AI 回答违反课程代码策略的类型
+const (
+	ViolationCodeBlock    = "code_block"    // 回答中包含策略不允许的代码块
+	ViolationSolutionCopy = "solution_copy" // 回答与参考答案或 AI 修正代码高度相似
+)
+
+// AI 回答违反代码策略的处理方式
+const (
+	ViolationActionRegenerated = "regenerated" // 重新生成后的回答符合策略
+	ViolationActionRedacted    = "redacted"    // 重新生成后仍不符合，已隐藏违规内容
+)
+
+// AI 回答违反课程代码策略的记录，供教师查看
+type AIViolation struct {
+	gorm.Model
+	UserID     uint    `json:"user_id" gorm:"index"`
+	CourseID   uint    `json:"course_id" gorm:"index"`
+	ProblemID  uint    `json:"problem_id" gorm:"index"`
+	SessionID  uint    `json:"session_id"` // 多轮对话的会话ID
+	Feature    string  `json:"feature" gorm:"type:varchar(64)"`
+	Provider   string  `json:"provider" gorm:"type:varchar(64)"`
+	Policy     string  `json:"policy" gorm:"type:varchar(32)"`
+	Kind       string  `json:"kind" gorm:"type:varchar(32)"`
+	Similarity float64 `json:"similarity"` // 与参考代码的最高相似度
+	Action     string  `json:"action" gorm:"type:varchar(32)"`
+	Question   string  `json:"question" gorm:"type:text"`
+	Original   string  `json:"original" gorm:"type:longtext"` // 模型最初的回答
+	Final      string  `json:"final" gorm:"type:longtext"`    // 返回给学生的回答
+}
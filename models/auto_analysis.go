@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 自动分析的状态
+const (
+	AutoAnalysisPending   = "pending"   // 等待执行，失败后等待重试时同样为该状态
+	AutoAnalysisRunning   = "running"   // 正在执行
+	AutoAnalysisCompleted = "completed" // 分析完成
+	AutoAnalysisFailed    = "failed"    // 重试次数用完或额度不足，不再重试
+)
+
+// 提交判定为 FAILED 后自动进行的 AI 代码分析，课程开启自动分析时创建。每条作答记录保留一条分析，
+// 作答记录再次判定为 FAILED 时重新排队并分析新提交的代码
+type AutoAnalysis struct {
+	gorm.Model
+	RecordID   uint                   `json:"record_id" gorm:"uniqueIndex"`
+	UserID     uint                   `json:"user_id" gorm:"index"`
+	ProblemID  uint                   `json:"problem_id"`
+	CourseID   uint                   `json:"course_id" gorm:"index"`
+	Language   string                 `json:"language" gorm:"type:varchar(32)"`
+	TypedCode  string                 `json:"typed_code" gorm:"type:text"` // 判定为 FAILED 时提交的代码
+	Status     string                 `json:"status" gorm:"type:varchar(16);index:idx_auto_analysis_due"`
+	Attempts   int                    `json:"attempts"`
+	NextRunAt  time.Time              `json:"next_run_at" gorm:"index:idx_auto_analysis_due"`
+	LastError  string                 `json:"last_error" gorm:"type:text"`
+	Result     map[string]interface{} `json:"result,omitempty" gorm:"type:longtext;serializer:json"` // 与 /ai/analyze_code/ 的返回结果一致
+	FinishedAt *time.Time             `json:"finished_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// AI 助教多轮对话会话，按用户和题目隔离
+type ChatSession struct {
+	gorm.Model
+	UserID    uint   `json:"user_id" gorm:"not null;index:idx_chat_session_user_problem"`
+	ProblemID uint   `json:"problem_id" gorm:"not null;index:idx_chat_session_user_problem"`
+	Title     string `json:"title" gorm:"type:varchar(255)"`
+	Provider  string `json:"provider" gorm:"type:varchar(64)"` // 会话使用的模型，创建后固定
+	// 超出 token 预算的早期对话会被压缩为摘要，SummarizedUntil 为摘要覆盖到的最后一条消息ID
+	Summary         string `json:"summary" gorm:"type:text"`
+	SummarizedUntil uint   `json:"summarized_until"`
+
+	Messages []ChatMessage `json:"messages,omitempty" gorm:"foreignKey:SessionID"`
+	User     User          `json:"-" gorm:"foreignKey:UserID"`
+	Problem  Problem       `json:"-" gorm:"foreignKey:ProblemID"`
+}
+
+type ChatMessage struct {
+	gorm.Model
+	SessionID  uint   `json:"session_id" gorm:"not null;index"`
+	Role       string `json:"role" gorm:"type:varchar(16);not null"`
+	Content    string `json:"content" gorm:"type:text"`
+	TypedCode  string `json:"typed_code" gorm:"type:text"` // 学生提问时的代码
+	TokenCount int    `json:"token_count"`                 // 估算的 token 数
+	// 生成回答时使用的提示词模板，仅助教消息有值，TemplateID 为 0 表示内置模板
+	TemplateID    uint `json:"template_id"`
+	PromptVersion int  `json:"prompt_version"`
+}
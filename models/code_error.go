@@ -0,0 +1,31 @@
+package models
+
+import "gorm.io/gorm"
+
+// AI 代码分析给出的错误类型
+const (
+	ErrorCategoryOffByOne           = "off_by_one"           // 边界差一、循环下标越界
+	ErrorCategoryWrongDataStructure = "wrong_data_structure" // 数据结构选择不当
+	ErrorCategoryTimeComplexity     = "time_complexity"      // 时间复杂度过高导致超时
+	ErrorCategoryEdgeCase           = "edge_case_missing"    // 遗漏边界或特殊情况
+	ErrorCategorySyntax             = "syntax"               // 语法或编译错误
+	ErrorCategoryLogic              = "logic"                // 算法思路或逻辑错误
+	ErrorCategoryOther              = "other"
+)
+
+// 作答记录中的一处错误，由 AI 代码分析的结构化结果生成，用于按错误类型和知识点统计
+type CodeError struct {
+	gorm.Model
+	RecordID                uint   `json:"record_id" gorm:"index"`
+	UserID                  uint   `json:"user_id" gorm:"index"`
+	ProblemID               uint   `json:"problem_id" gorm:"index"`
+	CourseID                uint   `json:"course_id" gorm:"index"`
+	KnowledgePointID        uint   `json:"knowledge_point_id" gorm:"index"` // 作答记录所属的知识点
+	Provider                string `json:"provider" gorm:"type:varchar(64)"`
+	Category                string `json:"category" gorm:"type:varchar(32);index"`
+	StartLine               int    `json:"start_line"`
+	EndLine                 int    `json:"end_line"`
+	RelatedKnowledgePoint   string `json:"related_knowledge_point"`                 // 模型给出的相关知识点
+	RelatedKnowledgePointID uint   `json:"related_knowledge_point_id" gorm:"index"` // 匹配到的课程知识点，未匹配时为 0
+	Description             string `json:"description" gorm:"type:text"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+// 代码审阅批注的严重程度
+const (
+	ReviewSeverityError      = "error"      // 会导致结果错误、运行错误或超时
+	ReviewSeverityWarning    = "warning"    // 暂不影响结果，但存在隐患或不好的写法
+	ReviewSeveritySuggestion = "suggestion" // 可读性、风格等改进建议
+)
+
+// 作答记录中的一条代码审阅批注，行号从 1 开始，编辑器据此在代码行上显示标记
+type ReviewComment struct {
+	gorm.Model
+	RecordID      uint   `json:"record_id" gorm:"index"`
+	UserID        uint   `json:"user_id" gorm:"index"`
+	ProblemID     uint   `json:"problem_id" gorm:"index"`
+	Provider      string `json:"provider" gorm:"type:varchar(64)"`
+	TemplateID    uint   `json:"template_id"`
+	PromptVersion int    `json:"prompt_version"`
+	StartLine     int    `json:"start_line"`
+	EndLine       int    `json:"end_line"`
+	Severity      string `json:"severity" gorm:"type:varchar(16)"`
+	Message       string `json:"message" gorm:"type:text"`
+	Suggestion    string `json:"suggestion" gorm:"type:text"` // 替换 start_line 至 end_line 的代码，为空表示没有修改建议
+}
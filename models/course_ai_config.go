@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// AI 助教的代码策略
+const (
+	CodePolicyAllowCode  = "allow_code"  // 允许给出代码
+	CodePolicyPseudoCode = "pseudo_code" // 只允许伪代码
+	CodePolicyNoCode     = "no_code"     // 不允许任何代码
+)
+
+// 课程级别的 AI 功能配置，课程没有配置时使用默认值
+type CourseAIConfig struct {
+	CourseID uint `json:"course_id" gorm:"primaryKey;autoIncrement:false"`
+	// 使用提示后是否按提示等级对得分打折
+	HintDiscountEnabled bool `json:"hint_discount_enabled"`
+	// 各提示等级对应的扣分百分比，以逗号分隔，如 "0,10,25,50"
+	HintDiscounts string `json:"hint_discounts" gorm:"type:varchar(255)"`
+	// AI 助教回答中允许出现的代码，为空时使用 allow_code
+	CodePolicy string `json:"code_policy" gorm:"type:varchar(32)"`
+	// 提交判定为 FAILED 后是否自动在后台进行 AI 代码分析
+	AutoAnalyzeEnabled bool      `json:"auto_analyze_enabled"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	Course Course `json:"-" gorm:"foreignKey:CourseID"`
+}
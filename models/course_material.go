@@ -0,0 +1,29 @@
+package models
+
+import "gorm.io/gorm"
+
+// 教师上传的课程资料（讲义、PDF 或课件导出的文本），切分为片段后用于 AI 助教检索
+type CourseMaterial struct {
+	gorm.Model
+	CourseID         uint   `json:"course_id" gorm:"index;not null"`
+	KnowledgePointID uint   `json:"knowledge_point_id" gorm:"index"` // 为 0 表示适用于整门课程
+	UploaderID       uint   `json:"uploader_id"`
+	Title            string `json:"title" gorm:"type:varchar(255);not null"`
+	FileName         string `json:"file_name" gorm:"type:varchar(255)"`
+	Content          string `json:"content,omitempty" gorm:"type:longtext"`
+	EmbeddingModel   string `json:"embedding_model" gorm:"type:varchar(128)"`
+	ChunkCount       int    `json:"chunk_count"`
+}
+
+// 课程资料切分后的片段及其向量
+type MaterialChunk struct {
+	gorm.Model
+	MaterialID       uint      `json:"material_id" gorm:"index;not null"`
+	CourseID         uint      `json:"course_id" gorm:"index;not null"`
+	KnowledgePointID uint      `json:"knowledge_point_id"`
+	Seq              int       `json:"seq"` // 片段在资料中的顺序，从 1 开始
+	Heading          string    `json:"heading" gorm:"type:varchar(512)"`
+	Content          string    `json:"content" gorm:"type:text"`
+	EmbeddingModel   string    `json:"embedding_model" gorm:"type:varchar(128)"`
+	Vector           []float64 `json:"-" gorm:"type:longtext;serializer:json"`
+}
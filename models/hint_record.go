@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/gorm"
+
+// 分级提示，必须按顺序解锁
+const (
+	HintLevelNudge      = 1 // 方向提示
+	HintLevelKeyIdea    = 2 // 关键思路
+	HintLevelSteps      = 3 // 详细步骤
+	HintLevelPseudoCode = 4 // 伪代码
+	HintLevelMax        = HintLevelPseudoCode
+)
+
+// 学生每次请求提示的记录
+type HintRecord struct {
+	gorm.Model
+	UserID           uint   `json:"user_id" gorm:"not null;index:idx_hint_record_user_problem"`
+	ProblemID        uint   `json:"problem_id" gorm:"not null;index:idx_hint_record_user_problem"`
+	KnowledgePointID uint   `json:"knowledge_point_id" gorm:"index:idx_hint_record_user_problem"`
+	UserProblemID    uint   `json:"user_problem_id" gorm:"index"`
+	Level            int    `json:"level" gorm:"not null"`
+	Provider         string `json:"provider" gorm:"type:varchar(64)"`
+	Content          string `json:"content" gorm:"type:text"`
+	// 生成提示时使用的提示词模板，TemplateID 为 0 表示内置模板
+	TemplateID    uint `json:"template_id"`
+	PromptVersion int  `json:"prompt_version"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// 定时任务的分布式租约，保证多副本部署时同一任务同一时间只在一个实例上运行
+type JobLease struct {
+	Name           string     `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Owner          string     `json:"owner" gorm:"type:varchar(255);not null;default:''"`
+	LeaseUntil     *time.Time `json:"lease_until"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastError      string     `json:"last_error" gorm:"type:text"`
+}
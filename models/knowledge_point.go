@@ -6,6 +6,9 @@ type KnowledgePoint struct {
 	gorm.Model
 	Name     string `json:"name" gorm:"type:varchar(255);not null"`
 	CourseID uint   `json:"course_id" gorm:"not null"`
+	Position int    `json:"position"` // 课程内的教学顺序，相同时按ID排序
+	// 知识点的教学内容说明，根据课程大纲生成知识点时填写
+	Description string `json:"description" gorm:"type:text"`
 	Course   Course `json:"-" gorm:"foreignKey:CourseID"`
 	Tags     []Tag  `json:"tags" gorm:"many2many:knowledge_point_tags;"`
 	Problems []Problem `json:"problems" gorm:"many2many:knowledge_point_problems;"`
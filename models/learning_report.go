@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LearningReportTriggerManual = "manual" // 学生或教师手动生成
+	LearningReportTriggerWeekly = "weekly" // 每周定时生成
+)
+
+// 学习报告中的薄弱知识点
+type ReportWeakSpot struct {
+	KnowledgePoint string `json:"knowledge_point"`
+	Reason         string `json:"reason"`
+}
+
+// 学习报告中推荐练习的题目，题目必须属于该课程
+type ReportPracticeItem struct {
+	ProblemID      uint   `json:"problem_id"`
+	Title          string `json:"title"`
+	KnowledgePoint string `json:"knowledge_point"`
+	Reason         string `json:"reason"`
+}
+
+// AI 根据学生在课程中的做题情况生成的个性化学习报告
+type LearningReport struct {
+	gorm.Model
+	UserID        uint                 `json:"user_id" gorm:"index:idx_learning_report_user_course"`
+	CourseID      uint                 `json:"course_id" gorm:"index:idx_learning_report_user_course"`
+	Trigger       string               `json:"trigger" gorm:"type:varchar(16)"`
+	PeriodStart   time.Time            `json:"period_start"` // 近期失败作答和提示使用的统计范围
+	PeriodEnd     time.Time            `json:"period_end"`
+	Provider      string               `json:"provider" gorm:"type:varchar(64)"`
+	TemplateID    uint                 `json:"template_id"`
+	PromptVersion int                  `json:"prompt_version"`
+	Summary       string               `json:"summary" gorm:"type:text"`
+	Strengths     []string             `json:"strengths" gorm:"type:text;serializer:json"`
+	WeakSpots     []ReportWeakSpot     `json:"weak_spots" gorm:"type:text;serializer:json"`
+	PracticePlan  []ReportPracticeItem `json:"practice_plan" gorm:"type:text;serializer:json"`
+}
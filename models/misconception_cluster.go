@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一段时间内课程某个知识点下错误类型相同、代码相似的失败作答组成的聚类，由定时任务生成，模型总结其中的共性误区
+type MisconceptionCluster struct {
+	gorm.Model
+	TaskRecordID     uint      `json:"task_record_id" gorm:"index"` // 生成该聚类的任务
+	CourseID         uint      `json:"course_id" gorm:"index"`
+	KnowledgePointID uint      `json:"knowledge_point_id" gorm:"index"`
+	Category         string    `json:"category" gorm:"type:varchar(32)"` // 错误类型，未归类的作答为空
+	PeriodStart      time.Time `json:"period_start"`
+	PeriodEnd        time.Time `json:"period_end"`
+	RecordCount      int       `json:"record_count"` // 为 0 时表示该次任务没有失败作答，只用于标记最近一次聚类
+	StudentCount     int       `json:"student_count"`
+	RecordIDs        string    `json:"record_ids" gorm:"type:text"`   // 聚类中的作答记录ID，以逗号分隔
+	Examples         string    `json:"examples" gorm:"type:longtext"` // 代表性作答（JSON）
+	Provider         string    `json:"provider" gorm:"type:varchar(64)"`
+	Title            string    `json:"title"`
+	Summary          string    `json:"summary" gorm:"type:text"`       // 共性误区
+	Suggestion       string    `json:"suggestion" gorm:"type:text"`    // 教学建议
+	SummaryError     string    `json:"summary_error" gorm:"type:text"` // 总结失败时的错误信息
+}
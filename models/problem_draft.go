@@ -0,0 +1,33 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	ProblemDraftStatusDraft     = "draft"     // 等待教师修改和发布
+	ProblemDraftStatusPublished = "published" // 已发布为自定义题目
+)
+
+// AI 根据知识点生成的题目草稿，教师修改后发布为自定义题目
+type ProblemDraft struct {
+	gorm.Model
+	KnowledgePointID  uint              `json:"knowledge_point_id" gorm:"index;not null"`
+	CreatorID         uint              `json:"creator_id" gorm:"index"`
+	Status            string            `json:"status" gorm:"type:varchar(32);default:'draft'"`
+	ProblemID         uint              `json:"problem_id"` // 发布后生成的题目ID
+	Provider          string            `json:"provider" gorm:"type:varchar(64)"`
+	Constraints       string            `json:"constraints" gorm:"type:text"` // 教师生成草稿时的额外要求
+	Title             string            `json:"title" gorm:"type:varchar(255)"`
+	TitleCn           string            `json:"title_cn"`
+	Content           string            `json:"content" gorm:"type:text"`
+	ContentCn         string            `json:"content_cn" gorm:"type:text"`
+	Difficulty        ProblemDifficulty `json:"difficulty" gorm:"type:ENUM('Easy', 'Medium', 'Hard')"`
+	SampleTestcases   string            `json:"sample_testcases" gorm:"type:text"`
+	TestCases         string            `json:"test_cases" gorm:"type:text"`
+	ReferenceSolution string            `json:"reference_solution" gorm:"type:text"`
+	ReferenceLanguage string            `json:"reference_language" gorm:"type:varchar(32)"`
+	TimeLimit         int               `json:"time_limit" gorm:"type:int;default:1000"`
+	MemoryLimit       int               `json:"memory_limit" gorm:"type:int;default:128"`
+	TagIDs            string            `json:"tag_ids"` // 发布时关联的标签ID，以逗号分隔
+
+	KnowledgePoint KnowledgePoint `json:"-" gorm:"foreignKey:KnowledgePointID"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// 题目内容的向量，用于语义检索。EmbeddingModel 为生成向量的模型，更换模型后需要重新生成
+type ProblemEmbedding struct {
+	gorm.Model
+	ProblemID      uint      `json:"problem_id" gorm:"uniqueIndex;not null"`
+	EmbeddingModel string    `json:"embedding_model" gorm:"type:varchar(128);index"`
+	ContentHash    string    `json:"content_hash" gorm:"type:varchar(64)"` // 向量化文本的哈希，题目内容变化时重新生成
+	Dimensions     int       `json:"dimensions"`
+	Vector         []float64 `json:"-" gorm:"type:longtext;serializer:json"`
+}
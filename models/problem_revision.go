@@ -0,0 +1,30 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	ProblemRevisionSourceBaseline = "baseline" // 首次发生变化前的原始版本
+	ProblemRevisionSourceSync     = "sync"     // LeetCode 同步
+	ProblemRevisionSourceTeacher  = "teacher"  // 教师编辑
+)
+
+// 题目修订记录，题面、样例、难度或标签发生变化时写入一条完整快照
+type ProblemRevision struct {
+	gorm.Model
+	ProblemID       uint              `json:"problem_id" gorm:"not null;uniqueIndex:idx_problem_version"`
+	Version         int               `json:"version" gorm:"not null;uniqueIndex:idx_problem_version"`
+	Source          string            `json:"source" gorm:"type:varchar(32);not null"`
+	EditorID        uint              `json:"editor_id"`
+	TaskRecordID    uint              `json:"task_record_id"`
+	ChangedFields   string            `json:"changed_fields"`
+	Title           string            `json:"title" gorm:"type:varchar(255)"`
+	TitleCn         string            `json:"title_cn"`
+	Content         string            `json:"content" gorm:"type:text"`
+	ContentCn       string            `json:"content_cn" gorm:"type:text"`
+	Difficulty      ProblemDifficulty `json:"difficulty" gorm:"type:ENUM('Easy', 'Medium', 'Hard')"`
+	SampleTestcases string            `json:"sample_testcases" gorm:"type:text"`
+	TestCases       string            `json:"test_cases" gorm:"type:text"`
+	Tags            string            `json:"tags" gorm:"type:text"` // 标签名，以逗号分隔
+
+	Problem Problem `json:"-" gorm:"foreignKey:ProblemID"`
+}
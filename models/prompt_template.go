@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// 教师维护的提示词模板，CourseID 为 0 表示全局模板，非 0 表示对该课程的覆盖
+type PromptTemplate struct {
+	gorm.Model
+	Key          string `json:"key" gorm:"type:varchar(64);not null;uniqueIndex:idx_prompt_key_course"`
+	CourseID     uint   `json:"course_id" gorm:"not null;default:0;uniqueIndex:idx_prompt_key_course"`
+	SystemPrompt string `json:"system_prompt" gorm:"type:text"`
+	Content      string `json:"content" gorm:"type:text;not null"`
+	Version      int    `json:"version" gorm:"not null"` // 当前生效的版本号
+	// 未删除时为 true，删除时置为 NULL。唯一索引允许多个 NULL，已删除的模板不会占用 (key, course_id)
+	Active *bool `json:"-" gorm:"default:true;uniqueIndex:idx_prompt_key_course"`
+
+	Versions []PromptTemplateVersion `json:"versions,omitempty" gorm:"foreignKey:TemplateID"`
+}
+
+// 模板的历史版本，每次修改或回滚都会新增一个版本
+type PromptTemplateVersion struct {
+	gorm.Model
+	TemplateID   uint   `json:"template_id" gorm:"not null;uniqueIndex:idx_prompt_template_version"`
+	Version      int    `json:"version" gorm:"not null;uniqueIndex:idx_prompt_template_version"`
+	SystemPrompt string `json:"system_prompt" gorm:"type:text"`
+	Content      string `json:"content" gorm:"type:text;not null"`
+	EditorID     uint   `json:"editor_id"`
+	Comment      string `json:"comment" gorm:"type:varchar(255)"`
+}
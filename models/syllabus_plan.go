@@ -0,0 +1,31 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	SyllabusPlanStatusDraft   = "draft"   // 等待教师修改和应用
+	SyllabusPlanStatusApplied = "applied" // 已创建知识点和标签关联
+)
+
+// 教学计划中的一个知识点，按 Position 从小到大排列
+type SyllabusPlanPoint struct {
+	Position    int      `json:"position"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	TagIDs      []uint   `json:"tag_ids"`
+	TagNames    []string `json:"tag_names"` // 便于教师审阅，应用时以 TagIDs 为准
+}
+
+// AI 根据课程大纲生成的知识点计划，教师审阅修改后一次性创建课程知识点和标签关联
+type SyllabusPlan struct {
+	gorm.Model
+	CourseID      uint                `json:"course_id" gorm:"index"` // 应用到已有课程时指定，为 0 时应用时新建课程
+	CourseName    string              `json:"course_name" gorm:"type:varchar(255)"`
+	CreatorID     uint                `json:"creator_id" gorm:"index"`
+	Status        string              `json:"status" gorm:"type:varchar(32);default:'draft'"`
+	Provider      string              `json:"provider" gorm:"type:varchar(64)"`
+	TemplateID    uint                `json:"template_id"`
+	PromptVersion int                 `json:"prompt_version"`
+	Syllabus      string              `json:"syllabus" gorm:"type:longtext"`
+	Points        []SyllabusPlanPoint `json:"points" gorm:"type:text;serializer:json"`
+}
@@ -13,15 +13,20 @@ const (
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusCanceled  TaskStatus = "canceled"
 )
 
 type TaskRecord struct {
 	gorm.Model
 	TaskType     string     `json:"task_type" gorm:"not null"`
-	Status       TaskStatus `json:"status" gorm:"type:ENUM('pending', 'running', 'completed', 'failed');not null"`
+	Status       TaskStatus `json:"status" gorm:"type:ENUM('pending', 'running', 'completed', 'failed', 'canceled');not null"`
 	StartTime    *time.Time `json:"start_time"`
 	EndTime      *time.Time `json:"end_time"`
 	TotalCount   int        `json:"total_count"`
 	SuccessCount int        `json:"success_count"`
 	ErrorMessage string     `json:"error_message" gorm:"type:text"`
+	Params       string     `json:"params" gorm:"type:text"` // 任务参数（JSON），用于断点续传
+	Checkpoint   int        `json:"checkpoint"`              // 已处理到的位置，用于断点续传
+	// 管理员请求取消任务，运行该任务的实例轮询到后停止执行
+	CancelRequested bool `json:"cancel_requested" gorm:"not null;default:false"`
 }
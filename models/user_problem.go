@@ -21,11 +21,29 @@ type UserProblem struct {
 	KnowledgePointID              uint          `json:"knowledge_point_id" gorm:"primaryKey;autoIncrement:false"`
 	Status                        ProblemStatus `json:"status" gorm:"type:ENUM('UNTRIED', 'TRIED', 'FAILED', 'SOLVED');default:'UNTRIED'"`
 	TypedCode                     string        `json:"typed_code"`
+	Language                      string        `json:"language" gorm:"type:varchar(32)"` // 提交时的编程语言
 	QwenWrongReasonAndAnalyze     string        `json:"wrong_reason_and_analyze"`
 	DeepseekWrongReasonAndAnalyze string        `json:"deepseek_wrong_reason_and_analyze"`
 	QwenCorrectedCode             string        `json:"qwen_corrected_code"`
 	DeepseekCorrected_code        string        `json:"deepseek_corrected_code"`
 	SubmissionID                  float64       `json:"submission_id" gorm:"index"`
+	HintLevel                     int           `json:"hint_level"` // 已解锁的最高提示等级
+
+	// qwen_* / deepseek_* 字段只表示功能配置的第一个和第二个模型，以下字段记录实际生成对应内容的模型名称
+	QwenWrongReasonAndAnalyzeModel     string `json:"wrong_reason_and_analyze_model" gorm:"type:varchar(64)"`
+	DeepseekWrongReasonAndAnalyzeModel string `json:"deepseek_wrong_reason_and_analyze_model" gorm:"type:varchar(64)"`
+	QwenCorrectedCodeModel             string `json:"qwen_corrected_code_model" gorm:"type:varchar(64)"`
+	DeepseekCorrectedCodeModel         string `json:"deepseek_corrected_code_model" gorm:"type:varchar(64)"`
+
+	// 生成对应内容时使用的提示词模板和版本，学生评价这些内容时以此为准
+	QwenWrongReasonAndAnalyzeTemplateID        uint `json:"wrong_reason_and_analyze_template_id"`
+	QwenWrongReasonAndAnalyzePromptVersion     int  `json:"wrong_reason_and_analyze_prompt_version"`
+	DeepseekWrongReasonAndAnalyzeTemplateID    uint `json:"deepseek_wrong_reason_and_analyze_template_id"`
+	DeepseekWrongReasonAndAnalyzePromptVersion int  `json:"deepseek_wrong_reason_and_analyze_prompt_version"`
+	QwenCorrectedCodeTemplateID                uint `json:"qwen_corrected_code_template_id"`
+	QwenCorrectedCodePromptVersion             int  `json:"qwen_corrected_code_prompt_version"`
+	DeepseekCorrectedCodeTemplateID            uint `json:"deepseek_corrected_code_template_id"`
+	DeepseekCorrectedCodePromptVersion         int  `json:"deepseek_corrected_code_prompt_version"`
 
 	User           User           `json:"-" gorm:"foreignkey:UserID"`
 	Problem        Problem        `json:"-" gorm:"foreignkey:ProblemID"`
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"ai_teach_system/models"
 	"ai_teach_system/utils"
 	"net/http"
 	"strings"
@@ -33,6 +34,20 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+
+		c.Next()
+	}
+}
+
+// AdminMiddleware 限制只有管理员（教师）可以访问，需在 AuthMiddleware 之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role != models.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, utils.Error("没有权限访问"))
+			return
+		}
 
 		c.Next()
 	}
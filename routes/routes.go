@@ -3,6 +3,7 @@ package routes
 import (
 	"ai_teach_system/controllers"
 	"ai_teach_system/services"
+	"ai_teach_system/tasks"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,7 +16,7 @@ func healthCheckHandler(c *gin.Context) {
 	})
 }
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupRoutes(r *gin.Engine, db *gorm.DB, tasksManager *tasks.TasksManager) {
 	r.GET("/healthz", healthCheckHandler)
 
 	r.Use(CORSMiddleware())
@@ -41,6 +42,21 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 	classService := services.NewClassService(db)
 	classController := controllers.NewClassController(classService)
 
+	taskService := services.NewTaskService(db)
+	taskController := controllers.NewTaskController(taskService, tasksManager)
+
+	promptService := services.NewPromptService(db)
+	promptController := controllers.NewPromptController(promptService)
+
+	aiUsageService := services.NewAIUsageService(db)
+	aiUsageController := controllers.NewAIUsageController(aiUsageService)
+
+	aiRatingService := services.NewAIRatingService(db)
+	aiRatingController := controllers.NewAIRatingController(aiRatingService)
+
+	courseMaterialService := services.NewCourseMaterialService(db)
+	courseMaterialController := controllers.NewCourseMaterialController(courseMaterialService)
+
 	// 需要鉴权的路由
 	auth := api.Group("")
 	auth.Use(AuthMiddleware())
@@ -51,6 +67,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 			leetcode.POST("/interpret_solution/", leetcodeController.RunTestCase)
 			leetcode.POST("/submit/", leetcodeController.Submit)
 			leetcode.POST("/check/", leetcodeController.Check)
+			leetcode.GET("/check/:run_code_id/stream/", leetcodeController.CheckStream)
 		}
 
 		// AI 相关路由
@@ -58,9 +75,54 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			ai.POST("/generate_hint/", aiController.GenerateHint)
 			ai.POST("/correct_code/", aiController.CorrectCode)
+			ai.POST("/review_code/", aiController.ReviewCode)
+			ai.GET("/review_code/:record_id/", aiController.GetReviewComments)
+			// 提交失败后的自动分析
+			ai.GET("/auto_analyses/:record_id/", aiController.GetAutoAnalysis)
+			ai.GET("/auto_analyses/:record_id/stream/", aiController.AutoAnalysisStream)
 			ai.POST("/analyze_code/", aiController.AnalyzeCode)
 			ai.POST("/chat/", aiController.Chat)
 			ai.POST("/judge/", aiController.JudgeCode)
+			ai.POST("/generate_hint/stream/", aiController.GenerateHintStream)
+			ai.POST("/analyze_code/stream/", aiController.AnalyzeCodeStream)
+			ai.POST("/chat/stream/", aiController.ChatStream)
+			ai.GET("/models/", aiController.ListModels)
+
+			// 分级提示
+			ai.GET("/hints/", aiController.GetHints)
+			ai.POST("/hints/", aiController.RequestHint)
+
+			// 响应缓存（仅管理员）
+			ai.GET("/cache/", AdminMiddleware(), aiController.GetCacheStats)
+			ai.DELETE("/cache/", AdminMiddleware(), aiController.PurgeCache)
+
+			// 学生评价 AI 输出
+			ai.POST("/ratings/", aiRatingController.RateAIOutput)
+
+			// 用量和额度
+			ai.GET("/usage/", aiUsageController.GetMyUsage)
+			ai.GET("/usage/report/", AdminMiddleware(), aiUsageController.GetUsageReport)
+			// 调用日志（仅管理员）
+			ai.GET("/logs/", AdminMiddleware(), aiUsageController.GetAILogList)
+			ai.GET("/logs/:id/", AdminMiddleware(), aiUsageController.GetAILogDetail)
+			quotas := ai.Group("/quotas")
+			quotas.Use(AdminMiddleware())
+			{
+				quotas.GET("/", aiUsageController.GetQuotaList)
+				quotas.POST("/", aiUsageController.CreateQuota)
+				quotas.PUT("/:id/", aiUsageController.UpdateQuota)
+				quotas.DELETE("/:id/", aiUsageController.DeleteQuota)
+			}
+
+			// 多轮对话会话
+			chatSessions := ai.Group("/chat/sessions")
+			{
+				chatSessions.GET("/", aiController.GetChatSessionList)
+				chatSessions.POST("/", aiController.StartChatSession)
+				chatSessions.GET("/:id/", aiController.GetChatSessionDetail)
+				chatSessions.POST("/:id/messages/", aiController.ContinueChatSession)
+				chatSessions.DELETE("/:id/", aiController.DeleteChatSession)
+			}
 		}
 
 		// 用户相关路由
@@ -82,6 +144,42 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 
 			// 获取课程下的班级统计数据
 			courses.GET("/:course_id/stats/", courseController.GetCourseClassStats)
+			// 课程内失败作答的共性误区（仅管理员）
+			courses.GET("/:course_id/stats/misconceptions/", AdminMiddleware(), courseController.GetMisconceptionStats)
+
+			// 课程 AI 配置
+			courses.GET("/:course_id/ai_config/", courseController.GetAIConfig)
+			courses.PUT("/:course_id/ai_config/", AdminMiddleware(), courseController.UpdateAIConfig)
+
+			// AI 助教回答违反代码策略的记录（仅管理员）
+			courses.GET("/:course_id/ai_violations/", AdminMiddleware(), courseController.GetAIViolations)
+
+			// AI 代码分析归类出的错误（仅管理员）
+			courses.GET("/:course_id/code_errors/", AdminMiddleware(), courseController.GetCodeErrors)
+			courses.GET("/:course_id/code_errors/stats/", AdminMiddleware(), courseController.GetCodeErrorStats)
+
+			// 学生对 AI 输出的评价（仅管理员）
+			courses.GET("/:course_id/ai_ratings/", AdminMiddleware(), aiRatingController.GetRatingList)
+			courses.GET("/:course_id/ai_ratings/report/", AdminMiddleware(), aiRatingController.GetRatingReport)
+
+			// 学习报告
+			learningReports := courses.Group("/:course_id/learning_reports")
+			{
+				learningReports.GET("/", aiController.GetLearningReportList)
+				learningReports.POST("/", aiController.GenerateLearningReport)
+				learningReports.GET("/:id/", aiController.GetLearningReportDetail)
+			}
+
+			// 课程资料（仅管理员），AI 助教和代码分析会检索并引用资料内容
+			materials := courses.Group("/:course_id/materials")
+			materials.Use(AdminMiddleware())
+			{
+				materials.GET("/", courseMaterialController.GetMaterialList)
+				materials.POST("/", courseMaterialController.UploadMaterial)
+				materials.GET("/:id/", courseMaterialController.GetMaterial)
+				materials.DELETE("/:id/", courseMaterialController.DeleteMaterial)
+				materials.POST("/:id/reindex/", courseMaterialController.ReindexMaterial)
+			}
 
 			// 知识点相关路由
 			knowledgePoints := courses.Group("/:course_id/knowledge_points")
@@ -109,6 +207,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				ai := knowledgePoints.Group("/:knowledge_point_id/ai")
 				{
 					ai.GET("/suggest_tags/", aiController.SuggestKnowledgePointTags)
+					ai.POST("/draft_problem/", AdminMiddleware(), aiController.DraftProblem)
 				}
 			}
 
@@ -145,9 +244,25 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		// 题库相关路由
 		problems := auth.Group("/problems")
 		{
+			problems.GET("/search/", problemController.SearchProblems)
 			problems.GET("/:id/", problemController.GetProblemDetail)
+			problems.PUT("/:id/", AdminMiddleware(), problemController.UpdateProblem)
+			// 修订记录相关路由（仅管理员，修订记录包含测试用例）
+			problems.GET("/:id/revisions/", AdminMiddleware(), problemController.GetProblemRevisions)
+			problems.GET("/:id/revisions/diff/", AdminMiddleware(), problemController.DiffProblemRevisions)
+			problems.GET("/:id/revisions/:version/", AdminMiddleware(), problemController.GetProblemRevision)
 			problems.POST("/", problemController.GetProblemList)
 			problems.POST("/custom/", problemController.CreateCustomProblem)
+			// AI 生成的题目草稿
+			drafts := problems.Group("/drafts")
+			drafts.Use(AdminMiddleware())
+			{
+				drafts.GET("/", problemController.GetProblemDraftList)
+				drafts.GET("/:id/", problemController.GetProblemDraft)
+				drafts.PUT("/:id/", problemController.UpdateProblemDraft)
+				drafts.DELETE("/:id/", problemController.DeleteProblemDraft)
+				drafts.POST("/:id/publish/", problemController.PublishProblemDraft)
+			}
 			// 标签相关路由
 			tags := problems.Group("/tags")
 			{
@@ -155,6 +270,18 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 			}
 		}
 
+		// 根据课程大纲生成的知识点计划（仅管理员）
+		syllabusPlans := auth.Group("/syllabus_plans")
+		syllabusPlans.Use(AdminMiddleware())
+		{
+			syllabusPlans.GET("/", courseController.GetSyllabusPlanList)
+			syllabusPlans.POST("/", aiController.DraftSyllabusPlan)
+			syllabusPlans.GET("/:id/", courseController.GetSyllabusPlan)
+			syllabusPlans.PUT("/:id/", courseController.UpdateSyllabusPlan)
+			syllabusPlans.DELETE("/:id/", courseController.DeleteSyllabusPlan)
+			syllabusPlans.POST("/:id/apply/", courseController.ApplySyllabusPlan)
+		}
+
 		// 课程相关路由
 		classes := auth.Group("/classes")
 		{
@@ -173,6 +300,32 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			records.GET("/", userController.GetTryRecords)
 		}
+
+		// 任务管理相关路由（仅管理员）
+		taskRoutes := auth.Group("/tasks")
+		taskRoutes.Use(AdminMiddleware())
+		{
+			taskRoutes.GET("/", taskController.GetTaskList)
+			taskRoutes.POST("/sync/", taskController.StartSync)
+			taskRoutes.POST("/misconceptions/", taskController.StartClusterMisconceptions)
+			taskRoutes.POST("/embeddings/", taskController.StartBackfillEmbeddings)
+			taskRoutes.GET("/:id/", taskController.GetTaskDetail)
+			taskRoutes.POST("/:id/cancel/", taskController.CancelTask)
+			taskRoutes.POST("/:id/retry/", taskController.RetryTask)
+		}
+
+		// 提示词模板相关路由（仅管理员）
+		prompts := auth.Group("/prompts")
+		prompts.Use(AdminMiddleware())
+		{
+			prompts.GET("/", promptController.GetTemplateList)
+			prompts.POST("/", promptController.CreateTemplate)
+			prompts.POST("/preview/", promptController.PreviewTemplate)
+			prompts.GET("/:id/", promptController.GetTemplateDetail)
+			prompts.PUT("/:id/", promptController.UpdateTemplate)
+			prompts.DELETE("/:id/", promptController.DeleteTemplate)
+			prompts.POST("/:id/rollback/", promptController.RollbackTemplate)
+		}
 	}
 
 	// 用户相关路由
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// aiCacheStats 当前进程内各功能的缓存命中统计
+var aiCacheStats = struct {
+	sync.Mutex
+	hits   map[string]int64
+	misses map[string]int64
+}{
+	hits:   make(map[string]int64),
+	misses: make(map[string]int64),
+}
+
+func recordCacheResult(feature string, hit bool) {
+	aiCacheStats.Lock()
+	defer aiCacheStats.Unlock()
+	if hit {
+		aiCacheStats.hits[feature]++
+	} else {
+		aiCacheStats.misses[feature]++
+	}
+}
+
+// cacheKey 决定两次请求能否共用同一个缓存结果的全部因素
+type cacheKey struct {
+	feature   string
+	provider  *LLMProvider
+	prompt    *renderedPrompt
+	problemID uint
+	language  string
+	code      string
+}
+
+// hash 返回缓存键和规范化后的代码哈希。内置模板没有版本号，使用模板内容的哈希代替，
+// 这样内置模板随代码更新后旧的缓存不会再命中
+func (k cacheKey) hash() (string, string) {
+	codeHash := utils.CodeHash(k.code, k.language)
+
+	promptVersion := fmt.Sprintf("%d:%d", k.prompt.TemplateID, k.prompt.Version)
+	if k.prompt.TemplateID == 0 {
+		builtin := builtinPrompts[k.prompt.Key]
+		sum := sha256.Sum256([]byte(builtin.System + "\n" + builtin.Content))
+		promptVersion = "builtin:" + hex.EncodeToString(sum[:8])
+	}
+
+	raw := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s", k.feature, k.provider.Name, k.provider.Model, k.prompt.Key, promptVersion, k.problemID, codeHash)
+	if k.prompt.Context != "" {
+		sum := sha256.Sum256([]byte(k.prompt.Context))
+		raw += "|" + hex.EncodeToString(sum[:8])
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:]), codeHash
+}
+
+// lookupCache 查询未过期的缓存，功能未启用缓存时直接返回未命中
+func (s *AIService) lookupCache(ctx context.Context, key cacheKey) (string, bool) {
+	if _, enabled := config.LLM.CacheTTL[key.feature]; !enabled {
+		return "", false
+	}
+
+	hashKey, _ := key.hash()
+	var entry models.AIResponseCache
+	err := s.db.Where("cache_key = ? AND expires_at > ?", hashKey, time.Now()).First(&entry).Error
+	if err != nil {
+		recordCacheResult(key.feature, false)
+		return "", false
+	}
+
+	recordCacheResult(key.feature, true)
+	s.db.Model(&entry).UpdateColumn("hit_count", gorm.Expr("hit_count + 1"))
+	s.logCacheHit(ctx, key, &entry)
+	return entry.Content, true
+}
+
+// logCacheHit 命中缓存时同样写一条调用日志，记录学生实际看到的输出和提供该输出的缓存记录。
+// 缓存记录可能被覆盖或清除，因此输出内容也保存在日志中
+func (s *AIService) logCacheHit(ctx context.Context, key cacheKey, entry *models.AIResponseCache) {
+	raw, _ := json.Marshal([]openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(key.prompt.System),
+		openai.UserMessage(key.prompt.User),
+	})
+	aiLog := models.AILog{
+		UserID:    aiUserFrom(ctx),
+		Feature:   key.feature,
+		ProblemID: key.problemID,
+		Provider:  entry.Provider,
+		ModelName: entry.ModelName,
+		Messages:  string(raw),
+		Response:  entry.Content,
+		CacheID:   entry.ID,
+	}
+	if scope, ok := ctx.Value(aiScopeKey{}).(aiScope); ok {
+		aiLog.CourseID = scope.courseID
+	}
+	if target, ok := ctx.Value(aiTargetKey{}).(aiTarget); ok {
+		aiLog.RecordID = target.recordID
+	}
+	if err := s.db.Create(&aiLog).Error; err != nil {
+		log.Printf("记录 AI 调用日志失败: %v", err)
+	}
+}
+
+// storeCache 写入缓存，已存在的缓存会被覆盖并重新计算有效期
+func (s *AIService) storeCache(key cacheKey, content string) {
+	ttl, enabled := config.LLM.CacheTTL[key.feature]
+	if !enabled {
+		return
+	}
+
+	hashKey, codeHash := key.hash()
+	entry := models.AIResponseCache{
+		CacheKey:      hashKey,
+		Feature:       key.feature,
+		Provider:      key.provider.Name,
+		ModelName:     key.provider.Model,
+		TemplateID:    key.prompt.TemplateID,
+		PromptVersion: key.prompt.Version,
+		ProblemID:     key.problemID,
+		CodeHash:      codeHash,
+		Content:       content,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"content": content, "expires_at": entry.ExpiresAt, "hit_count": 0, "updated_at": time.Now()}),
+	}).Create(&entry).Error
+	if err != nil {
+		log.Printf("写入 AI 响应缓存失败: %v", err)
+	}
+}
+
+// cachedComplete 启用缓存的功能先查询缓存，未命中时调用模型并写入缓存
+func (s *AIService) cachedComplete(ctx context.Context, key cacheKey) (string, error) {
+	if content, ok := s.lookupCache(ctx, key); ok {
+		return content, nil
+	}
+
+	content, err := s.complete(ctx, key.provider, key.prompt.System, key.prompt.User)
+	if err != nil {
+		return "", err
+	}
+
+	s.storeCache(key, content)
+	return content, nil
+}
+
+// CacheStats 返回各功能的缓存配置、命中统计（当前进程）和缓存条目数
+func (s *AIService) CacheStats() ([]map[string]interface{}, error) {
+	var rows []struct {
+		Feature  string
+		Entries  int64
+		HitCount int64
+	}
+	err := s.db.Model(&models.AIResponseCache{}).
+		Select("feature, COUNT(*) AS entries, COALESCE(SUM(hit_count), 0) AS hit_count").
+		Where("expires_at > ?", time.Now()).
+		Group("feature").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	features := make(map[string]bool)
+	for feature := range config.LLM.CacheTTL {
+		features[feature] = true
+	}
+	for _, row := range rows {
+		features[row.Feature] = true
+	}
+	names := make([]string, 0, len(features))
+	for feature := range features {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+
+	aiCacheStats.Lock()
+	defer aiCacheStats.Unlock()
+
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, feature := range names {
+		ttl, enabled := config.LLM.CacheTTL[feature]
+		item := map[string]interface{}{
+			"feature":     feature,
+			"enabled":     enabled,
+			"ttl_seconds": int64(ttl.Seconds()),
+			"hits":        aiCacheStats.hits[feature],
+			"misses":      aiCacheStats.misses[feature],
+			"entries":     int64(0),
+			"total_hits":  int64(0),
+		}
+		if total := aiCacheStats.hits[feature] + aiCacheStats.misses[feature]; total > 0 {
+			item["hit_rate"] = float64(aiCacheStats.hits[feature]) / float64(total) * 100
+		} else {
+			item["hit_rate"] = float64(0)
+		}
+		for _, row := range rows {
+			if row.Feature == feature {
+				item["entries"] = row.Entries
+				item["total_hits"] = row.HitCount
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// PurgeCache 删除缓存，feature / problemID 为空时不按该条件过滤
+func (s *AIService) PurgeCache(feature string, problemID uint) (int64, error) {
+	query := s.db.Unscoped().Where("1 = 1")
+	if feature != "" {
+		query = query.Where("feature = ?", feature)
+	}
+	if problemID != 0 {
+		query = query.Where("problem_id = ?", problemID)
+	}
+
+	result := query.Delete(&models.AIResponseCache{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeExpiredAICache 删除已过期的缓存，由定时任务调用
+func PurgeExpiredAICache(db *gorm.DB) (int64, error) {
+	result := db.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.AIResponseCache{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"context"
+	"sync"
+)
+
+// modelResult 多模型对比功能中单个模型的调用结果
+type modelResult struct {
+	Model   string `json:"model"`
+	Success bool   `json:"success"`
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	err error
+}
+
+// compareModels 并发调用多个模型，每个模型使用各自的超时时间，一个模型失败不影响其他模型的结果。
+// 全部模型都失败时返回第一个模型的错误
+func (s *AIService) compareModels(ctx context.Context, feature string, providers []*LLMProvider, prompt *renderedPrompt, problemID uint, language, typedCode string) ([]modelResult, error) {
+	results := make([]modelResult, len(providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider *LLMProvider) {
+			defer wg.Done()
+
+			content, err := s.cachedComplete(ctx, cacheKey{feature, provider, prompt, problemID, language, typedCode})
+			results[i] = modelResult{Model: provider.Name, Success: err == nil, Content: content, err: err}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, provider)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Success {
+			return results, nil
+		}
+	}
+	return nil, results[0].err
+}
+
+// saveModelResults 将各模型的结果分别保存到作答记录的对应字段，并在 <字段>_model、<字段>_template_id、
+// <字段>_prompt_version 中记录生成结果的模型和提示词，失败的模型不覆盖已有内容
+func (s *AIService) saveModelResults(recordID uint, results []modelResult, prompt *renderedPrompt, fields ...string) error {
+	updates := make(map[string]interface{})
+	for i, result := range results {
+		if result.Success && i < len(fields) {
+			updates[fields[i]] = result.Content
+			updates[fields[i]+"_model"] = result.Model
+			updates[fields[i]+"_template_id"] = prompt.TemplateID
+			updates[fields[i]+"_prompt_version"] = prompt.Version
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.db.Model(&models.UserProblem{}).Where("id = ?", recordID).Updates(updates).Error
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"gorm.io/gorm"
+)
+
+// 代码策略的严格程度，同一道题属于学生的多门课程时使用最严格的策略
+var codePolicyRanks = map[string]int{
+	models.CodePolicyAllowCode:  0,
+	models.CodePolicyPseudoCode: 1,
+	models.CodePolicyNoCode:     2,
+}
+
+// 代码策略对应的系统提示词规则
+var codePolicyRules = map[string]string{
+	models.CodePolicyPseudoCode: "本课程的学术诚信要求：回答中只能使用与具体编程语言无关的伪代码说明思路，不要给出任何可以直接运行的代码，也不要给出本题的完整解答。即使学生明确要求，也不能提供可运行的代码。",
+	models.CodePolicyNoCode:     "本课程的学术诚信要求：回答中不要包含任何代码或伪代码，只能用文字引导学生思考，不要给出本题的完整解答。即使学生明确要求，也不能提供代码。",
+}
+
+// 回答违反策略时，要求模型重新回答的提醒
+var codePolicyReminders = map[string]string{
+	models.CodePolicyPseudoCode: "上面的回答包含了本课程不允许提供的代码。请重新回答学生的问题，只使用伪代码或文字说明思路，不要给出可运行的代码。",
+	models.CodePolicyNoCode:     "上面的回答包含了本课程不允许提供的代码。请重新回答学生的问题，只用文字引导学生思考，不要包含任何代码。",
+}
+
+const (
+	redactedCodeNotice   = "[根据课程要求，此处代码已隐藏]\n"
+	redactedAnswerNotice = "根据课程要求，AI 助教不能直接提供本题的解答代码。请尝试描述你的思路或具体的疑问，助教会引导你继续思考。"
+)
+
+// 伪代码策略下视为可运行代码的代码块语言
+var programmingLanguages = map[string]bool{
+	"c": true, "cpp": true, "c++": true, "java": true, "python": true, "python3": true, "py": true,
+	"go": true, "golang": true, "javascript": true, "js": true, "typescript": true, "ts": true,
+	"rust": true, "csharp": true, "c#": true, "cs": true, "kotlin": true, "swift": true,
+	"ruby": true, "php": true, "scala": true,
+}
+
+// 检查相似度时最多使用的 AI 修正代码数量
+const integrityReferenceLimit = 20
+
+// integrityGuard 一次 AI 助教回答适用的代码策略，以及用于检测答案泄露的参考代码
+type integrityGuard struct {
+	policy     string
+	userID     uint
+	courseID   uint
+	problemID  uint
+	sessionID  uint
+	references []string
+}
+
+// integrityCheck 回答违反策略的检查结果
+type integrityCheck struct {
+	kind       string
+	similarity float64
+	blocks     []utils.CodeBlock // 需要隐藏的代码块
+	whole      bool              // 代码不在代码块中，需要隐藏整个回答
+}
+
+// courseOfProblem 获取题目所属且学生班级选修的课程及其代码策略，有多门课程时取策略最严格的一门，
+// 没有对应课程时返回 0
+func courseOfProblem(db *gorm.DB, userID, problemID uint) (uint, string, error) {
+	if userID == 0 || problemID == 0 {
+		return 0, "", nil
+	}
+
+	var courseIDs []uint
+	err := db.Table("knowledge_point_problems").
+		Distinct("knowledge_points.course_id").
+		Joins("JOIN knowledge_points ON knowledge_points.id = knowledge_point_problems.knowledge_point_id").
+		Joins("JOIN course_classes ON course_classes.course_id = knowledge_points.course_id").
+		Joins("JOIN users ON users.class_id = course_classes.class_id").
+		Where("knowledge_point_problems.problem_id = ? AND users.id = ?", problemID, userID).
+		Pluck("knowledge_points.course_id", &courseIDs).Error
+	if err != nil {
+		return 0, "", fmt.Errorf("获取课程代码策略失败: %v", err)
+	}
+
+	var courseID uint
+	var policy string
+	for i, id := range courseIDs {
+		cfg, err := loadCourseAIConfig(db, id)
+		if err != nil {
+			return 0, "", fmt.Errorf("获取课程代码策略失败: %v", err)
+		}
+		if i == 0 || codePolicyRanks[cfg.CodePolicy] > codePolicyRanks[policy] {
+			policy = cfg.CodePolicy
+			courseID = id
+		}
+	}
+	return courseID, policy, nil
+}
+
+// integrityGuard 获取学生在题目上适用的代码策略：题目所属且学生班级选修的课程中最严格的策略，
+// 没有对应课程（如没有登录用户的调用）时允许代码。参考代码包括题目的参考解答和最近的 AI 修正代码
+func (s *AIService) integrityGuard(userID, problemID uint) (*integrityGuard, error) {
+	guard := &integrityGuard{policy: models.CodePolicyAllowCode, userID: userID, problemID: problemID}
+	if userID == 0 {
+		return guard, nil
+	}
+
+	courseID, policy, err := courseOfProblem(s.db, userID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	if courseID != 0 {
+		guard.courseID = courseID
+		guard.policy = policy
+	}
+
+	if guard.policy == models.CodePolicyAllowCode {
+		return guard, nil
+	}
+
+	var problem models.Problem
+	if err := s.db.Select("reference_solution").First(&problem, problemID).Error; err == nil && strings.TrimSpace(problem.ReferenceSolution) != "" {
+		guard.references = append(guard.references, problem.ReferenceSolution)
+	}
+
+	var records []models.UserProblem
+	err = s.db.Select("qwen_corrected_code", "deepseek_corrected_code").
+		Where("problem_id = ? AND (qwen_corrected_code <> '' OR deepseek_corrected_code <> '')", problemID).
+		Order("id DESC").
+		Limit(integrityReferenceLimit).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取参考代码失败: %v", err)
+	}
+	for _, record := range records {
+		for _, code := range []string{record.QwenCorrectedCode, record.DeepseekCorrected_code} {
+			if strings.TrimSpace(code) != "" {
+				guard.references = append(guard.references, code)
+			}
+		}
+	}
+	return guard, nil
+}
+
+// similarity 计算内容与参考代码的最高相似度
+func (g *integrityGuard) similarity(content string) float64 {
+	var best float64
+	for _, reference := range g.references {
+		if value := utils.CodeContainment(content, reference, ""); value > best {
+			best = value
+		}
+	}
+	return best
+}
+
+// check 检查回答是否违反代码策略，没有违反时返回 nil
+func (g *integrityGuard) check(answer string) *integrityCheck {
+	if g.policy == models.CodePolicyAllowCode {
+		return nil
+	}
+
+	threshold := config.LLM.IntegritySimilarity
+	result := &integrityCheck{}
+	for _, block := range utils.FindCodeBlocks(answer) {
+		similarity := g.similarity(block.Code)
+		if similarity > result.similarity {
+			result.similarity = similarity
+		}
+
+		switch {
+		case similarity >= threshold:
+			result.kind = models.ViolationSolutionCopy
+		case g.policy == models.CodePolicyNoCode || programmingLanguages[block.Language]:
+			if result.kind == "" {
+				result.kind = models.ViolationCodeBlock
+			}
+		default:
+			continue
+		}
+		result.blocks = append(result.blocks, block)
+	}
+
+	// 没有放在代码块中的代码按整个回答计算相似度
+	if similarity := g.similarity(answer); similarity >= threshold {
+		result.whole = true
+		result.kind = models.ViolationSolutionCopy
+		if similarity > result.similarity {
+			result.similarity = similarity
+		}
+	}
+
+	if len(result.blocks) == 0 && !result.whole {
+		return nil
+	}
+	return result
+}
+
+// redact 隐藏回答中违反策略的内容
+func (g *integrityGuard) redact(answer string, check *integrityCheck) string {
+	if check.whole {
+		return redactedAnswerNotice
+	}
+	for i := len(check.blocks) - 1; i >= 0; i-- {
+		block := check.blocks[i]
+		answer = answer[:block.Start] + redactedCodeNotice + answer[block.End:]
+	}
+	return answer
+}
+
+// guardedComplete 按代码策略在系统提示词中加入规则并调用模型。回答违反策略时要求模型重新回答一次，
+// 仍然违反时隐藏违规内容，两种情况都会记录违规供教师查看
+func (s *AIService) guardedComplete(ctx context.Context, guard *integrityGuard, provider *LLMProvider, messages []openai.ChatCompletionMessageParamUnion, question string) (string, error) {
+	if rule, ok := codePolicyRules[guard.policy]; ok {
+		messages = append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(rule)}, messages...)
+	}
+
+	answer, err := s.completeMessages(ctx, provider, messages)
+	if err != nil {
+		return "", err
+	}
+
+	violation := guard.check(answer)
+	if violation == nil {
+		return answer, nil
+	}
+
+	retry := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)+2)
+	retry = append(retry, messages...)
+	retry = append(retry, openai.AssistantMessage(answer), openai.UserMessage(codePolicyReminders[guard.policy]))
+
+	action := models.ViolationActionRegenerated
+	final, err := s.completeMessages(ctx, provider, retry)
+	if err != nil {
+		log.Printf("重新生成符合代码策略的回答失败，将隐藏违规内容: %v", err)
+		final = guard.redact(answer, violation)
+		action = models.ViolationActionRedacted
+	} else if check := guard.check(final); check != nil {
+		final = guard.redact(final, check)
+		action = models.ViolationActionRedacted
+	}
+
+	record := models.AIViolation{
+		UserID:     guard.userID,
+		CourseID:   guard.courseID,
+		ProblemID:  guard.problemID,
+		SessionID:  guard.sessionID,
+		Feature:    FeatureChat,
+		Provider:   provider.Name,
+		Policy:     guard.policy,
+		Kind:       violation.kind,
+		Similarity: violation.similarity,
+		Action:     action,
+		Question:   question,
+		Original:   answer,
+		Final:      final,
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Printf("记录代码策略违规失败: %v", err)
+	}
+
+	return final, nil
+}
+
+// ListAIViolations 分页获取课程内 AI 助教回答违反代码策略的记录
+func (s *CourseService) ListAIViolations(courseID uint, page, pageSize int) (map[string]interface{}, error) {
+	query := s.db.Model(&models.AIViolation{}).Where("course_id = ?", courseID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var violations []models.AIViolation
+	err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&violations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total": total,
+		"items": violations,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AILogFilter AI 调用日志的查询条件
+type AILogFilter struct {
+	UserID    uint
+	CourseID  uint
+	ProblemID uint
+	RecordID  uint
+	Feature   string
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	PageSize  int
+}
+
+// ListAILogs 分页查询 AI 调用日志，列表不含完整的提示词和模型输出，按时间倒序
+func (s *AIUsageService) ListAILogs(filter AILogFilter) (map[string]interface{}, error) {
+	query := s.db.Model(&models.AILog{})
+	if filter.UserID != 0 {
+		query = query.Where("ai_logs.user_id = ?", filter.UserID)
+	}
+	if filter.CourseID != 0 {
+		query = query.Where("ai_logs.course_id = ?", filter.CourseID)
+	}
+	if filter.ProblemID != 0 {
+		query = query.Where("ai_logs.problem_id = ?", filter.ProblemID)
+	}
+	if filter.RecordID != 0 {
+		query = query.Where("ai_logs.record_id = ?", filter.RecordID)
+	}
+	if filter.Feature != "" {
+		query = query.Where("ai_logs.feature = ?", filter.Feature)
+	}
+	if filter.From != nil {
+		query = query.Where("ai_logs.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("ai_logs.created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var items []struct {
+		models.AILog
+		Username     string `json:"username"`
+		ProblemTitle string `json:"problem_title"`
+	}
+	err := query.Select("ai_logs.id, ai_logs.usage_id, ai_logs.user_id, ai_logs.course_id, ai_logs.feature, " +
+		"ai_logs.problem_id, ai_logs.record_id, ai_logs.provider, ai_logs.model_name, ai_logs.prompt_tokens, " +
+		"ai_logs.completion_tokens, ai_logs.total_tokens, ai_logs.latency_ms, ai_logs.error, ai_logs.cache_id, ai_logs.created_at, " +
+		"users.username, problems.title AS problem_title").
+		Joins("LEFT JOIN users ON users.id = ai_logs.user_id").
+		Joins("LEFT JOIN problems ON problems.id = ai_logs.problem_id").
+		Order("ai_logs.id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total": total,
+		"items": items,
+	}, nil
+}
+
+// GetAILog 获取一次 AI 调用的完整记录，包括发送给模型的消息和模型的原始输出
+func (s *AIUsageService) GetAILog(id uint) (*models.AILog, error) {
+	var aiLog models.AILog
+	if err := s.db.First(&aiLog, id).Error; err != nil {
+		return nil, fmt.Errorf("调用日志不存在: %v", err)
+	}
+	return &aiLog, nil
+}
+
+// PurgeExpiredAILogs 删除超过保留天数的 AI 调用日志，由定时任务调用，AI_LOG_RETENTION_DAYS 为 0 时不删除
+func PurgeExpiredAILogs(db *gorm.DB) (int64, error) {
+	if config.LLM.LogRetentionDays <= 0 {
+		return 0, nil
+	}
+	before := time.Now().AddDate(0, 0, -config.LLM.LogRetentionDays)
+	result := db.Where("created_at < ?", before).Delete(&models.AILog{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,552 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// 提示词模板的 key，教师可以在数据库中覆盖同名模板，未覆盖时使用下方的内置模板
+const (
+	PromptHint           = "hint"
+	PromptHintNudge      = "hint_nudge"
+	PromptHintKeyIdea    = "hint_key_idea"
+	PromptHintSteps      = "hint_steps"
+	PromptHintPseudoCode = "hint_pseudo_code"
+	PromptCorrectCode    = "correct_code"
+	PromptAnalyzeCode    = "analyze_code"
+	PromptChat           = "chat"
+	PromptChatSession    = "chat_session"
+	PromptChatSummary    = "chat_summary"
+	PromptSuggestTags    = "suggest_tags"
+	PromptJudge          = "judge"
+	PromptDraftProblem   = "draft_problem"
+	PromptClassifyErrors = "classify_errors"
+	PromptMisconceptions = "misconceptions"
+	PromptLearningReport = "learning_report"
+	PromptSyllabusPlan   = "syllabus_plan"
+	PromptReviewCode     = "review_code"
+)
+
+// PromptData 渲染提示词模板时可用的变量，模板中以 {{.Title}} 的形式引用
+type PromptData struct {
+	Title           string   // 题目标题
+	Content         string   // 题目内容
+	SampleTestcases string   // 示例测试用例
+	TestCases       string   // 判题使用的测试用例
+	TimeLimit       int      // 时间限制（ms）
+	MemoryLimit     int      // 内存限制（MB）
+	Language        string   // 编程语言
+	Code            string   // 学生代码
+	Question        string   // 学生问题
+	PreviousHints   []string // 学生已经看过的低等级提示
+	KnowledgePoint  string   // 知识点名称
+	TagList         string   // 带序号的标签列表
+	Summary         string   // 已有的对话摘要
+	Transcript      string   // 需要压缩的对话内容
+	Difficulty      string   // 目标难度（Easy / Medium / Hard）
+	Constraints     string   // 教师对题目的额外要求
+	NumberedCode    string   // 带行号的学生代码
+	LineCount       int      // 学生代码的行数
+	Analysis        string   // 已有的错误分析
+	KnowledgePoints string   // 课程的知识点列表
+	ErrorCategory   string   // 错误类型
+	ClusterSize     int      // 聚类中的作答数量
+	Examples        string   // 代表性的学生作答
+	Course          string   // 课程名称
+	Stats           string   // 学生各知识点的做题统计
+	RecentFailures  string   // 学生近期的失败作答
+	HintUsage       string   // 学生近期的提示使用情况
+	Candidates      string   // 可以推荐的题目
+	Syllabus        string   // 课程大纲
+	Materials       string   // 检索到的带编号的课程资料片段
+}
+
+// newPromptData 以题目信息初始化模板变量
+func newPromptData(problem *models.Problem) PromptData {
+	return PromptData{
+		Title:           problem.Title,
+		Content:         problem.Content,
+		SampleTestcases: problem.SampleTestcases,
+		TestCases:       problem.TestCases,
+		TimeLimit:       problem.TimeLimit,
+		MemoryLimit:     problem.MemoryLimit,
+	}
+}
+
+type builtinPrompt struct {
+	Name    string
+	System  string
+	Content string
+}
+
+var promptFuncs = template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}
+
+// hintLevelPrompts 各提示等级对应的模板
+var hintLevelPrompts = map[int]string{
+	models.HintLevelNudge:      PromptHintNudge,
+	models.HintLevelKeyIdea:    PromptHintKeyIdea,
+	models.HintLevelSteps:      PromptHintSteps,
+	models.HintLevelPseudoCode: PromptHintPseudoCode,
+}
+
+const (
+	hintSystemPrompt = "你是一个大学算法课的老师，你需要通过语言引导学生做出正确的答案。"
+	chatSystemPrompt = "你是一个大学算法课的AI助教，你的任务是帮助学生理解算法题目，解答他们的疑问，并提供有教育意义的指导。"
+
+	// 分级提示共用的题目信息和已看过的提示
+	hintLevelHeader = `题目：{{.Title}}
+题目内容：{{.Content}}
+
+示例测试用例：
+{{.SampleTestcases}}
+
+学生已经看过的提示：
+{{range $i, $hint := .PreviousHints}}第{{inc $i}}级提示：{{$hint}}
+{{else}}无
+{{end}}
+`
+	hintLevelFooter = `
+
+请在已有提示的基础上更进一步，不要重复已有提示的内容。`
+)
+
+var builtinPrompts = map[string]builtinPrompt{
+	PromptHint: {
+		Name:   "解题思路",
+		System: hintSystemPrompt,
+		Content: `题目：{{.Title}}
+	题目内容：{{.Content}}
+
+	示例测试用例：
+	{{.SampleTestcases}}
+
+	请直接给出解题思路，要求：
+	1. 只描述解题的具体步骤
+	2. 不要包含任何引导语、过渡语或语气词
+	3. 不要分析时空复杂度
+	4. 不要给出代码示例`,
+	},
+	PromptHintNudge: {
+		Name:   "分级提示：方向提示",
+		System: hintSystemPrompt,
+		Content: hintLevelHeader + `请只给出一句简短的方向性提示，要求：
+1. 不超过两句话
+2. 不要说出具体的算法或数据结构名称
+3. 引导学生思考题目的关键约束或切入点` + hintLevelFooter,
+	},
+	PromptHintKeyIdea: {
+		Name:   "分级提示：关键思路",
+		System: hintSystemPrompt,
+		Content: hintLevelHeader + `请指出解决本题的关键思路，要求：
+1. 说明应当使用的核心算法或数据结构，以及为什么适用
+2. 不要给出完整的解题步骤
+3. 不要给出代码或伪代码` + hintLevelFooter,
+	},
+	PromptHintSteps: {
+		Name:   "分级提示：详细步骤",
+		System: hintSystemPrompt,
+		Content: hintLevelHeader + `请直接给出解题思路，要求：
+1. 只描述解题的具体步骤
+2. 不要包含任何引导语、过渡语或语气词
+3. 不要分析时空复杂度
+4. 不要给出代码示例` + hintLevelFooter,
+	},
+	PromptHintPseudoCode: {
+		Name:   "分级提示：伪代码",
+		System: hintSystemPrompt,
+		Content: hintLevelHeader + `请给出解决本题的伪代码，要求：
+1. 使用与具体编程语言无关的伪代码
+2. 在关键步骤旁用简短注释说明
+3. 不要给出任何可以直接运行的代码` + hintLevelFooter,
+	},
+	PromptCorrectCode: {
+		Name:   "代码纠错",
+		System: "你是一个专业的算法工程师，精通各种编程语言和算法。请生成最优时空复杂度的代码来解决问题。",
+		Content: `作为一个专业的算法工程师，请修改以下已有代码解答问题：
+
+题目：{{.Title}}
+编程语言：{{.Language}}
+题目内容：{{.Content}}
+当前已有代码：{{.Code}}
+
+示例测试用例：
+{{.SampleTestcases}}
+
+请生成符合要求的代码，并确保：
+1. 代码正确性
+2. 代码时空复杂度最优
+3. 代码可读性
+4. 尽量在已有代码的基础上进行修改，非必要情况下请勿大规模修改代码逻辑
+5. 请不要删除被修改的代码片段，而是将修改后的代码片段添加到被修改的代码片段下方，并添加注释说明修改原因，该注释需要以"AI Comment："作为前缀，注释中不要出现prompt相关的内容
+
+只需要返回修改后代码，不需要其他解释，不需要测试用例的示例，也不需要用markdown格式来返回代码，直接返回即可。`,
+	},
+	PromptAnalyzeCode: {
+		Name:   "错误代码分析",
+		System: "你是一个大学的算法课老师，请对同学们的错误代码片段和对应的题目进行分析。",
+		Content: `你是一个大学算法课的老师，请分析以下错误代码：
+
+题目：{{.Title}}
+编程语言：{{.Language}}
+题目内容：{{.Content}}
+当前已有代码：{{.Code}}
+
+示例测试用例：
+{{.SampleTestcases}}
+{{if .Materials}}
+课程资料（讲解知识点时请与资料保持一致，并在引用处用 [编号] 标注出处）：
+{{.Materials}}
+{{end}}
+请生成代码和题目分析，并确保分为两个点进行输出：
+第一点为指出代码的错误原因（指定标题为"错误分析"）、
+第二点为分析本题目所涉及的计算机领域的知识点（指定标题为"AI讲师分析"），
+注意，不要返回正确的代码示例，仅仅进行分析即可。
+
+同时，请一定确保你生成的响应格式如下（在花括号内填入具体的内容）：
+**错误分析**：
+{错误分析}
+
+**AI讲师分析**：
+{AI讲师分析}`,
+	},
+	PromptClassifyErrors: {
+		Name:   "错误类型归类",
+		System: "你是一个大学的算法课老师，负责把学生错误代码中的问题归类为固定的错误类型，只输出 JSON。",
+		Content: `请根据题目、学生代码和已有的错误分析，列出代码中的每一处错误。
+
+题目：{{.Title}}
+题目内容：{{.Content}}
+编程语言：{{.Language}}
+
+学生代码（每行开头为行号）：
+{{.NumberedCode}}
+
+已有的错误分析：
+{{if .Analysis}}{{.Analysis}}{{else}}无{{end}}
+
+课程知识点：
+{{if .KnowledgePoints}}{{.KnowledgePoints}}{{else}}无{{end}}
+
+错误类型只能是以下之一：
+- off_by_one：边界差一、循环下标越界
+- wrong_data_structure：数据结构选择不当
+- time_complexity：时间复杂度过高导致超时
+- edge_case_missing：遗漏边界或特殊情况
+- syntax：语法或编译错误
+- logic：算法思路或逻辑错误
+- other：其他错误
+
+要求：
+1. start_line 和 end_line 为错误所在的行号范围，错误不对应具体的行时都填 0
+2. knowledge_point 尽量从课程知识点中选择，没有合适的知识点时填写相关的计算机知识点
+3. description 用一句话说明错误
+4. 代码没有错误时返回空的 errors 数组
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "errors": [
+        {
+            "category": "错误类型",
+            "start_line": 起始行号,
+            "end_line": 结束行号,
+            "knowledge_point": "相关知识点",
+            "description": "错误说明"
+        }
+    ]
+}`,
+	},
+	PromptReviewCode: {
+		Name:   "代码审阅批注",
+		System: "你是一个大学的算法课老师，负责像代码审阅一样逐行批注学生的代码，只输出 JSON。",
+		Content: `请审阅学生针对以下题目编写的代码，针对具体的代码行给出批注。
+
+题目：{{.Title}}
+题目内容：{{.Content}}
+编程语言：{{.Language}}
+
+学生代码（每行开头为行号，共 {{.LineCount}} 行）：
+{{.NumberedCode}}
+
+要求：
+1. 每条批注只针对一段连续的代码行，start_line 和 end_line 为批注的行号范围，必须在 1 到 {{.LineCount}} 之间
+2. severity 只能是以下之一：
+   - error：会导致结果错误、运行错误或超时的问题
+   - warning：暂不影响结果，但存在隐患或不好的写法
+   - suggestion：可读性、命名、风格等改进建议
+3. message 用一两句话说明问题和原因
+4. suggestion 为替换 start_line 至 end_line 这几行的代码，不带行号，保持原有缩进；只需要说明而不需要修改代码时填空字符串
+5. 不要修改批注范围以外的代码，也不要给出完整的解答
+6. 代码没有问题时返回空的 comments 数组
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "comments": [
+        {
+            "start_line": 起始行号,
+            "end_line": 结束行号,
+            "severity": "严重程度",
+            "message": "批注内容",
+            "suggestion": "替换的代码"
+        }
+    ]
+}`,
+	},
+	PromptMisconceptions: {
+		Name:   "共性误区总结",
+		System: "你是一个大学的算法课老师，擅长从多名学生的错误代码中总结共性的误区，并给出教学建议，只输出 JSON。",
+		Content: `以下是最近一段时间内 {{.ClusterSize}} 次失败作答中的代表性作答，这些作答属于同一个知识点，错误类型相同，代码相似。
+
+知识点：{{.KnowledgePoint}}
+错误类型：{{if .ErrorCategory}}{{.ErrorCategory}}{{else}}未归类{{end}}
+
+代表性作答：
+{{.Examples}}
+
+请总结这些学生共同的误区：
+1. title 用一句话概括误区，不超过 30 个字
+2. summary 说明学生错在哪里、为什么会这样想，可以引用代表性作答中的代码片段
+3. suggestion 给出教师在课堂上纠正这个误区的具体建议
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "title": "误区概括",
+    "summary": "误区说明",
+    "suggestion": "教学建议"
+}`,
+	},
+	PromptLearningReport: {
+		Name:   "个性化学习报告",
+		System: "你是一个大学算法课的老师，根据学生的做题数据撰写个性化的学习报告，语气亲切、具体，只输出 JSON。",
+		Content: `请根据学生在课程「{{.Course}}」中的学习数据生成学习报告。
+
+各知识点的做题情况：
+{{.Stats}}
+
+近期的失败作答：
+{{if .RecentFailures}}{{.RecentFailures}}{{else}}无{{end}}
+
+近期的提示使用情况：
+{{if .HintUsage}}{{.HintUsage}}{{else}}无{{end}}
+
+可以推荐练习的题目（格式为 题目ID. 标题 [难度] 知识点）：
+{{.Candidates}}
+
+要求：
+1. summary 用两三句话总结学生近期的学习情况
+2. strengths 列出学生掌握较好的方面，要有数据支撑
+3. weak_spots 列出薄弱的知识点及原因，原因需结合失败作答和提示使用情况
+4. practice_plan 推荐 3 到 5 道题目，problem_id 只能从上面可以推荐的题目中选择，优先针对薄弱知识点，并按建议的练习顺序排列
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "summary": "学习情况总结",
+    "strengths": ["掌握较好的方面"],
+    "weak_spots": [
+        {"knowledge_point": "知识点名称", "reason": "薄弱的原因"}
+    ],
+    "practice_plan": [
+        {"problem_id": 题目ID, "reason": "推荐理由"}
+    ]
+}`,
+	},
+	PromptSyllabusPlan: {
+		Name:   "课程大纲生成知识点",
+		System: "你是一个大学算法课的老师，擅长根据课程大纲拆分知识点并关联练习题标签，只输出 JSON。",
+		Content: `请根据课程「{{.Course}}」的大纲拆分出适合布置编程练习的知识点，并为每个知识点从已有标签中选择相关的标签。
+
+课程大纲：
+{{.Syllabus}}
+{{if .KnowledgePoints}}
+课程已有的知识点（不要重复生成）：
+{{.KnowledgePoints}}
+{{end}}
+已有标签列表：
+{{.TagList}}
+
+要求：
+1. 知识点按教学顺序排列，粒度适中，每个知识点能对应一组练习题
+2. 知识点名称简洁，不超过 20 个字，同一课程中不能重复
+3. description 用一句话说明知识点的教学内容
+4. tags 填写相关标签的序号，只能从已有标签列表中选择，没有合适的标签时为空数组
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "knowledge_points": [
+        {"name": "知识点名称", "description": "教学内容", "tags": [标签序号]}
+    ]
+}`,
+	},
+	PromptChat: {
+		Name:   "AI 助教问答",
+		System: chatSystemPrompt,
+		Content: `你是一个大学算法课的AI助教，请基于以下题目信息，回答学生的问题：
+
+题目：{{.Title}}
+题目内容：{{.Content}}
+示例测试用例：
+{{.SampleTestcases}}
+
+学生问题：{{.Question}}
+学生当前代码：{{.Code}}
+{{if .Materials}}
+课程资料（回答时请与资料的讲法保持一致，并在引用处用 [编号] 标注出处）：
+{{.Materials}}
+{{end}}
+请提供专业、准确、有教育意义的回答，帮助学生理解题目和相关知识点。`,
+	},
+	PromptChatSession: {
+		Name:   "AI 助教多轮对话",
+		System: chatSystemPrompt,
+		Content: `你正在与学生讨论以下题目，请结合之前的对话回答学生的问题：
+
+题目：{{.Title}}
+题目内容：{{.Content}}
+示例测试用例：
+{{.SampleTestcases}}
+
+请提供专业、准确、有教育意义的回答，帮助学生理解题目和相关知识点。`,
+	},
+	PromptChatSummary: {
+		Name:   "对话摘要",
+		System: "你是一个对话记录整理助手，需要将算法课AI助教与学生的对话压缩为简洁的摘要。",
+		Content: `请将以下对话内容整理为不超过300字的摘要，保留学生的主要疑问、已经给出的关键提示和学生代码中存在的问题，只返回摘要内容。
+
+已有摘要：
+{{.Summary}}
+
+新增对话：
+{{.Transcript}}`,
+	},
+	PromptSuggestTags: {
+		Name:   "知识点标签推荐",
+		System: "你是一个专业的计算机教育领域AI助手，精通计算机课程知识点的分类和标签关联。",
+		Content: `作为一个专业的计算机教育领域AI助手，请从以下已有标签中为知识点内容选择最相关的标签：
+
+知识点：{{.KnowledgePoint}}
+
+已有标签列表：
+{{.TagList}}
+
+请从上述已有标签中选择3-5个最相关的标签。请仅返回标签的序号（每行一个数字），例如：
+1
+3
+5
+
+注意：
+1. 只能从已有标签中选择
+2. 选择最能反映知识点核心内容的标签
+3. 确保选择的标签数量在3-5个之间`,
+	},
+	PromptDraftProblem: {
+		Name:   "AI 出题",
+		System: "你是一个经验丰富的大学算法课出题老师，擅长围绕指定知识点设计原创的编程题，并给出正确的参考解答和测试用例。",
+		Content: `请围绕以下知识点设计一道原创的编程题：
+
+知识点：{{.KnowledgePoint}}
+已有标签：
+{{.TagList}}
+目标难度：{{.Difficulty}}
+参考解答使用的编程语言：{{.Language}}
+教师的额外要求：{{if .Constraints}}{{.Constraints}}{{else}}无{{end}}
+
+要求：
+1. 题目必须考察上述知识点，难度符合目标难度，不要照搬 LeetCode 等平台上的已有题目
+2. 同时给出中文和英文的题目标题和题面，题面包含输入输出格式、数据范围和至少一个示例
+3. 示例测试用例与判题测试用例的格式相同：每组用例的输入按参数顺序每行一个
+4. 判题测试用例至少 5 组，需要覆盖边界情况
+5. 参考解答必须正确，并满足数据范围下的时间限制
+
+请确保严格按照以下JSON格式返回，不要出现任何其他信息：
+{
+    "title": "英文标题",
+    "title_cn": "中文标题",
+    "content": "英文题面",
+    "content_cn": "中文题面",
+    "sample_testcases": "示例测试用例输入",
+    "test_cases": "判题测试用例输入",
+    "reference_solution": "参考解答代码",
+    "time_limit": 时间限制(ms),
+    "memory_limit": 内存限制(MB)
+}`,
+	},
+	PromptJudge: {
+		Name:   "AI 判题",
+		System: "你是一个专业的编程题目评测系统，你需要严格按照题目要求对代码进行评测，并返回规范的评测结果。",
+		Content: `作为一个专业的编程题目评测系统，请对以下代码进行评测：
+
+题目信息：
+{{.Content}}
+
+提交的代码：
+{{.Code}}
+
+测试用例：
+{{.TestCases}}
+
+判题要求：
+1. 时间限制：{{.TimeLimit}}ms
+2. 内存限制：{{.MemoryLimit}}MB
+3. 编程语言：{{.Language}}
+
+请确保严格按照以下JSON格式返回判题结果，不要出现任何其他信息，不需要解释说明，只返回以下格式的JSON即可：
+{
+    "status": "判题状态(SUCCESS/FAILED/Time Limit Exceeded/Memory Limit Exceeded/Runtime Error)",
+    "time_used": 实际运行时间(ms),
+    "memory_used": 实际使用内存(MB),
+    "test_results": [
+        {
+            "input": "测试用例输入",
+            "expected_output": "期望输出",
+            "actual_output": "实际输出",
+            "status": "用例状态",
+            "message": "错误信息（如果有）"
+        }
+    ]
+}
+
+注意：
+1. 必须验证代码的正确性
+2. 必须检查时间和内存限制
+3. 必须测试所有测试用例
+4. 必须按照规定格式返回结果
+5. 对于每个测试用例，都要实际运行代码并比较结果`,
+	},
+}
+
+// executePrompt 渲染系统提示词和用户提示词模板
+func executePrompt(system, content string, data PromptData) (string, string, error) {
+	renderedSystem, err := executeTemplate("system", system, data)
+	if err != nil {
+		return "", "", err
+	}
+	renderedContent, err := executeTemplate("content", content, data)
+	if err != nil {
+		return "", "", err
+	}
+	return renderedSystem, renderedContent, nil
+}
+
+func executeTemplate(name, text string, data PromptData) (string, error) {
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析模板失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染模板失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// chatSessionQuestion 多轮对话中学生的一条提问
+func chatSessionQuestion(question, typedCode string) string {
+	if typedCode == "" {
+		return question
+	}
+	return fmt.Sprintf("%s\n\n学生当前代码：\n%s", question, typedCode)
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 可以被评价的 AI 功能
+var ratableFeatures = map[string]bool{
+	FeatureHint:        true,
+	FeatureChat:        true,
+	FeatureCorrectCode: true,
+	FeatureAnalyzeCode: true,
+	FeatureReviewCode:  true,
+}
+
+// ErrInvalidRating 评价对应的 AI 输出不存在或参数不完整
+var ErrInvalidRating = errors.New("无效的评价")
+
+type AIRatingService struct {
+	db *gorm.DB
+}
+
+func NewAIRatingService(db *gorm.DB) *AIRatingService {
+	return &AIRatingService{db: db}
+}
+
+// RatingInput 学生提交的评价。评价分级提示或多轮对话时，模型、提示词版本和课程从提示记录 / 对话消息中获取；
+// 其余功能通过作答记录和 AI 接口返回的 model 确定评价的是哪个模型的输出，提示词版本取自作答记录中保存的该输出。
+// 每条评价必须能对应到一次具体的输出，因此其余功能只能评价有作答记录的输出，单次 AI 助教问答需要改用多轮对话评价
+type RatingInput struct {
+	Feature       string
+	Provider      string
+	RecordID      uint
+	HintRecordID  uint
+	ChatMessageID uint
+	Helpful       bool
+	Reason        string
+}
+
+// Rate 保存学生对 AI 输出的评价，同一输出重复评价时更新之前的评价。参数无效时返回的错误包装了 ErrInvalidRating
+func (s *AIRatingService) Rate(userID uint, input RatingInput) (*models.AIRating, error) {
+	rating := models.AIRating{
+		UserID:        userID,
+		Feature:       input.Feature,
+		Provider:      input.Provider,
+		RecordID:      input.RecordID,
+		HintRecordID:  input.HintRecordID,
+		ChatMessageID: input.ChatMessageID,
+		Helpful:       input.Helpful,
+		Reason:        input.Reason,
+	}
+
+	switch {
+	case input.HintRecordID != 0:
+		var hint models.HintRecord
+		if err := s.db.Where("id = ? AND user_id = ?", input.HintRecordID, userID).First(&hint).Error; err != nil {
+			return nil, fmt.Errorf("%w: 提示记录不存在: %v", ErrInvalidRating, err)
+		}
+		rating.Feature = FeatureHint
+		rating.Provider = hint.Provider
+		rating.TemplateID = hint.TemplateID
+		rating.PromptVersion = hint.PromptVersion
+		rating.RecordID = hint.UserProblemID
+		s.db.Model(&models.KnowledgePoint{}).Select("course_id").Where("id = ?", hint.KnowledgePointID).Scan(&rating.CourseID)
+	case input.ChatMessageID != 0:
+		var message models.ChatMessage
+		err := s.db.Joins("JOIN chat_sessions ON chat_sessions.id = chat_messages.session_id").
+			Where("chat_messages.id = ? AND chat_sessions.user_id = ? AND chat_messages.role = ?", input.ChatMessageID, userID, models.ChatRoleAssistant).
+			First(&message).Error
+		if err != nil {
+			return nil, fmt.Errorf("%w: 对话消息不存在: %v", ErrInvalidRating, err)
+		}
+		var session models.ChatSession
+		if err := s.db.First(&session, message.SessionID).Error; err != nil {
+			return nil, fmt.Errorf("%w: 会话不存在: %v", ErrInvalidRating, err)
+		}
+		// 与 AI 助教一致，按会话的题目和学生班级确定课程
+		courseID, _, err := courseOfProblem(s.db, userID, session.ProblemID)
+		if err != nil {
+			return nil, err
+		}
+		rating.CourseID = courseID
+		rating.Feature = FeatureChat
+		rating.Provider = session.Provider
+		rating.TemplateID = message.TemplateID
+		rating.PromptVersion = message.PromptVersion
+	default:
+		if !ratableFeatures[input.Feature] || input.Feature == FeatureHint || input.Feature == FeatureChat {
+			return nil, fmt.Errorf("%w: 不支持按功能评价 %s，分级提示请指定 hint_record_id，AI 助教请指定 chat_message_id", ErrInvalidRating, input.Feature)
+		}
+		if input.Provider == "" {
+			return nil, fmt.Errorf("%w: 请指定评价的模型", ErrInvalidRating)
+		}
+		if input.RecordID == 0 {
+			return nil, fmt.Errorf("%w: 请指定评价的作答记录", ErrInvalidRating)
+		}
+		var record models.UserProblem
+		if err := s.db.Where("id = ? AND user_id = ?", input.RecordID, userID).First(&record).Error; err != nil {
+			return nil, fmt.Errorf("%w: 作答记录不存在: %v", ErrInvalidRating, err)
+		}
+		output, err := s.storedOutput(&record, input.Feature, input.Provider)
+		if err != nil {
+			return nil, err
+		}
+		rating.TemplateID = output.TemplateID
+		rating.PromptVersion = output.PromptVersion
+
+		// 与 AI 接口一致，优先使用作答记录所属的课程，否则按题目和学生班级确定
+		s.db.Model(&models.KnowledgePoint{}).Select("course_id").Where("id = ?", record.KnowledgePointID).Scan(&rating.CourseID)
+		if rating.CourseID == 0 {
+			rating.CourseID, _, err = courseOfProblem(s.db, userID, record.ProblemID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if rating.CourseID != 0 {
+		if err := s.db.First(&models.Course{}, rating.CourseID).Error; err != nil {
+			return nil, fmt.Errorf("课程不存在: %v", err)
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.AIRating
+		err := tx.Where(&models.AIRating{
+			UserID:        userID,
+			Feature:       rating.Feature,
+			Provider:      rating.Provider,
+			RecordID:      rating.RecordID,
+			HintRecordID:  rating.HintRecordID,
+			ChatMessageID: rating.ChatMessageID,
+		}, "user_id", "feature", "provider", "record_id", "hint_record_id", "chat_message_id").First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			rating.ID = existing.ID
+			rating.CreatedAt = existing.CreatedAt
+		}
+		return tx.Save(&rating).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存评价失败: %v", err)
+	}
+	return &rating, nil
+}
+
+// recordOutput 作答记录上保存的一个模型输出的来源
+type recordOutput struct {
+	Provider      string
+	TemplateID    uint
+	PromptVersion int
+}
+
+// storedOutput 查找作答记录上由 provider 生成的输出，评价使用生成该输出时的提示词。
+// 修正代码和错误分析保存在作答记录的字段中，代码审阅的批注保存在审阅批注中
+func (s *AIRatingService) storedOutput(record *models.UserProblem, feature, provider string) (*recordOutput, error) {
+	var outputs []recordOutput
+	switch feature {
+	case FeatureCorrectCode:
+		outputs = []recordOutput{
+			{record.QwenCorrectedCodeModel, record.QwenCorrectedCodeTemplateID, record.QwenCorrectedCodePromptVersion},
+			{record.DeepseekCorrectedCodeModel, record.DeepseekCorrectedCodeTemplateID, record.DeepseekCorrectedCodePromptVersion},
+		}
+	case FeatureAnalyzeCode:
+		outputs = []recordOutput{
+			{record.QwenWrongReasonAndAnalyzeModel, record.QwenWrongReasonAndAnalyzeTemplateID, record.QwenWrongReasonAndAnalyzePromptVersion},
+			{record.DeepseekWrongReasonAndAnalyzeModel, record.DeepseekWrongReasonAndAnalyzeTemplateID, record.DeepseekWrongReasonAndAnalyzePromptVersion},
+		}
+	case FeatureReviewCode:
+		var comment models.ReviewComment
+		err := s.db.Where("record_id = ? AND provider = ?", record.ID, provider).First(&comment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: 作答记录没有模型 %s 的审阅批注", ErrInvalidRating, provider)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &recordOutput{comment.Provider, comment.TemplateID, comment.PromptVersion}, nil
+	}
+
+	for i := range outputs {
+		if outputs[i].Provider == provider {
+			return &outputs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 作答记录没有模型 %s 生成的 %s 结果", ErrInvalidRating, provider, feature)
+}
+
+// RatingFilter 评价列表和报表的过滤条件
+type RatingFilter struct {
+	CourseID uint
+	Feature  string
+	Provider string
+	Helpful  *bool
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	PageSize int
+}
+
+func (s *AIRatingService) filterQuery(filter RatingFilter) *gorm.DB {
+	query := s.db.Model(&models.AIRating{}).Where("course_id = ?", filter.CourseID)
+	if filter.Feature != "" {
+		query = query.Where("feature = ?", filter.Feature)
+	}
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+	if filter.Helpful != nil {
+		query = query.Where("helpful = ?", *filter.Helpful)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	return query
+}
+
+// Report 按功能、模型和提示词版本统计课程内的评价，helpful_rate 为认为有帮助的百分比
+func (s *AIRatingService) Report(filter RatingFilter) ([]map[string]interface{}, error) {
+	var rows []struct {
+		Feature       string
+		Provider      string
+		TemplateID    uint
+		PromptVersion int
+		Total         int64
+		Helpful       int64
+	}
+	err := s.filterQuery(filter).
+		Select("feature, provider, template_id, prompt_version, COUNT(*) AS total, SUM(CASE WHEN helpful THEN 1 ELSE 0 END) AS helpful").
+		Group("feature, provider, template_id, prompt_version").
+		Order("feature, provider, template_id, prompt_version").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		var rate float64
+		if row.Total > 0 {
+			rate = float64(row.Helpful) / float64(row.Total) * 100
+		}
+		result = append(result, map[string]interface{}{
+			"feature":        row.Feature,
+			"provider":       row.Provider,
+			"template_id":    row.TemplateID,
+			"prompt_version": row.PromptVersion,
+			"total":          row.Total,
+			"helpful":        row.Helpful,
+			"not_helpful":    row.Total - row.Helpful,
+			"helpful_rate":   rate,
+		})
+	}
+	return result, nil
+}
+
+// ListRatings 分页获取课程内的评价，用于查看学生填写的理由
+func (s *AIRatingService) ListRatings(filter RatingFilter) (map[string]interface{}, error) {
+	query := s.filterQuery(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var ratings []models.AIRating
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&ratings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total": total,
+		"items": ratings,
+	}, nil
+}
@@ -1,26 +1,47 @@
 package services
 
 import (
-	"ai_teach_system/constants"
 	"ai_teach_system/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log"
 	"strings"
 
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"gorm.io/gorm"
 )
 
 type AIServiceInterface interface {
-	GenerateHint(title, content, sampleTestCases, modelType string) (string, error)
-	CorrectCode(recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error)
-	AnalyzeCode(recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error)
-	Chat(problemID uint, typedCode, question, modelType string) (string, error)
-	SuggestKnowledgePointTags(knowledgePointID uint) ([]models.Tag, error)
-	JudgeCode(problemID uint, lang, code string, test bool) (map[string]interface{}, error)
+	GenerateHint(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string) (string, error)
+	CorrectCode(ctx context.Context, recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error)
+	ReviewCode(ctx context.Context, userID, recordID, problemID uint, lang, typedCode, modelType string) (map[string]interface{}, error)
+	GetReviewComments(userID, recordID uint) ([]models.ReviewComment, error)
+	GetAutoAnalysis(userID, recordID uint) (*models.AutoAnalysis, error)
+	WaitAutoAnalysis(ctx context.Context, userID, recordID uint, onEvent func(event string, data map[string]interface{})) error
+	AnalyzeCode(ctx context.Context, recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error)
+	Chat(ctx context.Context, problemID uint, typedCode, question, modelType string) (string, []MaterialCitation, error)
+	SuggestKnowledgePointTags(ctx context.Context, knowledgePointID uint) ([]models.Tag, error)
+	DraftProblem(ctx context.Context, creatorID, knowledgePointID uint, difficulty models.ProblemDifficulty, constraints, language, modelType string) (*models.ProblemDraft, error)
+	JudgeCode(ctx context.Context, problemID uint, lang, code string, test bool) (map[string]interface{}, error)
+	ListModels() []map[string]interface{}
+	GenerateHintStream(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string, onEvent func(event string, data map[string]interface{})) error
+	ChatStream(ctx context.Context, problemID uint, typedCode, question, modelType string, onEvent func(event string, data map[string]interface{})) error
+	AnalyzeCodeStream(ctx context.Context, recordID, problemID uint, language, typedCode string, onEvent func(event string, data map[string]interface{})) error
+	StartChatSession(ctx context.Context, userID, problemID uint, question, typedCode, modelType string) (*models.ChatSession, error)
+	ContinueChatSession(ctx context.Context, userID, sessionID uint, question, typedCode string) ([]models.ChatMessage, error)
+	ListChatSessions(userID, problemID uint) ([]models.ChatSession, error)
+	GetChatSession(userID, sessionID uint) (*models.ChatSession, error)
+	DeleteChatSession(userID, sessionID uint) error
+	GetHints(userID, problemID, knowledgePointID uint) (map[string]interface{}, error)
+	RequestHint(ctx context.Context, userID, problemID, knowledgePointID uint, level int, modelType string) (*models.HintRecord, error)
+	CacheStats() ([]map[string]interface{}, error)
+	PurgeCache(feature string, problemID uint) (int64, error)
+	GenerateLearningReport(ctx context.Context, userID, courseID uint, trigger string) (*models.LearningReport, error)
+	ListLearningReports(userID, courseID uint) ([]models.LearningReport, error)
+	GetLearningReport(userID, courseID, reportID uint) (*models.LearningReport, error)
+	DraftSyllabusPlan(ctx context.Context, creatorID, courseID uint, courseName, syllabus string) (*models.SyllabusPlan, error)
 }
 
 // JudgeResult 定义判题结果的结构
@@ -38,277 +59,333 @@ type JudgeResult struct {
 }
 
 type AIService struct {
-	clientDeepseek *openai.Client
-	clientQwen     *openai.Client
-	db             *gorm.DB
+	llm      *LLMRegistry
+	db       *gorm.DB
+	embedder Embedder
 }
 
 func NewAIService(db *gorm.DB) *AIService {
-	clientDeepseek := openai.NewClient(
-		option.WithAPIKey(os.Getenv("DEEPSEEK_API_KEY")),
-		option.WithBaseURL(constants.DeepseekHost),
-	)
-
-	clientQwen := openai.NewClient(
-		option.WithAPIKey(os.Getenv("QWEN_API_KEY")),
-		option.WithBaseURL(constants.QwenHost),
-	)
-
 	return &AIService{
-		clientDeepseek: clientDeepseek,
-		clientQwen:     clientQwen,
-		db:             db,
+		llm:      NewLLMRegistry(),
+		db:       db,
+		embedder: NewEmbedder(),
 	}
 }
 
-func (s *AIService) GenerateHint(title, content, sampleTestCases, modelType string) (string, error) {
-	var client *openai.Client
-	if modelType == "qwen" {
-		client = s.clientQwen
-	} else {
-		client = s.clientDeepseek
+// ListModels 返回已配置的模型列表
+func (s *AIService) ListModels() []map[string]interface{} {
+	return s.llm.List()
+}
+
+// complete 调用模型完成一次对话，并返回第一条回复内容
+func (s *AIService) complete(ctx context.Context, provider *LLMProvider, systemPrompt, prompt string) (string, error) {
+	return s.completeMessages(ctx, provider, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemPrompt),
+		openai.UserMessage(prompt),
+	})
+}
+
+// completeMessages 以完整的消息列表调用模型，用于多轮对话
+func (s *AIService) completeMessages(ctx context.Context, provider *LLMProvider, messages []openai.ChatCompletionMessageParamUnion) (string, error) {
+	call, err := s.beginAICall(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	var model string
-	if modelType == "qwen" {
-		model = "qwen2.5-14b-instruct-1m"
-	} else {
-		model = "deepseek-chat"
+	ctx, cancel := provider.withTimeout(ctx)
+	defer cancel()
+
+	completion, err := provider.Client.Chat.Completions.New(ctx, provider.params(messages))
+	if err != nil {
+		err = fmt.Errorf("%s AI service error: %v", provider.Name, err)
+		s.finishAICall(call, provider, messages, openai.CompletionUsage{}, "", err)
+		return "", err
 	}
 
-	prompt := fmt.Sprintf(`题目：%s
-	题目内容：%s
+	if len(completion.Choices) == 0 {
+		err = fmt.Errorf("no response from %s AI service", provider.Name)
+		s.finishAICall(call, provider, messages, completion.Usage, "", err)
+		return "", err
+	}
 
-	示例测试用例：
-	%v
+	content := completion.Choices[0].Message.Content
+	s.finishAICall(call, provider, messages, completion.Usage, content, nil)
+	return content, nil
+}
 
-	请直接给出解题思路，要求：
-	1. 只描述解题的具体步骤
-	2. 不要包含任何引导语、过渡语或语气词
-	3. 不要分析时空复杂度
-	4. 不要给出代码示例`, title, content, sampleTestCases)
+// prompt 渲染 AI 功能使用的提示词模板，courseID 为 0 时只使用全局模板
+func (s *AIService) prompt(key string, courseID uint, data PromptData) (*renderedPrompt, error) {
+	return renderPrompt(s.db, key, courseID, data)
+}
 
-	completion, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个大学算法课的老师，你需要通过语言引导学生做出正确的答案。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F(model),
-	})
+// courseOfKnowledgePoint 获取知识点所属的课程，查询失败时返回 0
+func (s *AIService) courseOfKnowledgePoint(knowledgePointID uint) uint {
+	var courseID uint
+	s.db.Model(&models.KnowledgePoint{}).Select("course_id").Where("id = ?", knowledgePointID).Scan(&courseID)
+	return courseID
+}
+
+// courseOfRecord 获取作答记录所属的课程，查询失败时返回 0
+func (s *AIService) courseOfRecord(recordID uint) uint {
+	if recordID == 0 {
+		return 0
+	}
+	var courseID uint
+	s.db.Model(&models.UserProblem{}).
+		Select("knowledge_points.course_id").
+		Joins("JOIN knowledge_points ON user_problems.knowledge_point_id = knowledge_points.id").
+		Where("user_problems.id = ?", recordID).
+		Scan(&courseID)
+	return courseID
+}
+
+// recordForAI 加载 AI 功能针对的作答记录，recordID 为 0 时返回 nil。有发起调用的用户时只能使用该用户的作答记录，
+// 且作答记录必须属于请求的题目
+func (s *AIService) recordForAI(ctx context.Context, recordID, problemID uint) (*models.UserProblem, error) {
+	if recordID == 0 {
+		return nil, nil
+	}
+	query := s.db.Where("id = ?", recordID)
+	if userID := aiUserFrom(ctx); userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var record models.UserProblem
+	if err := query.First(&record).Error; err != nil {
+		return nil, fmt.Errorf("作答记录不存在: %w", err)
+	}
+	if record.ProblemID != problemID {
+		return nil, errors.New("作答记录与题目不匹配")
+	}
+	return &record, nil
+}
+
+// recordCode 返回 AI 功能处理的代码和语言。指定了作答记录时使用作答记录提交的代码，
+// 保存到作答记录上的结果必须对应其中的代码
+func recordCode(record *models.UserProblem, language, typedCode string) (string, string, error) {
+	if record != nil {
+		typedCode = record.TypedCode
+		if record.Language != "" {
+			language = record.Language
+		}
+	}
+	if strings.TrimSpace(typedCode) == "" {
+		return "", "", errors.New("没有需要处理的代码")
+	}
+	return language, typedCode, nil
+}
 
+// courseForAI 获取 AI 调用计入的课程：优先使用作答记录所属的课程，没有作答记录或作答记录不属于任何课程时，
+// 按题目和学生班级确定，保证课程额度和课程提示词始终生效
+func (s *AIService) courseForAI(ctx context.Context, recordID, problemID uint) (uint, error) {
+	if courseID := s.courseOfRecord(recordID); courseID != 0 {
+		return courseID, nil
+	}
+	courseID, _, err := courseOfProblem(s.db, aiUserFrom(ctx), problemID)
+	return courseID, err
+}
+
+// GenerateHint 根据题面生成参考代码，problemID 非 0 时按题目和学生班级确定所属课程
+func (s *AIService) GenerateHint(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string) (string, error) {
+	provider, err := s.llm.Resolve(FeatureHint, modelType)
 	if err != nil {
-		return "", fmt.Errorf("AI service error: %v", err)
+		return "", err
 	}
 
-	if len(completion.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI service")
+	courseID, _, err := courseOfProblem(s.db, aiUserFrom(ctx), problemID)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := s.prompt(PromptHint, courseID, PromptData{Title: title, Content: content, SampleTestcases: sampleTestCases})
+	if err != nil {
+		return "", err
 	}
 
-	return completion.Choices[0].Message.Content, nil
+	ctx = withAIScope(ctx, FeatureHint, courseID)
+	ctx = withAITarget(ctx, problemID, 0)
+	return s.complete(ctx, provider, prompt.System, prompt.User)
 }
 
-func (s *AIService) CorrectCode(recordID, problemID uint, language, typedCode string) (map[string]interface{}, error) {
+func (s *AIService) CorrectCode(ctx context.Context, recordID, problemID uint, language, typedCode string) (map[string]interface{}, error) {
 	var problem models.Problem
 	err := s.db.Model(&models.Problem{}).First(&problem, problemID).Error
 	if err != nil {
 		return nil, err
 	}
 
-	prompt := fmt.Sprintf(`作为一个专业的算法工程师，请修改以下已有代码解答问题：
-
-题目：%s
-编程语言：%s
-题目内容：%s
-当前已有代码：%s
-
-示例测试用例：
-%v
-
-请生成符合要求的代码，并确保：
-1. 代码正确性
-2. 代码时空复杂度最优
-3. 代码可读性
-4. 尽量在已有代码的基础上进行修改，非必要情况下请勿大规模修改代码逻辑
-5. 请不要删除被修改的代码片段，而是将修改后的代码片段添加到被修改的代码片段下方，并添加注释说明修改原因，该注释需要以"AI Comment："作为前缀，注释中不要出现prompt相关的内容
-
-只需要返回修改后代码，不需要其他解释，不需要测试用例的示例，也不需要用markdown格式来返回代码，直接返回即可。`, problem.Title, language, problem.Content, typedCode, problem.SampleTestcases)
-	completionQwen, err := s.clientQwen.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个专业的算法工程师，精通各种编程语言和算法。请生成最优时空复杂度的代码来解决问题。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("qwen2.5-14b-instruct-1m"),
-	})
-
+	record, err := s.recordForAI(ctx, recordID, problemID)
 	if err != nil {
-		return nil, fmt.Errorf("qwen AI service error: %v", err)
+		return nil, err
 	}
-
-	if len(completionQwen.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Qwen AI service")
+	language, typedCode, err = recordCode(record, language, typedCode)
+	if err != nil {
+		return nil, err
 	}
 
-	completionDeepseek, err := s.clientDeepseek.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个专业的算法工程师，精通各种编程语言和算法。请生成最优时空复杂度的代码来解决问题。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("deepseek-chat"),
-	})
+	data := newPromptData(&problem)
+	data.Language = language
+	data.Code = typedCode
+	courseID, err := s.courseForAI(ctx, recordID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.prompt(PromptCorrectCode, courseID, data)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureCorrectCode, courseID)
+	ctx = withAITarget(ctx, problemID, recordID)
 
+	providers, err := s.llm.ForFeature(FeatureCorrectCode)
 	if err != nil {
-		return nil, fmt.Errorf("deepseek AI service error: %v", err)
+		return nil, err
+	}
+	if len(providers) < 2 {
+		return nil, fmt.Errorf("功能 %s 需要配置两个模型", FeatureCorrectCode)
 	}
 
-	if len(completionQwen.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Deepseek AI service")
+	// 结果字段沿用原有的 qwen_* / deepseek_* 命名，分别对应功能配置的第一个和第二个模型，实际模型名称保存在 *_model 字段
+	results, err := s.compareModels(ctx, FeatureCorrectCode, providers[:2], prompt, problemID, language, typedCode)
+	if err != nil {
+		return nil, err
 	}
 
 	if recordID != 0 {
-		err = s.db.Model(&models.UserProblem{}).Where("id = ?", recordID).Updates(map[string]interface{}{
-			"deepseek_corrected_code": completionDeepseek.Choices[0].Message.Content,
-			"qwen_corrected_code":     completionQwen.Choices[0].Message.Content,
-		}).Error
+		err = s.saveModelResults(recordID, results, prompt, "qwen_corrected_code", "deepseek_corrected_code")
 		if err != nil {
 			return nil, fmt.Errorf("set corrected_code error: %v", err)
 		}
 	}
 
 	return map[string]interface{}{
-		"deepseek_corrected_code": completionDeepseek.Choices[0].Message.Content,
-		"qwen_corrected_code":     completionQwen.Choices[0].Message.Content,
+		"qwen_corrected_code":     results[0].Content,
+		"deepseek_corrected_code": results[1].Content,
+		"models":                  []string{providers[0].Name, providers[1].Name},
+		"results":                 results,
+		"template_id":             prompt.TemplateID,
+		"prompt_version":          prompt.Version,
 	}, nil
 }
 
-func (s *AIService) AnalyzeCode(recordID, problemID uint, language, typedCode string) (map[string]interface{}, error) {
+func (s *AIService) AnalyzeCode(ctx context.Context, recordID, problemID uint, language, typedCode string) (map[string]interface{}, error) {
 	var problem models.Problem
 	err := s.db.Model(&models.Problem{}).First(&problem, problemID).Error
 	if err != nil {
 		return nil, err
 	}
 
-	prompt := fmt.Sprintf(`你是一个大学算法课的老师，请分析以下错误代码：
-
-题目：%s
-编程语言：%s
-题目内容：%s
-当前已有代码：%s
-
-示例测试用例：
-%v
-
-请生成代码和题目分析，并确保分为两个点进行输出：
-第一点为指出代码的错误原因（指定标题为"错误分析"）、
-第二点为分析本题目所涉及的计算机领域的知识点（指定标题为"AI讲师分析"），
-注意，不要返回正确的代码示例，仅仅进行分析即可。
-
-同时，请一定确保你生成的响应格式如下（在花括号内填入具体的内容）：
-**错误分析**：
-{错误分析}
+	record, err := s.recordForAI(ctx, recordID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	language, typedCode, err = recordCode(record, language, typedCode)
+	if err != nil {
+		return nil, err
+	}
 
-**AI讲师分析**：
-{AI讲师分析}`, problem.Title, language, problem.Content, typedCode, problem.SampleTestcases)
-	completionQwen, err := s.clientQwen.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个大学的算法课老师，请对同学们的错误代码片段和对应的题目进行分析。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("qwen2.5-14b-instruct-1m"),
-	})
+	return s.analyzeCode(ctx, &problem, recordID, language, typedCode)
+}
 
+// analyzeCode 分析代码，recordID 非 0 时把结果和错误归类保存到作答记录，调用方需保证代码属于该作答记录
+func (s *AIService) analyzeCode(ctx context.Context, problem *models.Problem, recordID uint, language, typedCode string) (map[string]interface{}, error) {
+	problemID := problem.ID
+	data := newPromptData(problem)
+	data.Language = language
+	data.Code = typedCode
+	courseID, err := s.courseForAI(ctx, recordID, problemID)
 	if err != nil {
-		return nil, fmt.Errorf("qwen AI service error: %v", err)
+		return nil, err
 	}
-
-	if len(completionQwen.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Qwen AI service")
+	citations := s.withMaterials(ctx, courseID, problem, "", &data)
+	prompt, err := s.prompt(PromptAnalyzeCode, courseID, data)
+	if err != nil {
+		return nil, err
 	}
+	// 检索到的资料不同时不能复用缓存的分析结果
+	prompt.Context = data.Materials
+	ctx = withAIScope(ctx, FeatureAnalyzeCode, courseID)
+	ctx = withAITarget(ctx, problemID, recordID)
 
-	completionDeepseek, err := s.clientDeepseek.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个大学的算法课老师，请对同学们的错误代码片段和对应的题目进行分析。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("deepseek-chat"),
-	})
-
+	providers, err := s.llm.ForFeature(FeatureAnalyzeCode)
 	if err != nil {
-		return nil, fmt.Errorf("deepseek AI service error: %v", err)
+		return nil, err
+	}
+	if len(providers) < 2 {
+		return nil, fmt.Errorf("功能 %s 需要配置两个模型", FeatureAnalyzeCode)
 	}
 
-	if len(completionQwen.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Deepseek AI service")
+	// 结果字段沿用原有的 qwen_* / deepseek_* 命名，分别对应功能配置的第一个和第二个模型，实际模型名称保存在 *_model 字段
+	results, err := s.compareModels(ctx, FeatureAnalyzeCode, providers[:2], prompt, problemID, language, typedCode)
+	if err != nil {
+		return nil, err
 	}
 
 	if recordID != 0 {
-		err = s.db.Model(&models.UserProblem{}).Where("id = ?", recordID).Updates(map[string]interface{}{
-			"qwen_wrong_reason_and_analyze":     completionQwen.Choices[0].Message.Content,
-			"deepseek_wrong_reason_and_analyze": completionDeepseek.Choices[0].Message.Content,
-		}).Error
+		err = s.saveModelResults(recordID, results, prompt, "qwen_wrong_reason_and_analyze", "deepseek_wrong_reason_and_analyze")
 		if err != nil {
 			return nil, fmt.Errorf("set wrong_reason_and_analyze error: %v", err)
 		}
 	}
 
-	return map[string]interface{}{
-		"qwen_wrong_reason_and_analyze":     completionQwen.Choices[0].Message.Content,
-		"deepseek_wrong_reason_and_analyze": completionDeepseek.Choices[0].Message.Content,
-	}, nil
+	response := map[string]interface{}{
+		"qwen_wrong_reason_and_analyze":     results[0].Content,
+		"deepseek_wrong_reason_and_analyze": results[1].Content,
+		"models":                            []string{providers[0].Name, providers[1].Name},
+		"results":                           results,
+		"template_id":                       prompt.TemplateID,
+		"prompt_version":                    prompt.Version,
+		"citations":                         citations,
+	}
+
+	// 错误归类失败不影响文字分析的结果
+	codeErrors, err := s.classifyCodeErrors(ctx, recordID, problem, courseID, language, typedCode, firstAnalysis(results))
+	if err != nil {
+		log.Printf("错误归类失败: %v", err)
+		response["errors_error"] = err.Error()
+	}
+	response["errors"] = codeErrors
+
+	return response, nil
 }
 
-func (s *AIService) Chat(problemID uint, typedCode, question, modelType string) (string, error) {
+func (s *AIService) Chat(ctx context.Context, problemID uint, typedCode, question, modelType string) (string, []MaterialCitation, error) {
 	var problem models.Problem
 	err := s.db.Model(&models.Problem{}).First(&problem, problemID).Error
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	var client *openai.Client
-	if modelType == "qwen" {
-		client = s.clientQwen
-	} else {
-		client = s.clientDeepseek
+	provider, err := s.llm.Resolve(FeatureChat, modelType)
+	if err != nil {
+		return "", nil, err
 	}
 
-	var model string
-	if modelType == "qwen" {
-		model = "qwen2.5-14b-instruct-1m"
-	} else {
-		model = "deepseek-chat"
+	guard, err := s.integrityGuard(aiUserFrom(ctx), problemID)
+	if err != nil {
+		return "", nil, err
 	}
 
-	prompt := fmt.Sprintf(`你是一个大学算法课的AI助教，请基于以下题目信息，回答学生的问题：
-
-题目：%s
-题目内容：%s
-示例测试用例：
-%v
-
-学生问题：%s
-学生当前代码：%s
-
-请提供专业、准确、有教育意义的回答，帮助学生理解题目和相关知识点。`, problem.Title, problem.Content, problem.SampleTestcases, typedCode, question)
-
-	completion, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个大学算法课的AI助教，你的任务是帮助学生理解算法题目，解答他们的疑问，并提供有教育意义的指导。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F(model),
-	})
-
+	data := newPromptData(&problem)
+	data.Question = question
+	data.Code = typedCode
+	citations := s.withMaterials(ctx, guard.courseID, &problem, question, &data)
+	prompt, err := s.prompt(PromptChat, guard.courseID, data)
 	if err != nil {
-		return "", fmt.Errorf("AI service error: %v", err)
+		return "", nil, err
 	}
+	ctx = withAIScope(ctx, FeatureChat, guard.courseID)
+	ctx = withAITarget(ctx, problemID, 0)
 
-	if len(completion.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI service")
+	message, err := s.guardedComplete(ctx, guard, provider, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(prompt.System),
+		openai.UserMessage(prompt.User),
+	}, question)
+	if err != nil {
+		return "", nil, err
 	}
-
-	return completion.Choices[0].Message.Content, nil
+	return message, citations, nil
 }
 
-func (s *AIService) SuggestKnowledgePointTags(knowledgePointID uint) ([]models.Tag, error) {
+func (s *AIService) SuggestKnowledgePointTags(ctx context.Context, knowledgePointID uint) ([]models.Tag, error) {
 	var knowledgePoint models.KnowledgePoint
 	if err := s.db.First(&knowledgePoint, knowledgePointID).Error; err != nil {
 		return nil, fmt.Errorf("未找到知识点: %v", err)
@@ -333,42 +410,25 @@ func (s *AIService) SuggestKnowledgePointTags(knowledgePointID uint) ([]models.T
 		tagsContext += fmt.Sprintf("%d. %s（%s）", i+1, tag.Name, tag.NameCn)
 	}
 
-	prompt := fmt.Sprintf(`作为一个专业的计算机教育领域AI助手，请从以下已有标签中为知识点内容选择最相关的标签：
-
-知识点：%s
-
-已有标签列表：
-%s
-
-请从上述已有标签中选择3-5个最相关的标签。请仅返回标签的序号（每行一个数字），例如：
-1
-3
-5
-
-注意：
-1. 只能从已有标签中选择
-2. 选择最能反映知识点核心内容的标签
-3. 确保选择的标签数量在3-5个之间`, knowledgePoint.Name, tagsContext)
-
-	completion, err := s.clientQwen.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个专业的计算机教育领域AI助手，精通计算机课程知识点的分类和标签关联。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("qwen2.5-14b-instruct-1m"),
-	})
+	prompt, err := s.prompt(PromptSuggestTags, knowledgePoint.CourseID, PromptData{KnowledgePoint: knowledgePoint.Name, TagList: tagsContext})
+	if err != nil {
+		return nil, err
+	}
 
+	provider, err := s.llm.Resolve(FeatureSuggestTags, "")
 	if err != nil {
-		return nil, fmt.Errorf("AI 服务错误: %v", err)
+		return nil, err
 	}
+	ctx = withAIScope(ctx, FeatureSuggestTags, knowledgePoint.CourseID)
 
-	if len(completion.Choices) == 0 {
-		return nil, fmt.Errorf("AI 服务未返回结果")
+	content, err := s.complete(ctx, provider, prompt.System, prompt.User)
+	if err != nil {
+		return nil, err
 	}
 
 	// 解析返回的标签序号
 	var selectedTags []models.Tag
-	lines := strings.Split(strings.TrimSpace(completion.Choices[0].Message.Content), "\n")
+	lines := strings.Split(strings.TrimSpace(content), "\n")
 
 	for _, line := range lines {
 		index := 0
@@ -392,7 +452,7 @@ func (s *AIService) SuggestKnowledgePointTags(knowledgePointID uint) ([]models.T
 	return selectedTags, nil
 }
 
-func (s *AIService) JudgeCode(problemID uint, lang, code string, test bool) (map[string]interface{}, error) {
+func (s *AIService) JudgeCode(ctx context.Context, problemID uint, lang, code string, test bool) (map[string]interface{}, error) {
 	var problem models.Problem
 	if err := s.db.First(&problem, problemID).Error; err != nil {
 		return nil, fmt.Errorf("题目不存在: %v", err)
@@ -404,70 +464,32 @@ func (s *AIService) JudgeCode(problemID uint, lang, code string, test bool) (map
 	}
 
 	// 构建判题提示
-	prompt := fmt.Sprintf(`作为一个专业的编程题目评测系统，请对以下代码进行评测：
-
-题目信息：
-%s
-
-提交的代码：
-%s
-
-测试用例：
-%s
-
-判题要求：
-1. 时间限制：%dms
-2. 内存限制：%dMB
-3. 编程语言：%s
-
-请确保严格按照以下JSON格式返回判题结果，不要出现任何其他信息，不需要解释说明，只返回以下格式的JSON即可：
-{
-    "status": "判题状态(SUCCESS/FAILED/Time Limit Exceeded/Memory Limit Exceeded/Runtime Error)",
-    "time_used": 实际运行时间(ms),
-    "memory_used": 实际使用内存(MB),
-    "test_results": [
-        {
-            "input": "测试用例输入",
-            "expected_output": "期望输出",
-            "actual_output": "实际输出",
-            "status": "用例状态",
-            "message": "错误信息（如果有）"
-        }
-    ]
-}
-
-注意：
-1. 必须验证代码的正确性
-2. 必须检查时间和内存限制
-3. 必须测试所有测试用例
-4. 必须按照规定格式返回结果
-5. 对于每个测试用例，都要实际运行代码并比较结果`,
-		problem.Content,
-		code,
-		testCases,
-		problem.TimeLimit,
-		problem.MemoryLimit,
-		lang,
-	)
-
-	completion, err := s.clientQwen.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("你是一个专业的编程题目评测系统，你需要严格按照题目要求对代码进行评测，并返回规范的评测结果。"),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F("qwen2.5-14b-instruct-1m"),
-	})
+	data := newPromptData(&problem)
+	data.TestCases = testCases
+	data.Language = lang
+	data.Code = code
+	courseID, _, err := courseOfProblem(s.db, aiUserFrom(ctx), problemID)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.prompt(PromptJudge, courseID, data)
+	if err != nil {
+		return nil, err
+	}
 
+	provider, err := s.llm.Resolve(FeatureJudge, "")
 	if err != nil {
-		return nil, fmt.Errorf("AI 服务错误: %v", err)
+		return nil, err
 	}
+	ctx = withAIScope(ctx, FeatureJudge, courseID)
+	ctx = withAITarget(ctx, problemID, 0)
 
-	if len(completion.Choices) == 0 {
-		return nil, fmt.Errorf("AI 服务未返回结果")
+	content, err := s.complete(ctx, provider, prompt.System, prompt.User)
+	if err != nil {
+		return nil, err
 	}
 
 	// 替换掉markdown格式
-	content := completion.Choices[0].Message.Content
 	content = strings.ReplaceAll(content, "```json", "")
 	content = strings.ReplaceAll(content, "```", "")
 	content = strings.TrimSpace(content)
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// 流式接口推送的事件：
+//   - delta：模型新生成的内容片段 {"model": 模型名, "content": 片段}
+//   - done：单个模型生成结束 {"model": 模型名, "content": 完整内容}
+//   - citations：回答引用的课程资料片段 {"citations": 片段列表}，在模型开始生成前推送，没有检索到资料时不推送
+//
+// ctx 被取消（如客户端断开连接）时会同时取消上游模型请求
+
+// stream 以流式方式调用模型，每收到一段内容就回调 onDelta，返回完整内容
+func (s *AIService) stream(ctx context.Context, provider *LLMProvider, systemPrompt, prompt string, onDelta func(delta string)) (string, error) {
+	call, err := s.beginAICall(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := provider.withTimeout(ctx)
+	defer cancel()
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemPrompt),
+		openai.UserMessage(prompt),
+	}
+	params := provider.params(messages)
+	// 要求在最后一个分片中返回本次调用的 token 用量
+	params.StreamOptions = openai.F(openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.F(true)})
+
+	stream := provider.Client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var content strings.Builder
+	var usage openai.CompletionUsage
+	for stream.Next() {
+		chunk := stream.Current()
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		onDelta(delta)
+	}
+
+	err = stream.Err()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		err = fmt.Errorf("%s AI service error: %v", provider.Name, err)
+	} else if content.Len() == 0 {
+		err = fmt.Errorf("no response from %s AI service", provider.Name)
+	}
+	// 中途失败时已生成的内容同样消耗了 token，按实际内容记录
+	s.finishAICall(call, provider, messages, usage, content.String(), err)
+	if err != nil {
+		return "", err
+	}
+
+	return content.String(), nil
+}
+
+// streamTo 流式调用单个模型，并将内容以 delta / done 事件推送
+func (s *AIService) streamTo(ctx context.Context, provider *LLMProvider, systemPrompt, prompt string, onEvent func(event string, data map[string]interface{})) (string, error) {
+	content, err := s.stream(ctx, provider, systemPrompt, prompt, func(delta string) {
+		onEvent("delta", map[string]interface{}{"model": provider.Name, "content": delta})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	onEvent("done", map[string]interface{}{"model": provider.Name, "content": content})
+	return content, nil
+}
+
+func (s *AIService) GenerateHintStream(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string, onEvent func(event string, data map[string]interface{})) error {
+	provider, err := s.llm.Resolve(FeatureHint, modelType)
+	if err != nil {
+		return err
+	}
+
+	courseID, _, err := courseOfProblem(s.db, aiUserFrom(ctx), problemID)
+	if err != nil {
+		return err
+	}
+	prompt, err := s.prompt(PromptHint, courseID, PromptData{Title: title, Content: content, SampleTestcases: sampleTestCases})
+	if err != nil {
+		return err
+	}
+	ctx = withAIScope(ctx, FeatureHint, courseID)
+	ctx = withAITarget(ctx, problemID, 0)
+
+	_, err = s.streamTo(ctx, provider, prompt.System, prompt.User, onEvent)
+	return err
+}
+
+// ChatStream 课程限制了代码时，回答需要检查后才能返回，此时生成结束后一次性推送完整内容
+func (s *AIService) ChatStream(ctx context.Context, problemID uint, typedCode, question, modelType string, onEvent func(event string, data map[string]interface{})) error {
+	var problem models.Problem
+	err := s.db.Model(&models.Problem{}).First(&problem, problemID).Error
+	if err != nil {
+		return err
+	}
+
+	provider, err := s.llm.Resolve(FeatureChat, modelType)
+	if err != nil {
+		return err
+	}
+
+	guard, err := s.integrityGuard(aiUserFrom(ctx), problemID)
+	if err != nil {
+		return err
+	}
+
+	data := newPromptData(&problem)
+	data.Question = question
+	data.Code = typedCode
+	citations := s.withMaterials(ctx, guard.courseID, &problem, question, &data)
+	prompt, err := s.prompt(PromptChat, guard.courseID, data)
+	if err != nil {
+		return err
+	}
+	ctx = withAIScope(ctx, FeatureChat, guard.courseID)
+	ctx = withAITarget(ctx, problemID, 0)
+	if len(citations) > 0 {
+		onEvent("citations", map[string]interface{}{"citations": citations})
+	}
+
+	if guard.policy == models.CodePolicyAllowCode {
+		_, err = s.streamTo(ctx, provider, prompt.System, prompt.User, onEvent)
+		return err
+	}
+
+	content, err := s.guardedComplete(ctx, guard, provider, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(prompt.System),
+		openai.UserMessage(prompt.User),
+	}, question)
+	if err != nil {
+		return err
+	}
+	onEvent("delta", map[string]interface{}{"model": provider.Name, "content": content})
+	onEvent("done", map[string]interface{}{"model": provider.Name, "content": content})
+	return nil
+}
+
+// AnalyzeCodeStream 依次流式调用功能配置的两个模型，每个模型生成结束后立即保存到做题记录
+func (s *AIService) AnalyzeCodeStream(ctx context.Context, recordID, problemID uint, language, typedCode string, onEvent func(event string, data map[string]interface{})) error {
+	var problem models.Problem
+	err := s.db.Model(&models.Problem{}).First(&problem, problemID).Error
+	if err != nil {
+		return err
+	}
+
+	record, err := s.recordForAI(ctx, recordID, problemID)
+	if err != nil {
+		return err
+	}
+	language, typedCode, err = recordCode(record, language, typedCode)
+	if err != nil {
+		return err
+	}
+
+	providers, err := s.llm.ForFeature(FeatureAnalyzeCode)
+	if err != nil {
+		return err
+	}
+	if len(providers) < 2 {
+		return fmt.Errorf("功能 %s 需要配置两个模型", FeatureAnalyzeCode)
+	}
+
+	data := newPromptData(&problem)
+	data.Language = language
+	data.Code = typedCode
+	courseID, err := s.courseForAI(ctx, recordID, problemID)
+	if err != nil {
+		return err
+	}
+	citations := s.withMaterials(ctx, courseID, &problem, "", &data)
+	prompt, err := s.prompt(PromptAnalyzeCode, courseID, data)
+	if err != nil {
+		return err
+	}
+	prompt.Context = data.Materials
+	ctx = withAIScope(ctx, FeatureAnalyzeCode, courseID)
+	ctx = withAITarget(ctx, problemID, recordID)
+	if len(citations) > 0 {
+		onEvent("citations", map[string]interface{}{"citations": citations})
+	}
+
+	// 与 AnalyzeCode 一致，第一个和第二个模型分别对应 qwen_* / deepseek_* 字段
+	fields := []string{"qwen_wrong_reason_and_analyze", "deepseek_wrong_reason_and_analyze"}
+	var analysis string
+	for i, provider := range providers[:2] {
+		key := cacheKey{FeatureAnalyzeCode, provider, prompt, problemID, language, typedCode}
+		content, ok := s.lookupCache(ctx, key)
+		if ok {
+			// 命中缓存时一次性推送完整内容
+			onEvent("delta", map[string]interface{}{"model": provider.Name, "content": content})
+			onEvent("done", map[string]interface{}{"model": provider.Name, "content": content, "cached": true})
+		} else {
+			content, err = s.streamTo(ctx, provider, prompt.System, prompt.User, onEvent)
+			if err != nil {
+				return err
+			}
+			s.storeCache(key, content)
+		}
+		if analysis == "" {
+			analysis = content
+		}
+
+		if recordID != 0 {
+			err = s.db.Model(&models.UserProblem{}).Where("id = ?", recordID).Updates(map[string]interface{}{
+				fields[i]:                     content,
+				fields[i] + "_model":          provider.Name,
+				fields[i] + "_template_id":    prompt.TemplateID,
+				fields[i] + "_prompt_version": prompt.Version,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("set wrong_reason_and_analyze error: %v", err)
+			}
+		}
+	}
+
+	// 两个模型都生成结束后推送结构化的错误归类，归类失败时只推送错误信息
+	codeErrors, err := s.classifyCodeErrors(ctx, recordID, &problem, courseID, language, typedCode, analysis)
+	if err != nil {
+		onEvent("errors", map[string]interface{}{"error": err.Error()})
+		return nil
+	}
+	onEvent("errors", map[string]interface{}{"errors": codeErrors})
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+)
+
+// completeStructured 调用模型生成 JSON 等结构化输出，parse 解析和校验失败时把错误反馈给模型并要求重新输出，
+// 最多重试 AI_STRUCTURED_RETRIES 次
+func (s *AIService) completeStructured(ctx context.Context, provider *LLMProvider, prompt *renderedPrompt, parse func(content string) error) error {
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(prompt.System),
+		openai.UserMessage(prompt.User),
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= config.LLM.StructuredRetries; attempt++ {
+		content, err := s.completeMessages(ctx, provider, messages)
+		if err != nil {
+			return err
+		}
+
+		if lastErr = parse(content); lastErr == nil {
+			return nil
+		}
+		messages = append(messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage(fmt.Sprintf("上面的输出不符合要求：%v。请修正后重新输出，只返回符合格式要求的 JSON。", lastErr)),
+		)
+	}
+	return fmt.Errorf("模型多次返回的结果格式无效: %v", lastErr)
+}
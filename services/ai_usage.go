@@ -0,0 +1,221 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/openai/openai-go"
+	"gorm.io/gorm"
+)
+
+// ErrAIQuotaExceeded 用户当天的 AI 用量已达到额度上限
+var ErrAIQuotaExceeded = errors.New("AI 使用额度已用完")
+
+type aiUserKey struct{}
+type aiScopeKey struct{}
+type aiTargetKey struct{}
+
+// aiScope 一次 AI 功能调用的功能名和所属课程，课程未知时为 0
+type aiScope struct {
+	feature  string
+	courseID uint
+}
+
+// aiTarget 一次 AI 功能调用针对的题目和作答记录，用于记录调用日志
+type aiTarget struct {
+	problemID uint
+	recordID  uint
+}
+
+// WithAIUser 将发起 AI 调用的用户附加到 context 上，用于记录用量和检查额度。
+// 没有用户的调用（如定时任务）不检查额度
+func WithAIUser(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, aiUserKey{}, userID)
+}
+
+// aiUserFrom 获取发起 AI 调用的用户，没有用户时返回 0
+func aiUserFrom(ctx context.Context) uint {
+	userID, _ := ctx.Value(aiUserKey{}).(uint)
+	return userID
+}
+
+// withAIScope 标记之后的模型调用属于哪个 AI 功能和课程
+func withAIScope(ctx context.Context, feature string, courseID uint) context.Context {
+	return context.WithValue(ctx, aiScopeKey{}, aiScope{feature: feature, courseID: courseID})
+}
+
+// withAITarget 标记之后的模型调用针对的题目和作答记录，没有时为 0
+func withAITarget(ctx context.Context, problemID, recordID uint) context.Context {
+	return context.WithValue(ctx, aiTargetKey{}, aiTarget{problemID: problemID, recordID: recordID})
+}
+
+// aiCall 一次模型调用的调用方信息，调用结束后据此记录用量和调用日志
+type aiCall struct {
+	userID    uint
+	classID   uint
+	feature   string
+	courseID  uint
+	problemID uint
+	recordID  uint
+	start     time.Time
+}
+
+// beginAICall 检查调用方的额度，额度已用完时返回 ErrAIQuotaExceeded。
+// 额度只在调用前检查，正在进行的调用可能使当天用量略微超出上限
+func (s *AIService) beginAICall(ctx context.Context) (*aiCall, error) {
+	call := &aiCall{start: time.Now()}
+	call.userID = aiUserFrom(ctx)
+	if scope, ok := ctx.Value(aiScopeKey{}).(aiScope); ok {
+		call.feature = scope.feature
+		call.courseID = scope.courseID
+	}
+	if target, ok := ctx.Value(aiTargetKey{}).(aiTarget); ok {
+		call.problemID = target.problemID
+		call.recordID = target.recordID
+	}
+	if call.userID == 0 {
+		return call, nil
+	}
+
+	var user models.User
+	if err := s.db.Select("id, role, class_id").First(&user, call.userID).Error; err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %v", err)
+	}
+	call.classID = user.ClassID
+
+	limits, err := quotaLimits(s.db, user.Role, user.ClassID, call.courseID)
+	if err != nil {
+		return nil, fmt.Errorf("获取AI额度失败: %v", err)
+	}
+	for courseID, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		used, err := usedTokensToday(s.db, call.userID, courseID)
+		if err != nil {
+			return nil, fmt.Errorf("获取AI用量失败: %v", err)
+		}
+		if used < limit {
+			continue
+		}
+		if courseID == 0 {
+			return nil, fmt.Errorf("%w：今日已使用 %d tokens，每日上限为 %d tokens，请明天再试", ErrAIQuotaExceeded, used, limit)
+		}
+		return nil, fmt.Errorf("%w：本课程今日已使用 %d tokens，每日上限为 %d tokens，请明天再试", ErrAIQuotaExceeded, used, limit)
+	}
+
+	return call, nil
+}
+
+// finishAICall 记录一次模型调用的用量和调用日志，模型没有返回用量时按消息长度估算
+func (s *AIService) finishAICall(call *aiCall, provider *LLMProvider, messages []openai.ChatCompletionMessageParamUnion, usage openai.CompletionUsage, content string, callErr error) {
+	raw, _ := json.Marshal(messages)
+
+	record := models.AIUsage{
+		UserID:           call.userID,
+		ClassID:          call.classID,
+		CourseID:         call.courseID,
+		Feature:          call.feature,
+		Provider:         provider.Name,
+		ModelName:        provider.Model,
+		PromptTokens:     int(usage.PromptTokens),
+		CompletionTokens: int(usage.CompletionTokens),
+		TotalTokens:      int(usage.TotalTokens),
+		LatencyMs:        time.Since(call.start).Milliseconds(),
+		Success:          callErr == nil,
+	}
+	if record.TotalTokens == 0 && content != "" {
+		record.PromptTokens = utils.EstimateTokens(string(raw))
+		record.CompletionTokens = utils.EstimateTokens(content)
+		record.TotalTokens = record.PromptTokens + record.CompletionTokens
+		record.Estimated = true
+	}
+	record.Cost = provider.cost(record.PromptTokens, record.CompletionTokens)
+
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Printf("记录 AI 用量失败: %v", err)
+	}
+
+	aiLog := models.AILog{
+		UsageID:          record.ID,
+		UserID:           call.userID,
+		CourseID:         call.courseID,
+		Feature:          call.feature,
+		ProblemID:        call.problemID,
+		RecordID:         call.recordID,
+		Provider:         provider.Name,
+		ModelName:        provider.Model,
+		Messages:         string(raw),
+		Response:         content,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		TotalTokens:      record.TotalTokens,
+		LatencyMs:        record.LatencyMs,
+	}
+	if callErr != nil {
+		aiLog.Error = callErr.Error()
+	}
+	if err := s.db.Create(&aiLog).Error; err != nil {
+		log.Printf("记录 AI 调用日志失败: %v", err)
+	}
+}
+
+// quotaLimits 计算用户适用的每日额度，返回 课程ID（0 表示当天总用量）-> token 上限，上限为 0 表示不限制。
+// 同一范围内同时配置了多条额度时，按 班级+角色 > 班级 > 角色 > 全部用户 > 配置文件 的优先级选择，
+// 优先级相同时取较小的上限
+func quotaLimits(db *gorm.DB, role models.Role, classID, courseID uint) (map[uint]int64, error) {
+	limits := make(map[uint]int64)
+	ranks := make(map[uint]int)
+	if limit := config.LLM.DailyTokenQuota[string(role)]; limit > 0 {
+		limits[0] = int64(limit)
+	}
+
+	var quotas []models.AIQuota
+	err := db.Where("role IN ? AND class_id IN ? AND course_id IN ?", []models.Role{"", role}, []uint{0, classID}, []uint{0, courseID}).
+		Find(&quotas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, quota := range quotas {
+		rank := 1
+		if quota.Role != "" {
+			rank++
+		}
+		if quota.ClassID != 0 {
+			rank += 2
+		}
+		current, exists := ranks[quota.CourseID]
+		stricter := quota.DailyTokens > 0 && (limits[quota.CourseID] == 0 || quota.DailyTokens < limits[quota.CourseID])
+		if exists && (rank < current || (rank == current && !stricter)) {
+			continue
+		}
+		ranks[quota.CourseID] = rank
+		limits[quota.CourseID] = quota.DailyTokens
+	}
+	return limits, nil
+}
+
+// usedTokensToday 统计用户当天已使用的 token 数，courseID 为 0 时统计全部课程
+func usedTokensToday(db *gorm.DB, userID, courseID uint) (int64, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	query := db.Model(&models.AIUsage{}).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Where("user_id = ? AND created_at >= ?", userID, today)
+	if courseID != 0 {
+		query = query.Where("course_id = ?", courseID)
+	}
+
+	var used int64
+	err := query.Scan(&used).Error
+	return used, err
+}
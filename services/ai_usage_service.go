@@ -0,0 +1,267 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AIUsageService struct {
+	db *gorm.DB
+}
+
+func NewAIUsageService(db *gorm.DB) *AIUsageService {
+	return &AIUsageService{db: db}
+}
+
+// UsageFilter 用量报表的过滤条件，CourseID 非 0 时统计该课程下各班级学生的全部用量
+type UsageFilter struct {
+	CourseID uint
+	ClassID  uint
+	From     *time.Time
+	To       *time.Time
+}
+
+// usageSummary 一组调用的用量汇总
+type usageSummary struct {
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *usageSummary) add(other usageSummary) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+// usageRow 按班级和功能汇总的用量
+type usageRow struct {
+	ClassID   uint   `json:"class_id"`
+	ClassName string `json:"class_name"`
+	Feature   string `json:"feature"`
+	usageSummary
+}
+
+// Report 按班级和功能统计用量和估算费用，同时给出按班级、按功能的小计和总计
+func (s *AIUsageService) Report(filter UsageFilter) (map[string]interface{}, error) {
+	query := s.db.Model(&models.AIUsage{}).
+		Select("ai_usages.class_id, classes.name AS class_name, ai_usages.feature, COUNT(*) AS calls, " +
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens, COALESCE(SUM(cost), 0) AS cost").
+		Joins("LEFT JOIN classes ON classes.id = ai_usages.class_id")
+	if filter.CourseID != 0 {
+		query = query.Where("ai_usages.class_id IN (?)", s.db.Model(&models.CourseClasses{}).Select("class_id").Where("course_id = ?", filter.CourseID))
+	}
+	if filter.ClassID != 0 {
+		query = query.Where("ai_usages.class_id = ?", filter.ClassID)
+	}
+	if filter.From != nil {
+		query = query.Where("ai_usages.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("ai_usages.created_at <= ?", *filter.To)
+	}
+
+	var rows []usageRow
+	err := query.Group("ai_usages.class_id, classes.name, ai_usages.feature").
+		Order("ai_usages.class_id, ai_usages.feature").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var total usageSummary
+	byClass := make(map[uint]*usageRow)
+	byFeature := make(map[string]*usageRow)
+	for _, row := range rows {
+		total.add(row.usageSummary)
+
+		if byClass[row.ClassID] == nil {
+			byClass[row.ClassID] = &usageRow{ClassID: row.ClassID, ClassName: row.ClassName}
+		}
+		byClass[row.ClassID].add(row.usageSummary)
+
+		if byFeature[row.Feature] == nil {
+			byFeature[row.Feature] = &usageRow{Feature: row.Feature}
+		}
+		byFeature[row.Feature].add(row.usageSummary)
+	}
+
+	classes := make([]*usageRow, 0, len(byClass))
+	for _, row := range byClass {
+		classes = append(classes, row)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ClassID < classes[j].ClassID })
+
+	features := make([]*usageRow, 0, len(byFeature))
+	for _, row := range byFeature {
+		features = append(features, row)
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].Feature < features[j].Feature })
+
+	return map[string]interface{}{
+		"rows":       rows,
+		"by_class":   classes,
+		"by_feature": features,
+		"total":      total,
+	}, nil
+}
+
+// GetUserUsage 获取用户当天的用量和适用的额度，courses 中列出配置了课程额度或当天有用量的课程
+func (s *AIUsageService) GetUserUsage(userID uint) (map[string]interface{}, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在: %v", err)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var used []struct {
+		CourseID    uint
+		TotalTokens int64
+	}
+	err := s.db.Model(&models.AIUsage{}).
+		Select("course_id, COALESCE(SUM(total_tokens), 0) AS total_tokens").
+		Where("user_id = ? AND created_at >= ?", userID, today).
+		Group("course_id").
+		Scan(&used).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var courseIDs []uint
+	err = s.db.Model(&models.AIQuota{}).
+		Where("role IN ? AND class_id IN ? AND course_id <> 0", []models.Role{"", user.Role}, []uint{0, user.ClassID}).
+		Distinct().
+		Pluck("course_id", &courseIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var totalUsed int64
+	courseUsed := make(map[uint]int64)
+	for _, item := range used {
+		totalUsed += item.TotalTokens
+		if item.CourseID != 0 {
+			courseUsed[item.CourseID] = item.TotalTokens
+		}
+	}
+	for _, courseID := range courseIDs {
+		if _, ok := courseUsed[courseID]; !ok {
+			courseUsed[courseID] = 0
+		}
+	}
+
+	limits, err := quotaLimits(s.db, user.Role, user.ClassID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	courses := make([]map[string]interface{}, 0, len(courseUsed))
+	for courseID, tokens := range courseUsed {
+		courseLimits, err := quotaLimits(s.db, user.Role, user.ClassID, courseID)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, map[string]interface{}{
+			"course_id":    courseID,
+			"used_tokens":  tokens,
+			"daily_tokens": courseLimits[courseID],
+		})
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i]["course_id"].(uint) < courses[j]["course_id"].(uint) })
+
+	return map[string]interface{}{
+		"date":         today.Format("2006-01-02"),
+		"used_tokens":  totalUsed,
+		"daily_tokens": limits[0], // 0 表示不限制
+		"courses":      courses,
+	}, nil
+}
+
+func (s *AIUsageService) ListQuotas() ([]models.AIQuota, error) {
+	var quotas []models.AIQuota
+	if err := s.db.Order("course_id, class_id, role").Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// validateQuota 检查额度配置的角色、班级和课程是否有效
+func (s *AIUsageService) validateQuota(quota *models.AIQuota) error {
+	if quota.Role != "" && quota.Role != models.RoleUser && quota.Role != models.RoleAdmin {
+		return fmt.Errorf("无效的角色: %s", quota.Role)
+	}
+	if quota.DailyTokens < 0 {
+		return errors.New("每日额度不能为负数")
+	}
+	if quota.ClassID != 0 {
+		if err := s.db.First(&models.Class{}, quota.ClassID).Error; err != nil {
+			return fmt.Errorf("班级不存在: %v", err)
+		}
+	}
+	if quota.CourseID != 0 {
+		if err := s.db.First(&models.Course{}, quota.CourseID).Error; err != nil {
+			return fmt.Errorf("课程不存在: %v", err)
+		}
+	}
+
+	var count int64
+	err := s.db.Model(&models.AIQuota{}).
+		Where("role = ? AND class_id = ? AND course_id = ? AND id <> ?", quota.Role, quota.ClassID, quota.CourseID, quota.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("相同角色、班级和课程的额度已存在，请直接修改")
+	}
+	return nil
+}
+
+func (s *AIUsageService) CreateQuota(quota *models.AIQuota) error {
+	if err := s.validateQuota(quota); err != nil {
+		return err
+	}
+	return s.db.Create(quota).Error
+}
+
+func (s *AIUsageService) UpdateQuota(id uint, updates *models.AIQuota) (*models.AIQuota, error) {
+	var quota models.AIQuota
+	if err := s.db.First(&quota, id).Error; err != nil {
+		return nil, fmt.Errorf("额度不存在: %v", err)
+	}
+
+	quota.Role = updates.Role
+	quota.ClassID = updates.ClassID
+	quota.CourseID = updates.CourseID
+	quota.DailyTokens = updates.DailyTokens
+	if err := s.validateQuota(&quota); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(&quota).Error; err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+func (s *AIUsageService) DeleteQuota(id uint) error {
+	result := s.db.Delete(&models.AIQuota{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("额度不存在")
+	}
+	return nil
+}
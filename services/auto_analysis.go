@@ -0,0 +1,228 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 每次处理的自动分析数量上限
+	autoAnalysisBatchSize = 20
+	// 状态为 running 但超过该时间没有更新的分析视为执行实例已退出，可以重新执行
+	autoAnalysisStaleAfter = 10 * time.Minute
+	// 等待自动分析结果时的轮询间隔
+	autoAnalysisPollInitialInterval = time.Second
+	autoAnalysisPollMaxInterval     = 5 * time.Second
+	autoAnalysisPollTimeout         = 10 * time.Minute
+)
+
+// enqueueAutoAnalysis 作答记录判定为 FAILED 后，如果所属课程开启了自动分析则创建一条待执行的分析，
+// 课程未开启时返回 nil。作答记录已有分析时重置为待执行，并改为分析本次提交的代码
+func enqueueAutoAnalysis(db *gorm.DB, record *models.UserProblem) (*models.AutoAnalysis, error) {
+	var courseID uint
+	err := db.Model(&models.KnowledgePoint{}).Select("course_id").Where("id = ?", record.KnowledgePointID).Scan(&courseID).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取作答记录所属课程失败: %v", err)
+	}
+	if courseID == 0 {
+		return nil, nil
+	}
+
+	cfg, err := loadCourseAIConfig(db, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("获取课程AI配置失败: %v", err)
+	}
+	if !cfg.AutoAnalyzeEnabled {
+		return nil, nil
+	}
+
+	analysis := models.AutoAnalysis{
+		RecordID:  record.ID,
+		UserID:    record.UserID,
+		ProblemID: record.ProblemID,
+		CourseID:  courseID,
+		Language:  record.Language,
+		TypedCode: record.TypedCode,
+		Status:    models.AutoAnalysisPending,
+		NextRunAt: time.Now(),
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "record_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"language", "typed_code", "status", "attempts", "next_run_at", "last_error", "result", "finished_at", "updated_at",
+		}),
+	}).Create(&analysis).Error
+	if err != nil {
+		return nil, fmt.Errorf("创建自动分析失败: %v", err)
+	}
+	return &analysis, nil
+}
+
+// autoAnalysisRetryDelay 第 attempts 次执行失败后等待重试的时间，每次翻倍
+func autoAnalysisRetryDelay(attempts int) time.Duration {
+	delay := config.LLM.AutoAnalyzeRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+	}
+	return delay
+}
+
+// ProcessAutoAnalyses 执行已到执行时间的自动分析，返回本次执行的数量。由定时任务调用，
+// 每条分析先通过条件更新认领，多个实例同时处理时也只会执行一次
+func (s *AIService) ProcessAutoAnalyses(ctx context.Context) (int, error) {
+	now := time.Now()
+	dueCondition := s.db.Where("status = ? AND next_run_at <= ?", models.AutoAnalysisPending, now).
+		Or("status = ? AND updated_at < ?", models.AutoAnalysisRunning, now.Add(-autoAnalysisStaleAfter))
+
+	var due []models.AutoAnalysis
+	err := s.db.Where(dueCondition).
+		Order("next_run_at").
+		Limit(autoAnalysisBatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("获取待执行的自动分析失败: %v", err)
+	}
+
+	concurrency := config.LLM.AutoAnalyzeConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	processed := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// 认领时重新检查执行条件，已被其他实例认领的分析不再满足条件
+		result := s.db.Model(&models.AutoAnalysis{}).
+			Where("id = ?", due[i].ID).
+			Where(dueCondition).
+			Updates(map[string]interface{}{
+				"status":   models.AutoAnalysisRunning,
+				"attempts": gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			return processed, fmt.Errorf("认领自动分析失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		due[i].Attempts++
+		processed++
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(analysis *models.AutoAnalysis) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.runAutoAnalysis(ctx, analysis)
+		}(&due[i])
+	}
+	wg.Wait()
+	return processed, nil
+}
+
+// runAutoAnalysis 以作答记录所属学生的身份分析判定为 FAILED 时提交的代码，失败时按次数等待重试，
+// 额度不足或次数用完时不再重试
+func (s *AIService) runAutoAnalysis(ctx context.Context, analysis *models.AutoAnalysis) {
+	var problem models.Problem
+	err := s.db.Select("id").First(&models.UserProblem{}, analysis.RecordID).Error
+	if err == nil {
+		err = s.db.First(&problem, analysis.ProblemID).Error
+	}
+	var result map[string]interface{}
+	if err == nil {
+		result, err = s.analyzeCode(WithAIUser(ctx, analysis.UserID), &problem, analysis.RecordID, analysis.Language, analysis.TypedCode)
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		analysis.Status = models.AutoAnalysisCompleted
+		analysis.Result = result
+		analysis.LastError = ""
+		analysis.FinishedAt = &now
+	case errors.Is(err, ErrAIQuotaExceeded) || errors.Is(err, gorm.ErrRecordNotFound) || analysis.Attempts >= config.LLM.AutoAnalyzeMaxAttempts:
+		log.Printf("作答记录 %d 的自动分析失败: %v", analysis.RecordID, err)
+		analysis.Status = models.AutoAnalysisFailed
+		analysis.LastError = err.Error()
+		analysis.FinishedAt = &now
+	default:
+		log.Printf("作答记录 %d 的自动分析第 %d 次执行失败，稍后重试: %v", analysis.RecordID, analysis.Attempts, err)
+		analysis.Status = models.AutoAnalysisPending
+		analysis.LastError = err.Error()
+		analysis.NextRunAt = now.Add(autoAnalysisRetryDelay(analysis.Attempts))
+	}
+
+	// 执行期间作答记录再次判定为 FAILED 时分析已被重置，本次结果对应旧代码，不再写回
+	err = s.db.Model(analysis).
+		Where("status = ? AND attempts = ?", models.AutoAnalysisRunning, analysis.Attempts).
+		Select("status", "result", "last_error", "finished_at", "next_run_at").
+		Updates(analysis).Error
+	if err != nil {
+		log.Printf("更新作答记录 %d 的自动分析状态失败: %v", analysis.RecordID, err)
+	}
+}
+
+// GetAutoAnalysis 获取作答记录的自动分析，userID 非 0 时只能获取该用户的作答记录
+func (s *AIService) GetAutoAnalysis(userID, recordID uint) (*models.AutoAnalysis, error) {
+	query := s.db.Where("record_id = ?", recordID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var analysis models.AutoAnalysis
+	if err := query.First(&analysis).Error; err != nil {
+		return nil, fmt.Errorf("自动分析不存在: %v", err)
+	}
+	return &analysis, nil
+}
+
+// WaitAutoAnalysis 轮询作答记录的自动分析，状态变化时推送 state 事件，分析完成或不再重试时推送 result 事件后返回
+func (s *AIService) WaitAutoAnalysis(ctx context.Context, userID, recordID uint, onEvent func(event string, data map[string]interface{})) error {
+	ctx, cancel := context.WithTimeout(ctx, autoAnalysisPollTimeout)
+	defer cancel()
+
+	interval := autoAnalysisPollInitialInterval
+	lastState := ""
+	for {
+		analysis, err := s.GetAutoAnalysis(userID, recordID)
+		if err != nil {
+			return err
+		}
+
+		state := fmt.Sprintf("%s:%d", analysis.Status, analysis.Attempts)
+		if state != lastState {
+			lastState = state
+			onEvent("state", map[string]interface{}{"status": analysis.Status, "attempts": analysis.Attempts, "last_error": analysis.LastError})
+		}
+
+		if analysis.Status == models.AutoAnalysisCompleted || analysis.Status == models.AutoAnalysisFailed {
+			onEvent("result", map[string]interface{}{"analysis": analysis})
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("等待自动分析结果超时: %v", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > autoAnalysisPollMaxInterval {
+			interval = autoAnalysisPollMaxInterval
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"gorm.io/gorm"
+)
+
+// 会话标题取首个问题的前若干个字符
+const chatSessionTitleLength = 30
+
+// StartChatSession 创建多轮对话会话并回答第一个问题。会话需要先创建，违规记录会关联到会话，
+// 第一个问题回答失败时会删除该会话
+func (s *AIService) StartChatSession(ctx context.Context, userID, problemID uint, question, typedCode, modelType string) (*models.ChatSession, error) {
+	var problem models.Problem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, fmt.Errorf("题目不存在: %v", err)
+	}
+
+	provider, err := s.llm.Resolve(FeatureChat, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	title := []rune(strings.TrimSpace(question))
+	if len(title) > chatSessionTitleLength {
+		title = title[:chatSessionTitleLength]
+	}
+
+	session := models.ChatSession{
+		UserID:    userID,
+		ProblemID: problemID,
+		Title:     string(title),
+		Provider:  provider.Name,
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %v", err)
+	}
+
+	reply, err := s.ask(ctx, &session, &problem, question, typedCode)
+	if err != nil {
+		// 第一个问题没有得到回答（模型调用失败、超出额度等）时删除会话，避免会话列表中留下空会话
+		if delErr := s.db.Delete(&session).Error; delErr != nil {
+			log.Printf("删除空会话 %d 失败: %v", session.ID, delErr)
+		}
+		return nil, err
+	}
+
+	session.Messages = reply
+	return &session, nil
+}
+
+// ContinueChatSession 在已有会话中继续提问，返回本轮的提问和回答
+func (s *AIService) ContinueChatSession(ctx context.Context, userID, sessionID uint, question, typedCode string) ([]models.ChatMessage, error) {
+	var session models.ChatSession
+	err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error
+	if err != nil {
+		return nil, fmt.Errorf("会话不存在: %v", err)
+	}
+
+	var problem models.Problem
+	if err := s.db.First(&problem, session.ProblemID).Error; err != nil {
+		return nil, fmt.Errorf("题目不存在: %v", err)
+	}
+
+	return s.ask(ctx, &session, &problem, question, typedCode)
+}
+
+// ListChatSessions 获取用户的会话列表，problemID 为 0 时返回全部题目的会话
+func (s *AIService) ListChatSessions(userID, problemID uint) ([]models.ChatSession, error) {
+	query := s.db.Model(&models.ChatSession{}).Where("user_id = ?", userID)
+	if problemID != 0 {
+		query = query.Where("problem_id = ?", problemID)
+	}
+
+	var sessions []models.ChatSession
+	if err := query.Order("updated_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetChatSession 获取会话及全部消息，userID 为 0 时不校验会话归属（教师查看学生会话）
+func (s *AIService) GetChatSession(userID, sessionID uint) (*models.ChatSession, error) {
+	query := s.db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Where("id = ?", sessionID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var session models.ChatSession
+	if err := query.First(&session).Error; err != nil {
+		return nil, fmt.Errorf("会话不存在: %v", err)
+	}
+	return &session, nil
+}
+
+func (s *AIService) DeleteChatSession(userID, sessionID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", sessionID, userID).Delete(&models.ChatSession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("会话不存在")
+		}
+		return tx.Where("session_id = ?", sessionID).Delete(&models.ChatMessage{}).Error
+	})
+}
+
+// ask 将历史对话和本轮问题发送给模型，并保存本轮的提问和回答
+func (s *AIService) ask(ctx context.Context, session *models.ChatSession, problem *models.Problem, question, typedCode string) ([]models.ChatMessage, error) {
+	provider, err := s.llm.Provider(session.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	guard, err := s.integrityGuard(session.UserID, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	guard.sessionID = session.ID
+	ctx = withAITarget(ctx, problem.ID, 0)
+
+	messages, prompt, err := s.chatHistory(ctx, session, problem, guard.courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	content := chatSessionQuestion(question, typedCode)
+	messages = append(messages, openai.UserMessage(content))
+
+	answer, err := s.guardedComplete(withAIScope(ctx, FeatureChat, guard.courseID), guard, provider, messages, question)
+	if err != nil {
+		return nil, err
+	}
+
+	turn := []models.ChatMessage{
+		{
+			SessionID:  session.ID,
+			Role:       models.ChatRoleUser,
+			Content:    question,
+			TypedCode:  typedCode,
+			TokenCount: utils.EstimateTokens(content),
+		},
+		{
+			SessionID:  session.ID,
+			Role:       models.ChatRoleAssistant,
+			Content:    answer,
+			TokenCount: utils.EstimateTokens(answer),
+
+			TemplateID:    prompt.TemplateID,
+			PromptVersion: prompt.Version,
+		},
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&turn).Error; err != nil {
+			return err
+		}
+		// 更新会话的更新时间，使会话列表按最近对话排序
+		return tx.Model(session).Update("updated_at", gorm.Expr("NOW()")).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存对话失败: %v", err)
+	}
+
+	return turn, nil
+}
+
+// chatHistory 构造发送给模型的历史消息：从最近的消息往前取，直到达到 token 预算，
+// 更早的消息压缩为摘要，摘要失败时直接截断。同时返回使用的提示词模板
+func (s *AIService) chatHistory(ctx context.Context, session *models.ChatSession, problem *models.Problem, courseID uint) ([]openai.ChatCompletionMessageParamUnion, *renderedPrompt, error) {
+	var history []models.ChatMessage
+	err := s.db.Where("session_id = ? AND id > ?", session.ID, session.SummarizedUntil).
+		Order("id ASC").
+		Find(&history).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取历史对话失败: %v", err)
+	}
+
+	budget := config.LLM.ChatHistoryTokens
+	used := utils.EstimateTokens(session.Summary)
+	keep := len(history)
+	for keep > 0 && used+history[keep-1].TokenCount <= budget {
+		used += history[keep-1].TokenCount
+		keep--
+	}
+
+	if keep > 0 {
+		if err := s.summarize(ctx, session, history[:keep], courseID); err != nil {
+			log.Printf("压缩会话 %d 的历史对话失败，将直接截断: %v", session.ID, err)
+		}
+	}
+
+	prompt, err := s.prompt(PromptChatSession, courseID, newPromptData(problem))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(prompt.System),
+		openai.SystemMessage(prompt.User),
+	}
+	if session.Summary != "" {
+		messages = append(messages, openai.SystemMessage("此前对话摘要：\n"+session.Summary))
+	}
+	for _, message := range history[keep:] {
+		if message.Role == models.ChatRoleAssistant {
+			messages = append(messages, openai.AssistantMessage(message.Content))
+		} else {
+			messages = append(messages, openai.UserMessage(chatSessionQuestion(message.Content, message.TypedCode)))
+		}
+	}
+
+	return messages, prompt, nil
+}
+
+// summarize 将超出预算的消息与已有摘要合并为新的摘要
+func (s *AIService) summarize(ctx context.Context, session *models.ChatSession, messages []models.ChatMessage, courseID uint) error {
+	provider, err := s.llm.Resolve(FeatureChatSummary, "")
+	if err != nil {
+		return err
+	}
+
+	var transcript strings.Builder
+	for _, message := range messages {
+		if message.Role == models.ChatRoleAssistant {
+			transcript.WriteString("助教：")
+		} else {
+			transcript.WriteString("学生：")
+		}
+		transcript.WriteString(chatSessionQuestion(message.Content, message.TypedCode))
+		transcript.WriteString("\n")
+	}
+
+	prompt, err := s.prompt(PromptChatSummary, courseID, PromptData{Summary: session.Summary, Transcript: transcript.String()})
+	if err != nil {
+		return err
+	}
+
+	summary, err := s.complete(withAIScope(ctx, FeatureChatSummary, courseID), provider, prompt.System, prompt.User)
+	if err != nil {
+		return err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return errors.New("摘要为空")
+	}
+
+	until := messages[len(messages)-1].ID
+	err = s.db.Model(session).Updates(map[string]interface{}{
+		"summary":          summary,
+		"summarized_until": until,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	session.Summary = summary
+	session.SummarizedUntil = until
+	return nil
+}
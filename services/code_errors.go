@@ -0,0 +1,334 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 结构化错误分析允许的错误类型
+var codeErrorCategories = map[string]bool{
+	models.ErrorCategoryOffByOne:           true,
+	models.ErrorCategoryWrongDataStructure: true,
+	models.ErrorCategoryTimeComplexity:     true,
+	models.ErrorCategoryEdgeCase:           true,
+	models.ErrorCategorySyntax:             true,
+	models.ErrorCategoryLogic:              true,
+	models.ErrorCategoryOther:              true,
+}
+
+// codeErrorItem 模型返回的一处错误
+type codeErrorItem struct {
+	Category       string `json:"category"`
+	StartLine      int    `json:"start_line"`
+	EndLine        int    `json:"end_line"`
+	KnowledgePoint string `json:"knowledge_point"`
+	Description    string `json:"description"`
+}
+
+// numberLines 为代码加上行号，便于模型给出准确的行号
+func numberLines(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("%d: %s", i+1, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// countLines 代码的行数，与 numberLines 的行号一致
+func countLines(code string) int {
+	return len(strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n"))
+}
+
+// parseCodeErrors 解析并校验模型返回的错误列表：错误类型必须是已定义的类型，行号必须在代码范围内，
+// 不对应具体行的错误 start_line 和 end_line 都为 0
+func parseCodeErrors(content string, lineCount int) ([]codeErrorItem, error) {
+	var result struct {
+		Errors *[]codeErrorItem `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+		return nil, fmt.Errorf("输出不是有效的 JSON: %v", err)
+	}
+	if result.Errors == nil {
+		return nil, errors.New("缺少 errors 字段")
+	}
+
+	items := *result.Errors
+	for i := range items {
+		item := &items[i]
+		item.Category = strings.ToLower(strings.TrimSpace(item.Category))
+		item.Description = strings.TrimSpace(item.Description)
+		item.KnowledgePoint = strings.TrimSpace(item.KnowledgePoint)
+
+		if !codeErrorCategories[item.Category] {
+			return nil, fmt.Errorf("第 %d 个错误的类型 %q 不在允许的错误类型中", i+1, item.Category)
+		}
+		if item.Description == "" {
+			return nil, fmt.Errorf("第 %d 个错误缺少 description", i+1)
+		}
+		if item.StartLine == 0 && item.EndLine == 0 {
+			continue
+		}
+		if item.StartLine < 1 || item.EndLine < item.StartLine || item.EndLine > lineCount {
+			return nil, fmt.Errorf("第 %d 个错误的行号范围 %d-%d 无效，代码共 %d 行", i+1, item.StartLine, item.EndLine, lineCount)
+		}
+	}
+	return items, nil
+}
+
+// classifyCodeErrors 在文字分析的基础上把代码错误归类为结构化的错误列表。recordID 非 0 时替换作答记录已有的错误，
+// 未保存时返回的记录没有 ID
+func (s *AIService) classifyCodeErrors(ctx context.Context, recordID uint, problem *models.Problem, courseID uint, language, typedCode, analysis string) ([]models.CodeError, error) {
+	var knowledgePoints []models.KnowledgePoint
+	if courseID != 0 {
+		if err := s.db.Where("course_id = ?", courseID).Order("id").Find(&knowledgePoints).Error; err != nil {
+			return nil, fmt.Errorf("获取课程知识点失败: %v", err)
+		}
+	}
+	names := make([]string, 0, len(knowledgePoints))
+	for i, kp := range knowledgePoints {
+		names = append(names, fmt.Sprintf("%d. %s", i+1, kp.Name))
+	}
+
+	data := newPromptData(problem)
+	data.Language = language
+	data.Code = typedCode
+	data.NumberedCode = numberLines(typedCode)
+	data.Analysis = analysis
+	data.KnowledgePoints = strings.Join(names, "\n")
+	prompt, err := s.prompt(PromptClassifyErrors, courseID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.llm.Resolve(FeatureClassifyErrors, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureClassifyErrors, courseID)
+
+	// 分析结果命中缓存时归类结果也不会变化，同样按代码、分析内容和课程知识点缓存。
+	// 批注行号依赖原始代码，因此缓存键中包含未规范化的代码
+	prompt.Context = typedCode + "\n" + analysis + "\n" + data.KnowledgePoints
+	key := cacheKey{FeatureClassifyErrors, provider, prompt, problem.ID, language, typedCode}
+	lineCount := countLines(typedCode)
+	var items []codeErrorItem
+	cached, ok := s.lookupCache(ctx, key)
+	if ok {
+		items, err = parseCodeErrors(cached, lineCount)
+	}
+	if !ok || err != nil {
+		var raw string
+		err = s.completeStructured(ctx, provider, prompt, func(content string) error {
+			var err error
+			items, err = parseCodeErrors(content, lineCount)
+			raw = content
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.storeCache(key, raw)
+	}
+
+	var record models.UserProblem
+	if recordID != 0 {
+		if err := s.db.First(&record, recordID).Error; err != nil {
+			return nil, fmt.Errorf("作答记录不存在: %v", err)
+		}
+	}
+
+	codeErrors := make([]models.CodeError, 0, len(items))
+	for _, item := range items {
+		codeError := models.CodeError{
+			RecordID:              recordID,
+			UserID:                record.UserID,
+			ProblemID:             problem.ID,
+			CourseID:              courseID,
+			KnowledgePointID:      record.KnowledgePointID,
+			Provider:              provider.Name,
+			Category:              item.Category,
+			StartLine:             item.StartLine,
+			EndLine:               item.EndLine,
+			RelatedKnowledgePoint: item.KnowledgePoint,
+			Description:           item.Description,
+		}
+		// 按名称匹配课程知识点，便于按知识点统计
+		for _, kp := range knowledgePoints {
+			if item.KnowledgePoint != "" && (kp.Name == item.KnowledgePoint || strings.Contains(item.KnowledgePoint, kp.Name)) {
+				codeError.RelatedKnowledgePointID = kp.ID
+				break
+			}
+		}
+		codeErrors = append(codeErrors, codeError)
+	}
+
+	if recordID == 0 {
+		return codeErrors, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("record_id = ?", recordID).Delete(&models.CodeError{}).Error; err != nil {
+			return err
+		}
+		if len(codeErrors) == 0 {
+			return nil
+		}
+		return tx.Create(&codeErrors).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存错误归类失败: %v", err)
+	}
+	return codeErrors, nil
+}
+
+// firstAnalysis 返回第一个成功的模型的分析内容
+func firstAnalysis(results []modelResult) string {
+	for _, result := range results {
+		if result.Success {
+			return result.Content
+		}
+	}
+	return ""
+}
+
+// CodeErrorFilter 错误列表和统计的过滤条件
+type CodeErrorFilter struct {
+	CourseID         uint
+	KnowledgePointID uint // 作答记录所属的知识点
+	Category         string
+	UserID           uint
+	ProblemID        uint
+	From             *time.Time
+	To               *time.Time
+	Page             int
+	PageSize         int
+}
+
+func (s *CourseService) codeErrorQuery(filter CodeErrorFilter) *gorm.DB {
+	query := s.db.Model(&models.CodeError{}).Where("code_errors.course_id = ?", filter.CourseID)
+	if filter.KnowledgePointID != 0 {
+		query = query.Where("code_errors.knowledge_point_id = ?", filter.KnowledgePointID)
+	}
+	if filter.Category != "" {
+		query = query.Where("code_errors.category = ?", filter.Category)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("code_errors.user_id = ?", filter.UserID)
+	}
+	if filter.ProblemID != 0 {
+		query = query.Where("code_errors.problem_id = ?", filter.ProblemID)
+	}
+	if filter.From != nil {
+		query = query.Where("code_errors.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("code_errors.created_at <= ?", *filter.To)
+	}
+	return query
+}
+
+// ListCodeErrors 分页获取课程内作答记录的结构化错误
+func (s *CourseService) ListCodeErrors(filter CodeErrorFilter) (map[string]interface{}, error) {
+	query := s.codeErrorQuery(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var codeErrors []models.CodeError
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&codeErrors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total": total,
+		"items": codeErrors,
+	}, nil
+}
+
+// GetCodeErrorStats 按错误类型、作答记录的知识点统计课程内的错误数量和涉及的学生数
+func (s *CourseService) GetCodeErrorStats(filter CodeErrorFilter) (map[string]interface{}, error) {
+	var byCategory []struct {
+		Category string `json:"category"`
+		Errors   int64  `json:"errors"`
+		Records  int64  `json:"records"`
+		Students int64  `json:"students"`
+	}
+	err := s.codeErrorQuery(filter).
+		Select("category, COUNT(*) AS errors, COUNT(DISTINCT record_id) AS records, COUNT(DISTINCT user_id) AS students").
+		Group("category").
+		Order("errors DESC").
+		Scan(&byCategory).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var byKnowledgePoint []struct {
+		KnowledgePointID   uint   `json:"knowledge_point_id"`
+		KnowledgePointName string `json:"knowledge_point_name"`
+		Category           string `json:"category"`
+		Errors             int64  `json:"errors"`
+		Students           int64  `json:"students"`
+	}
+	err = s.codeErrorQuery(filter).
+		Select("code_errors.knowledge_point_id, knowledge_points.name AS knowledge_point_name, code_errors.category, " +
+			"COUNT(*) AS errors, COUNT(DISTINCT code_errors.user_id) AS students").
+		Joins("LEFT JOIN knowledge_points ON knowledge_points.id = code_errors.knowledge_point_id").
+		Group("code_errors.knowledge_point_id, knowledge_points.name, code_errors.category").
+		Order("code_errors.knowledge_point_id, errors DESC").
+		Scan(&byKnowledgePoint).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var byRelated []struct {
+		RelatedKnowledgePointID uint   `json:"related_knowledge_point_id"`
+		RelatedKnowledgePoint   string `json:"related_knowledge_point"`
+		Errors                  int64  `json:"errors"`
+	}
+	err = s.codeErrorQuery(filter).
+		Select("code_errors.related_knowledge_point_id, knowledge_points.name AS related_knowledge_point, COUNT(*) AS errors").
+		Joins("LEFT JOIN knowledge_points ON knowledge_points.id = code_errors.related_knowledge_point_id").
+		Where("code_errors.related_knowledge_point_id <> 0").
+		Group("code_errors.related_knowledge_point_id, knowledge_points.name").
+		Order("errors DESC").
+		Scan(&byRelated).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"by_category":        byCategory,
+		"by_knowledge_point": byKnowledgePoint,
+		"by_related_point":   byRelated,
+	}, nil
+}
+
+// recordCodeErrors 获取作答记录的结构化错误
+func recordCodeErrors(db *gorm.DB, recordID uint) ([]models.CodeError, error) {
+	var codeErrors []models.CodeError
+	if err := db.Where("record_id = ?", recordID).Order("start_line, id").Find(&codeErrors).Error; err != nil {
+		return nil, err
+	}
+	return codeErrors, nil
+}
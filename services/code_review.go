@@ -0,0 +1,188 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 代码审阅批注允许的严重程度
+var reviewSeverities = map[string]bool{
+	models.ReviewSeverityError:      true,
+	models.ReviewSeverityWarning:    true,
+	models.ReviewSeveritySuggestion: true,
+}
+
+// reviewCommentItem 模型返回的一条批注
+type reviewCommentItem struct {
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// parseReviewComments 解析并校验模型返回的批注：每条批注必须对应代码中的行，严重程度必须是已定义的类型
+func parseReviewComments(content string, lineCount int) ([]reviewCommentItem, error) {
+	var result struct {
+		Comments *[]reviewCommentItem `json:"comments"`
+	}
+	if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+		return nil, fmt.Errorf("输出不是有效的 JSON: %v", err)
+	}
+	if result.Comments == nil {
+		return nil, errors.New("缺少 comments 字段")
+	}
+
+	items := *result.Comments
+	for i := range items {
+		item := &items[i]
+		item.Severity = strings.ToLower(strings.TrimSpace(item.Severity))
+		item.Message = strings.TrimSpace(item.Message)
+		item.Suggestion = strings.TrimRight(strings.ReplaceAll(item.Suggestion, "\r\n", "\n"), "\n")
+
+		if !reviewSeverities[item.Severity] {
+			return nil, fmt.Errorf("第 %d 条批注的严重程度 %q 不在允许的类型中", i+1, item.Severity)
+		}
+		if item.Message == "" {
+			return nil, fmt.Errorf("第 %d 条批注缺少 message", i+1)
+		}
+		if item.StartLine < 1 || item.EndLine < item.StartLine || item.EndLine > lineCount {
+			return nil, fmt.Errorf("第 %d 条批注的行号范围 %d-%d 无效，代码共 %d 行", i+1, item.StartLine, item.EndLine, lineCount)
+		}
+	}
+	return items, nil
+}
+
+// ReviewCode 审阅学生代码并返回按行定位的批注，不修改学生的代码。recordID 非 0 时审阅作答记录提交的代码
+// 并替换作答记录已有的批注，userID 非 0 时只能审阅该用户的作答记录
+func (s *AIService) ReviewCode(ctx context.Context, userID, recordID, problemID uint, language, typedCode, modelType string) (map[string]interface{}, error) {
+	var problem models.Problem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, err
+	}
+
+	var record models.UserProblem
+	if recordID != 0 {
+		query := s.db.Where("id = ?", recordID)
+		if userID != 0 {
+			query = query.Where("user_id = ?", userID)
+		}
+		if err := query.First(&record).Error; err != nil {
+			return nil, fmt.Errorf("作答记录不存在: %v", err)
+		}
+		if record.ProblemID != problemID {
+			return nil, errors.New("作答记录与题目不匹配")
+		}
+		// 批注的行号对应作答记录中的代码，不使用请求中可能已经修改过的代码
+		typedCode = record.TypedCode
+		if record.Language != "" {
+			language = record.Language
+		}
+	}
+	if strings.TrimSpace(typedCode) == "" {
+		return nil, errors.New("没有需要审阅的代码")
+	}
+
+	lineCount := countLines(typedCode)
+	data := newPromptData(&problem)
+	data.Language = language
+	data.Code = typedCode
+	data.NumberedCode = numberLines(typedCode)
+	data.LineCount = lineCount
+	courseID, err := s.courseForAI(ctx, recordID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.prompt(PromptReviewCode, courseID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.llm.Resolve(FeatureReviewCode, modelType)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureReviewCode, courseID)
+	ctx = withAITarget(ctx, problemID, recordID)
+
+	var items []reviewCommentItem
+	err = s.completeStructured(ctx, provider, prompt, func(content string) error {
+		var err error
+		items, err = parseReviewComments(content, lineCount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].StartLine < items[j].StartLine })
+
+	comments := make([]models.ReviewComment, 0, len(items))
+	for _, item := range items {
+		comments = append(comments, models.ReviewComment{
+			RecordID:      recordID,
+			UserID:        record.UserID,
+			ProblemID:     problemID,
+			Provider:      provider.Name,
+			TemplateID:    prompt.TemplateID,
+			PromptVersion: prompt.Version,
+			StartLine:     item.StartLine,
+			EndLine:       item.EndLine,
+			Severity:      item.Severity,
+			Message:       item.Message,
+			Suggestion:    item.Suggestion,
+		})
+	}
+
+	if recordID != 0 {
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("record_id = ?", recordID).Delete(&models.ReviewComment{}).Error; err != nil {
+				return err
+			}
+			if len(comments) == 0 {
+				return nil
+			}
+			return tx.Create(&comments).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("保存审阅批注失败: %v", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"comments":       comments,
+		"typed_code":     typedCode,
+		"line_count":     lineCount,
+		"model":          provider.Name,
+		"template_id":    prompt.TemplateID,
+		"prompt_version": prompt.Version,
+	}, nil
+}
+
+// GetReviewComments 获取作答记录已保存的审阅批注，userID 非 0 时只能获取该用户的作答记录
+func (s *AIService) GetReviewComments(userID, recordID uint) ([]models.ReviewComment, error) {
+	query := s.db.Where("id = ?", recordID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&models.UserProblem{}).Error; err != nil {
+		return nil, fmt.Errorf("作答记录不存在: %v", err)
+	}
+	return recordReviewComments(s.db, recordID)
+}
+
+// recordReviewComments 获取作答记录的审阅批注，按行号排序
+func recordReviewComments(db *gorm.DB, recordID uint) ([]models.ReviewComment, error) {
+	var comments []models.ReviewComment
+	if err := db.Where("record_id = ?", recordID).Order("start_line, id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 未配置时各提示等级的默认扣分百分比
+const defaultHintDiscounts = "0,10,25,50"
+
+// loadCourseAIConfig 获取课程的 AI 配置，课程未配置时返回默认配置
+func loadCourseAIConfig(db *gorm.DB, courseID uint) (*models.CourseAIConfig, error) {
+	cfg := models.CourseAIConfig{CourseID: courseID}
+	err := db.Where("course_id = ?", courseID).First(&cfg).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if cfg.HintDiscounts == "" {
+		cfg.HintDiscounts = defaultHintDiscounts
+	}
+	if cfg.CodePolicy == "" {
+		cfg.CodePolicy = models.CodePolicyAllowCode
+	}
+	return &cfg, nil
+}
+
+// parseHintDiscounts 解析各提示等级的扣分百分比
+func parseHintDiscounts(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != models.HintLevelMax {
+		return nil, fmt.Errorf("需要为 %d 个提示等级分别设置扣分比例", models.HintLevelMax)
+	}
+
+	discounts := make([]float64, len(parts))
+	for i, part := range parts {
+		discount, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || discount < 0 || discount > 100 {
+			return nil, fmt.Errorf("无效的扣分比例: %s", part)
+		}
+		discounts[i] = discount
+	}
+	return discounts, nil
+}
+
+// hintScore 根据使用的最高提示等级计算题目得分（满分 100），未通过的题目得分为 0
+func hintScore(cfg *models.CourseAIConfig, status models.ProblemStatus, hintLevel int) float64 {
+	if status != models.ProblemStatusSolved {
+		return 0
+	}
+	if !cfg.HintDiscountEnabled || hintLevel <= 0 {
+		return 100
+	}
+
+	discounts, err := parseHintDiscounts(cfg.HintDiscounts)
+	if err != nil {
+		return 100
+	}
+	if hintLevel > len(discounts) {
+		hintLevel = len(discounts)
+	}
+	return 100 - discounts[hintLevel-1]
+}
+
+func (s *CourseService) GetAIConfig(courseID uint) (*models.CourseAIConfig, error) {
+	if err := s.db.First(&models.Course{}, courseID).Error; err != nil {
+		return nil, fmt.Errorf("课程不存在: %v", err)
+	}
+	return loadCourseAIConfig(s.db, courseID)
+}
+
+// UpdateAIConfig 更新课程的 AI 配置，updates 中只包含需要修改的字段
+func (s *CourseService) UpdateAIConfig(courseID uint, updates map[string]interface{}) (*models.CourseAIConfig, error) {
+	cfg, err := s.GetAIConfig(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := updates["hint_discounts"].(string); ok {
+		if _, err := parseHintDiscounts(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := updates["code_policy"].(string); ok {
+		if _, valid := codePolicyRanks[value]; !valid {
+			return nil, fmt.Errorf("无效的代码策略: %s", value)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.FirstOrCreate(&models.CourseAIConfig{}, models.CourseAIConfig{CourseID: courseID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CourseAIConfig{}).Where("course_id = ?", courseID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loadCourseAIConfig(s.db, cfg.CourseID)
+}
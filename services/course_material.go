@@ -0,0 +1,328 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MaterialCitation AI 回答中引用的课程资料片段，Index 与提示词中的编号一致
+type MaterialCitation struct {
+	Index      int     `json:"index"`
+	MaterialID uint    `json:"material_id"`
+	ChunkID    uint    `json:"chunk_id"`
+	Title      string  `json:"title"`
+	Heading    string  `json:"heading"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
+type CourseMaterialService struct {
+	db       *gorm.DB
+	embedder Embedder
+}
+
+func NewCourseMaterialService(db *gorm.DB) *CourseMaterialService {
+	return &CourseMaterialService{db: db, embedder: NewEmbedder()}
+}
+
+// chunkMaterial 切分资料内容并生成每个片段的向量，片段的标题参与向量化
+func (s *CourseMaterialService) chunkMaterial(ctx context.Context, material *models.CourseMaterial) ([]models.MaterialChunk, error) {
+	pieces := utils.ChunkText(material.Content, config.LLM.MaterialChunkSize, config.LLM.MaterialChunkOverlap)
+	if len(pieces) == 0 {
+		return nil, errors.New("资料内容为空")
+	}
+
+	batchSize := config.Embedding.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	model := s.embedder.Model()
+	chunks := make([]models.MaterialChunk, 0, len(pieces))
+	for start := 0; start < len(pieces); start += batchSize {
+		end := start + batchSize
+		if end > len(pieces) {
+			end = len(pieces)
+		}
+
+		texts := make([]string, 0, end-start)
+		for _, piece := range pieces[start:end] {
+			texts = append(texts, material.Title+" "+piece.Heading+"\n"+piece.Content)
+		}
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, piece := range pieces[start:end] {
+			heading := piece.Heading
+			if len([]rune(heading)) > 500 {
+				heading = string([]rune(heading)[:500])
+			}
+			chunks = append(chunks, models.MaterialChunk{
+				CourseID:         material.CourseID,
+				KnowledgePointID: material.KnowledgePointID,
+				Seq:              start + i + 1,
+				Heading:          heading,
+				Content:          piece.Content,
+				EmbeddingModel:   model,
+				Vector:           vectors[i],
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// saveChunks 替换资料的全部片段并更新资料的片段数量
+func saveChunks(tx *gorm.DB, material *models.CourseMaterial, chunks []models.MaterialChunk) error {
+	if err := tx.Unscoped().Where("material_id = ?", material.ID).Delete(&models.MaterialChunk{}).Error; err != nil {
+		return fmt.Errorf("删除资料片段失败: %v", err)
+	}
+	for i := range chunks {
+		chunks[i].MaterialID = material.ID
+	}
+	if err := tx.CreateInBatches(&chunks, 100).Error; err != nil {
+		return fmt.Errorf("保存资料片段失败: %v", err)
+	}
+
+	material.ChunkCount = len(chunks)
+	material.EmbeddingModel = chunks[0].EmbeddingModel
+	return tx.Model(material).Updates(map[string]interface{}{
+		"chunk_count":     material.ChunkCount,
+		"embedding_model": material.EmbeddingModel,
+	}).Error
+}
+
+// UploadMaterial 保存课程资料并建立检索索引，knowledgePointID 非 0 时资料只用于该知识点的题目
+func (s *CourseMaterialService) UploadMaterial(ctx context.Context, uploaderID, courseID, knowledgePointID uint, title, fileName, content string) (*models.CourseMaterial, error) {
+	if err := s.db.First(&models.Course{}, courseID).Error; err != nil {
+		return nil, fmt.Errorf("课程不存在: %v", err)
+	}
+	if knowledgePointID != 0 {
+		var knowledgePoint models.KnowledgePoint
+		if err := s.db.First(&knowledgePoint, knowledgePointID).Error; err != nil {
+			return nil, fmt.Errorf("知识点不存在: %v", err)
+		}
+		if knowledgePoint.CourseID != courseID {
+			return nil, errors.New("知识点不属于该课程")
+		}
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = strings.TrimSpace(fileName)
+	}
+	if title == "" {
+		return nil, errors.New("资料标题不能为空")
+	}
+
+	material := &models.CourseMaterial{
+		CourseID:         courseID,
+		KnowledgePointID: knowledgePointID,
+		UploaderID:       uploaderID,
+		Title:            title,
+		FileName:         fileName,
+		Content:          content,
+	}
+	chunks, err := s.chunkMaterial(ctx, material)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(material).Error; err != nil {
+			return fmt.Errorf("保存课程资料失败: %v", err)
+		}
+		return saveChunks(tx, material, chunks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return material, nil
+}
+
+// ReindexMaterial 重新切分资料并生成向量，用于更换向量模型或调整片段大小之后
+func (s *CourseMaterialService) ReindexMaterial(ctx context.Context, courseID, materialID uint) (*models.CourseMaterial, error) {
+	material, err := s.GetMaterial(courseID, materialID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := s.chunkMaterial(ctx, material)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return saveChunks(tx, material, chunks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return material, nil
+}
+
+// ListMaterials 获取课程资料列表（不含内容），knowledgePointID 非 0 时只返回该知识点的资料
+func (s *CourseMaterialService) ListMaterials(courseID, knowledgePointID uint) ([]models.CourseMaterial, error) {
+	query := s.db.Omit("content").Where("course_id = ?", courseID)
+	if knowledgePointID != 0 {
+		query = query.Where("knowledge_point_id = ?", knowledgePointID)
+	}
+
+	var materials []models.CourseMaterial
+	if err := query.Order("id DESC").Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取课程资料失败: %v", err)
+	}
+	return materials, nil
+}
+
+func (s *CourseMaterialService) GetMaterial(courseID, materialID uint) (*models.CourseMaterial, error) {
+	var material models.CourseMaterial
+	if err := s.db.Where("id = ? AND course_id = ?", materialID, courseID).First(&material).Error; err != nil {
+		return nil, fmt.Errorf("课程资料不存在: %v", err)
+	}
+	return &material, nil
+}
+
+func (s *CourseMaterialService) DeleteMaterial(courseID, materialID uint) error {
+	material, err := s.GetMaterial(courseID, materialID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("material_id = ?", material.ID).Delete(&models.MaterialChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(material).Error
+	})
+}
+
+// problemKnowledgePoints 获取题目在课程中所属的知识点：直接关联的知识点，以及标签与题目标签有交集的知识点
+func (s *AIService) problemKnowledgePoints(courseID, problemID uint) ([]models.KnowledgePoint, error) {
+	var knowledgePoints []models.KnowledgePoint
+	err := s.db.Where("course_id = ?", courseID).
+		Where("(id IN (?) OR id IN (?))",
+			s.db.Table("knowledge_point_problems").Select("knowledge_point_id").Where("problem_id = ?", problemID),
+			s.db.Table("knowledge_point_tags").Select("knowledge_point_tags.knowledge_point_id").
+				Joins("JOIN problem_tags ON problem_tags.tag_id = knowledge_point_tags.tag_id").
+				Where("problem_tags.problem_id = ?", problemID)).
+		Order("position, id").
+		Find(&knowledgePoints).Error
+	return knowledgePoints, err
+}
+
+// retrieveMaterials 从课程资料中检索与题目所属知识点、题目和学生问题最相关的片段，
+// 只在整门课程的资料和题目所属知识点的资料中检索。courseID 为 0 或课程没有资料时返回空
+func (s *AIService) retrieveMaterials(ctx context.Context, courseID uint, problem *models.Problem, question string) ([]MaterialCitation, error) {
+	if courseID == 0 || config.LLM.MaterialTopK <= 0 {
+		return nil, nil
+	}
+
+	knowledgePoints, err := s.problemKnowledgePoints(courseID, problem.ID)
+	if err != nil {
+		return nil, fmt.Errorf("获取题目知识点失败: %v", err)
+	}
+	kpIDs := []uint{0}
+	names := make([]string, 0, len(knowledgePoints))
+	for _, kp := range knowledgePoints {
+		kpIDs = append(kpIDs, kp.ID)
+		names = append(names, kp.Name)
+	}
+
+	var chunks []models.MaterialChunk
+	err = s.db.Where("course_id = ? AND embedding_model = ? AND knowledge_point_id IN ?", courseID, s.embedder.Model(), kpIDs).
+		Find(&chunks).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取课程资料失败: %v", err)
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	title := problem.TitleCn
+	if title == "" {
+		title = problem.Title
+	}
+	query := strings.TrimSpace(strings.Join(names, " ") + "\n" + title + "\n" + question)
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		chunk *models.MaterialChunk
+		score float64
+	}
+	results := make([]scored, 0, len(chunks))
+	for i := range chunks {
+		score := utils.CosineSimilarity(vectors[0], chunks[i].Vector)
+		if score >= config.LLM.MaterialMinScore {
+			results = append(results, scored{chunk: &chunks[i], score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+	if len(results) > config.LLM.MaterialTopK {
+		results = results[:config.LLM.MaterialTopK]
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	materialIDs := make([]uint, 0, len(results))
+	for _, result := range results {
+		materialIDs = append(materialIDs, result.chunk.MaterialID)
+	}
+	var materials []models.CourseMaterial
+	if err := s.db.Omit("content").Where("id IN ?", materialIDs).Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取课程资料失败: %v", err)
+	}
+	titles := make(map[uint]string, len(materials))
+	for _, material := range materials {
+		titles[material.ID] = material.Title
+	}
+
+	citations := make([]MaterialCitation, 0, len(results))
+	for i, result := range results {
+		citations = append(citations, MaterialCitation{
+			Index:      i + 1,
+			MaterialID: result.chunk.MaterialID,
+			ChunkID:    result.chunk.ID,
+			Title:      titles[result.chunk.MaterialID],
+			Heading:    result.chunk.Heading,
+			Content:    result.chunk.Content,
+			Score:      result.score,
+		})
+	}
+	return citations, nil
+}
+
+// formatMaterials 将检索到的资料片段格式化为提示词中带编号的参考资料
+func formatMaterials(citations []MaterialCitation) string {
+	var text strings.Builder
+	for _, citation := range citations {
+		fmt.Fprintf(&text, "[%d] 《%s》", citation.Index, citation.Title)
+		if citation.Heading != "" {
+			text.WriteString(" " + citation.Heading)
+		}
+		text.WriteString("\n" + citation.Content + "\n\n")
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// withMaterials 检索课程资料并写入模板变量，检索失败时记录日志，不影响 AI 功能的使用
+func (s *AIService) withMaterials(ctx context.Context, courseID uint, problem *models.Problem, question string, data *PromptData) []MaterialCitation {
+	citations, err := s.retrieveMaterials(ctx, courseID, problem, question)
+	if err != nil {
+		log.Printf("检索课程 %d 的资料失败: %v", courseID, err)
+		return nil
+	}
+	data.Materials = formatMaterials(citations)
+	return citations
+}
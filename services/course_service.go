@@ -55,7 +55,7 @@ func (s *CourseService) GetCourseDetail(courseID, userID uint) (*models.Course,
 	}
 
 	var points []models.KnowledgePoint
-	if err := s.db.Model(&models.KnowledgePoint{}).Where("course_id = ?", courseID).Find(&points).Error; err != nil {
+	if err := s.db.Model(&models.KnowledgePoint{}).Where("course_id = ?", courseID).Order("position, id").Find(&points).Error; err != nil {
 		return nil, nil, nil, nil, err
 	}
 
@@ -167,7 +167,7 @@ func (s *CourseService) GetCourseDetail(courseID, userID uint) (*models.Course,
 
 func (s *CourseService) GetKnowledgePoints(courseID uint) ([]map[string]interface{}, error) {
 	var points []map[string]interface{}
-	err := s.db.Model(&models.KnowledgePoint{}).Select("id, name").Where("course_id = ?", courseID).Find(&points).Error
+	err := s.db.Model(&models.KnowledgePoint{}).Select("id, name, position").Where("course_id = ?", courseID).Order("position, id").Find(&points).Error
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +209,7 @@ func (s *CourseService) AddCourse(courseName string, pointNames []string) (map[s
 			knowledgePoint := models.KnowledgePoint{
 				Name:     name,
 				CourseID: course.ID,
+				Position: i + 1,
 				Course:   course,
 			}
 			knowledgePoints[i] = knowledgePoint
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/utils"
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Embedder 将文本转换为向量
+type Embedder interface {
+	// Model 生成向量的模型名称，不同模型的向量不能相互比较
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// NewEmbedder 根据配置创建向量模型，未配置 EMBEDDING_BASE_URL 时使用本地哈希向量
+func NewEmbedder() Embedder {
+	cfg := config.Embedding
+	if cfg.BaseURL == "" {
+		return &localEmbedder{dims: cfg.Dimensions}
+	}
+
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		// 自部署的服务通常不需要鉴权，但 SDK 要求必须设置 API Key
+		apiKey = "none"
+	}
+	return &openAIEmbedder{
+		client: openai.NewClient(option.WithBaseURL(cfg.BaseURL), option.WithAPIKey(apiKey)),
+		model:  cfg.Model,
+	}
+}
+
+// openAIEmbedder 调用兼容 OpenAI embeddings 接口的服务
+type openAIEmbedder struct {
+	client *openai.Client
+	model  string
+}
+
+func (e *openAIEmbedder) Model() string {
+	return e.model
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if config.Embedding.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Embedding.Timeout)
+		defer cancel()
+	}
+
+	resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings(texts)),
+		Model: openai.F(e.model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("请求向量模型失败: %v", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("向量模型返回了 %d 个向量，请求了 %d 个", len(resp.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || int(item.Index) >= len(texts) {
+			return nil, fmt.Errorf("向量模型返回了无效的序号 %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// localEmbedder 本地哈希向量，不需要外部服务，只能匹配字面上相近的文本
+type localEmbedder struct {
+	dims int
+}
+
+func (e *localEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-%d", e.dims)
+}
+
+func (e *localEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = utils.HashEmbedding(text, e.dims)
+	}
+	return vectors, nil
+}
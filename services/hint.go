@@ -0,0 +1,152 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// unlockedHintLevel 获取学生在题目上已解锁的最高提示等级
+func unlockedHintLevel(db *gorm.DB, userID, problemID, knowledgePointID uint) (int, error) {
+	var level int
+	err := db.Model(&models.HintRecord{}).
+		Select("COALESCE(MAX(level), 0)").
+		Where("user_id = ? AND problem_id = ? AND knowledge_point_id = ?", userID, problemID, knowledgePointID).
+		Scan(&level).Error
+	return level, err
+}
+
+// unlockedHints 获取每个已解锁等级最近一次生成的提示内容，按等级排序
+func (s *AIService) unlockedHints(userID, problemID, knowledgePointID uint) ([]models.HintRecord, error) {
+	var records []models.HintRecord
+	err := s.db.Where("user_id = ? AND problem_id = ? AND knowledge_point_id = ?", userID, problemID, knowledgePointID).
+		Order("level ASC, id DESC").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hints := make([]models.HintRecord, 0, models.HintLevelMax)
+	for _, record := range records {
+		if len(hints) == 0 || hints[len(hints)-1].Level != record.Level {
+			hints = append(hints, record)
+		}
+	}
+	return hints, nil
+}
+
+// GetHints 获取学生在题目上已解锁的提示
+func (s *AIService) GetHints(userID, problemID, knowledgePointID uint) (map[string]interface{}, error) {
+	hints, err := s.unlockedHints(userID, problemID, knowledgePointID)
+	if err != nil {
+		return nil, err
+	}
+
+	nextLevel := len(hints) + 1
+	if nextLevel > models.HintLevelMax {
+		nextLevel = 0
+	}
+
+	return map[string]interface{}{
+		"hints":      hints,
+		"hint_level": len(hints),
+		"next_level": nextLevel,
+	}, nil
+}
+
+// RequestHint 获取指定等级的提示，level 为 0 时解锁下一级提示。提示必须按顺序解锁，
+// 已解锁的等级直接返回之前生成的内容，每次请求都会记录到学生的作答记录上
+func (s *AIService) RequestHint(ctx context.Context, userID, problemID, knowledgePointID uint, level int, modelType string) (*models.HintRecord, error) {
+	var problem models.Problem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, fmt.Errorf("题目不存在: %v", err)
+	}
+
+	hints, err := s.unlockedHints(userID, problemID, knowledgePointID)
+	if err != nil {
+		return nil, err
+	}
+	current := len(hints)
+
+	if level == 0 {
+		level = current + 1
+		if level > models.HintLevelMax {
+			level = models.HintLevelMax
+		}
+	}
+	if level < 1 || level > models.HintLevelMax {
+		return nil, fmt.Errorf("无效的提示等级: %d", level)
+	}
+	if level > current+1 {
+		return nil, fmt.Errorf("请先查看第 %d 级提示", current+1)
+	}
+
+	record := models.HintRecord{
+		UserID:           userID,
+		ProblemID:        problemID,
+		KnowledgePointID: knowledgePointID,
+		Level:            level,
+	}
+	if level <= current {
+		record.Provider = hints[level-1].Provider
+		record.Content = hints[level-1].Content
+		record.TemplateID = hints[level-1].TemplateID
+		record.PromptVersion = hints[level-1].PromptVersion
+	} else {
+		provider, err := s.llm.Resolve(FeatureHint, modelType)
+		if err != nil {
+			return nil, err
+		}
+
+		previous := make([]string, 0, current)
+		for _, hint := range hints {
+			previous = append(previous, hint.Content)
+		}
+
+		data := newPromptData(&problem)
+		data.PreviousHints = previous
+		courseID := s.courseOfKnowledgePoint(knowledgePointID)
+		prompt, err := s.prompt(hintLevelPrompts[level], courseID, data)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx = withAITarget(withAIScope(ctx, FeatureHint, courseID), problemID, 0)
+		content, err := s.complete(ctx, provider, prompt.System, prompt.User)
+		if err != nil {
+			return nil, err
+		}
+		record.Provider = provider.Name
+		record.Content = content
+		record.TemplateID = prompt.TemplateID
+		record.PromptVersion = prompt.Version
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 记录到最近一次作答记录上，还没有作答时创建一条未作答的记录
+		var tryRecord models.UserProblem
+		err := tx.Where(models.UserProblem{UserID: userID, ProblemID: problemID, KnowledgePointID: knowledgePointID}).
+			Order("id DESC").
+			FirstOrCreate(&tryRecord).Error
+		if err != nil {
+			return err
+		}
+		record.UserProblemID = tryRecord.ID
+
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+
+		// 只更新提示所挂的作答记录，其他作答按作答时的提示等级计分；已通过的作答不再扣分
+		return tx.Model(&models.UserProblem{}).
+			Where("id = ? AND status <> ? AND hint_level < ?", tryRecord.ID, models.ProblemStatusSolved, level).
+			Update("hint_level", level).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存提示记录失败: %v", err)
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 学习报告中发送给模型的近期失败作答数量
+	reportFailureLimit = 10
+	// 学习报告中可以推荐的题目数量
+	reportCandidateLimit = 50
+)
+
+// KnowledgePointProgress 学生在课程一个知识点上的做题情况，按题目计数
+type KnowledgePointProgress struct {
+	Name   string
+	Tried  int
+	Solved int
+	Failed int // 失败过且还没有通过的题目
+	Total  int
+}
+
+// ReportCandidate 可以推荐给学生练习的课程题目
+type ReportCandidate struct {
+	ProblemID      uint
+	Title          string
+	Difficulty     string
+	KnowledgePoint string
+}
+
+// reportInput 生成学习报告所需的学生数据
+type reportInput struct {
+	course     models.Course
+	stats      string
+	failures   string
+	hints      string
+	candidates []ReportCandidate
+}
+
+// isEnrolled 判断学生所在班级是否选修了课程
+func (s *AIService) isEnrolled(userID, courseID uint) (bool, error) {
+	var count int64
+	err := s.db.Table("course_classes").
+		Joins("JOIN users ON users.class_id = course_classes.class_id").
+		Where("course_classes.course_id = ? AND users.id = ?", courseID, userID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// learningReportInput 汇总学生在课程各知识点上的做题情况、统计范围内的失败作答和提示使用，以及未通过的课程题目
+func (s *AIService) learningReportInput(userID, courseID uint, from, to time.Time) (*reportInput, error) {
+	input := &reportInput{}
+	if err := s.db.First(&input.course, courseID).Error; err != nil {
+		return nil, fmt.Errorf("课程不存在: %v", err)
+	}
+
+	stats, err := s.KnowledgePointProgress(userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, stat := range stats {
+		fmt.Fprintf(&text, "- %s：共 %d 题，尝试 %d 题，通过 %d 题，失败 %d 题\n", stat.Name, stat.Total, stat.Tried, stat.Solved, stat.Failed)
+	}
+	input.stats = strings.TrimSpace(text.String())
+
+	var failures []struct {
+		RecordID       uint
+		ProblemTitle   string
+		KnowledgePoint string
+	}
+	err = s.db.Model(&models.UserProblem{}).
+		Select("user_problems.id AS record_id, COALESCE(NULLIF(problems.title_cn, ''), problems.title) AS problem_title, "+
+			"knowledge_points.name AS knowledge_point").
+		Joins("JOIN knowledge_points ON knowledge_points.id = user_problems.knowledge_point_id").
+		Joins("JOIN problems ON problems.id = user_problems.problem_id").
+		Where("knowledge_points.course_id = ? AND user_problems.user_id = ? AND user_problems.status = ?", courseID, userID, models.ProblemStatusFailed).
+		Where("user_problems.updated_at BETWEEN ? AND ?", from, to).
+		Order("user_problems.updated_at DESC").
+		Limit(reportFailureLimit).
+		Scan(&failures).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取近期失败作答失败: %v", err)
+	}
+	text.Reset()
+	for _, failure := range failures {
+		fmt.Fprintf(&text, "- %s（%s）", failure.ProblemTitle, failure.KnowledgePoint)
+		codeErrors, err := recordCodeErrors(s.db, failure.RecordID)
+		if err != nil {
+			return nil, fmt.Errorf("获取错误归类失败: %v", err)
+		}
+		descriptions := make([]string, 0, len(codeErrors))
+		for _, codeError := range codeErrors {
+			descriptions = append(descriptions, fmt.Sprintf("[%s] %s", codeError.Category, codeError.Description))
+		}
+		if len(descriptions) > 0 {
+			text.WriteString("：" + strings.Join(descriptions, "；"))
+		}
+		text.WriteString("\n")
+	}
+	input.failures = strings.TrimSpace(text.String())
+
+	var hints []struct {
+		KnowledgePoint string
+		Hints          int
+		Problems       int
+		MaxLevel       int
+	}
+	err = s.db.Model(&models.HintRecord{}).
+		Select("knowledge_points.name AS knowledge_point, COUNT(*) AS hints, "+
+			"COUNT(DISTINCT hint_records.problem_id) AS problems, MAX(hint_records.level) AS max_level").
+		Joins("JOIN knowledge_points ON knowledge_points.id = hint_records.knowledge_point_id").
+		Where("knowledge_points.course_id = ? AND hint_records.user_id = ?", courseID, userID).
+		Where("hint_records.created_at BETWEEN ? AND ?", from, to).
+		Group("knowledge_points.id, knowledge_points.name").
+		Order("hints DESC").
+		Scan(&hints).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取提示使用情况失败: %v", err)
+	}
+	text.Reset()
+	for _, hint := range hints {
+		fmt.Fprintf(&text, "- %s：在 %d 道题上请求提示 %d 次，最高解锁到第 %d 级\n", hint.KnowledgePoint, hint.Problems, hint.Hints, hint.MaxLevel)
+	}
+	input.hints = strings.TrimSpace(text.String())
+
+	input.candidates, err = s.ReportCandidates(userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+// KnowledgePointProgress 统计学生在课程各知识点上尝试、通过和失败的题目数。同一道题可能有多次作答，
+// 只要有一次通过就不再计入失败
+func (s *AIService) KnowledgePointProgress(userID, courseID uint) ([]KnowledgePointProgress, error) {
+	var stats []KnowledgePointProgress
+	err := s.db.Table("knowledge_points").
+		Select("knowledge_points.name, "+
+			"COUNT(DISTINCT CASE WHEN user_problems.status <> ? THEN user_problems.problem_id END) AS tried, "+
+			"COUNT(DISTINCT CASE WHEN user_problems.status = ? THEN user_problems.problem_id END) AS solved, "+
+			"COUNT(DISTINCT CASE WHEN user_problems.status = ? AND NOT EXISTS (SELECT 1 FROM user_problems solved "+
+			"WHERE solved.user_id = user_problems.user_id AND solved.problem_id = user_problems.problem_id "+
+			"AND solved.knowledge_point_id = user_problems.knowledge_point_id AND solved.status = ? AND solved.deleted_at IS NULL) "+
+			"THEN user_problems.problem_id END) AS failed, "+
+			"(SELECT COUNT(*) FROM knowledge_point_problems WHERE knowledge_point_problems.knowledge_point_id = knowledge_points.id) AS total",
+			models.ProblemStatusUntried, models.ProblemStatusSolved, models.ProblemStatusFailed, models.ProblemStatusSolved).
+		Joins("LEFT JOIN user_problems ON user_problems.knowledge_point_id = knowledge_points.id AND user_problems.user_id = ? AND user_problems.deleted_at IS NULL", userID).
+		Where("knowledge_points.course_id = ? AND knowledge_points.deleted_at IS NULL", courseID).
+		Group("knowledge_points.id, knowledge_points.name").
+		Order("knowledge_points.id").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取知识点做题情况失败: %v", err)
+	}
+	return stats, nil
+}
+
+// ReportCandidates 获取课程中学生还没有通过的题目，失败过的题目排在前面。
+// 同一道题在多个知识点下或有多次作答时只返回一次，知识点取名称最靠前的一个
+func (s *AIService) ReportCandidates(userID, courseID uint) ([]ReportCandidate, error) {
+	var candidates []ReportCandidate
+	err := s.db.Table("knowledge_point_problems").
+		Select("problems.id AS problem_id, COALESCE(NULLIF(problems.title_cn, ''), problems.title) AS title, "+
+			"problems.difficulty, MIN(knowledge_points.name) AS knowledge_point").
+		Joins("JOIN knowledge_points ON knowledge_points.id = knowledge_point_problems.knowledge_point_id").
+		Joins("JOIN problems ON problems.id = knowledge_point_problems.problem_id").
+		Where("knowledge_points.course_id = ? AND knowledge_points.deleted_at IS NULL", courseID).
+		Where("NOT EXISTS (SELECT 1 FROM user_problems JOIN knowledge_points solved_kp ON solved_kp.id = user_problems.knowledge_point_id "+
+			"WHERE user_problems.user_id = ? AND user_problems.problem_id = problems.id AND solved_kp.course_id = ? "+
+			"AND user_problems.status = ? AND user_problems.deleted_at IS NULL)", userID, courseID, models.ProblemStatusSolved).
+		Group("problems.id, problems.title_cn, problems.title, problems.difficulty").
+		Order(clause.OrderBy{Expression: gorm.Expr("CASE WHEN EXISTS (SELECT 1 FROM user_problems JOIN knowledge_points failed_kp ON failed_kp.id = user_problems.knowledge_point_id "+
+			"WHERE user_problems.user_id = ? AND user_problems.problem_id = problems.id AND failed_kp.course_id = ? "+
+			"AND user_problems.status = ? AND user_problems.deleted_at IS NULL) THEN 0 ELSE 1 END, MIN(knowledge_points.id), problems.id",
+			userID, courseID, models.ProblemStatusFailed)}).
+		Limit(reportCandidateLimit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取课程题目失败: %v", err)
+	}
+	return candidates, nil
+}
+
+// LearningReportStudents 获取选修课程的学生中在时间范围内有作答或提示记录的学生
+func (s *AIService) LearningReportStudents(courseID uint, from, to time.Time) ([]uint, error) {
+	var userIDs []uint
+	err := s.db.Table("users").
+		Distinct("users.id").
+		Joins("JOIN course_classes ON course_classes.class_id = users.class_id").
+		Where("course_classes.course_id = ? AND users.deleted_at IS NULL", courseID).
+		Where("(EXISTS (SELECT 1 FROM user_problems JOIN knowledge_points ON knowledge_points.id = user_problems.knowledge_point_id "+
+			"WHERE user_problems.user_id = users.id AND knowledge_points.course_id = ? AND user_problems.status <> ? "+
+			"AND user_problems.updated_at BETWEEN ? AND ?) OR "+
+			"EXISTS (SELECT 1 FROM hint_records JOIN knowledge_points ON knowledge_points.id = hint_records.knowledge_point_id "+
+			"WHERE hint_records.user_id = users.id AND knowledge_points.course_id = ? AND hint_records.created_at BETWEEN ? AND ?))",
+			courseID, models.ProblemStatusUntried, from, to, courseID, from, to).
+		Order("users.id").
+		Pluck("users.id", &userIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取课程学生失败: %v", err)
+	}
+	return userIDs, nil
+}
+
+// GenerateLearningReport 根据学生在课程中的做题情况、近期失败作答和提示使用生成学习报告并保存，
+// 推荐练习的题目只能是课程中学生还没有通过的题目
+func (s *AIService) GenerateLearningReport(ctx context.Context, userID, courseID uint, trigger string) (*models.LearningReport, error) {
+	enrolled, err := s.isEnrolled(userID, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("验证选课信息失败: %v", err)
+	}
+	if !enrolled {
+		return nil, errors.New("学生所在班级未选修该课程")
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -config.LLM.ReportWindowDays)
+	input, err := s.learningReportInput(userID, courseID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.candidates) == 0 {
+		return nil, errors.New("课程中没有可以推荐练习的题目")
+	}
+
+	candidates := make(map[uint]ReportCandidate, len(input.candidates))
+	lines := make([]string, 0, len(input.candidates))
+	for _, candidate := range input.candidates {
+		candidates[candidate.ProblemID] = candidate
+		lines = append(lines, fmt.Sprintf("%d. %s [%s] %s", candidate.ProblemID, candidate.Title, candidate.Difficulty, candidate.KnowledgePoint))
+	}
+
+	prompt, err := s.prompt(PromptLearningReport, courseID, PromptData{
+		Course:         input.course.Name,
+		Stats:          input.stats,
+		RecentFailures: input.failures,
+		HintUsage:      input.hints,
+		Candidates:     strings.Join(lines, "\n"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.llm.Resolve(FeatureLearningReport, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureLearningReport, courseID)
+
+	report := models.LearningReport{
+		UserID:        userID,
+		CourseID:      courseID,
+		Trigger:       trigger,
+		PeriodStart:   from,
+		PeriodEnd:     to,
+		Provider:      provider.Name,
+		TemplateID:    prompt.TemplateID,
+		PromptVersion: prompt.Version,
+	}
+	err = s.completeStructured(ctx, provider, prompt, func(content string) error {
+		var result struct {
+			Summary      string                      `json:"summary"`
+			Strengths    []string                    `json:"strengths"`
+			WeakSpots    []models.ReportWeakSpot     `json:"weak_spots"`
+			PracticePlan []models.ReportPracticeItem `json:"practice_plan"`
+		}
+		if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+			return fmt.Errorf("输出不是有效的 JSON: %v", err)
+		}
+		if strings.TrimSpace(result.Summary) == "" {
+			return errors.New("缺少 summary")
+		}
+		if len(result.PracticePlan) == 0 {
+			return errors.New("practice_plan 不能为空")
+		}
+
+		seen := make(map[uint]bool, len(result.PracticePlan))
+		for i := range result.PracticePlan {
+			item := &result.PracticePlan[i]
+			candidate, ok := candidates[item.ProblemID]
+			if !ok {
+				return fmt.Errorf("practice_plan 中的题目 %d 不在可以推荐的题目中", item.ProblemID)
+			}
+			if seen[item.ProblemID] {
+				return fmt.Errorf("practice_plan 中的题目 %d 重复", item.ProblemID)
+			}
+			seen[item.ProblemID] = true
+			item.Title = candidate.Title
+			item.KnowledgePoint = candidate.KnowledgePoint
+			item.Reason = strings.TrimSpace(item.Reason)
+		}
+
+		report.Summary = strings.TrimSpace(result.Summary)
+		report.Strengths = result.Strengths
+		report.WeakSpots = result.WeakSpots
+		report.PracticePlan = result.PracticePlan
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&report).Error; err != nil {
+		return nil, fmt.Errorf("保存学习报告失败: %v", err)
+	}
+	return &report, nil
+}
+
+// ListLearningReports 获取学生在课程中的历史学习报告，userID 为 0 时返回课程所有学生的报告
+func (s *AIService) ListLearningReports(userID, courseID uint) ([]models.LearningReport, error) {
+	query := s.db.Where("course_id = ?", courseID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var reports []models.LearningReport
+	if err := query.Order("id DESC").Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("获取学习报告失败: %v", err)
+	}
+	return reports, nil
+}
+
+// GetLearningReport 获取学习报告详情，userID 非 0 时只能获取自己的报告
+func (s *AIService) GetLearningReport(userID, courseID, reportID uint) (*models.LearningReport, error) {
+	query := s.db.Where("id = ? AND course_id = ?", reportID, courseID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var report models.LearningReport
+	if err := query.First(&report).Error; err != nil {
+		return nil, fmt.Errorf("学习报告不存在: %v", err)
+	}
+	return &report, nil
+}
@@ -4,6 +4,7 @@ import (
 	"ai_teach_system/config"
 	"ai_teach_system/constants"
 	"ai_teach_system/models"
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -15,17 +16,40 @@ import (
 
 type LeetCodeServiceInterface interface {
 	FetchAllProblems() ([]*models.Problem, error)
+	FetchProblems(ctx context.Context, filter ProblemFilter, handle func(offset int, problems []*models.Problem, failures []FetchFailure) error) error
 	RunTestCase(userID uint, questionId int, code string, lang string) (map[string]interface{}, error)
 	Submit(userID uint, lang string, knowledge_point_id uint, question_id int, code string) (map[string]interface{}, error)
 	Check(userID uint, runCodeID string, test bool) (map[string]interface{}, error)
+	CheckStream(ctx context.Context, userID uint, runCodeID string, test bool, onEvent func(event string, data map[string]interface{})) error
 	GetRecommendedProblem(currentProblemID uint, userID uint) (*models.Problem, error)
 }
 
+const (
+	checkPollInitialInterval = 500 * time.Millisecond
+	checkPollMaxInterval     = 4 * time.Second
+	checkPollTimeout         = 2 * time.Minute
+)
+
 type LeetCodeService struct {
 	Client *resty.Client
 	db     *gorm.DB
 }
 
+// ProblemFilter 题目抓取的过滤条件
+type ProblemFilter struct {
+	Slugs    []string // 非空时只抓取指定 slug 的题目
+	FromID   int      // 前端题号下限（包含），0 表示不限制
+	ToID     int      // 前端题号上限（包含），0 表示不限制
+	Skip     int      // 从题目列表（或 Slugs）的第几条开始抓取，用于断点续传
+	TagsOnly bool     // 只抓取题目标签，不请求题目详情
+}
+
+// FetchFailure 抓取失败的题目，与同一页抓取成功的题目一起交给 handle，由调用方计入失败数
+type FetchFailure struct {
+	TitleSlug string
+	Err       error
+}
+
 type GraphQLQuery struct {
 	Query         string                 `json:"query"`
 	Variables     map[string]interface{} `json:"variables"`
@@ -45,8 +69,26 @@ func NewLeetCodeService(db *gorm.DB) *LeetCodeService {
 
 func (s *LeetCodeService) FetchAllProblems() ([]*models.Problem, error) {
 	problems := make([]*models.Problem, 0)
+	err := s.FetchProblems(context.Background(), ProblemFilter{}, func(offset int, page []*models.Problem, failures []FetchFailure) error {
+		problems = append(problems, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// FetchProblems 按过滤条件分页抓取题目，每抓取完一页调用一次 handle，
+// offset 为该页处理完后在题目列表（或 Slugs）中的位置，可作为断点续传的起点，
+// 获取详情失败的题目不会中断抓取，通过 failures 交给 handle
+func (s *LeetCodeService) FetchProblems(ctx context.Context, filter ProblemFilter, handle func(offset int, problems []*models.Problem, failures []FetchFailure) error) error {
+	if len(filter.Slugs) > 0 {
+		return s.fetchProblemsBySlugs(ctx, filter, handle)
+	}
+
 	pageSize := 100
-	skip := 0
+	skip := filter.Skip
 	hasMore := true
 
 	for hasMore {
@@ -95,34 +137,54 @@ func (s *LeetCodeService) FetchAllProblems() ([]*models.Problem, error) {
 
 		var result map[string]interface{}
 		_, err := s.Client.R().
+			SetContext(ctx).
 			SetBody(graphqlQuery).
 			SetResult(&result).
 			Post("/graphql")
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		data, ok := result["data"].(map[string]interface{})
 		if !ok {
-			log.Printf("%v", result["data"])
+			return fmt.Errorf("获取题目列表失败: %v", result["errors"])
 		}
 		problemList := data["problemsetQuestionList"].(map[string]interface{})
 		questions := problemList["questions"].([]interface{})
 		hasMore = problemList["hasMore"].(bool)
 
+		problems := make([]*models.Problem, 0, len(questions))
+		var failures []FetchFailure
 		for _, q := range questions {
 			question := q.(map[string]interface{})
 			titleSlug := question["titleSlug"].(string)
 
-			problem, err := s.FetchProblemDetail(titleSlug)
-			if err != nil {
-				log.Printf("获取题目详情失败 %s: %v", titleSlug, err)
+			if !filter.matchFrontendID(question["frontendQuestionId"]) {
 				continue
 			}
 
-			// 处理标签
-			if tags, ok := question["topicTags"].([]interface{}); ok {
+			var problem *models.Problem
+			if filter.TagsOnly {
+				// 只同步标签时无需请求题目详情
+				title, _ := question["title"].(string)
+				titleCn, _ := question["titleCn"].(string)
+				problem = &models.Problem{
+					Title:     title,
+					TitleCn:   titleCn,
+					TitleSlug: titleSlug,
+				}
+			} else {
+				problem, err = s.FetchProblemDetail(titleSlug)
+				if err != nil {
+					log.Printf("获取题目详情失败 %s: %v", titleSlug, err)
+					failures = append(failures, FetchFailure{TitleSlug: titleSlug, Err: err})
+					continue
+				}
+			}
+
+			// 处理标签（题目详情中已包含标签时以详情为准）
+			if tags, ok := question["topicTags"].([]interface{}); ok && len(problem.Tags) == 0 {
 				for _, t := range tags {
 					tag := t.(map[string]interface{})
 					problem.Tags = append(problem.Tags, models.Tag{
@@ -135,14 +197,65 @@ func (s *LeetCodeService) FetchAllProblems() ([]*models.Problem, error) {
 			problems = append(problems, problem)
 		}
 
-		log.Printf("已获取 %d 题，当前页 %d 条记录，是否还有更多：%v", len(problems), len(questions), hasMore)
+		skip += len(questions)
+		log.Printf("已处理至第 %d 题，当前页 %d 条记录，命中 %d 条，是否还有更多：%v", skip, len(questions), len(problems), hasMore)
 
-		time.Sleep(3 * time.Second)
+		if err := handle(skip, problems, failures); err != nil {
+			return err
+		}
 
-		skip += pageSize
+		if hasMore {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(3 * time.Second):
+			}
+		}
 	}
 
-	return problems, nil
+	return nil
+}
+
+func (s *LeetCodeService) fetchProblemsBySlugs(ctx context.Context, filter ProblemFilter, handle func(offset int, problems []*models.Problem, failures []FetchFailure) error) error {
+	for i := filter.Skip; i < len(filter.Slugs); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		problem, err := s.FetchProblemDetail(filter.Slugs[i])
+		if err != nil {
+			log.Printf("获取题目详情失败 %s: %v", filter.Slugs[i], err)
+			failure := FetchFailure{TitleSlug: filter.Slugs[i], Err: err}
+			if err := handle(i+1, nil, []FetchFailure{failure}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := handle(i+1, []*models.Problem{problem}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchFrontendID 判断题目的前端题号是否落在过滤范围内，无法解析为数字的题号（如剑指 Offer）在设置了范围时会被过滤掉
+func (f ProblemFilter) matchFrontendID(value interface{}) bool {
+	if f.FromID == 0 && f.ToID == 0 {
+		return true
+	}
+	str, _ := value.(string)
+	id, err := strconv.Atoi(str)
+	if err != nil {
+		return false
+	}
+	if f.FromID != 0 && id < f.FromID {
+		return false
+	}
+	if f.ToID != 0 && id > f.ToID {
+		return false
+	}
+	return true
 }
 
 func (s *LeetCodeService) FetchProblemDetail(titleSlug string) (*models.Problem, error) {
@@ -157,6 +270,10 @@ func (s *LeetCodeService) FetchProblemDetail(titleSlug string) (*models.Problem,
 			translatedContent
 			difficulty
 			sampleTestCase
+			topicTags {
+				name
+				translatedName
+			}
 		}
 	}`
 
@@ -183,7 +300,10 @@ func (s *LeetCodeService) FetchProblemDetail(titleSlug string) (*models.Problem,
 		return nil, fmt.Errorf("data is not a map[string]interface{}")
 	}
 
-	question := data["question"].(map[string]interface{})
+	question, ok := data["question"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("题目不存在: %s", titleSlug)
+	}
 
 	leetcodeID, err := strconv.Atoi(question["questionId"].(string))
 	if err != nil {
@@ -216,6 +336,17 @@ func (s *LeetCodeService) FetchProblemDetail(titleSlug string) (*models.Problem,
 		SampleTestcases: question["sampleTestCase"].(string),
 	}
 
+	if tags, ok := question["topicTags"].([]interface{}); ok {
+		for _, t := range tags {
+			tag := t.(map[string]interface{})
+			nameCn, _ := tag["translatedName"].(string)
+			problem.Tags = append(problem.Tags, models.Tag{
+				Name:   tag["name"].(string),
+				NameCn: nameCn,
+			})
+		}
+	}
+
 	return problem, nil
 }
 
@@ -267,15 +398,21 @@ func (s *LeetCodeService) Submit(userID uint, lang string, knowledge_point_id ui
 		return nil, err
 	}
 
-	// 新增作答记录
+	// 新增作答记录，沿用此前已解锁的提示等级
 	submissionID := result["submission_id"].(float64)
+	hintLevel, err := unlockedHintLevel(s.db, userID, problem.ID, knowledge_point_id)
+	if err != nil {
+		return nil, err
+	}
 	tryRecord := models.UserProblem{
 		UserID:           userID,
 		KnowledgePointID: knowledge_point_id,
 		ProblemID:        problem.ID,
 		Status:           models.ProblemStatusTried,
 		TypedCode:        code,
+		Language:         lang,
 		SubmissionID:     submissionID,
+		HintLevel:        hintLevel,
 	}
 	s.db.Create(&tryRecord)
 	result["record_id"] = tryRecord.ID
@@ -284,9 +421,75 @@ func (s *LeetCodeService) Submit(userID uint, lang string, knowledge_point_id ui
 }
 
 func (s *LeetCodeService) Check(userID uint, runCodeID string, test bool) (map[string]interface{}, error) {
+	result, err := s.fetchCheckResult(context.Background(), runCodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 当检查提交结果时才更新提交记录状态
+	if !test {
+		if err := s.applyCheckResult(userID, runCodeID, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// CheckStream 在服务端轮询判题结果，每当状态发生变化时通过 onEvent 推送，直到得到最终结果或 ctx 被取消
+func (s *LeetCodeService) CheckStream(ctx context.Context, userID uint, runCodeID string, test bool, onEvent func(event string, data map[string]interface{})) error {
+	ctx, cancel := context.WithTimeout(ctx, checkPollTimeout)
+	defer cancel()
+
+	interval := checkPollInitialInterval
+	lastState := ""
+	var lastErr error
+	for {
+		result, err := s.fetchCheckResult(ctx, runCodeID)
+		if err != nil {
+			// 查询失败视为暂时性错误，退避后继续轮询，直到超时
+			lastErr = err
+		} else {
+			lastErr = nil
+			state, _ := result["state"].(string)
+			if state != lastState {
+				lastState = state
+				onEvent("state", map[string]interface{}{"state": state})
+			}
+
+			if state == "SUCCESS" || state == "FAILED" {
+				if !test {
+					if err := s.applyCheckResult(userID, runCodeID, result); err != nil {
+						return err
+					}
+				}
+				onEvent("result", result)
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("等待判题结果超时，最近一次查询失败: %v", lastErr)
+			}
+			return fmt.Errorf("等待判题结果超时: %v", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		// 指数退避，避免频繁请求 LeetCode
+		interval *= 2
+		if interval > checkPollMaxInterval {
+			interval = checkPollMaxInterval
+		}
+	}
+}
+
+func (s *LeetCodeService) fetchCheckResult(ctx context.Context, runCodeID string) (map[string]interface{}, error) {
 	var result map[string]interface{}
 	path := fmt.Sprintf("/submissions/detail/%s/check", runCodeID)
 	_, err := s.Client.R().
+		SetContext(ctx).
 		SetResult(&result).
 		Get(path)
 
@@ -294,46 +497,65 @@ func (s *LeetCodeService) Check(userID uint, runCodeID string, test bool) (map[s
 		return nil, err
 	}
 
-	// 当检查提交结果时才更新提交记录状态
-	if !test {
-		// 修改提交记录状态
-		state := result["state"].(string)
-		var status models.ProblemStatus
-		switch state {
-		case "SUCCESS":
-			status = models.ProblemStatusSolved
-		case "FAILED":
-			status = models.ProblemStatusFailed
-		default:
-			return result, nil
-		}
+	return result, nil
+}
 
-		var record models.UserProblem
-		err = s.db.Where("user_id = ? AND submission_id = ?", userID, runCodeID).First(&record).Error
-		if err != nil {
-			return nil, err
-		}
+// applyCheckResult 根据判题最终状态更新作答记录，并在解答成功时附带推荐题目。
+// 作答记录变为 FAILED 且课程开启了自动分析时，创建后台分析并在结果中附带 auto_analysis
+func (s *LeetCodeService) applyCheckResult(userID uint, runCodeID string, result map[string]interface{}) error {
+	// 修改提交记录状态
+	state, _ := result["state"].(string)
+	var status models.ProblemStatus
+	switch state {
+	case "SUCCESS":
+		status = models.ProblemStatusSolved
+	case "FAILED":
+		status = models.ProblemStatusFailed
+	default:
+		return nil
+	}
+
+	var record models.UserProblem
+	err := s.db.Where("user_id = ? AND submission_id = ?", userID, runCodeID).First(&record).Error
+	if err != nil {
+		return err
+	}
+
+	// 只更新仍处于作答中的记录，保证同一次提交的状态只会被更新一次
+	updated := s.db.Model(&models.UserProblem{}).
+		Where("id = ? AND status = ?", record.ID, models.ProblemStatusTried).
+		Update("status", status)
+	if updated.Error != nil {
+		return updated.Error
+	}
 
-		err = s.db.Model(&record).Update("status", status).Error
+	// 自动分析失败不影响判题结果的返回
+	if status == models.ProblemStatusFailed && updated.RowsAffected > 0 {
+		analysis, err := enqueueAutoAnalysis(s.db, &record)
 		if err != nil {
-			return nil, err
+			log.Printf("作答记录 %d 创建自动分析失败: %v", record.ID, err)
+		} else if analysis != nil {
+			result["auto_analysis"] = map[string]interface{}{
+				"record_id": record.ID,
+				"status":    analysis.Status,
+			}
 		}
+	}
 
-		// 如果解答成功，获取推荐题目
-		if status == models.ProblemStatusSolved {
-			recommendedProblem, err := s.GetRecommendedProblem(record.ProblemID, userID)
-			if err == nil && recommendedProblem != nil {
-				result["recommended_problem"] = map[string]interface{}{
-					"id":         recommendedProblem.ID,
-					"title":      recommendedProblem.Title,
-					"title_cn":   recommendedProblem.TitleCn,
-					"difficulty": recommendedProblem.Difficulty,
-				}
+	// 如果解答成功，获取推荐题目
+	if status == models.ProblemStatusSolved {
+		recommendedProblem, err := s.GetRecommendedProblem(record.ProblemID, userID)
+		if err == nil && recommendedProblem != nil {
+			result["recommended_problem"] = map[string]interface{}{
+				"id":         recommendedProblem.ID,
+				"title":      recommendedProblem.Title,
+				"title_cn":   recommendedProblem.TitleCn,
+				"difficulty": recommendedProblem.Difficulty,
 			}
 		}
 	}
 
-	return result, nil
+	return nil
 }
 
 func (s *LeetCodeService) GetRecommendedProblem(currentProblemID uint, userID uint) (*models.Problem, error) {
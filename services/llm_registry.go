@@ -0,0 +1,177 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// AI 功能名称，与 AI_FEATURE_<FEATURE> 配置对应
+const (
+	FeatureHint           = "hint"
+	FeatureChat           = "chat"
+	FeatureChatSummary    = "chat_summary"
+	FeatureCorrectCode    = "correct_code"
+	FeatureAnalyzeCode    = "analyze_code"
+	FeatureSuggestTags    = "suggest_tags"
+	FeatureJudge          = "judge"
+	FeatureDraftProblem   = "draft_problem"
+	FeatureClassifyErrors = "classify_errors"
+	FeatureMisconceptions = "misconceptions"
+	FeatureLearningReport = "learning_report"
+	FeatureSyllabusPlan   = "syllabus_plan"
+	FeatureReviewCode     = "review_code"
+)
+
+// LLMProvider 一个兼容 OpenAI 接口的模型服务
+type LLMProvider struct {
+	Name        string
+	Model       string
+	Client      *openai.Client
+	Temperature *float64
+	MaxTokens   int
+	Timeout     time.Duration
+	// 每千 tokens 的价格（元）
+	PromptPrice     float64
+	CompletionPrice float64
+}
+
+// LLMRegistry 根据配置管理所有模型服务，以及各 AI 功能使用的模型
+type LLMRegistry struct {
+	providers map[string]*LLMProvider
+	names     []string
+	features  map[string][]string
+}
+
+func NewLLMRegistry() *LLMRegistry {
+	registry := &LLMRegistry{
+		providers: make(map[string]*LLMProvider),
+		features:  config.LLM.Features,
+	}
+
+	for _, cfg := range config.LLM.Providers {
+		opts := []option.RequestOption{option.WithBaseURL(cfg.BaseURL)}
+		if cfg.APIKey != "" {
+			opts = append(opts, option.WithAPIKey(cfg.APIKey))
+		} else {
+			// 自部署的服务通常不需要鉴权，但 SDK 要求必须设置 API Key
+			opts = append(opts, option.WithAPIKey("none"))
+		}
+
+		registry.providers[cfg.Name] = &LLMProvider{
+			Name:        cfg.Name,
+			Model:       cfg.Model,
+			Client:      openai.NewClient(opts...),
+			Temperature: cfg.Temperature,
+			MaxTokens:   cfg.MaxTokens,
+			Timeout:     cfg.Timeout,
+
+			PromptPrice:     cfg.PromptPrice,
+			CompletionPrice: cfg.CompletionPrice,
+		}
+		registry.names = append(registry.names, cfg.Name)
+	}
+
+	return registry
+}
+
+// Provider 按名称获取模型服务
+func (r *LLMRegistry) Provider(name string) (*LLMProvider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("未配置的模型: %s", name)
+	}
+	return provider, nil
+}
+
+// ForFeature 获取 AI 功能配置的全部模型服务
+func (r *LLMRegistry) ForFeature(feature string) ([]*LLMProvider, error) {
+	names := r.features[feature]
+	if len(names) == 0 {
+		return nil, fmt.Errorf("功能 %s 未配置模型", feature)
+	}
+
+	providers := make([]*LLMProvider, 0, len(names))
+	for _, name := range names {
+		provider, err := r.Provider(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// Resolve 选择 AI 功能使用的模型服务，modelType 非空时按名称选择，否则使用功能配置的第一个模型
+func (r *LLMRegistry) Resolve(feature, modelType string) (*LLMProvider, error) {
+	if modelType != "" {
+		return r.Provider(modelType)
+	}
+
+	providers, err := r.ForFeature(feature)
+	if err != nil {
+		return nil, err
+	}
+	return providers[0], nil
+}
+
+// List 返回所有可用模型及其被哪些功能使用，不包含密钥等敏感信息
+func (r *LLMRegistry) List() []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(r.names))
+	for _, name := range r.names {
+		provider := r.providers[name]
+
+		features := make([]string, 0)
+		for feature, names := range r.features {
+			for _, n := range names {
+				if n == name {
+					features = append(features, feature)
+					break
+				}
+			}
+		}
+		sort.Strings(features)
+
+		result = append(result, map[string]interface{}{
+			"name":        provider.Name,
+			"model":       provider.Model,
+			"temperature": provider.Temperature,
+			"max_tokens":  provider.MaxTokens,
+			"features":    features,
+		})
+	}
+	return result
+}
+
+// params 构造对话补全请求参数，并应用模型配置中的温度和最大 token 数
+func (p *LLMProvider) params(messages []openai.ChatCompletionMessageParamUnion) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Messages: openai.F(messages),
+		Model:    openai.F(p.Model),
+	}
+	if p.Temperature != nil {
+		params.Temperature = openai.F(*p.Temperature)
+	}
+	if p.MaxTokens > 0 {
+		params.MaxTokens = openai.F(int64(p.MaxTokens))
+	}
+	return params
+}
+
+// cost 按配置的价格估算一次调用的费用（元）
+func (p *LLMProvider) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.PromptPrice + float64(completionTokens)*p.CompletionPrice) / 1000
+}
+
+// withTimeout 为单次模型调用设置超时时间
+func (p *LLMProvider) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
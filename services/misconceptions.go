@@ -0,0 +1,328 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 每个聚类发送给模型的代表性作答数量
+	misconceptionExampleLimit = 3
+	// 代表性作答中代码的最大长度
+	misconceptionCodeLimit = 3000
+)
+
+// failedAttempt 参与聚类的一次失败作答
+type failedAttempt struct {
+	RecordID         uint
+	UserID           uint
+	ProblemID        uint
+	KnowledgePointID uint
+	TypedCode        string
+	ProblemTitle     string
+	Category         string   `gorm:"-"`
+	Errors           []string `gorm:"-"` // 错误归类中的错误说明
+}
+
+// attemptCluster 同一知识点、同一错误类型下代码相似的失败作答，第一个作答为代表
+type attemptCluster struct {
+	knowledgePointID uint
+	category         string
+	attempts         []*failedAttempt
+}
+
+// misconceptionExample 保存在聚类中的代表性作答
+type misconceptionExample struct {
+	RecordID     uint     `json:"record_id"`
+	ProblemID    uint     `json:"problem_id"`
+	ProblemTitle string   `json:"problem_title"`
+	Code         string   `json:"code"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// failedAttempts 获取课程在时间范围内更新为失败的作答，错误类型取错误归类中出现最多的类型
+func (s *AIService) failedAttempts(courseID uint, from, to time.Time) ([]*failedAttempt, error) {
+	var attempts []*failedAttempt
+	err := s.db.Model(&models.UserProblem{}).
+		Select("user_problems.id AS record_id, user_problems.user_id, user_problems.problem_id, user_problems.knowledge_point_id, "+
+			"user_problems.typed_code, COALESCE(NULLIF(problems.title_cn, ''), problems.title) AS problem_title").
+		Joins("JOIN knowledge_points ON knowledge_points.id = user_problems.knowledge_point_id").
+		Joins("JOIN problems ON problems.id = user_problems.problem_id").
+		Where("knowledge_points.course_id = ? AND user_problems.status = ? AND user_problems.typed_code <> ''", courseID, models.ProblemStatusFailed).
+		Where("user_problems.updated_at BETWEEN ? AND ?", from, to).
+		Order("user_problems.id").
+		Scan(&attempts).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取失败作答失败: %v", err)
+	}
+	if len(attempts) == 0 {
+		return attempts, nil
+	}
+
+	recordIDs := make([]uint, 0, len(attempts))
+	byRecord := make(map[uint]*failedAttempt, len(attempts))
+	for _, attempt := range attempts {
+		recordIDs = append(recordIDs, attempt.RecordID)
+		byRecord[attempt.RecordID] = attempt
+	}
+
+	var codeErrors []models.CodeError
+	if err := s.db.Where("record_id IN ?", recordIDs).Order("id").Find(&codeErrors).Error; err != nil {
+		return nil, fmt.Errorf("获取错误归类失败: %v", err)
+	}
+	counts := make(map[uint]map[string]int)
+	for _, codeError := range codeErrors {
+		attempt := byRecord[codeError.RecordID]
+		attempt.Errors = append(attempt.Errors, codeError.Description)
+		if counts[codeError.RecordID] == nil {
+			counts[codeError.RecordID] = make(map[string]int)
+		}
+		counts[codeError.RecordID][codeError.Category]++
+		// 出现次数相同时保留先出现的类型
+		if counts[codeError.RecordID][codeError.Category] > counts[codeError.RecordID][attempt.Category] {
+			attempt.Category = codeError.Category
+		}
+	}
+	return attempts, nil
+}
+
+// clusterAttempts 按知识点和错误类型分组，组内同一题目的作答与聚类代表的代码相似度达到阈值时归为一类。
+// 少于最小聚类大小的聚类合并为该组的一个聚类，结果按作答数量从多到少排序
+func clusterAttempts(attempts []*failedAttempt) []*attemptCluster {
+	type groupKey struct {
+		knowledgePointID uint
+		category         string
+	}
+	groups := make(map[groupKey][]*attemptCluster)
+	var keys []groupKey
+
+	for _, attempt := range attempts {
+		key := groupKey{attempt.KnowledgePointID, attempt.Category}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		var matched *attemptCluster
+		for _, cluster := range groups[key] {
+			seed := cluster.attempts[0]
+			if seed.ProblemID == attempt.ProblemID && utils.CodeSimilarity(seed.TypedCode, attempt.TypedCode, "") >= config.LLM.ClusterSimilarity {
+				matched = cluster
+				break
+			}
+		}
+		if matched == nil {
+			matched = &attemptCluster{knowledgePointID: key.knowledgePointID, category: key.category}
+			groups[key] = append(groups[key], matched)
+		}
+		matched.attempts = append(matched.attempts, attempt)
+	}
+
+	var clusters []*attemptCluster
+	for _, key := range keys {
+		var rest *attemptCluster
+		for _, cluster := range groups[key] {
+			if len(cluster.attempts) >= config.LLM.ClusterMinSize {
+				clusters = append(clusters, cluster)
+				continue
+			}
+			if rest == nil {
+				rest = &attemptCluster{knowledgePointID: key.knowledgePointID, category: key.category}
+			}
+			rest.attempts = append(rest.attempts, cluster.attempts...)
+		}
+		if rest != nil {
+			clusters = append(clusters, rest)
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i].attempts) > len(clusters[j].attempts) })
+	return clusters
+}
+
+// ClusterMisconceptions 对课程在时间范围内的失败作答聚类，由模型总结每个聚类的共性误区并保存，返回生成的聚类数量。
+// 单个聚类总结失败时记录错误信息并继续处理其他聚类；任务被取消或额度用完时保存已生成的聚类后返回该错误
+func (s *AIService) ClusterMisconceptions(ctx context.Context, taskRecordID, courseID uint, from, to time.Time) (int, error) {
+	attempts, err := s.failedAttempts(courseID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	clusters := clusterAttempts(attempts)
+	if limit := config.LLM.ClusterMaxPerCourse; limit > 0 && len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+	if len(clusters) == 0 {
+		// 没有失败作答时保存一条空记录标记本次聚类，避免查询时返回上一次的聚类
+		marker := models.MisconceptionCluster{TaskRecordID: taskRecordID, CourseID: courseID, PeriodStart: from, PeriodEnd: to}
+		if err := s.db.Create(&marker).Error; err != nil {
+			return 0, fmt.Errorf("保存误区聚类失败: %v", err)
+		}
+		return 0, nil
+	}
+
+	var knowledgePoints []models.KnowledgePoint
+	if err := s.db.Where("course_id = ?", courseID).Find(&knowledgePoints).Error; err != nil {
+		return 0, fmt.Errorf("获取课程知识点失败: %v", err)
+	}
+	kpNames := make(map[uint]string, len(knowledgePoints))
+	for _, kp := range knowledgePoints {
+		kpNames[kp.ID] = kp.Name
+	}
+
+	provider, err := s.llm.Resolve(FeatureMisconceptions, "")
+	if err != nil {
+		return 0, err
+	}
+	ctx = withAIScope(ctx, FeatureMisconceptions, courseID)
+
+	// 先整理全部聚类的统计和示例，再逐个请求模型总结
+	records := make([]models.MisconceptionCluster, 0, len(clusters))
+	texts := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		students := make(map[uint]bool)
+		recordIDs := make([]string, 0, len(cluster.attempts))
+		for _, attempt := range cluster.attempts {
+			students[attempt.UserID] = true
+			recordIDs = append(recordIDs, strconv.FormatUint(uint64(attempt.RecordID), 10))
+		}
+
+		examples := make([]misconceptionExample, 0, misconceptionExampleLimit)
+		var text strings.Builder
+		for i, attempt := range cluster.attempts {
+			if i >= misconceptionExampleLimit {
+				break
+			}
+			code := attempt.TypedCode
+			if runes := []rune(code); len(runes) > misconceptionCodeLimit {
+				code = string(runes[:misconceptionCodeLimit]) + "\n..."
+			}
+			examples = append(examples, misconceptionExample{
+				RecordID:     attempt.RecordID,
+				ProblemID:    attempt.ProblemID,
+				ProblemTitle: attempt.ProblemTitle,
+				Code:         code,
+				Errors:       attempt.Errors,
+			})
+			fmt.Fprintf(&text, "作答 %d（题目：%s）：\n%s\n", i+1, attempt.ProblemTitle, code)
+			if len(attempt.Errors) > 0 {
+				fmt.Fprintf(&text, "错误：%s\n", strings.Join(attempt.Errors, "；"))
+			}
+			text.WriteString("\n")
+		}
+		examplesJSON, err := json.Marshal(examples)
+		if err != nil {
+			return 0, err
+		}
+
+		records = append(records, models.MisconceptionCluster{
+			TaskRecordID:     taskRecordID,
+			CourseID:         courseID,
+			KnowledgePointID: cluster.knowledgePointID,
+			Category:         cluster.category,
+			PeriodStart:      from,
+			PeriodEnd:        to,
+			RecordCount:      len(cluster.attempts),
+			StudentCount:     len(students),
+			RecordIDs:        strings.Join(recordIDs, ","),
+			Examples:         string(examplesJSON),
+			Provider:         provider.Name,
+		})
+		texts = append(texts, strings.TrimSpace(text.String()))
+	}
+
+	// 任务被取消或额度用完时不再请求模型，但已经总结的聚类和其余聚类的统计仍然保存，避免浪费已完成的调用
+	var stopErr error
+	for i := range records {
+		record := &records[i]
+		if stopErr == nil {
+			stopErr = ctx.Err()
+		}
+		if stopErr != nil {
+			record.SummaryError = fmt.Sprintf("未生成总结: %v", stopErr)
+			continue
+		}
+
+		prompt, err := s.prompt(PromptMisconceptions, courseID, PromptData{
+			KnowledgePoint: kpNames[record.KnowledgePointID],
+			ErrorCategory:  record.Category,
+			ClusterSize:    record.RecordCount,
+			Examples:       texts[i],
+		})
+		if err != nil {
+			record.SummaryError = err.Error()
+			continue
+		}
+
+		err = s.completeStructured(ctx, provider, prompt, func(content string) error {
+			var result struct {
+				Title      string `json:"title"`
+				Summary    string `json:"summary"`
+				Suggestion string `json:"suggestion"`
+			}
+			if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+				return fmt.Errorf("输出不是有效的 JSON: %v", err)
+			}
+			if strings.TrimSpace(result.Title) == "" || strings.TrimSpace(result.Summary) == "" {
+				return errors.New("缺少 title 或 summary")
+			}
+			record.Title = strings.TrimSpace(result.Title)
+			record.Summary = strings.TrimSpace(result.Summary)
+			record.Suggestion = strings.TrimSpace(result.Suggestion)
+			return nil
+		})
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrAIQuotaExceeded) {
+			stopErr = err
+			record.SummaryError = fmt.Sprintf("未生成总结: %v", err)
+			continue
+		}
+		if err != nil {
+			record.SummaryError = err.Error()
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存误区聚类失败: %v", err)
+	}
+	return len(records), stopErr
+}
+
+// GetMisconceptionClusters 获取课程最近一次生成的聚类，knowledgePointID 非 0 时只返回该知识点的聚类
+func (s *CourseService) GetMisconceptionClusters(courseID, knowledgePointID uint) (map[string]interface{}, error) {
+	var latest models.MisconceptionCluster
+	err := s.db.Where("course_id = ?", courseID).Order("id DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return map[string]interface{}{"items": []models.MisconceptionCluster{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Where("course_id = ? AND task_record_id = ? AND record_count > 0", courseID, latest.TaskRecordID)
+	if knowledgePointID != 0 {
+		query = query.Where("knowledge_point_id = ?", knowledgePointID)
+	}
+	var clusters []models.MisconceptionCluster
+	if err := query.Order("record_count DESC, id").Find(&clusters).Error; err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"task_record_id": latest.TaskRecordID,
+		"period_start":   latest.PeriodStart,
+		"period_end":     latest.PeriodEnd,
+		"items":          clusters,
+	}, nil
+}
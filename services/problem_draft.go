@@ -0,0 +1,302 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 生成草稿时参考解答默认使用的语言
+const defaultDraftLanguage = "cpp"
+
+// draftResult 模型返回的题目草稿
+type draftResult struct {
+	Title             string `json:"title"`
+	TitleCn           string `json:"title_cn"`
+	Content           string `json:"content"`
+	ContentCn         string `json:"content_cn"`
+	SampleTestcases   string `json:"sample_testcases"`
+	TestCases         string `json:"test_cases"`
+	ReferenceSolution string `json:"reference_solution"`
+	TimeLimit         int    `json:"time_limit"`
+	MemoryLimit       int    `json:"memory_limit"`
+}
+
+// DraftProblem 根据知识点、目标难度和额外要求让模型生成题目草稿，草稿默认关联知识点的全部标签
+func (s *AIService) DraftProblem(ctx context.Context, creatorID, knowledgePointID uint, difficulty models.ProblemDifficulty, constraints, language, modelType string) (*models.ProblemDraft, error) {
+	if language == "" {
+		language = defaultDraftLanguage
+	}
+
+	var knowledgePoint models.KnowledgePoint
+	if err := s.db.First(&knowledgePoint, knowledgePointID).Error; err != nil {
+		return nil, fmt.Errorf("未找到知识点: %v", err)
+	}
+
+	var tags []models.Tag
+	err := s.db.Joins("JOIN knowledge_point_tags ON knowledge_point_tags.tag_id = tags.id").
+		Where("knowledge_point_tags.knowledge_point_id = ?", knowledgePointID).
+		Find(&tags).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取知识点标签失败: %v", err)
+	}
+
+	var tagList string
+	tagIDs := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		if i > 0 {
+			tagList += "\n"
+		}
+		tagList += fmt.Sprintf("%d. %s（%s）", i+1, tag.Name, tag.NameCn)
+		tagIDs = append(tagIDs, strconv.FormatUint(uint64(tag.ID), 10))
+	}
+	if tagList == "" {
+		tagList = "无"
+	}
+
+	prompt, err := s.prompt(PromptDraftProblem, knowledgePoint.CourseID, PromptData{
+		KnowledgePoint: knowledgePoint.Name,
+		TagList:        tagList,
+		Difficulty:     string(difficulty),
+		Constraints:    constraints,
+		Language:       language,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.llm.Resolve(FeatureDraftProblem, modelType)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureDraftProblem, knowledgePoint.CourseID)
+
+	content, err := s.complete(ctx, provider, prompt.System, prompt.User)
+	if err != nil {
+		return nil, err
+	}
+
+	var result draftResult
+	if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+		return nil, fmt.Errorf("解析题目草稿失败: %v", err)
+	}
+	if result.TitleCn == "" || result.ContentCn == "" {
+		return nil, errors.New("模型返回的题目草稿缺少标题或题面")
+	}
+	if result.TimeLimit <= 0 {
+		result.TimeLimit = 1000
+	}
+	if result.MemoryLimit <= 0 {
+		result.MemoryLimit = 128
+	}
+
+	draft := models.ProblemDraft{
+		KnowledgePointID:  knowledgePointID,
+		CreatorID:         creatorID,
+		Status:            models.ProblemDraftStatusDraft,
+		Provider:          provider.Name,
+		Constraints:       constraints,
+		Title:             result.Title,
+		TitleCn:           result.TitleCn,
+		Content:           result.Content,
+		ContentCn:         result.ContentCn,
+		Difficulty:        difficulty,
+		SampleTestcases:   result.SampleTestcases,
+		TestCases:         result.TestCases,
+		ReferenceSolution: result.ReferenceSolution,
+		ReferenceLanguage: language,
+		TimeLimit:         result.TimeLimit,
+		MemoryLimit:       result.MemoryLimit,
+		TagIDs:            strings.Join(tagIDs, ","),
+	}
+	if err := s.db.Create(&draft).Error; err != nil {
+		return nil, fmt.Errorf("保存题目草稿失败: %v", err)
+	}
+	return &draft, nil
+}
+
+// parseDraftTagIDs 解析草稿中以逗号分隔的标签ID
+func parseDraftTagIDs(value string) ([]uint, error) {
+	var tagIDs []uint
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的标签ID: %s", item)
+		}
+		tagIDs = append(tagIDs, uint(id))
+	}
+	return tagIDs, nil
+}
+
+// formatDraftTagIDs 将标签ID保存为以逗号分隔的字符串
+func formatDraftTagIDs(tagIDs []uint) string {
+	items := make([]string, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		items = append(items, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(items, ",")
+}
+
+// ListProblemDrafts 获取题目草稿列表，可按知识点和状态过滤
+func (s *ProblemService) ListProblemDrafts(knowledgePointID uint, status string) ([]models.ProblemDraft, error) {
+	query := s.db.Model(&models.ProblemDraft{})
+	if knowledgePointID != 0 {
+		query = query.Where("knowledge_point_id = ?", knowledgePointID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var drafts []models.ProblemDraft
+	if err := query.Order("id DESC").Find(&drafts).Error; err != nil {
+		return nil, fmt.Errorf("获取题目草稿失败: %v", err)
+	}
+	return drafts, nil
+}
+
+func (s *ProblemService) GetProblemDraft(draftID uint) (*models.ProblemDraft, error) {
+	var draft models.ProblemDraft
+	if err := s.db.First(&draft, draftID).Error; err != nil {
+		return nil, fmt.Errorf("题目草稿不存在: %v", err)
+	}
+	return &draft, nil
+}
+
+// UpdateProblemDraft 教师修改草稿，updates 中只包含需要修改的字段；tagIDs 为 nil 时不修改标签
+func (s *ProblemService) UpdateProblemDraft(draftID uint, updates map[string]interface{}, tagIDs []uint) (*models.ProblemDraft, error) {
+	draft, err := s.GetProblemDraft(draftID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.Status == models.ProblemDraftStatusPublished {
+		return nil, errors.New("草稿已发布，请直接修改题目")
+	}
+
+	if tagIDs != nil {
+		if err := s.checkTags(tagIDs); err != nil {
+			return nil, err
+		}
+		updates["tag_ids"] = formatDraftTagIDs(tagIDs)
+	}
+	if len(updates) == 0 {
+		return draft, nil
+	}
+
+	if err := s.db.Model(draft).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新题目草稿失败: %v", err)
+	}
+	return s.GetProblemDraft(draftID)
+}
+
+func (s *ProblemService) DeleteProblemDraft(draftID uint) error {
+	result := s.db.Delete(&models.ProblemDraft{}, draftID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("题目草稿不存在")
+	}
+	return nil
+}
+
+// checkTags 检查标签是否都存在
+func (s *ProblemService) checkTags(tagIDs []uint) error {
+	var count int64
+	if err := s.db.Model(&models.Tag{}).Where("id IN ?", tagIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("验证标签失败: %v", err)
+	}
+	if int(count) != len(tagIDs) {
+		return errors.New("部分标签不存在")
+	}
+	return nil
+}
+
+// PublishProblemDraft 将草稿发布为自定义题目，在同一事务中关联标签和草稿所属的知识点
+func (s *ProblemService) PublishProblemDraft(draftID uint) (*models.Problem, error) {
+	draft, err := s.GetProblemDraft(draftID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.Status == models.ProblemDraftStatusPublished {
+		return nil, errors.New("草稿已发布")
+	}
+	if draft.TitleCn == "" || draft.ContentCn == "" || draft.TestCases == "" {
+		return nil, errors.New("草稿缺少标题、题面或测试用例")
+	}
+
+	tagIDs, err := parseDraftTagIDs(draft.TagIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(tagIDs) == 0 {
+		return nil, errors.New("请至少为草稿选择一个标签")
+	}
+	if err := s.checkTags(tagIDs); err != nil {
+		return nil, err
+	}
+
+	problem := &models.Problem{
+		Title:             draft.Title,
+		TitleCn:           draft.TitleCn,
+		Content:           draft.Content,
+		ContentCn:         draft.ContentCn,
+		Difficulty:        draft.Difficulty,
+		SampleTestcases:   draft.SampleTestcases,
+		TestCases:         draft.TestCases,
+		IsCustom:          true,
+		TimeLimit:         draft.TimeLimit,
+		MemoryLimit:       draft.MemoryLimit,
+		ReferenceSolution: draft.ReferenceSolution,
+		ReferenceLanguage: draft.ReferenceLanguage,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(problem).Error; err != nil {
+			return fmt.Errorf("创建题目失败: %v", err)
+		}
+
+		for _, tagID := range tagIDs {
+			if err := tx.Create(&models.ProblemTag{
+				ProblemID: problem.ID,
+				TagID:     tagID,
+			}).Error; err != nil {
+				return fmt.Errorf("创建题目标签关联失败: %v", err)
+			}
+		}
+
+		if err := tx.Create(&models.KnowledgePointProblem{
+			KnowledgePointID: draft.KnowledgePointID,
+			ProblemID:        problem.ID,
+		}).Error; err != nil {
+			return fmt.Errorf("关联知识点失败: %v", err)
+		}
+
+		// 条件更新防止同一草稿被并发发布两次
+		result := tx.Model(&models.ProblemDraft{}).
+			Where("id = ? AND status = ?", draft.ID, models.ProblemDraftStatusDraft).
+			Updates(map[string]interface{}{"status": models.ProblemDraftStatusPublished, "problem_id": problem.ID})
+		if result.Error != nil {
+			return fmt.Errorf("更新草稿状态失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("草稿已发布")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return problem, nil
+}
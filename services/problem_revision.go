@@ -0,0 +1,227 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DiffProblemFields 比较两个版本的题目，返回发生变化的字段名；两个题目的 Tags 都需要已加载
+func DiffProblemFields(old, new *models.Problem) []string {
+	var fields []string
+	if old.Title != new.Title {
+		fields = append(fields, "title")
+	}
+	if old.TitleCn != new.TitleCn {
+		fields = append(fields, "title_cn")
+	}
+	if old.Content != new.Content {
+		fields = append(fields, "content")
+	}
+	if old.ContentCn != new.ContentCn {
+		fields = append(fields, "content_cn")
+	}
+	if old.Difficulty != new.Difficulty {
+		fields = append(fields, "difficulty")
+	}
+	if old.SampleTestcases != new.SampleTestcases {
+		fields = append(fields, "sample_testcases")
+	}
+	if old.TestCases != new.TestCases {
+		fields = append(fields, "test_cases")
+	}
+	if tagNames(old.Tags) != tagNames(new.Tags) {
+		fields = append(fields, "tags")
+	}
+	return fields
+}
+
+// RecordProblemRevision 在题目发生变化时写入修订记录，需在更新题目的事务中调用。
+// 题目第一次发生变化时会先把修改前的内容保存为基线版本
+func RecordProblemRevision(tx *gorm.DB, before, after *models.Problem, source string, editorID, taskRecordID uint) error {
+	fields := DiffProblemFields(before, after)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var latest models.ProblemRevision
+	err := tx.Where("problem_id = ?", after.ID).Order("version DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("获取题目修订记录失败: %v", err)
+	}
+
+	version := latest.Version
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		version++
+		baseline := newProblemRevision(before, version, models.ProblemRevisionSourceBaseline)
+		if err := tx.Create(baseline).Error; err != nil {
+			return fmt.Errorf("创建题目基线版本失败: %v", err)
+		}
+	}
+
+	version++
+	revision := newProblemRevision(after, version, source)
+	revision.EditorID = editorID
+	revision.TaskRecordID = taskRecordID
+	revision.ChangedFields = strings.Join(fields, ",")
+	if err := tx.Create(revision).Error; err != nil {
+		return fmt.Errorf("创建题目修订记录失败: %v", err)
+	}
+	return nil
+}
+
+func newProblemRevision(problem *models.Problem, version int, source string) *models.ProblemRevision {
+	return &models.ProblemRevision{
+		ProblemID:       problem.ID,
+		Version:         version,
+		Source:          source,
+		Title:           problem.Title,
+		TitleCn:         problem.TitleCn,
+		Content:         problem.Content,
+		ContentCn:       problem.ContentCn,
+		Difficulty:      problem.Difficulty,
+		SampleTestcases: problem.SampleTestcases,
+		TestCases:       problem.TestCases,
+		Tags:            tagNames(problem.Tags),
+	}
+}
+
+// tagNames 返回排序后以逗号连接的标签名，便于比较和存储
+func tagNames(tags []models.Tag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (s *ProblemService) GetProblemRevisions(problemID uint) ([]map[string]interface{}, error) {
+	var revisions []map[string]interface{}
+	err := s.db.Model(&models.ProblemRevision{}).
+		Select("id, version, source, editor_id, task_record_id, changed_fields, created_at").
+		Where("problem_id = ?", problemID).
+		Order("version DESC").
+		Scan(&revisions).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取题目修订记录失败: %v", err)
+	}
+	return revisions, nil
+}
+
+func (s *ProblemService) GetProblemRevision(problemID uint, version int) (*models.ProblemRevision, error) {
+	var revision models.ProblemRevision
+	err := s.db.Where("problem_id = ? AND version = ?", problemID, version).First(&revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("版本 %d 不存在", version)
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// DiffProblemRevisions 比较题目的两个版本，to 为 0 时取最新版本，from 为 0 时取 to 的上一个版本
+func (s *ProblemService) DiffProblemRevisions(problemID uint, from, to int) (map[string]interface{}, error) {
+	if to == 0 {
+		var latest models.ProblemRevision
+		err := s.db.Where("problem_id = ?", problemID).Order("version DESC").First(&latest).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("题目暂无修订记录")
+			}
+			return nil, err
+		}
+		to = latest.Version
+	}
+	if from == 0 {
+		from = to - 1
+	}
+
+	fromRevision, err := s.GetProblemRevision(problemID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.GetProblemRevision(problemID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string][2]string{
+		"title":            {fromRevision.Title, toRevision.Title},
+		"title_cn":         {fromRevision.TitleCn, toRevision.TitleCn},
+		"content":          {fromRevision.Content, toRevision.Content},
+		"content_cn":       {fromRevision.ContentCn, toRevision.ContentCn},
+		"difficulty":       {string(fromRevision.Difficulty), string(toRevision.Difficulty)},
+		"sample_testcases": {fromRevision.SampleTestcases, toRevision.SampleTestcases},
+		"test_cases":       {fromRevision.TestCases, toRevision.TestCases},
+		"tags":             {strings.ReplaceAll(fromRevision.Tags, ",", "\n"), strings.ReplaceAll(toRevision.Tags, ",", "\n")},
+	}
+
+	changes := make(map[string][]utils.DiffLine)
+	for field, values := range fields {
+		if values[0] != values[1] {
+			changes[field] = utils.DiffLines(values[0], values[1])
+		}
+	}
+
+	return map[string]interface{}{
+		"problem_id": problemID,
+		"from":       from,
+		"to":         to,
+		"changes":    changes,
+	}, nil
+}
+
+// UpdateProblem 教师编辑题目，题目发生变化时写入修订记录；tagIDs 为 nil 时不修改标签
+func (s *ProblemService) UpdateProblem(problemID, editorID uint, updates map[string]interface{}, tagIDs []uint) (*models.Problem, error) {
+	var problem models.Problem
+	if err := s.db.Preload("Tags").First(&problem, problemID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("题目不存在")
+		}
+		return nil, err
+	}
+	before := problem
+
+	var tags []models.Tag
+	if tagIDs != nil {
+		if err := s.db.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+			return nil, fmt.Errorf("验证标签失败: %v", err)
+		}
+		if len(tags) != len(tagIDs) {
+			return nil, fmt.Errorf("部分标签不存在")
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&problem).Omit("Tags").Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新题目失败: %v", err)
+			}
+		}
+		if tagIDs != nil {
+			if err := tx.Model(&problem).Association("Tags").Replace(tags); err != nil {
+				return fmt.Errorf("更新题目标签失败: %v", err)
+			}
+		}
+
+		var after models.Problem
+		if err := tx.Preload("Tags").First(&after, problemID).Error; err != nil {
+			return err
+		}
+		problem = after
+
+		return RecordProblemRevision(tx, &before, &after, models.ProblemRevisionSourceTeacher, editorID, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &problem, nil
+}
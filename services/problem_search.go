@@ -0,0 +1,309 @@
+package services
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 向量化的题目内容最大字符数
+	problemEmbeddingTextLimit = 2000
+	// 回填时每次从数据库读取的题目数量
+	problemEmbeddingPageSize = 200
+	// 语义检索默认和最多返回的题目数量
+	problemSearchDefaultLimit = 20
+	problemSearchMaxLimit     = 100
+)
+
+// problemVectors 缓存的题目向量，向量数量或最后更新时间变化时重新加载
+type problemVectors struct {
+	mu        sync.Mutex
+	model     string
+	count     int64
+	updatedAt time.Time
+	ids       []uint
+	vectors   [][]float64
+}
+
+var problemVectorCache = &problemVectors{}
+
+// problemEmbeddingText 拼接用于向量化的题目文本：中英文标题、标签和去掉 HTML 的题面
+func problemEmbeddingText(problem *models.Problem) string {
+	var text strings.Builder
+	text.WriteString(problem.Title)
+	if problem.TitleCn != "" {
+		text.WriteString(" " + problem.TitleCn)
+	}
+	text.WriteString("\n")
+	for i, tag := range problem.Tags {
+		if i > 0 {
+			text.WriteString(", ")
+		}
+		text.WriteString(tag.Name)
+		if tag.NameCn != "" {
+			text.WriteString(" " + tag.NameCn)
+		}
+	}
+	text.WriteString("\n")
+
+	content := problem.ContentCn
+	if content == "" {
+		content = problem.Content
+	}
+	content = utils.StripHTML(content)
+	if utf8.RuneCountInString(content) > problemEmbeddingTextLimit {
+		content = string([]rune(content)[:problemEmbeddingTextLimit])
+	}
+	text.WriteString(content)
+	return text.String()
+}
+
+// BackfillProblemEmbeddings 为没有向量、向量模型不同或内容已变化的题目生成向量，返回更新的题目数量。
+// onProgress 在确定需要更新的数量后和每批保存后调用
+func (s *ProblemService) BackfillProblemEmbeddings(ctx context.Context, onProgress func(total, done int)) (int, error) {
+	model := s.embedder.Model()
+
+	var existing []models.ProblemEmbedding
+	if err := s.db.Select("problem_id, embedding_model, content_hash").Find(&existing).Error; err != nil {
+		return 0, fmt.Errorf("获取题目向量失败: %v", err)
+	}
+	hashes := make(map[uint]string, len(existing))
+	for _, embedding := range existing {
+		if embedding.EmbeddingModel == model {
+			hashes[embedding.ProblemID] = embedding.ContentHash
+		}
+	}
+
+	type pendingProblem struct {
+		id   uint
+		text string
+		hash string
+	}
+	var pending []pendingProblem
+	var lastID uint
+	for {
+		var problems []models.Problem
+		err := s.db.Preload("Tags").
+			Select("id, title, title_cn, content, content_cn").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(problemEmbeddingPageSize).
+			Find(&problems).Error
+		if err != nil {
+			return 0, fmt.Errorf("获取题目失败: %v", err)
+		}
+		if len(problems) == 0 {
+			break
+		}
+		for i := range problems {
+			text := problemEmbeddingText(&problems[i])
+			sum := sha256.Sum256([]byte(text))
+			hash := hex.EncodeToString(sum[:])
+			if hashes[problems[i].ID] != hash {
+				pending = append(pending, pendingProblem{id: problems[i].ID, text: text, hash: hash})
+			}
+		}
+		lastID = problems[len(problems)-1].ID
+	}
+
+	if onProgress != nil {
+		onProgress(len(pending), 0)
+	}
+
+	batchSize := config.Embedding.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	done := 0
+	for start := 0; start < len(pending); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		end := start + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		texts := make([]string, 0, len(batch))
+		for _, item := range batch {
+			texts = append(texts, item.text)
+		}
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return done, err
+		}
+
+		embeddings := make([]models.ProblemEmbedding, 0, len(batch))
+		for i, item := range batch {
+			embeddings = append(embeddings, models.ProblemEmbedding{
+				ProblemID:      item.id,
+				EmbeddingModel: model,
+				ContentHash:    item.hash,
+				Dimensions:     len(vectors[i]),
+				Vector:         vectors[i],
+			})
+		}
+		err = s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "problem_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"embedding_model", "content_hash", "dimensions", "vector", "updated_at"}),
+		}).Create(&embeddings).Error
+		if err != nil {
+			return done, fmt.Errorf("保存题目向量失败: %v", err)
+		}
+
+		done += len(batch)
+		if onProgress != nil {
+			onProgress(len(pending), done)
+		}
+	}
+	return done, nil
+}
+
+// loadProblemVectors 获取当前模型的全部题目向量，数据库中的向量没有变化时使用缓存
+func (s *ProblemService) loadProblemVectors(model string) ([]uint, [][]float64, error) {
+	var stat struct {
+		Count     int64
+		UpdatedAt *time.Time
+	}
+	err := s.db.Model(&models.ProblemEmbedding{}).
+		Select("COUNT(*) AS count, MAX(updated_at) AS updated_at").
+		Where("embedding_model = ?", model).
+		Scan(&stat).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取题目向量失败: %v", err)
+	}
+	var updatedAt time.Time
+	if stat.UpdatedAt != nil {
+		updatedAt = *stat.UpdatedAt
+	}
+
+	cache := problemVectorCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.model == model && cache.count == stat.Count && cache.updatedAt.Equal(updatedAt) {
+		return cache.ids, cache.vectors, nil
+	}
+
+	var embeddings []models.ProblemEmbedding
+	if err := s.db.Select("problem_id, vector").Where("embedding_model = ?", model).Find(&embeddings).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取题目向量失败: %v", err)
+	}
+	ids := make([]uint, 0, len(embeddings))
+	vectors := make([][]float64, 0, len(embeddings))
+	for _, embedding := range embeddings {
+		ids = append(ids, embedding.ProblemID)
+		vectors = append(vectors, embedding.Vector)
+	}
+
+	cache.model = model
+	cache.count = stat.Count
+	cache.updatedAt = updatedAt
+	cache.ids = ids
+	cache.vectors = vectors
+	return ids, vectors, nil
+}
+
+// SearchProblems 按自然语言描述检索题目，按与题目向量的余弦相似度从高到低排序，可按难度过滤
+func (s *ProblemService) SearchProblems(ctx context.Context, query string, difficulty models.ProblemDifficulty, limit int) ([]map[string]interface{}, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("检索内容不能为空")
+	}
+	if limit <= 0 || limit > problemSearchMaxLimit {
+		limit = problemSearchDefaultLimit
+	}
+
+	model := s.embedder.Model()
+	ids, vectors, err := s.loadProblemVectors(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("题目向量尚未生成，请先执行向量回填任务")
+	}
+
+	queryVectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[uint]bool(nil)
+	if difficulty != "" {
+		var problemIDs []uint
+		if err := s.db.Model(&models.Problem{}).Where("difficulty = ?", difficulty).Pluck("id", &problemIDs).Error; err != nil {
+			return nil, fmt.Errorf("获取题目失败: %v", err)
+		}
+		allowed = make(map[uint]bool, len(problemIDs))
+		for _, id := range problemIDs {
+			allowed[id] = true
+		}
+	}
+
+	type scored struct {
+		id    uint
+		score float64
+	}
+	results := make([]scored, 0, len(ids))
+	for i, id := range ids {
+		if allowed != nil && !allowed[id] {
+			continue
+		}
+		results = append(results, scored{id: id, score: utils.CosineSimilarity(queryVectors[0], vectors[i])})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if len(results) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	problemIDs := make([]uint, 0, len(results))
+	for _, result := range results {
+		problemIDs = append(problemIDs, result.id)
+	}
+	var problems []models.Problem
+	err = s.db.Select("id, leetcode_id, title_slug, title, title_cn, difficulty, is_custom").
+		Where("id IN ?", problemIDs).
+		Find(&problems).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取题目失败: %v", err)
+	}
+	byID := make(map[uint]models.Problem, len(problems))
+	for _, problem := range problems {
+		byID[problem.ID] = problem
+	}
+
+	items := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		problem, ok := byID[result.id]
+		if !ok {
+			continue
+		}
+		items = append(items, map[string]interface{}{
+			"id":          problem.ID,
+			"leetcode_id": problem.LeetcodeID,
+			"title_slug":  problem.TitleSlug,
+			"title":       problem.Title,
+			"title_cn":    problem.TitleCn,
+			"difficulty":  problem.Difficulty,
+			"is_custom":   problem.IsCustom,
+			"score":       result.score,
+		})
+	}
+	return items, nil
+}
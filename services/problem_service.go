@@ -9,11 +9,12 @@ import (
 )
 
 type ProblemService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	embedder Embedder
 }
 
 func NewProblemService(db *gorm.DB) *ProblemService {
-	return &ProblemService{db: db}
+	return &ProblemService{db: db, embedder: NewEmbedder()}
 }
 
 func (s *ProblemService) GetCourseProblemList(courseID, userID uint, difficulty models.ProblemDifficulty, knowledgePointID uint, tagID uint) ([]map[string]interface{}, error) {
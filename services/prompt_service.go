@@ -0,0 +1,323 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+type PromptService struct {
+	db *gorm.DB
+}
+
+func NewPromptService(db *gorm.DB) *PromptService {
+	return &PromptService{db: db}
+}
+
+// renderedPrompt 渲染后的提示词，TemplateID 为 0 表示使用的是内置模板
+type renderedPrompt struct {
+	Key        string
+	TemplateID uint
+	Version    int
+	System     string
+	User       string
+	// 检索到的课程资料等随请求变化的上下文，参与响应缓存键的计算
+	Context string
+}
+
+// renderPrompt 渲染提示词：优先使用课程覆盖的模板，其次是全局模板，都没有时使用内置模板。
+// 数据库中的模板渲染失败时回退到内置模板，避免错误的模板导致 AI 功能不可用
+func renderPrompt(db *gorm.DB, key string, courseID uint, data PromptData) (*renderedPrompt, error) {
+	builtin, ok := builtinPrompts[key]
+	if !ok {
+		return nil, fmt.Errorf("未知的提示词模板: %s", key)
+	}
+
+	var tmpl models.PromptTemplate
+	err := db.Where("`key` = ? AND course_id IN ?", key, []uint{courseID, 0}).
+		Order("course_id DESC").
+		First(&tmpl).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取提示词模板失败: %v", err)
+	}
+
+	if err == nil {
+		systemPrompt := tmpl.SystemPrompt
+		if systemPrompt == "" {
+			systemPrompt = builtin.System
+		}
+		system, user, err := executePrompt(systemPrompt, tmpl.Content, data)
+		if err == nil {
+			return &renderedPrompt{Key: key, TemplateID: tmpl.ID, Version: tmpl.Version, System: system, User: user}, nil
+		}
+		log.Printf("提示词模板 %s (id=%d, version=%d) 渲染失败，使用内置模板: %v", key, tmpl.ID, tmpl.Version, err)
+	}
+
+	system, user, err := executePrompt(builtin.System, builtin.Content, data)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedPrompt{Key: key, System: system, User: user}, nil
+}
+
+// samplePromptData 预览和校验模板时使用的示例变量
+func samplePromptData(problem *models.Problem) PromptData {
+	data := newPromptData(problem)
+	data.Language = "cpp"
+	data.Code = "class Solution {\npublic:\n    // 学生代码\n};"
+	data.Question = "这道题应该从哪里入手？"
+	data.PreviousHints = []string{"想一想题目中的数据范围有什么特点。"}
+	data.KnowledgePoint = "动态规划"
+	data.TagList = "1. Dynamic Programming（动态规划）\n2. Array（数组）"
+	data.Summary = "学生询问了如何定义状态。"
+	data.Transcript = "学生：状态转移方程怎么写？\n助教：先想想 dp[i] 表示什么。"
+	data.Difficulty = string(models.ProblemDifficultyMedium)
+	data.Constraints = "数据范围不超过 10^5"
+	data.NumberedCode = numberLines(data.Code)
+	data.LineCount = countLines(data.Code)
+	data.Analysis = "**错误分析**：\n循环条件 i <= n 导致数组越界。"
+	data.KnowledgePoints = "1. 数组\n2. 哈希表"
+	data.ErrorCategory = models.ErrorCategoryOffByOne
+	data.ClusterSize = 12
+	data.Course = "数据结构与算法"
+	data.Stats = "- 数组：尝试 5 题，通过 4 题，失败 1 题\n- 动态规划：尝试 3 题，通过 1 题，失败 2 题"
+	data.RecentFailures = "- 最长递增子序列（动态规划）：状态转移时遗漏了 j < i 的条件"
+	data.HintUsage = "- 动态规划：请求提示 4 次，最高解锁到第 3 级"
+	data.Materials = "[1] 《第二章 线性表》 2.3 双指针\n在有序数组中，可以用左右两个指针从两端向中间移动来查找满足条件的元素对。"
+	data.Syllabus = "第一章 线性表：顺序表、链表\n第二章 栈与队列\n第三章 树与二叉树：遍历、二叉搜索树"
+	data.Candidates = "300. 最长递增子序列 [Medium] 动态规划\n70. 爬楼梯 [Easy] 动态规划"
+	data.Examples = "作答 1（题目：两数之和）：\n" + data.NumberedCode + "\n错误：循环条件 i <= n 导致数组越界。"
+	return data
+}
+
+// validatePrompt 使用示例变量渲染模板，检查语法错误和不存在的变量
+func validatePrompt(systemPrompt, content string) error {
+	if content == "" {
+		return errors.New("模板内容不能为空")
+	}
+	_, _, err := executePrompt(systemPrompt, content, samplePromptData(&models.Problem{Title: "Two Sum", Content: "示例题目"}))
+	return err
+}
+
+// ListTemplates 返回所有内置模板及数据库中的模板，courseID 非 0 时只返回全局模板和该课程的覆盖
+func (s *PromptService) ListTemplates(courseID uint) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(builtinPrompts))
+	for key := range builtinPrompts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	builtins := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		builtin := builtinPrompts[key]
+		builtins = append(builtins, map[string]interface{}{
+			"key":           key,
+			"name":          builtin.Name,
+			"system_prompt": builtin.System,
+			"content":       builtin.Content,
+		})
+	}
+
+	query := s.db.Model(&models.PromptTemplate{})
+	if courseID != 0 {
+		query = query.Where("course_id IN ?", []uint{courseID, 0})
+	}
+	var templates []models.PromptTemplate
+	if err := query.Order("`key`, course_id").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"builtin":   builtins,
+		"templates": templates,
+	}, nil
+}
+
+// GetTemplate 获取模板及其全部历史版本
+func (s *PromptService) GetTemplate(id uint) (*models.PromptTemplate, error) {
+	var tmpl models.PromptTemplate
+	err := s.db.Preload("Versions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("version DESC")
+	}).First(&tmpl, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("模板不存在: %v", err)
+	}
+	return &tmpl, nil
+}
+
+func (s *PromptService) CreateTemplate(editorID uint, key string, courseID uint, systemPrompt, content, comment string) (*models.PromptTemplate, error) {
+	if _, ok := builtinPrompts[key]; !ok {
+		return nil, fmt.Errorf("未知的提示词模板: %s", key)
+	}
+	if courseID != 0 {
+		if err := s.db.First(&models.Course{}, courseID).Error; err != nil {
+			return nil, fmt.Errorf("课程不存在: %v", err)
+		}
+	}
+	if err := validatePrompt(systemPrompt, content); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := s.db.Model(&models.PromptTemplate{}).Where("`key` = ? AND course_id = ?", key, courseID).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("模板 %s 已存在，请直接修改", key)
+	}
+
+	tmpl := models.PromptTemplate{
+		Key:          key,
+		CourseID:     courseID,
+		SystemPrompt: systemPrompt,
+		Content:      content,
+		Version:      1,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tmpl).Error; err != nil {
+			return err
+		}
+		return tx.Create(newPromptTemplateVersion(&tmpl, editorID, comment)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建模板失败: %v", err)
+	}
+	return &tmpl, nil
+}
+
+// UpdateTemplate 修改模板，旧内容保留在历史版本中
+func (s *PromptService) UpdateTemplate(editorID, id uint, systemPrompt, content, comment string) (*models.PromptTemplate, error) {
+	if err := validatePrompt(systemPrompt, content); err != nil {
+		return nil, err
+	}
+	return s.saveVersion(editorID, id, systemPrompt, content, comment)
+}
+
+// RollbackTemplate 将模板恢复为指定的历史版本，回滚本身也会生成一个新版本
+func (s *PromptService) RollbackTemplate(editorID, id uint, version int) (*models.PromptTemplate, error) {
+	var target models.PromptTemplateVersion
+	if err := s.db.Where("template_id = ? AND version = ?", id, version).First(&target).Error; err != nil {
+		return nil, fmt.Errorf("版本不存在: %v", err)
+	}
+	return s.saveVersion(editorID, id, target.SystemPrompt, target.Content, fmt.Sprintf("回滚到版本 %d", version))
+}
+
+func (s *PromptService) saveVersion(editorID, id uint, systemPrompt, content, comment string) (*models.PromptTemplate, error) {
+	var tmpl models.PromptTemplate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tmpl, id).Error; err != nil {
+			return fmt.Errorf("模板不存在: %v", err)
+		}
+
+		tmpl.SystemPrompt = systemPrompt
+		tmpl.Content = content
+		tmpl.Version++
+		if err := tx.Save(&tmpl).Error; err != nil {
+			return err
+		}
+		return tx.Create(newPromptTemplateVersion(&tmpl, editorID, comment)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// DeleteTemplate 删除模板，之后该 key 回退到全局模板或内置模板。
+// 历史版本仍被评分、提示记录等引用，因此保留；模板本身软删除并释放 (key, course_id) 唯一索引
+func (s *PromptService) DeleteTemplate(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PromptTemplate{}).Where("id = ?", id).Update("active", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("模板不存在")
+		}
+		return tx.Delete(&models.PromptTemplate{}, id).Error
+	})
+}
+
+// PreviewRequest 预览参数，SystemPrompt / Content 为空时预览当前生效的模板
+type PreviewRequest struct {
+	Key          string
+	CourseID     uint
+	ProblemID    uint
+	SystemPrompt string
+	Content      string
+	Language     string
+	Code         string
+	Question     string
+}
+
+// Preview 使用示例题目渲染模板，未指定题目时使用题库中的第一道题
+func (s *PromptService) Preview(req PreviewRequest) (map[string]interface{}, error) {
+	builtin, ok := builtinPrompts[req.Key]
+	if !ok {
+		return nil, fmt.Errorf("未知的提示词模板: %s", req.Key)
+	}
+
+	var problem models.Problem
+	query := s.db.Model(&models.Problem{})
+	if req.ProblemID != 0 {
+		query = query.Where("id = ?", req.ProblemID)
+	}
+	if err := query.First(&problem).Error; err != nil {
+		return nil, fmt.Errorf("获取示例题目失败: %v", err)
+	}
+
+	data := samplePromptData(&problem)
+	if req.Language != "" {
+		data.Language = req.Language
+	}
+	if req.Code != "" {
+		data.Code = req.Code
+	}
+	if req.Question != "" {
+		data.Question = req.Question
+	}
+
+	if req.Content == "" {
+		rendered, err := renderPrompt(s.db, req.Key, req.CourseID, data)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"problem_id":    problem.ID,
+			"template_id":   rendered.TemplateID,
+			"version":       rendered.Version,
+			"system_prompt": rendered.System,
+			"content":       rendered.User,
+		}, nil
+	}
+
+	systemPrompt := req.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = builtin.System
+	}
+	system, user, err := executePrompt(systemPrompt, req.Content, data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"problem_id":    problem.ID,
+		"system_prompt": system,
+		"content":       user,
+	}, nil
+}
+
+func newPromptTemplateVersion(tmpl *models.PromptTemplate, editorID uint, comment string) *models.PromptTemplateVersion {
+	return &models.PromptTemplateVersion{
+		TemplateID:   tmpl.ID,
+		Version:      tmpl.Version,
+		SystemPrompt: tmpl.SystemPrompt,
+		Content:      tmpl.Content,
+		EditorID:     editorID,
+		Comment:      comment,
+	}
+}
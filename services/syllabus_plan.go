@@ -0,0 +1,372 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 发送给模型的课程大纲最大字符数
+const syllabusMaxLength = 20000
+
+// DraftSyllabusPlan 根据课程大纲让模型生成有序的知识点和推荐标签，保存为待审阅的计划。
+// courseID 非 0 时计划应用到已有课程，否则应用时以 courseName 新建课程
+func (s *AIService) DraftSyllabusPlan(ctx context.Context, creatorID, courseID uint, courseName, syllabus string) (*models.SyllabusPlan, error) {
+	syllabus = strings.TrimSpace(syllabus)
+	if syllabus == "" {
+		return nil, errors.New("课程大纲不能为空")
+	}
+	if utf8.RuneCountInString(syllabus) > syllabusMaxLength {
+		syllabus = string([]rune(syllabus)[:syllabusMaxLength])
+	}
+
+	existing := make(map[string]bool)
+	var pointList string
+	if courseID != 0 {
+		var course models.Course
+		if err := s.db.First(&course, courseID).Error; err != nil {
+			return nil, fmt.Errorf("课程不存在: %v", err)
+		}
+		courseName = course.Name
+
+		var points []models.KnowledgePoint
+		if err := s.db.Where("course_id = ?", courseID).Order("position, id").Find(&points).Error; err != nil {
+			return nil, fmt.Errorf("获取课程知识点失败: %v", err)
+		}
+		names := make([]string, 0, len(points))
+		for i, point := range points {
+			existing[point.Name] = true
+			names = append(names, fmt.Sprintf("%d. %s", i+1, point.Name))
+		}
+		pointList = strings.Join(names, "\n")
+	} else {
+		courseName = strings.TrimSpace(courseName)
+		if courseName == "" {
+			return nil, errors.New("请指定课程或填写新课程名称")
+		}
+	}
+
+	var tags []models.Tag
+	if err := s.db.Order("id").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("获取已有标签失败: %v", err)
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("当前暂无标签")
+	}
+	tagList := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		tagList = append(tagList, fmt.Sprintf("%d. %s（%s）", i+1, tag.Name, tag.NameCn))
+	}
+
+	prompt, err := s.prompt(PromptSyllabusPlan, courseID, PromptData{
+		Course:          courseName,
+		Syllabus:        syllabus,
+		KnowledgePoints: pointList,
+		TagList:         strings.Join(tagList, "\n"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.llm.Resolve(FeatureSyllabusPlan, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAIScope(ctx, FeatureSyllabusPlan, courseID)
+
+	var points []models.SyllabusPlanPoint
+	err = s.completeStructured(ctx, provider, prompt, func(content string) error {
+		var result struct {
+			KnowledgePoints []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				Tags        []int  `json:"tags"`
+			} `json:"knowledge_points"`
+		}
+		if err := json.Unmarshal([]byte(utils.ExtractJSON(content)), &result); err != nil {
+			return fmt.Errorf("输出不是有效的 JSON: %v", err)
+		}
+		if len(result.KnowledgePoints) == 0 {
+			return errors.New("knowledge_points 不能为空")
+		}
+
+		points = make([]models.SyllabusPlanPoint, 0, len(result.KnowledgePoints))
+		seen := make(map[string]bool, len(result.KnowledgePoints))
+		for i, item := range result.KnowledgePoints {
+			name := strings.TrimSpace(item.Name)
+			if name == "" {
+				return fmt.Errorf("第 %d 个知识点缺少 name", i+1)
+			}
+			if seen[name] || existing[name] {
+				return fmt.Errorf("知识点 %q 重复", name)
+			}
+			seen[name] = true
+
+			point := models.SyllabusPlanPoint{
+				Position:    i + 1,
+				Name:        name,
+				Description: strings.TrimSpace(item.Description),
+				TagIDs:      []uint{},
+				TagNames:    []string{},
+			}
+			selected := make(map[int]bool, len(item.Tags))
+			for _, index := range item.Tags {
+				if index < 1 || index > len(tags) {
+					return fmt.Errorf("知识点 %q 的标签序号 %d 不在已有标签列表中", name, index)
+				}
+				if selected[index] {
+					continue
+				}
+				selected[index] = true
+				point.TagIDs = append(point.TagIDs, tags[index-1].ID)
+				point.TagNames = append(point.TagNames, tags[index-1].Name)
+			}
+			points = append(points, point)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := models.SyllabusPlan{
+		CourseID:      courseID,
+		CourseName:    courseName,
+		CreatorID:     creatorID,
+		Status:        models.SyllabusPlanStatusDraft,
+		Provider:      provider.Name,
+		TemplateID:    prompt.TemplateID,
+		PromptVersion: prompt.Version,
+		Syllabus:      syllabus,
+		Points:        points,
+	}
+	if err := s.db.Create(&plan).Error; err != nil {
+		return nil, fmt.Errorf("保存知识点计划失败: %v", err)
+	}
+	return &plan, nil
+}
+
+// ListSyllabusPlans 获取知识点计划列表，可按课程和状态过滤
+func (s *CourseService) ListSyllabusPlans(courseID uint, status string) ([]models.SyllabusPlan, error) {
+	query := s.db.Model(&models.SyllabusPlan{})
+	if courseID != 0 {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var plans []models.SyllabusPlan
+	if err := query.Order("id DESC").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("获取知识点计划失败: %v", err)
+	}
+	return plans, nil
+}
+
+func (s *CourseService) GetSyllabusPlan(planID uint) (*models.SyllabusPlan, error) {
+	var plan models.SyllabusPlan
+	if err := s.db.First(&plan, planID).Error; err != nil {
+		return nil, fmt.Errorf("知识点计划不存在: %v", err)
+	}
+	return &plan, nil
+}
+
+// checkSyllabusPoints 校验教师修改后的知识点：名称不能为空且不能重复，标签必须存在。
+// 按 Position 排序后重新编号，并根据标签ID重新填写标签名称
+func (s *CourseService) checkSyllabusPoints(points []models.SyllabusPlanPoint) error {
+	if len(points) == 0 {
+		return errors.New("知识点不能为空")
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Position < points[j].Position })
+
+	seen := make(map[string]bool, len(points))
+	var tagIDs []uint
+	for i := range points {
+		point := &points[i]
+		point.Name = strings.TrimSpace(point.Name)
+		point.Description = strings.TrimSpace(point.Description)
+		if point.Name == "" {
+			return fmt.Errorf("第 %d 个知识点名称不能为空", i+1)
+		}
+		if seen[point.Name] {
+			return fmt.Errorf("知识点 %q 重复", point.Name)
+		}
+		seen[point.Name] = true
+		point.Position = i + 1
+		if point.TagIDs == nil {
+			point.TagIDs = []uint{}
+		}
+		tagIDs = append(tagIDs, point.TagIDs...)
+	}
+
+	var tags []models.Tag
+	if len(tagIDs) > 0 {
+		if err := s.db.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+			return fmt.Errorf("验证标签失败: %v", err)
+		}
+	}
+	tagNames := make(map[uint]string, len(tags))
+	for _, tag := range tags {
+		tagNames[tag.ID] = tag.Name
+	}
+	for i := range points {
+		point := &points[i]
+		point.TagNames = make([]string, 0, len(point.TagIDs))
+		for _, tagID := range point.TagIDs {
+			name, ok := tagNames[tagID]
+			if !ok {
+				return fmt.Errorf("标签 %d 不存在", tagID)
+			}
+			point.TagNames = append(point.TagNames, name)
+		}
+	}
+	return nil
+}
+
+// UpdateSyllabusPlan 教师修改计划，courseName 为空时不修改，points 为 nil 时不修改知识点
+func (s *CourseService) UpdateSyllabusPlan(planID uint, courseName string, points []models.SyllabusPlanPoint) (*models.SyllabusPlan, error) {
+	plan, err := s.GetSyllabusPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == models.SyllabusPlanStatusApplied {
+		return nil, errors.New("计划已应用，请直接修改课程知识点")
+	}
+
+	if courseName = strings.TrimSpace(courseName); courseName != "" {
+		if plan.CourseID != 0 {
+			return nil, errors.New("计划应用到已有课程，不能修改课程名称")
+		}
+		plan.CourseName = courseName
+	}
+	if points != nil {
+		if err := s.checkSyllabusPoints(points); err != nil {
+			return nil, err
+		}
+		plan.Points = points
+	}
+
+	if err := s.db.Select("course_name", "points").Save(plan).Error; err != nil {
+		return nil, fmt.Errorf("更新知识点计划失败: %v", err)
+	}
+	return plan, nil
+}
+
+func (s *CourseService) DeleteSyllabusPlan(planID uint) error {
+	result := s.db.Delete(&models.SyllabusPlan{}, planID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("知识点计划不存在")
+	}
+	return nil
+}
+
+// ApplySyllabusPlan 在同一事务中创建课程（计划未指定课程时）、按顺序追加知识点并关联标签
+func (s *CourseService) ApplySyllabusPlan(planID uint) (map[string]interface{}, error) {
+	plan, err := s.GetSyllabusPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == models.SyllabusPlanStatusApplied {
+		return nil, errors.New("计划已应用")
+	}
+	if err := s.checkSyllabusPoints(plan.Points); err != nil {
+		return nil, err
+	}
+
+	course := models.Course{Name: plan.CourseName}
+	var points []models.KnowledgePoint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if plan.CourseID != 0 {
+			if err := tx.First(&course, plan.CourseID).Error; err != nil {
+				return fmt.Errorf("课程不存在: %v", err)
+			}
+		} else {
+			var count int64
+			if err := tx.Model(&models.Course{}).Where("name = ?", plan.CourseName).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("课程: %s已存在", plan.CourseName)
+			}
+			if err := tx.Create(&course).Error; err != nil {
+				return fmt.Errorf("创建课程失败: %v", err)
+			}
+		}
+
+		var existing []models.KnowledgePoint
+		if err := tx.Where("course_id = ?", course.ID).Find(&existing).Error; err != nil {
+			return fmt.Errorf("获取课程知识点失败: %v", err)
+		}
+		base := 0
+		names := make(map[string]bool, len(existing))
+		for _, point := range existing {
+			names[point.Name] = true
+			if point.Position > base {
+				base = point.Position
+			}
+		}
+
+		points = make([]models.KnowledgePoint, 0, len(plan.Points))
+		for _, item := range plan.Points {
+			if names[item.Name] {
+				return fmt.Errorf("课程中已存在知识点 %q", item.Name)
+			}
+			points = append(points, models.KnowledgePoint{
+				Name:        item.Name,
+				CourseID:    course.ID,
+				Position:    base + item.Position,
+				Description: item.Description,
+			})
+		}
+		if err := tx.Create(&points).Error; err != nil {
+			return fmt.Errorf("创建知识点失败: %v", err)
+		}
+
+		var pointTags []models.KnowledgePointTag
+		for i, item := range plan.Points {
+			for _, tagID := range item.TagIDs {
+				pointTags = append(pointTags, models.KnowledgePointTag{
+					KnowledgePointID: points[i].ID,
+					TagID:            tagID,
+				})
+			}
+		}
+		if len(pointTags) > 0 {
+			if err := tx.Create(&pointTags).Error; err != nil {
+				return fmt.Errorf("创建知识点标签关联失败: %v", err)
+			}
+		}
+
+		// 条件更新防止同一计划被并发应用两次
+		result := tx.Model(&models.SyllabusPlan{}).
+			Where("id = ? AND status = ?", plan.ID, models.SyllabusPlanStatusDraft).
+			Updates(map[string]interface{}{"status": models.SyllabusPlanStatusApplied, "course_id": course.ID})
+		if result.Error != nil {
+			return fmt.Errorf("更新计划状态失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("计划已应用")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"course_id":   course.ID,
+		"course_name": course.Name,
+		"points":      points,
+	}, nil
+}
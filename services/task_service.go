@@ -0,0 +1,76 @@
+package services
+
+import (
+	"ai_teach_system/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskService struct {
+	db *gorm.DB
+}
+
+func NewTaskService(db *gorm.DB) *TaskService {
+	return &TaskService{db: db}
+}
+
+// TaskFilter 任务列表筛选条件
+type TaskFilter struct {
+	TaskType string
+	Status   models.TaskStatus
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	PageSize int
+}
+
+func (s *TaskService) ListTasks(filter TaskFilter) (map[string]interface{}, error) {
+	query := s.db.Model(&models.TaskRecord{})
+	if filter.TaskType != "" {
+		query = query.Where("task_type = ?", filter.TaskType)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var records []models.TaskRecord
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total": total,
+		"items": records,
+	}, nil
+}
+
+func (s *TaskService) GetTask(taskID uint) (*models.TaskRecord, error) {
+	var record models.TaskRecord
+	if err := s.db.First(&record, taskID).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 上传的文本文件最大字节数
+const textFileMaxSize = 1 << 20
+
+// 支持上传的文本文件类型，PDF 和课件需要先导出为文本
+var textFileExts = map[string]bool{
+	".txt":      true,
+	".md":       true,
+	".markdown": true,
+}
+
+// ReadTextFile 读取上传的 UTF-8 纯文本或 Markdown 文件
+func ReadTextFile(file *multipart.FileHeader) (string, error) {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !textFileExts[ext] {
+		return "", fmt.Errorf("不支持的文件类型 %s，请上传 txt 或 md 文件", ext)
+	}
+	if file.Size > textFileMaxSize {
+		return "", errors.New("文件不能超过 1MB")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	defer src.Close()
+
+	content, err := io.ReadAll(io.LimitReader(src, textFileMaxSize))
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	if !utf8.Valid(content) {
+		return "", errors.New("文件必须是 UTF-8 编码的文本")
+	}
+	return string(content), nil
+}
@@ -5,6 +5,7 @@ import (
 	"ai_teach_system/utils"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -130,8 +131,13 @@ func (s *UserService) GetCourseTryRecords(courseID, userID uint) ([]map[string]i
 		return nil, err
 	}
 
+	aiConfig, err := loadCourseAIConfig(s.db, courseID)
+	if err != nil {
+		return nil, err
+	}
+
 	var records []map[string]interface{}
-	err = s.db.Select("user_problems.id, user_problems.problem_id, knowledge_points.name AS knowledge_point_name, problems.title_cn, problems.title, user_problems.status, user_problems.updated_at").
+	err = s.db.Select("user_problems.id, user_problems.problem_id, knowledge_points.name AS knowledge_point_name, problems.title_cn, problems.title, user_problems.status, user_problems.hint_level, user_problems.updated_at").
 		Model(&models.UserProblem{}).
 		Joins("JOIN knowledge_points ON user_problems.knowledge_point_id = knowledge_points.id").
 		Joins("JOIN problems ON user_problems.problem_id = problems.id").
@@ -142,12 +148,20 @@ func (s *UserService) GetCourseTryRecords(courseID, userID uint) ([]map[string]i
 		return nil, err
 	}
 
+	// 按使用的提示等级计算得分
+	for _, record := range records {
+		status, _ := record["status"].(string)
+		hintLevel := toInt(record["hint_level"])
+		record["score"] = hintScore(aiConfig, models.ProblemStatus(status), hintLevel)
+	}
+
 	return records, err
 }
 
 func (s *UserService) GetTryRecordDetail(recordID uint) (map[string]interface{}, error) {
 	var result map[string]interface{}
-	err := s.db.Select("problems.title, problems.title_cn, problems.content, problems.content_cn, problems.difficulty, user_problems.typed_code, user_problems.deepseek_wrong_reason_and_analyze, qwen_wrong_reason_and_analyze, user_problems.qwen_corrected_code, user_problems.deepseek_corrected_code").
+	err := s.db.Select("problems.title, problems.title_cn, problems.content, problems.content_cn, problems.difficulty, user_problems.typed_code, user_problems.hint_level, user_problems.deepseek_wrong_reason_and_analyze, qwen_wrong_reason_and_analyze, user_problems.qwen_corrected_code, user_problems.deepseek_corrected_code, "+
+		"user_problems.qwen_wrong_reason_and_analyze_model, user_problems.deepseek_wrong_reason_and_analyze_model, user_problems.qwen_corrected_code_model, user_problems.deepseek_corrected_code_model").
 		Model(&models.UserProblem{}).
 		Joins("JOIN problems ON user_problems.problem_id = problems.id").
 		Where("user_problems.id = ?", recordID).
@@ -157,6 +171,19 @@ func (s *UserService) GetTryRecordDetail(recordID uint) (map[string]interface{},
 		return nil, err
 	}
 
+	codeErrors, err := recordCodeErrors(s.db, recordID)
+	if err != nil {
+		return nil, err
+	}
+	reviewComments, err := recordReviewComments(s.db, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		result["code_errors"] = codeErrors
+		result["review_comments"] = reviewComments
+	}
+
 	return result, nil
 }
 
@@ -223,6 +250,16 @@ func (s *UserService) GetUserListByCourseAndClass(classID, courseID uint) ([]map
 			return nil, err
 		}
 
+		// 查询提示使用次数
+		var hintCount int64
+		err = s.db.Model(&models.HintRecord{}).
+			Where("user_id = ? AND knowledge_point_id in (?)", user.ID, courseKnowledgePointIDs).
+			Count(&hintCount).
+			Error
+		if err != nil {
+			return nil, err
+		}
+
 		// 进度
 		var progress float64
 		if totalProblemCount > 0 {
@@ -248,6 +285,7 @@ func (s *UserService) GetUserListByCourseAndClass(classID, courseID uint) ([]map
 			"wrong_count":  wrongCount,
 			"correct_rate": correctRate,
 			"progress":     progress,
+			"hint_count":   hintCount,
 		}
 	}
 	return result, nil
@@ -377,3 +415,24 @@ func (s *UserService) DeleteUser(userID uint) error {
 		return nil
 	})
 }
+
+// toInt 将 Scan 到 map 中的整数字段转换为 int
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return v
+	case uint64:
+		return int(v)
+	case []byte:
+		n, _ := strconv.Atoi(string(v))
+		return n
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
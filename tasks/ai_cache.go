@@ -0,0 +1,19 @@
+package tasks
+
+import (
+	"ai_teach_system/services"
+	"context"
+	"log"
+)
+
+const TaskTypePurgeAICache = "purge_ai_cache"
+
+// purgeAICacheJob 清理已过期的 AI 响应缓存
+func (tm *TasksManager) purgeAICacheJob(ctx context.Context) error {
+	deleted, err := services.PurgeExpiredAICache(tm.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	log.Printf("已清理 %d 条过期的 AI 响应缓存", deleted)
+	return nil
+}
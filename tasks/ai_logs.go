@@ -0,0 +1,19 @@
+package tasks
+
+import (
+	"ai_teach_system/services"
+	"context"
+	"log"
+)
+
+const TaskTypePurgeAILogs = "purge_ai_logs"
+
+// purgeAILogsJob 清理超过保留天数的 AI 调用日志
+func (tm *TasksManager) purgeAILogsJob(ctx context.Context) error {
+	deleted, err := services.PurgeExpiredAILogs(tm.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	log.Printf("已清理 %d 条过期的 AI 调用日志", deleted)
+	return nil
+}
@@ -0,0 +1,20 @@
+package tasks
+
+import (
+	"context"
+	"log"
+)
+
+const TaskTypeProcessAutoAnalyses = "process_auto_analyses"
+
+// processAutoAnalysesJob 执行提交失败后创建的自动分析，包括等待重试的分析
+func (tm *TasksManager) processAutoAnalysesJob(ctx context.Context) error {
+	processed, err := tm.aiService.ProcessAutoAnalyses(ctx)
+	if err != nil {
+		return err
+	}
+	if processed > 0 {
+		log.Printf("已执行 %d 个自动分析", processed)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"ai_teach_system/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const TaskTypeBackfillProblemEmbeddings = "backfill_problem_embeddings"
+
+// backfillEmbeddingsJob 定时任务入口，为新增和内容变化的题目生成向量
+func (tm *TasksManager) backfillEmbeddingsJob(ctx context.Context) error {
+	taskRecord, err := tm.createEmbeddingRecord()
+	if err != nil {
+		return err
+	}
+	return tm.runBackfillEmbeddings(ctx, taskRecord)
+}
+
+// StartBackfillEmbeddings 在后台启动一次题目向量回填，立即返回新建的任务记录
+func (tm *TasksManager) StartBackfillEmbeddings() (*models.TaskRecord, error) {
+	ctx, release, err := tm.acquireLease(context.Background(), TaskTypeBackfillProblemEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+
+	taskRecord, err := tm.createEmbeddingRecord()
+	if err != nil {
+		release(err)
+		return nil, err
+	}
+
+	snapshot := *taskRecord
+	go func() {
+		err := tm.runBackfillEmbeddings(ctx, taskRecord)
+		if err != nil {
+			log.Printf("题目向量回填任务 %d 失败: %v", taskRecord.ID, err)
+		}
+		release(err)
+	}()
+	return &snapshot, nil
+}
+
+func (tm *TasksManager) createEmbeddingRecord() (*models.TaskRecord, error) {
+	now := time.Now()
+	taskRecord := &models.TaskRecord{
+		TaskType:  TaskTypeBackfillProblemEmbeddings,
+		Status:    models.TaskStatusPending,
+		StartTime: &now,
+	}
+	if err := tm.db.Create(taskRecord).Error; err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+	return taskRecord, nil
+}
+
+func (tm *TasksManager) runBackfillEmbeddings(ctx context.Context, taskRecord *models.TaskRecord) error {
+	save := func() {
+		if err := tm.saveTaskRecord(taskRecord); err != nil {
+			log.Printf("保存任务记录失败: %v", err)
+		}
+	}
+
+	ctx, done := tm.track(ctx, taskRecord.ID)
+	defer done()
+
+	taskRecord.Status = models.TaskStatusRunning
+	save()
+
+	_, err := tm.problemService.BackfillProblemEmbeddings(ctx, func(total, done int) {
+		taskRecord.TotalCount = total
+		taskRecord.SuccessCount = done
+		save()
+	})
+
+	endTime := time.Now()
+	taskRecord.EndTime = &endTime
+	switch {
+	case errors.Is(err, context.Canceled):
+		taskRecord.Status = models.TaskStatusCanceled
+		taskRecord.ErrorMessage = err.Error()
+	case err != nil:
+		taskRecord.Status = models.TaskStatusFailed
+		taskRecord.ErrorMessage = err.Error()
+	default:
+		taskRecord.Status = models.TaskStatusCompleted
+	}
+	save()
+
+	return err
+}
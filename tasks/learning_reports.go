@@ -0,0 +1,99 @@
+package tasks
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const TaskTypeWeeklyLearningReports = "weekly_learning_reports"
+
+// weeklyLearningReportsJob 定时任务入口，为最近一段时间有做题或提示记录的学生生成每门课程的学习报告
+func (tm *TasksManager) weeklyLearningReportsJob(ctx context.Context) error {
+	now := time.Now()
+	taskRecord := &models.TaskRecord{
+		TaskType:  TaskTypeWeeklyLearningReports,
+		Status:    models.TaskStatusPending,
+		StartTime: &now,
+	}
+	if err := tm.db.Create(taskRecord).Error; err != nil {
+		return fmt.Errorf("创建任务记录失败: %v", err)
+	}
+
+	save := func() {
+		if err := tm.saveTaskRecord(taskRecord); err != nil {
+			log.Printf("保存任务记录失败: %v", err)
+		}
+	}
+
+	ctx, done := tm.track(ctx, taskRecord.ID)
+	defer done()
+
+	taskRecord.Status = models.TaskStatusRunning
+	save()
+
+	err := tm.generateLearningReports(ctx, taskRecord, save)
+
+	endTime := time.Now()
+	taskRecord.EndTime = &endTime
+	switch {
+	case errors.Is(err, context.Canceled):
+		taskRecord.Status = models.TaskStatusCanceled
+		taskRecord.ErrorMessage = err.Error()
+	case err != nil:
+		taskRecord.Status = models.TaskStatusFailed
+		taskRecord.ErrorMessage = err.Error()
+	default:
+		taskRecord.Status = models.TaskStatusCompleted
+	}
+	save()
+
+	return err
+}
+
+// generateLearningReports 逐门课程、逐个学生生成报告，单个学生失败时记录错误并继续处理其他学生
+func (tm *TasksManager) generateLearningReports(ctx context.Context, taskRecord *models.TaskRecord, save func()) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -config.LLM.ReportWindowDays)
+
+	var courseIDs []uint
+	if err := tm.db.Model(&models.Course{}).Order("id").Pluck("id", &courseIDs).Error; err != nil {
+		return fmt.Errorf("获取课程列表失败: %v", err)
+	}
+
+	students := make(map[uint][]uint, len(courseIDs))
+	for _, courseID := range courseIDs {
+		userIDs, err := tm.aiService.LearningReportStudents(courseID, from, to)
+		if err != nil {
+			return err
+		}
+		students[courseID] = userIDs
+		taskRecord.TotalCount += len(userIDs)
+	}
+	save()
+
+	var failures []error
+	for _, courseID := range courseIDs {
+		for _, userID := range students[courseID] {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			_, err := tm.aiService.GenerateLearningReport(ctx, userID, courseID, models.LearningReportTriggerWeekly)
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			if err != nil {
+				log.Printf("课程 %d 学生 %d 学习报告生成失败: %v", courseID, userID, err)
+				failures = append(failures, fmt.Errorf("课程 %d 学生 %d: %v", courseID, userID, err))
+				continue
+			}
+			taskRecord.SuccessCount++
+			save()
+		}
+	}
+	return errors.Join(failures...)
+}
@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLeaseHeld = errors.New("任务正在其他实例上运行")
+
+// leaseTTL 租约有效期（秒），续约和检查取消标记都以其三分之一为间隔
+func leaseTTL() int {
+	ttl := int(config.Scheduler.LeaseTTL / time.Second)
+	if ttl <= 0 {
+		ttl = 60
+	}
+	return ttl
+}
+
+// acquireLease 获取任务的分布式租约。成功时返回的 ctx 会在租约丢失时被取消，
+// 任务结束后必须调用 release 释放租约并记录执行结果
+func (tm *TasksManager) acquireLease(ctx context.Context, name string) (context.Context, func(error), error) {
+	ttl := leaseTTL()
+	owner := config.Scheduler.InstanceID
+
+	err := tm.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.JobLease{Name: name}).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 使用数据库时间判断租约是否过期，避免各实例时钟不一致
+	result := tm.db.Model(&models.JobLease{}).
+		Where("name = ? AND (owner = '' OR lease_until IS NULL OR lease_until < NOW())", name).
+		Updates(map[string]interface{}{
+			"owner":       owner,
+			"lease_until": gorm.Expr("DATE_ADD(NOW(), INTERVAL ? SECOND)", ttl),
+			"last_run_at": gorm.Expr("NOW()"),
+		})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrLeaseHeld
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopRenew := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(ttl) * time.Second / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopRenew:
+				return
+			case <-ticker.C:
+				result := tm.db.Model(&models.JobLease{}).
+					Where("name = ? AND owner = ?", name, owner).
+					Update("lease_until", gorm.Expr("DATE_ADD(NOW(), INTERVAL ? SECOND)", ttl))
+				if result.Error != nil || result.RowsAffected != 0 {
+					continue
+				}
+				// MySQL 只统计值发生变化的行，同一秒内续约时 RowsAffected 也为 0，需要查回租约确认是否仍由本实例持有
+				var held int64
+				err := tm.db.Model(&models.JobLease{}).Where("name = ? AND owner = ?", name, owner).Count(&held).Error
+				if err == nil && held == 0 {
+					log.Printf("任务 %s 的租约已丢失，停止执行", name)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	release := func(runErr error) {
+		close(stopRenew)
+		cancel()
+
+		lastError := ""
+		if runErr != nil {
+			lastError = runErr.Error()
+		}
+		err := tm.db.Model(&models.JobLease{}).
+			Where("name = ? AND owner = ?", name, owner).
+			Updates(map[string]interface{}{
+				"owner":            "",
+				"lease_until":      nil,
+				"last_finished_at": gorm.Expr("NOW()"),
+				"last_error":       lastError,
+			}).Error
+		if err != nil {
+			log.Printf("释放任务 %s 的租约失败: %v", name, err)
+		}
+	}
+
+	return ctx, release, nil
+}
+
+// leaseHeld 判断任务的租约当前是否被某个实例持有
+func (tm *TasksManager) leaseHeld(name string) (bool, error) {
+	var count int64
+	err := tm.db.Model(&models.JobLease{}).
+		Where("name = ? AND owner <> '' AND lease_until >= NOW()", name).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// lastRunAt 返回任务最近一次开始执行的时间，从未执行过时返回 nil
+func (tm *TasksManager) lastRunAt(name string) (*time.Time, error) {
+	var lease models.JobLease
+	err := tm.db.Where("name = ?", name).First(&lease).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease.LastRunAt, nil
+}
@@ -2,111 +2,397 @@ package tasks
 
 import (
 	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+const TaskTypeSyncLeetCodeProblems = "sync_leetcode_problems"
+
+// SyncOptions 题目同步选项
+type SyncOptions struct {
+	Slugs    []string `json:"slugs,omitempty"`     // 只同步指定 slug 的题目
+	FromID   int      `json:"from_id,omitempty"`   // 题号范围下限（包含）
+	ToID     int      `json:"to_id,omitempty"`     // 题号范围上限（包含）
+	TagsOnly bool     `json:"tags_only,omitempty"` // 只同步题目标签
+	DryRun   bool     `json:"dry_run,omitempty"`   // 只统计变化，不写入数据库
+}
+
+// SyncChange 单道题目在同步中发生的变化
+type SyncChange struct {
+	LeetcodeID int      `json:"leetcode_id"`
+	TitleSlug  string   `json:"title_slug"`
+	Action     string   `json:"action"` // create / update
+	Fields     []string `json:"fields,omitempty"`
+}
+
+// SyncReport 同步结果统计
+type SyncReport struct {
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Failed    int          `json:"failed"`
+	Changes   []SyncChange `json:"changes,omitempty"`
+}
+
+// SyncLeetCodeProblems 全量同步题目
 func (tm *TasksManager) SyncLeetCodeProblems() {
+	if _, _, err := tm.RunSync(context.Background(), SyncOptions{}); err != nil {
+		log.Printf("同步题目失败: %v", err)
+	}
+}
+
+// syncJob 定时任务入口，租约由调度器负责获取
+func (tm *TasksManager) syncJob(ctx context.Context) error {
+	taskRecord, err := tm.createSyncRecord(SyncOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = tm.runSync(ctx, taskRecord, SyncOptions{})
+	return err
+}
+
+// RunSync 按选项同步题目，返回本次任务记录和统计结果；DryRun 时不会创建任务记录，也不需要获取租约
+func (tm *TasksManager) RunSync(ctx context.Context, opts SyncOptions) (*models.TaskRecord, *SyncReport, error) {
+	release := func(error) {}
+	if !opts.DryRun {
+		var err error
+		ctx, release, err = tm.acquireLease(ctx, TaskTypeSyncLeetCodeProblems)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	taskRecord, err := tm.createSyncRecord(opts)
+	if err != nil {
+		release(err)
+		return nil, nil, err
+	}
+
+	report, err := tm.runSync(ctx, taskRecord, opts)
+	release(err)
+	return taskRecord, report, err
+}
+
+// StartSync 在后台启动一次题目同步，立即返回新建的任务记录
+func (tm *TasksManager) StartSync(opts SyncOptions) (*models.TaskRecord, error) {
+	if opts.DryRun {
+		return nil, fmt.Errorf("后台任务不支持 dry-run")
+	}
+
+	ctx, release, err := tm.acquireLease(context.Background(), TaskTypeSyncLeetCodeProblems)
+	if err != nil {
+		return nil, err
+	}
+
+	taskRecord, err := tm.createSyncRecord(opts)
+	if err != nil {
+		release(err)
+		return nil, err
+	}
+
+	snapshot := *taskRecord
+	go func() {
+		_, err := tm.runSync(ctx, taskRecord, opts)
+		if err != nil {
+			log.Printf("同步任务 %d 失败: %v", taskRecord.ID, err)
+		}
+		release(err)
+	}()
+	return &snapshot, nil
+}
+
+// ResumeSync 从失败或已取消任务的断点处继续同步，沿用原任务记录
+func (tm *TasksManager) ResumeSync(ctx context.Context, taskID uint) (*models.TaskRecord, *SyncReport, error) {
+	taskRecord, opts, err := tm.loadResumableTask(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, release, err := tm.acquireLease(ctx, TaskTypeSyncLeetCodeProblems)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tm.claimResumableTask(taskRecord); err != nil {
+		release(err)
+		return nil, nil, err
+	}
+
+	report, err := tm.runSync(ctx, taskRecord, opts)
+	release(err)
+	return taskRecord, report, err
+}
+
+// StartResume 在后台从断点处重试失败或已取消的任务
+func (tm *TasksManager) StartResume(taskID uint) (*models.TaskRecord, error) {
+	taskRecord, opts, err := tm.loadResumableTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, release, err := tm.acquireLease(context.Background(), TaskTypeSyncLeetCodeProblems)
+	if err != nil {
+		return nil, err
+	}
+	if err := tm.claimResumableTask(taskRecord); err != nil {
+		release(err)
+		return nil, err
+	}
+
+	snapshot := *taskRecord
+	go func() {
+		_, err := tm.runSync(ctx, taskRecord, opts)
+		if err != nil {
+			log.Printf("重试任务 %d 失败: %v", taskRecord.ID, err)
+		}
+		release(err)
+	}()
+	return &snapshot, nil
+}
+
+func (tm *TasksManager) createSyncRecord(opts SyncOptions) (*models.TaskRecord, error) {
+	params, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	taskRecord := &models.TaskRecord{
-		TaskType:  "sync_leetcode_problems",
+		TaskType:  TaskTypeSyncLeetCodeProblems,
 		Status:    models.TaskStatusPending,
 		StartTime: &now,
+		Params:    string(params),
 	}
 
-	if err := tm.db.Create(taskRecord).Error; err != nil {
-		log.Printf("创建任务记录失败: %v", err)
-		return
+	if !opts.DryRun {
+		if err := tm.db.Create(taskRecord).Error; err != nil {
+			return nil, fmt.Errorf("创建任务记录失败: %v", err)
+		}
 	}
+	return taskRecord, nil
+}
 
-	taskRecord.Status = models.TaskStatusRunning
-	tm.db.Save(taskRecord)
+func (tm *TasksManager) loadResumableTask(taskID uint) (*models.TaskRecord, SyncOptions, error) {
+	var opts SyncOptions
+	var taskRecord models.TaskRecord
+	if err := tm.db.First(&taskRecord, taskID).Error; err != nil {
+		return nil, opts, fmt.Errorf("任务不存在: %v", err)
+	}
+	if taskRecord.TaskType != TaskTypeSyncLeetCodeProblems {
+		return nil, opts, fmt.Errorf("任务 %d 不是题目同步任务", taskID)
+	}
+	if taskRecord.Status != models.TaskStatusFailed && taskRecord.Status != models.TaskStatusCanceled {
+		return nil, opts, fmt.Errorf("只能恢复失败或已取消的任务，当前状态: %s", taskRecord.Status)
+	}
+	if tm.IsRunning(taskID) {
+		return nil, opts, fmt.Errorf("任务 %d 正在运行", taskID)
+	}
 
-	func() {
-		defer func() {
-			endTime := time.Now()
-			taskRecord.EndTime = &endTime
-			tm.db.Save(taskRecord)
-		}()
+	if taskRecord.Params != "" {
+		if err := json.Unmarshal([]byte(taskRecord.Params), &opts); err != nil {
+			return nil, opts, fmt.Errorf("解析任务参数失败: %v", err)
+		}
+	}
 
-		problems, err := tm.leetcodeService.FetchAllProblems()
-		if err != nil {
-			taskRecord.Status = models.TaskStatusFailed
-			taskRecord.ErrorMessage = err.Error()
+	taskRecord.ErrorMessage = ""
+	taskRecord.EndTime = nil
+	return &taskRecord, opts, nil
+}
+
+// claimResumableTask 通过条件更新把失败或已取消的任务标记为运行中，同一任务被同时恢复时只有一个请求能认领成功
+func (tm *TasksManager) claimResumableTask(taskRecord *models.TaskRecord) error {
+	result := tm.db.Model(&models.TaskRecord{}).
+		Where("id = ? AND status IN ?", taskRecord.ID, []models.TaskStatus{models.TaskStatusFailed, models.TaskStatusCanceled}).
+		Updates(map[string]interface{}{"status": models.TaskStatusRunning, "cancel_requested": false})
+	if result.Error != nil {
+		return fmt.Errorf("认领任务失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("任务 %d 已被其他请求恢复", taskRecord.ID)
+	}
+	taskRecord.Status = models.TaskStatusRunning
+	taskRecord.CancelRequested = false
+	return nil
+}
+
+func (tm *TasksManager) runSync(ctx context.Context, taskRecord *models.TaskRecord, opts SyncOptions) (*SyncReport, error) {
+	report := &SyncReport{}
+	save := func() {
+		if opts.DryRun {
 			return
 		}
+		if err := tm.saveTaskRecord(taskRecord); err != nil {
+			log.Printf("保存任务记录失败: %v", err)
+		}
+	}
 
-		taskRecord.TotalCount = len(problems)
+	if taskRecord.ID != 0 {
+		var done func()
+		ctx, done = tm.track(ctx, taskRecord.ID)
+		defer done()
+	}
+
+	taskRecord.Status = models.TaskStatusRunning
+	save()
+
+	filter := services.ProblemFilter{
+		Slugs:    opts.Slugs,
+		FromID:   opts.FromID,
+		ToID:     opts.ToID,
+		Skip:     taskRecord.Checkpoint,
+		TagsOnly: opts.TagsOnly,
+	}
+
+	err := tm.leetcodeService.FetchProblems(ctx, filter, func(offset int, problems []*models.Problem, failures []services.FetchFailure) error {
+		taskRecord.TotalCount += len(problems) + len(failures)
+		report.Failed += len(failures)
 
-		// 先处理所有标签
-		allTags := make(map[string]*models.Tag)
 		for _, problem := range problems {
-			for _, tag := range problem.Tags {
-				if _, exists := allTags[tag.Name]; !exists {
-					allTags[tag.Name] = &tag
-				}
+			change, err := tm.syncProblem(problem, opts, taskRecord.ID)
+			if err != nil {
+				log.Printf("同步题目失败 %s: %v", problem.TitleSlug, err)
+				report.Failed++
+				continue
 			}
-		}
 
-		// 批量创建或更新标签
-		for _, tag := range allTags {
-			var existingTag models.Tag
-			result := tm.db.Where("name = ?", tag.Name).First(&existingTag)
-			if result.Error == gorm.ErrRecordNotFound {
-				if err := tm.db.Create(tag).Error; err != nil {
-					log.Printf("创建标签失败 %s: %v", tag.Name, err)
-					continue
-				}
-			} else {
-				tag.ID = existingTag.ID
+			switch {
+			case change == nil:
+				report.Unchanged++
+			case change.Action == "create":
+				report.Created++
+				report.Changes = append(report.Changes, *change)
+			default:
+				report.Updated++
+				report.Changes = append(report.Changes, *change)
 			}
+			taskRecord.SuccessCount++
 		}
 
-		// 增量更新题目
-		for _, problem := range problems {
-			var existingProblem models.Problem
-			result := tm.db.Where("leetcode_id = ?", problem.LeetcodeID).First(&existingProblem)
-
-			// 更新标签的ID
-			for i, tag := range problem.Tags {
-				var existingTag models.Tag
-				if err := tm.db.Where("name = ?", tag.Name).First(&existingTag).Error; err != nil {
-					log.Printf("获取标签失败 %s: %v", tag.Name, err)
-					continue
-				}
-				problem.Tags[i].ID = existingTag.ID
-			}
+		// 每处理完一页保存一次进度，任务中断后可从此处继续
+		taskRecord.Checkpoint = offset
+		save()
+		return nil
+	})
+
+	endTime := time.Now()
+	taskRecord.EndTime = &endTime
+	switch {
+	case errors.Is(err, context.Canceled):
+		taskRecord.Status = models.TaskStatusCanceled
+		taskRecord.ErrorMessage = err.Error()
+	case err != nil:
+		taskRecord.Status = models.TaskStatusFailed
+		taskRecord.ErrorMessage = err.Error()
+	default:
+		taskRecord.Status = models.TaskStatusCompleted
+	}
+	save()
+
+	return report, err
+}
+
+// syncProblem 同步单道题目，返回题目发生的变化，无变化时返回 nil
+func (tm *TasksManager) syncProblem(problem *models.Problem, opts SyncOptions, taskRecordID uint) (*SyncChange, error) {
+	var existingProblem models.Problem
+	query := tm.db.Preload("Tags")
+	if opts.TagsOnly {
+		// 只同步标签时题目列表中没有 questionId，按 slug 匹配
+		query = query.Where("title_slug = ?", problem.TitleSlug)
+	} else {
+		query = query.Where("leetcode_id = ?", problem.LeetcodeID)
+	}
+	result := query.First(&existingProblem)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
 
-			if result.Error == gorm.ErrRecordNotFound {
-				// 新题目，直接创建
-				if err := tm.db.Create(problem).Error; err != nil {
-					log.Printf("创建题目失败 %d: %v", problem.LeetcodeID, err)
-					continue
-				}
-			} else {
-				// 已存在的题目，更新内容
-				existingProblem.Title = problem.Title
-				existingProblem.Content = problem.Content
-				existingProblem.Difficulty = problem.Difficulty
-				existingProblem.SampleTestcases = problem.SampleTestcases
-
-				// 更新标签
-				if err := tm.db.Model(&existingProblem).Association("Tags").Replace(problem.Tags); err != nil {
-					log.Printf("更新题目标签失败 %d: %v", problem.LeetcodeID, err)
-					continue
-				}
-
-				if err := tm.db.Save(&existingProblem).Error; err != nil {
-					log.Printf("更新题目失败 %d: %v", problem.LeetcodeID, err)
-					continue
-				}
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		if opts.TagsOnly {
+			return nil, fmt.Errorf("题目不存在，请先同步题目")
+		}
+		if !opts.DryRun {
+			tags, err := tm.ensureTags(problem.Tags)
+			if err != nil {
+				return nil, err
+			}
+			problem.Tags = tags
+			// 新题目，直接创建
+			if err := tm.db.Create(problem).Error; err != nil {
+				return nil, fmt.Errorf("创建题目失败: %v", err)
 			}
+		}
+		return &SyncChange{LeetcodeID: problem.LeetcodeID, TitleSlug: problem.TitleSlug, Action: "create"}, nil
+	}
 
-			taskRecord.SuccessCount++
+	// 计算更新后的题目，只同步标签时保留原有题面
+	updated := existingProblem
+	updated.Tags = problem.Tags
+	if !opts.TagsOnly {
+		updated.Title = problem.Title
+		updated.TitleCn = problem.TitleCn
+		updated.Content = problem.Content
+		updated.ContentCn = problem.ContentCn
+		updated.Difficulty = problem.Difficulty
+		updated.SampleTestcases = problem.SampleTestcases
+	}
+
+	fields := services.DiffProblemFields(&existingProblem, &updated)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	change := &SyncChange{LeetcodeID: existingProblem.LeetcodeID, TitleSlug: existingProblem.TitleSlug, Action: "update", Fields: fields}
+	if opts.DryRun {
+		return change, nil
+	}
+
+	tags, err := tm.ensureTags(problem.Tags)
+	if err != nil {
+		return nil, err
+	}
+	updated.Tags = tags
+
+	err = tm.db.Transaction(func(tx *gorm.DB) error {
+		// 更新标签
+		if err := tx.Model(&updated).Association("Tags").Replace(tags); err != nil {
+			return fmt.Errorf("更新题目标签失败: %v", err)
 		}
 
-		taskRecord.Status = models.TaskStatusCompleted
-	}()
+		// 已存在的题目，更新内容
+		if err := tx.Omit("Tags").Save(&updated).Error; err != nil {
+			return fmt.Errorf("更新题目失败: %v", err)
+		}
+
+		return services.RecordProblemRevision(tx, &existingProblem, &updated, models.ProblemRevisionSourceSync, 0, taskRecordID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// ensureTags 按名称查找标签，不存在时创建，返回带有ID的标签列表
+func (tm *TasksManager) ensureTags(tags []models.Tag) ([]models.Tag, error) {
+	result := make([]models.Tag, 0, len(tags))
+	for _, tag := range tags {
+		var existingTag models.Tag
+		err := tm.db.Where("name = ?", tag.Name).First(&existingTag).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existingTag = models.Tag{Name: tag.Name, NameCn: tag.NameCn}
+			if err := tm.db.Create(&existingTag).Error; err != nil {
+				return nil, fmt.Errorf("创建标签失败 %s: %v", tag.Name, err)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("获取标签失败 %s: %v", tag.Name, err)
+		}
+		result = append(result, existingTag)
+	}
+	return result, nil
 }
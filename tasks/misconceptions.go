@@ -0,0 +1,151 @@
+package tasks
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const TaskTypeClusterMisconceptions = "cluster_misconceptions"
+
+// MisconceptionOptions 误区聚类选项
+type MisconceptionOptions struct {
+	CourseID uint `json:"course_id,omitempty"` // 为 0 时处理全部课程
+	Days     int  `json:"days,omitempty"`      // 统计最近多少天的失败作答，为 0 时使用 AI_CLUSTER_WINDOW_DAYS
+}
+
+// clusterMisconceptionsJob 定时任务入口，对全部课程最近一段时间的失败作答聚类
+func (tm *TasksManager) clusterMisconceptionsJob(ctx context.Context) error {
+	taskRecord, err := tm.createMisconceptionRecord(MisconceptionOptions{})
+	if err != nil {
+		return err
+	}
+	return tm.runClusterMisconceptions(ctx, taskRecord, MisconceptionOptions{})
+}
+
+// StartClusterMisconceptions 在后台启动一次误区聚类，立即返回新建的任务记录
+func (tm *TasksManager) StartClusterMisconceptions(opts MisconceptionOptions) (*models.TaskRecord, error) {
+	if opts.CourseID != 0 {
+		if err := tm.db.First(&models.Course{}, opts.CourseID).Error; err != nil {
+			return nil, fmt.Errorf("课程不存在: %v", err)
+		}
+	}
+
+	ctx, release, err := tm.acquireLease(context.Background(), TaskTypeClusterMisconceptions)
+	if err != nil {
+		return nil, err
+	}
+
+	taskRecord, err := tm.createMisconceptionRecord(opts)
+	if err != nil {
+		release(err)
+		return nil, err
+	}
+
+	snapshot := *taskRecord
+	go func() {
+		err := tm.runClusterMisconceptions(ctx, taskRecord, opts)
+		if err != nil {
+			log.Printf("误区聚类任务 %d 失败: %v", taskRecord.ID, err)
+		}
+		release(err)
+	}()
+	return &snapshot, nil
+}
+
+func (tm *TasksManager) createMisconceptionRecord(opts MisconceptionOptions) (*models.TaskRecord, error) {
+	params, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	taskRecord := &models.TaskRecord{
+		TaskType:  TaskTypeClusterMisconceptions,
+		Status:    models.TaskStatusPending,
+		StartTime: &now,
+		Params:    string(params),
+	}
+	if err := tm.db.Create(taskRecord).Error; err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+	return taskRecord, nil
+}
+
+// runClusterMisconceptions 逐门课程聚类，单门课程失败时记录错误并继续处理其他课程
+func (tm *TasksManager) runClusterMisconceptions(ctx context.Context, taskRecord *models.TaskRecord, opts MisconceptionOptions) error {
+	save := func() {
+		if err := tm.saveTaskRecord(taskRecord); err != nil {
+			log.Printf("保存任务记录失败: %v", err)
+		}
+	}
+
+	ctx, done := tm.track(ctx, taskRecord.ID)
+	defer done()
+
+	taskRecord.Status = models.TaskStatusRunning
+	save()
+
+	err := tm.clusterCourses(ctx, taskRecord, opts, save)
+
+	endTime := time.Now()
+	taskRecord.EndTime = &endTime
+	switch {
+	case errors.Is(err, context.Canceled):
+		taskRecord.Status = models.TaskStatusCanceled
+		taskRecord.ErrorMessage = err.Error()
+	case err != nil:
+		taskRecord.Status = models.TaskStatusFailed
+		taskRecord.ErrorMessage = err.Error()
+	default:
+		taskRecord.Status = models.TaskStatusCompleted
+	}
+	save()
+
+	return err
+}
+
+func (tm *TasksManager) clusterCourses(ctx context.Context, taskRecord *models.TaskRecord, opts MisconceptionOptions, save func()) error {
+	days := opts.Days
+	if days <= 0 {
+		days = config.LLM.ClusterWindowDays
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+
+	var courseIDs []uint
+	query := tm.db.Model(&models.Course{})
+	if opts.CourseID != 0 {
+		query = query.Where("id = ?", opts.CourseID)
+	}
+	if err := query.Order("id").Pluck("id", &courseIDs).Error; err != nil {
+		return fmt.Errorf("获取课程列表失败: %v", err)
+	}
+	taskRecord.TotalCount = len(courseIDs)
+	save()
+
+	var failures []error
+	for _, courseID := range courseIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		count, err := tm.aiService.ClusterMisconceptions(ctx, taskRecord.ID, courseID, from, to)
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		if err != nil {
+			log.Printf("课程 %d 误区聚类失败: %v", courseID, err)
+			failures = append(failures, fmt.Errorf("课程 %d: %v", courseID, err))
+			continue
+		}
+		log.Printf("课程 %d 生成了 %d 个误区聚类", courseID, count)
+		taskRecord.SuccessCount++
+		save()
+	}
+	return errors.Join(failures...)
+}
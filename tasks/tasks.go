@@ -1,37 +1,317 @@
 package tasks
 
 import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
 	"ai_teach_system/services"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
+// 上一次执行未结束时的策略
+const (
+	OverlapSkip  = "skip"  // 跳过本次执行
+	OverlapDelay = "delay" // 等待上一次执行结束后再执行
+)
+
+// 服务停机期间错过执行时间时的策略
+const (
+	MissedSkip    = "skip"     // 不补跑，等待下一次调度
+	MissedRunOnce = "run_once" // 启动时补跑一次
+)
+
+// Job 注册到调度器中的定时任务，Schedule / Overlap / Missed 为默认值，可被配置覆盖
+type Job struct {
+	Name     string
+	Schedule string
+	Overlap  string
+	Missed   string
+	Run      func(ctx context.Context) error
+}
+
 type TasksManager struct {
 	db   *gorm.DB
 	cron *cron.Cron
 
 	leetcodeService services.LeetCodeServiceInterface
+	aiService       *services.AIService
+	problemService  *services.ProblemService
+
+	jobs map[string]Job
+
+	// 正在运行的任务，用于取消
+	mu      sync.Mutex
+	running map[uint]context.CancelFunc
 }
 
 func NewTasksManager(db *gorm.DB, s services.LeetCodeServiceInterface) *TasksManager {
-	return &TasksManager{
+	tm := &TasksManager{
 		db:              db,
 		cron:            cron.New(cron.WithSeconds()),
 		leetcodeService: s,
+		aiService:       services.NewAIService(db),
+		problemService:  services.NewProblemService(db),
+		jobs:            make(map[string]Job),
+		running:         make(map[uint]context.CancelFunc),
 	}
+
+	tm.Register(Job{
+		Name:     TaskTypeSyncLeetCodeProblems,
+		Schedule: "0 0 0 * * *", // 每天0点执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedRunOnce,
+		Run:      tm.syncJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypePurgeAICache,
+		Schedule: "0 30 * * * *", // 每小时执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.purgeAICacheJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypeClusterMisconceptions,
+		Schedule: "0 0 3 * * 1", // 每周一3点执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.clusterMisconceptionsJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypeWeeklyLearningReports,
+		Schedule: "0 0 4 * * 1", // 每周一4点执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.weeklyLearningReportsJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypeBackfillProblemEmbeddings,
+		Schedule: "0 0 1 * * *", // 每天1点执行，只处理新增和内容变化的题目
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.backfillEmbeddingsJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypePurgeAILogs,
+		Schedule: "0 0 2 * * *", // 每天2点执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.purgeAILogsJob,
+	})
+	tm.Register(Job{
+		Name:     TaskTypeProcessAutoAnalyses,
+		Schedule: "*/10 * * * * *", // 每10秒执行
+		Overlap:  OverlapSkip,
+		Missed:   MissedSkip,
+		Run:      tm.processAutoAnalysesJob,
+	})
+
+	return tm
+}
+
+// Register 注册定时任务，需在 Start 之前调用
+func (tm *TasksManager) Register(job Job) {
+	tm.jobs[job.Name] = job
 }
 
 func (tm *TasksManager) Start() {
-	_, err := tm.cron.AddFunc("0 0 0 * * *", tm.SyncLeetCodeProblems) // 每天0点执行
-	if err != nil {
-		log.Printf("添加定时任务失败: %v", err)
-		return
+	names := make([]string, 0, len(tm.jobs))
+	for name := range tm.jobs {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	logger := cron.PrintfLogger(log.Default())
+
+	missed := make(map[string]cron.Job)
+	for _, name := range names {
+		job, enabled := applyJobConfig(tm.jobs[name], config.Scheduler.Jobs[name])
+		if !enabled {
+			log.Printf("定时任务 %s 已禁用", name)
+			continue
+		}
+
+		schedule, err := parser.Parse(job.Schedule)
+		if err != nil {
+			log.Printf("定时任务 %s 的调度表达式无效 %q: %v", name, job.Schedule, err)
+			continue
+		}
+
+		var wrapper cron.JobWrapper
+		if job.Overlap == OverlapDelay {
+			wrapper = cron.DelayIfStillRunning(logger)
+		} else {
+			wrapper = cron.SkipIfStillRunning(logger)
+		}
+		wrapped := cron.NewChain(wrapper).Then(cron.FuncJob(func() { tm.runJob(job) }))
+		tm.cron.Schedule(schedule, wrapped)
+
+		if job.Missed == MissedRunOnce {
+			lastRun, err := tm.lastRunAt(name)
+			if err != nil {
+				log.Printf("获取定时任务 %s 的执行记录失败: %v", name, err)
+				continue
+			}
+			// 从未执行过，或上次执行后应当触发的时间已经过去
+			if lastRun == nil || schedule.Next(*lastRun).Before(time.Now()) {
+				missed[name] = wrapped
+			}
+		}
+	}
+
 	tm.cron.Start()
+
+	for name, job := range missed {
+		log.Printf("定时任务 %s 错过了执行时间，开始补跑", name)
+		go job.Run()
+	}
 }
 
 func (tm *TasksManager) Stop() {
 	tm.cron.Stop()
 }
+
+// runJob 获取租约后执行任务，租约被其他实例持有时跳过本次执行
+func (tm *TasksManager) runJob(job Job) {
+	ctx, release, err := tm.acquireLease(context.Background(), job.Name)
+	if errors.Is(err, ErrLeaseHeld) {
+		log.Printf("定时任务 %s 正在其他实例上运行，跳过本次执行", job.Name)
+		return
+	}
+	if err != nil {
+		log.Printf("获取定时任务 %s 的租约失败: %v", job.Name, err)
+		return
+	}
+
+	err = job.Run(ctx)
+	if err != nil {
+		log.Printf("定时任务 %s 执行失败: %v", job.Name, err)
+	}
+	release(err)
+}
+
+func applyJobConfig(job Job, cfg config.JobConfig) (Job, bool) {
+	if cfg.Schedule != "" {
+		job.Schedule = cfg.Schedule
+	}
+	if cfg.Overlap != "" {
+		job.Overlap = cfg.Overlap
+	}
+	if cfg.Missed != "" {
+		job.Missed = cfg.Missed
+	}
+	enabled := cfg.Enabled == nil || *cfg.Enabled
+	return job, enabled
+}
+
+var (
+	ErrTaskNotFound   = errors.New("任务不存在")
+	ErrTaskNotRunning = errors.New("任务未在运行")
+)
+
+// track 登记正在运行的任务，返回可被 CancelTask 取消的 ctx，任务结束后需调用返回的函数注销。
+// 任务可能由其他实例发起取消，因此同时定期检查任务记录上的取消标记
+func (tm *TasksManager) track(ctx context.Context, taskID uint) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	tm.mu.Lock()
+	tm.running[taskID] = cancel
+	tm.mu.Unlock()
+
+	stopPoll := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(leaseTTL()) * time.Second / 3)
+		defer ticker.Stop()
+		for {
+			if tm.cancelRequested(taskID) {
+				log.Printf("任务 %d 已被请求取消，停止执行", taskID)
+				cancel()
+				return
+			}
+			select {
+			case <-stopPoll:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return ctx, func() {
+		close(stopPoll)
+		tm.mu.Lock()
+		delete(tm.running, taskID)
+		tm.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequested 查询任务记录上的取消标记，查询失败时视为未取消，等待下次检查
+func (tm *TasksManager) cancelRequested(taskID uint) bool {
+	var record models.TaskRecord
+	err := tm.db.Select("id", "cancel_requested").First(&record, taskID).Error
+	return err == nil && record.CancelRequested
+}
+
+// saveTaskRecord 保存任务进度。取消标记只由 CancelTask 写入，保存时跳过，避免覆盖其他实例发出的取消请求
+func (tm *TasksManager) saveTaskRecord(taskRecord *models.TaskRecord) error {
+	return tm.db.Omit("cancel_requested").Save(taskRecord).Error
+}
+
+// IsRunning 判断任务是否正在当前进程中运行
+func (tm *TasksManager) IsRunning(taskID uint) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, ok := tm.running[taskID]
+	return ok
+}
+
+// CancelTask 请求取消正在运行的任务。取消标记写入任务记录，运行该任务的实例（可能不是当前实例）
+// 会在下次检查时停止任务并标记为已取消。任务已结束或没有实例持有其租约时返回 ErrTaskNotRunning
+func (tm *TasksManager) CancelTask(taskID uint) error {
+	var record models.TaskRecord
+	err := tm.db.First(&record, taskID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	active := []models.TaskStatus{models.TaskStatusPending, models.TaskStatusRunning}
+	if record.Status != models.TaskStatusPending && record.Status != models.TaskStatusRunning {
+		return fmt.Errorf("%w，当前状态: %s", ErrTaskNotRunning, record.Status)
+	}
+
+	// 所有任务都在对应租约下运行，租约无人持有说明执行该任务的进程已经退出
+	held, err := tm.leaseHeld(record.TaskType)
+	if err != nil {
+		return err
+	}
+	if !held && !tm.IsRunning(taskID) {
+		return fmt.Errorf("%w，没有实例在执行该任务", ErrTaskNotRunning)
+	}
+
+	err = tm.db.Model(&models.TaskRecord{}).
+		Where("id = ? AND status IN ?", taskID, active).
+		Update("cancel_requested", true).Error
+	if err != nil {
+		return err
+	}
+
+	// 任务在当前实例运行时立即取消，不必等待下次检查
+	tm.mu.Lock()
+	cancel, ok := tm.running[taskID]
+	tm.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
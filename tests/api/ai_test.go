@@ -24,7 +24,7 @@ func setupAITest() (*gin.Engine, *mocks.MockAIService) {
 		Service: mockService,
 	}
 
-	r.POST("/api/ai/generate-code", controller.GenerateCode)
+	r.POST("/api/ai/generate-hint", controller.GenerateHint)
 	r.POST("/api/ai/correct-code", controller.CorrectCode)
 	r.POST("/api/ai/analyze-code", controller.AnalyzeCode)
 	r.POST("/api/ai/chat", controller.Chat)
@@ -47,7 +47,6 @@ func TestGenerateCode(t *testing.T) {
 			name: "successful code generation",
 			request: controllers.GenerateCodeRequest{
 				Title:           "Two Sum",
-				Language:        "JavaScript",
 				Content:         "Given an array of integers nums and an integer target...",
 				SampleTestcases: "[2,7,11,15]\n9",
 			},
@@ -69,7 +68,7 @@ func TestGenerateCode(t *testing.T) {
 			name: "missing required fields",
 			request: controllers.GenerateCodeRequest{
 				Title: "Two Sum",
-				// Language field missing
+				// Content field missing
 			},
 			wantStatus: http.StatusBadRequest,
 			wantError:  true,
@@ -81,7 +80,7 @@ func TestGenerateCode(t *testing.T) {
 			jsonData, err := json.Marshal(tt.request)
 			assert.NoError(t, err)
 
-			req := httptest.NewRequest("POST", "/api/ai/generate-code", bytes.NewBuffer(jsonData))
+			req := httptest.NewRequest("POST", "/api/ai/generate-hint", bytes.NewBuffer(jsonData))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -172,8 +171,7 @@ func TestCorrectCode(t *testing.T) {
 				assert.Empty(t, response.Message)
 
 				data := response.Data.(map[string]interface{})
-				assert.NotEmpty(t, data["code"])
-				assert.Equal(t, tt.mockCode, data["code"])
+				assert.Equal(t, tt.mockCode, data["qwen_corrected_code"])
 			}
 		})
 	}
@@ -239,8 +237,7 @@ func TestAnalyzeCode(t *testing.T) {
 				assert.Empty(t, response.Message)
 
 				data := response.Data.(map[string]interface{})
-				assert.NotEmpty(t, data["message"])
-				assert.Equal(t, tt.mockMessage, data["message"])
+				assert.Equal(t, tt.mockMessage, data["qwen_wrong_reason_and_analyze"])
 			}
 		})
 	}
@@ -64,10 +64,10 @@ func TestGetCourseDetail(t *testing.T) {
 	db.Create(point)
 
 	tag := &models.Tag{
-		Name:             "数组操作",
-		KnowledgePointID: point.ID,
+		Name: "数组操作",
 	}
 	db.Create(tag)
+	db.Model(point).Association("Tags").Append(tag)
 
 	problem := &models.Problem{
 		Title:      "Two Sum",
@@ -65,10 +65,10 @@ func TestGetProblemList(t *testing.T) {
 	db.Create(point)
 
 	tag := &models.Tag{
-		Name:             "数组操作",
-		KnowledgePointID: point.ID,
+		Name: "数组操作",
 	}
 	db.Create(tag)
+	db.Model(point).Association("Tags").Append(tag)
 
 	problem := &models.Problem{
 		TitleSlug:  "Two Sum",
@@ -144,10 +144,10 @@ func TestGetProblemDetail(t *testing.T) {
 	db.Create(point)
 
 	tag := &models.Tag{
-		Name:             "数组操作",
-		KnowledgePointID: point.ID,
+		Name: "数组操作",
 	}
 	db.Create(tag)
+	db.Model(point).Association("Tags").Append(tag)
 
 	problem := &models.Problem{
 		TitleSlug:  "Two Sum",
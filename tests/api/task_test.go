@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"ai_teach_system/controllers"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/tasks"
+	"ai_teach_system/tests"
+	"ai_teach_system/tests/mocks"
+	"ai_teach_system/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// setupTaskTest 没有配置测试数据库时跳过
+func setupTaskTest(t *testing.T) (*gin.Engine, *gorm.DB, func()) {
+	if _, err := os.Stat("../../.env"); err != nil {
+		t.Skip("未配置测试数据库（../../.env），跳过")
+	}
+	gin.SetMode(gin.TestMode)
+	db, cleanup := tests.SetupTestDB()
+	if err := db.AutoMigrate(&models.JobLease{}); err != nil {
+		cleanup()
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+
+	manager := tasks.NewTasksManager(db, mocks.NewMockLeetCodeService())
+	controller := controllers.NewTaskController(services.NewTaskService(db), manager)
+
+	r := gin.New()
+	r.POST("/api/tasks/:id/cancel", controller.CancelTask)
+	return r, db, cleanup
+}
+
+func TestCancelTask(t *testing.T) {
+	r, db, cleanup := setupTaskTest(t)
+	defer cleanup()
+
+	now := time.Now()
+	completed := &models.TaskRecord{TaskType: tasks.TaskTypeSyncLeetCodeProblems, Status: models.TaskStatusCompleted, StartTime: &now}
+	orphaned := &models.TaskRecord{TaskType: tasks.TaskTypeClusterMisconceptions, Status: models.TaskStatusRunning, StartTime: &now}
+	remote := &models.TaskRecord{TaskType: tasks.TaskTypeSyncLeetCodeProblems, Status: models.TaskStatusRunning, StartTime: &now}
+	assert.NoError(t, db.Create([]*models.TaskRecord{completed, orphaned, remote}).Error)
+
+	// 同步任务正在其他实例上运行
+	leaseUntil := now.Add(time.Hour)
+	assert.NoError(t, db.Create(&models.JobLease{
+		Name:       tasks.TaskTypeSyncLeetCodeProblems,
+		Owner:      "other-instance",
+		LeaseUntil: &leaseUntil,
+	}).Error)
+
+	tests := []struct {
+		name          string
+		taskID        string
+		wantStatus    int
+		wantRequested bool
+	}{
+		{name: "invalid id", taskID: "abc", wantStatus: http.StatusBadRequest},
+		{name: "task not found", taskID: "99999", wantStatus: http.StatusNotFound},
+		{name: "task already finished", taskID: fmt.Sprint(completed.ID), wantStatus: http.StatusConflict},
+		{name: "no instance holds the lease", taskID: fmt.Sprint(orphaned.ID), wantStatus: http.StatusConflict},
+		{name: "running on another instance", taskID: fmt.Sprint(remote.ID), wantStatus: http.StatusOK, wantRequested: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/tasks/"+tt.taskID+"/cancel", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			var response utils.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantStatus == http.StatusOK, response.Result)
+
+			if tt.wantRequested {
+				var record models.TaskRecord
+				assert.NoError(t, db.First(&record, remote.ID).Error)
+				assert.True(t, record.CancelRequested)
+				// 取消由运行任务的实例完成，这里只写入标记
+				assert.Equal(t, models.TaskStatusRunning, record.Status)
+			}
+		})
+	}
+
+	var record models.TaskRecord
+	assert.NoError(t, db.First(&record, orphaned.ID).Error)
+	assert.False(t, record.CancelRequested)
+}
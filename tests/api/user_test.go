@@ -135,8 +135,7 @@ func TestUserRegister(t *testing.T) {
 				var user models.User
 				err := db.Where("username = ?", tt.payload.Username).First(&user).Error
 				assert.NoError(t, err)
-				assert.NotEmpty(t, user.Avatar)
-				assert.Contains(t, user.Avatar, "https://example.com/avatars/test.jpg")
+				assert.Equal(t, tt.payload.StudentID, user.StudentID)
 			}
 		})
 	}
@@ -246,7 +245,6 @@ func TestGetUserProgress(t *testing.T) {
 
 	user := &models.User{
 		Username:  "testuser",
-		Avatar:    "https://example.com/avatar.jpg",
 		Name:      "Test User",
 		StudentID: "2024001",
 		Class:     *class,
@@ -283,7 +281,6 @@ func TestGetUserProgress(t *testing.T) {
 
 	data := response.Data.(map[string]interface{})
 	assert.Equal(t, "testuser", data["username"])
-	assert.Equal(t, "https://example.com/avatar.jpg", data["avatar"])
 	assert.Equal(t, float64(1), data["solved_problems"])
 	assert.Equal(t, float64(100), data["learn_progress"])
 }
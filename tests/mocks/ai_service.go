@@ -1,14 +1,14 @@
 package mocks
 
-type MockAIService struct {
-}
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+)
 
-func NewMockAIService() *MockAIService {
-	return &MockAIService{}
-}
-
-func (m *MockAIService) GenerateCode(title string, language string, content string, sampleTestCases string) (string, error) {
-	return `function twoSum(nums, target) {
+// 各接口返回的固定内容
+const (
+	MockHintCode = `function twoSum(nums, target) {
     const map = new Map();
     for (let i = 0; i < nums.length; i++) {
         const complement = target - nums[i];
@@ -18,11 +18,9 @@ func (m *MockAIService) GenerateCode(title string, language string, content stri
         map.set(nums[i], i);
     }
     return [];
-}`, nil
-}
+}`
 
-func (m *MockAIService) CorrectCode(problemID uint, lang string, typedCode string) (string, error) {
-	return `class Solution:
+	MockCorrectedCode = `class Solution:
 		def twoSum(self, nums: List[int], target: int) -> List[int]:
 			hashtable = dict()
 			for i, num in enumerate(nums):
@@ -30,20 +28,169 @@ func (m *MockAIService) CorrectCode(problemID uint, lang string, typedCode strin
 					return [hashtable[target - num], i]
 				# AI Comment：将hashtable[nums[i]] = i改为hashtable[num] = i以避免重复访问nums[i]
 				# hashtable[nums[i]] = i
-				hashtable[num] = i  # 修改原因：直接使用num变量，减少对列表的索引操作，提高代码效率和可读性`, nil
-}
+				hashtable[num] = i  # 修改原因：直接使用num变量，减少对列表的索引操作，提高代码效率和可读性`
 
-func (m *MockAIService) AnalyzeCode(problemID uint, lang string, typedCode string) (string, error) {
-	return `**错误分析**
+	MockAnalysis = `**错误分析**
 		这段代码没有实现找到加起来等于目标和的两个数这一逻辑。
 		**AI讲师分析**
-		这道题包含了理解哈希表和它们在减少时间复杂度上的用法。`, nil
-}
+		这道题包含了理解哈希表和它们在减少时间复杂度上的用法。`
 
-func (m *MockAIService) Chat(problemID uint, typedCode string, question string) (string, error) {
-	return `这道题目是经典的"两数之和"问题，需要在数组中找到两个数，使它们的和等于目标值。
+	MockChatAnswer = `这道题目是经典的"两数之和"问题，需要在数组中找到两个数，使它们的和等于目标值。
 
 关于你的问题，这道题的核心思想是使用哈希表来降低时间复杂度。传统的暴力解法需要O(n²)的时间复杂度，而使用哈希表可以将时间复杂度降低到O(n)。
 
-哈希表的作用是记录已经遍历过的元素及其索引，这样当我们遍历到一个新元素时，可以在O(1)的时间内查找是否存在一个已经遍历过的元素，使得两者之和等于目标值。`, nil
+哈希表的作用是记录已经遍历过的元素及其索引，这样当我们遍历到一个新元素时，可以在O(1)的时间内查找是否存在一个已经遍历过的元素，使得两者之和等于目标值。`
+)
+
+type MockAIService struct {
+}
+
+func NewMockAIService() *MockAIService {
+	return &MockAIService{}
+}
+
+func (m *MockAIService) GenerateHint(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string) (string, error) {
+	return MockHintCode, nil
+}
+
+func (m *MockAIService) CorrectCode(ctx context.Context, recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"qwen_corrected_code":     MockCorrectedCode,
+		"deepseek_corrected_code": MockCorrectedCode,
+		"models":                  []string{"qwen", "deepseek"},
+	}, nil
+}
+
+func (m *MockAIService) ReviewCode(ctx context.Context, userID, recordID, problemID uint, lang, typedCode, modelType string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"comments":   []models.ReviewComment{},
+		"typed_code": typedCode,
+		"model":      "qwen",
+	}, nil
+}
+
+func (m *MockAIService) GetReviewComments(userID, recordID uint) ([]models.ReviewComment, error) {
+	return []models.ReviewComment{}, nil
+}
+
+func (m *MockAIService) GetAutoAnalysis(userID, recordID uint) (*models.AutoAnalysis, error) {
+	return &models.AutoAnalysis{RecordID: recordID, UserID: userID, Status: models.AutoAnalysisPending}, nil
+}
+
+func (m *MockAIService) WaitAutoAnalysis(ctx context.Context, userID, recordID uint, onEvent func(event string, data map[string]interface{})) error {
+	analysis := &models.AutoAnalysis{RecordID: recordID, UserID: userID, Status: models.AutoAnalysisCompleted}
+	onEvent("state", map[string]interface{}{"status": analysis.Status, "attempts": 1})
+	onEvent("result", map[string]interface{}{"analysis": analysis})
+	return nil
+}
+
+func (m *MockAIService) AnalyzeCode(ctx context.Context, recordID, problemID uint, lang, typedCode string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"qwen_wrong_reason_and_analyze":     MockAnalysis,
+		"deepseek_wrong_reason_and_analyze": MockAnalysis,
+		"models":                            []string{"qwen", "deepseek"},
+	}, nil
+}
+
+func (m *MockAIService) Chat(ctx context.Context, problemID uint, typedCode, question, modelType string) (string, []services.MaterialCitation, error) {
+	return MockChatAnswer, nil, nil
+}
+
+func (m *MockAIService) SuggestKnowledgePointTags(ctx context.Context, knowledgePointID uint) ([]models.Tag, error) {
+	return []models.Tag{{Name: "Array"}, {Name: "Hash Table"}}, nil
+}
+
+func (m *MockAIService) DraftProblem(ctx context.Context, creatorID, knowledgePointID uint, difficulty models.ProblemDifficulty, constraints, language, modelType string) (*models.ProblemDraft, error) {
+	return &models.ProblemDraft{CreatorID: creatorID, KnowledgePointID: knowledgePointID}, nil
+}
+
+func (m *MockAIService) JudgeCode(ctx context.Context, problemID uint, lang, code string, test bool) (map[string]interface{}, error) {
+	return map[string]interface{}{"status": "SUCCESS"}, nil
+}
+
+func (m *MockAIService) ListModels() []map[string]interface{} {
+	return []map[string]interface{}{{"name": "qwen"}, {"name": "deepseek"}}
+}
+
+func (m *MockAIService) GenerateHintStream(ctx context.Context, problemID uint, title, content, sampleTestCases, modelType string, onEvent func(event string, data map[string]interface{})) error {
+	onEvent("delta", map[string]interface{}{"content": MockHintCode})
+	onEvent("done", map[string]interface{}{"content": MockHintCode})
+	return nil
+}
+
+func (m *MockAIService) ChatStream(ctx context.Context, problemID uint, typedCode, question, modelType string, onEvent func(event string, data map[string]interface{})) error {
+	onEvent("delta", map[string]interface{}{"content": MockChatAnswer})
+	onEvent("done", map[string]interface{}{"content": MockChatAnswer})
+	return nil
+}
+
+func (m *MockAIService) AnalyzeCodeStream(ctx context.Context, recordID, problemID uint, language, typedCode string, onEvent func(event string, data map[string]interface{})) error {
+	for _, model := range []string{"qwen", "deepseek"} {
+		onEvent("delta", map[string]interface{}{"model": model, "content": MockAnalysis})
+		onEvent("done", map[string]interface{}{"model": model, "content": MockAnalysis})
+	}
+	return nil
+}
+
+func (m *MockAIService) StartChatSession(ctx context.Context, userID, problemID uint, question, typedCode, modelType string) (*models.ChatSession, error) {
+	return &models.ChatSession{
+		UserID:    userID,
+		ProblemID: problemID,
+		Title:     question,
+		Messages: []models.ChatMessage{
+			{Role: models.ChatRoleUser, Content: question, TypedCode: typedCode},
+			{Role: models.ChatRoleAssistant, Content: MockChatAnswer},
+		},
+	}, nil
+}
+
+func (m *MockAIService) ContinueChatSession(ctx context.Context, userID, sessionID uint, question, typedCode string) ([]models.ChatMessage, error) {
+	return []models.ChatMessage{
+		{SessionID: sessionID, Role: models.ChatRoleUser, Content: question, TypedCode: typedCode},
+		{SessionID: sessionID, Role: models.ChatRoleAssistant, Content: MockChatAnswer},
+	}, nil
+}
+
+func (m *MockAIService) ListChatSessions(userID, problemID uint) ([]models.ChatSession, error) {
+	return []models.ChatSession{}, nil
+}
+
+func (m *MockAIService) GetChatSession(userID, sessionID uint) (*models.ChatSession, error) {
+	return &models.ChatSession{UserID: userID}, nil
+}
+
+func (m *MockAIService) DeleteChatSession(userID, sessionID uint) error {
+	return nil
+}
+
+func (m *MockAIService) GetHints(userID, problemID, knowledgePointID uint) (map[string]interface{}, error) {
+	return map[string]interface{}{"hint_level": 0, "hints": []models.HintRecord{}}, nil
+}
+
+func (m *MockAIService) RequestHint(ctx context.Context, userID, problemID, knowledgePointID uint, level int, modelType string) (*models.HintRecord, error) {
+	return &models.HintRecord{UserID: userID, ProblemID: problemID, KnowledgePointID: knowledgePointID, Level: level}, nil
+}
+
+func (m *MockAIService) CacheStats() ([]map[string]interface{}, error) {
+	return []map[string]interface{}{}, nil
+}
+
+func (m *MockAIService) PurgeCache(feature string, problemID uint) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockAIService) GenerateLearningReport(ctx context.Context, userID, courseID uint, trigger string) (*models.LearningReport, error) {
+	return &models.LearningReport{UserID: userID, CourseID: courseID, Trigger: trigger}, nil
+}
+
+func (m *MockAIService) ListLearningReports(userID, courseID uint) ([]models.LearningReport, error) {
+	return []models.LearningReport{}, nil
+}
+
+func (m *MockAIService) GetLearningReport(userID, courseID, reportID uint) (*models.LearningReport, error) {
+	return &models.LearningReport{UserID: userID, CourseID: courseID}, nil
+}
+
+func (m *MockAIService) DraftSyllabusPlan(ctx context.Context, creatorID, courseID uint, courseName, syllabus string) (*models.SyllabusPlan, error) {
+	return &models.SyllabusPlan{CreatorID: creatorID, CourseID: courseID}, nil
 }
@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
 )
 
 type MockLeetCodeService struct {
@@ -52,14 +54,14 @@ func (m *MockLeetCodeService) RunTestCase(userID uint, questionId int, code stri
 	return resp, nil
 }
 
-func (m *MockLeetCodeService) Submit(userID uint, lang string, question_id int, code string) (map[string]interface{}, error) {
+func (m *MockLeetCodeService) Submit(userID uint, lang string, knowledge_point_id uint, question_id int, code string) (map[string]interface{}, error) {
 	resp := map[string]interface{}{
 		"submission_id": 594247274,
 	}
 	return resp, nil
 }
 
-func (c *MockLeetCodeService) Check(userID uint, runCodeID string) (map[string]interface{}, error) {
+func (c *MockLeetCodeService) Check(userID uint, runCodeID string, test bool) (map[string]interface{}, error) {
 	resp := map[string]interface{}{
 		"code_output":               []string{},
 		"compare_result":            "1",
@@ -99,3 +101,25 @@ func (c *MockLeetCodeService) Check(userID uint, runCodeID string) (map[string]i
 	}
 	return resp, nil
 }
+
+func (c *MockLeetCodeService) CheckStream(ctx context.Context, userID uint, runCodeID string, test bool, onEvent func(event string, data map[string]interface{})) error {
+	onEvent("state", map[string]interface{}{"state": "PENDING"})
+	onEvent("state", map[string]interface{}{"state": "SUCCESS"})
+	onEvent("result", map[string]interface{}{
+		"state":       "SUCCESS",
+		"status_code": 10,
+		"status_msg":  "Accepted",
+	})
+	return nil
+}
+
+func (m *MockLeetCodeService) FetchProblems(ctx context.Context, filter services.ProblemFilter, handle func(offset int, problems []*models.Problem, failures []services.FetchFailure) error) error {
+	if filter.Skip >= len(m.Problems) {
+		return nil
+	}
+	return handle(len(m.Problems), m.Problems[filter.Skip:], nil)
+}
+
+func (m *MockLeetCodeService) GetRecommendedProblem(currentProblemID uint, userID uint) (*models.Problem, error) {
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// modelResults 把响应中各模型的结果转换为通用结构，便于断言
+func modelResults(t *testing.T, response map[string]interface{}) []map[string]interface{} {
+	raw, err := json.Marshal(response["results"])
+	assert.NoError(t, err)
+	var results []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &results))
+	return results
+}
+
+func TestCorrectCodePartialResults(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	fake := useFakeLLM(t, replyByModel(map[string]string{"qwen": "修正后的代码"}, "另一个模型的修正"))
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	record := models.UserProblem{
+		UserID:                 user.ID,
+		ProblemID:              problem.ID,
+		KnowledgePointID:       point.ID,
+		Status:                 models.ProblemStatusFailed,
+		TypedCode:              "int main() {}",
+		Language:               "cpp",
+		DeepseekCorrected_code: "之前的修正",
+	}
+	assert.NoError(t, db.Create(&record).Error)
+
+	// 一个模型失败时仍然返回另一个模型的结果
+	fake.Fail("deepseek-test")
+	ctx := services.WithAIUser(context.Background(), user.ID)
+	response, err := service.CorrectCode(ctx, record.ID, problem.ID, "cpp", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "修正后的代码", response["qwen_corrected_code"])
+	assert.Equal(t, "", response["deepseek_corrected_code"])
+
+	results := modelResults(t, response)
+	assert.Len(t, results, 2)
+	assert.Equal(t, true, results[0]["success"])
+	assert.Equal(t, "qwen", results[0]["model"])
+	assert.Equal(t, false, results[1]["success"])
+	assert.NotEmpty(t, results[1]["error"])
+
+	// 失败的模型不覆盖作答记录上已有的内容
+	var saved models.UserProblem
+	assert.NoError(t, db.First(&saved, record.ID).Error)
+	assert.Equal(t, "修正后的代码", saved.QwenCorrectedCode)
+	assert.Equal(t, "qwen", saved.QwenCorrectedCodeModel)
+	assert.Equal(t, "之前的修正", saved.DeepseekCorrected_code)
+	assert.Empty(t, saved.DeepseekCorrectedCodeModel)
+
+	// 全部模型失败时返回错误
+	fake.Fail("qwen-test")
+	_, err = service.CorrectCode(ctx, record.ID, problem.ID, "cpp", "")
+	assert.Error(t, err)
+}
+
+func TestCorrectCodeCallsModelsConcurrently(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	useFakeLLM(t, func(model, system, user string) string {
+		time.Sleep(500 * time.Millisecond)
+		return model
+	})
+	service := services.NewAIService(db)
+	_, _, problem := seedProblem(t, db)
+
+	start := time.Now()
+	response, err := service.CorrectCode(context.Background(), 0, problem.ID, "cpp", "int main() {}")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 900*time.Millisecond)
+	assert.Equal(t, "qwen-test", response["qwen_corrected_code"])
+	assert.Equal(t, "deepseek-test", response["deepseek_corrected_code"])
+}
+
+func TestCodeAIUsesOwnRecord(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	fake := useFakeLLM(t, func(model, system, user string) string { return "结果" })
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	otherProblem := models.Problem{Title: "Add Two Numbers", TitleCn: "两数相加", TitleSlug: "add-two-numbers", Difficulty: models.ProblemDifficultyMedium, Content: "content"}
+	assert.NoError(t, db.Create(&otherProblem).Error)
+
+	record := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusFailed, TypedCode: "stored_code()", Language: "python3"}
+	othersRecord := models.UserProblem{UserID: other.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusFailed, TypedCode: "others_code()"}
+	emptyRecord := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID}
+	assert.NoError(t, db.Create([]*models.UserProblem{&record, &othersRecord, &emptyRecord}).Error)
+
+	ctx := services.WithAIUser(context.Background(), user.ID)
+
+	tests := []struct {
+		name      string
+		recordID  uint
+		problemID uint
+		typedCode string
+		wantErr   string
+	}{
+		{name: "another student's record", recordID: othersRecord.ID, problemID: problem.ID, typedCode: "x", wantErr: "作答记录不存在"},
+		{name: "record of another problem", recordID: record.ID, problemID: otherProblem.ID, typedCode: "x", wantErr: "作答记录与题目不匹配"},
+		{name: "record without code", recordID: emptyRecord.ID, problemID: problem.ID, typedCode: "x", wantErr: "没有需要处理的代码"},
+		{name: "no record and no code", problemID: problem.ID, wantErr: "没有需要处理的代码"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CorrectCode(ctx, tt.recordID, tt.problemID, "cpp", tt.typedCode)
+			assert.ErrorContains(t, err, tt.wantErr)
+			_, err = service.AnalyzeCode(ctx, tt.recordID, tt.problemID, "cpp", tt.typedCode)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+	assert.Empty(t, fake.Requests())
+
+	// 指定作答记录时处理记录中保存的代码和语言，忽略请求中的代码
+	_, err := service.AnalyzeCode(ctx, record.ID, problem.ID, "cpp", "submitted_later()")
+	assert.NoError(t, err)
+	requests := fake.Requests()
+	assert.NotEmpty(t, requests)
+	assert.True(t, strings.Contains(requests[0].User, "stored_code()"))
+	assert.False(t, strings.Contains(requests[0].User, "submitted_later()"))
+
+	var saved models.UserProblem
+	assert.NoError(t, db.First(&saved, record.ID).Error)
+	assert.Equal(t, "结果", saved.QwenWrongReasonAndAnalyze)
+	assert.Equal(t, "结果", saved.DeepseekWrongReasonAndAnalyze)
+	assert.Equal(t, "stored_code()", saved.TypedCode)
+
+	// 用量计入作答记录所属的课程
+	var usage models.AIUsage
+	assert.NoError(t, db.Where("feature = ?", services.FeatureAnalyzeCode).First(&usage).Error)
+	assert.Equal(t, point.CourseID, usage.CourseID)
+	assert.Equal(t, user.ID, usage.UserID)
+}
@@ -0,0 +1,137 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const integrityReference = `class Solution {
+public:
+    vector<int> twoSum(vector<int>& nums, int target) {
+        unordered_map<int, int> seen;
+        for (int i = 0; i < nums.size(); i++) {
+            if (seen.count(target - nums[i])) return {seen[target - nums[i]], i};
+            seen[nums[i]] = i;
+        }
+        return {};
+    }
+};`
+
+func TestChatIntegrityGuard(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var replies []string
+	fake := useFakeLLM(t, func(model, system, user string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		reply := replies[0]
+		if len(replies) > 1 {
+			replies = replies[1:]
+		}
+		return reply
+	})
+	config.LLM.IntegritySimilarity = 0.6
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	course, _, problem := seedProblem(t, db)
+	assert.NoError(t, db.Create(&models.CourseClasses{CourseID: course.ID, ClassID: user.ClassID}).Error)
+	assert.NoError(t, db.Model(&problem).Update("reference_solution", integrityReference).Error)
+
+	codeAnswer := "可以这样写：\n```cpp\nint main() { return 0; }\n```\n注意边界。"
+	pythonAnswer := "参考：\n```python\nprint(1)\n```"
+	pseudoAnswer := "思路：\n```text\n遍历数组，检查 target - x 是否出现过\n```"
+
+	tests := []struct {
+		name       string
+		policy     string
+		replies    []string
+		want       string
+		wantCalls  int
+		wantKind   string
+		wantAction string
+	}{
+		{name: "code allowed", policy: models.CodePolicyAllowCode, replies: []string{codeAnswer}, want: codeAnswer, wantCalls: 1},
+		{
+			name:       "regenerated without code",
+			policy:     models.CodePolicyNoCode,
+			replies:    []string{codeAnswer, "先想想哈希表能做什么。"},
+			want:       "先想想哈希表能做什么。",
+			wantCalls:  2,
+			wantKind:   models.ViolationCodeBlock,
+			wantAction: models.ViolationActionRegenerated,
+		},
+		{
+			name:       "code block redacted",
+			policy:     models.CodePolicyNoCode,
+			replies:    []string{codeAnswer},
+			want:       "可以这样写：\n[根据课程要求，此处代码已隐藏]\n注意边界。",
+			wantCalls:  2,
+			wantKind:   models.ViolationCodeBlock,
+			wantAction: models.ViolationActionRedacted,
+		},
+		{name: "pseudo code allowed", policy: models.CodePolicyPseudoCode, replies: []string{pseudoAnswer}, want: pseudoAnswer, wantCalls: 1},
+		{
+			name:       "runnable code under pseudo code policy",
+			policy:     models.CodePolicyPseudoCode,
+			replies:    []string{pythonAnswer},
+			want:       "参考：\n[根据课程要求，此处代码已隐藏]\n",
+			wantCalls:  2,
+			wantKind:   models.ViolationCodeBlock,
+			wantAction: models.ViolationActionRedacted,
+		},
+		{
+			name:       "reference solution outside code blocks",
+			policy:     models.CodePolicyPseudoCode,
+			replies:    []string{"直接这样写：\n" + integrityReference},
+			want:       "根据课程要求，AI 助教不能直接提供本题的解答代码。请尝试描述你的思路或具体的疑问，助教会引导你继续思考。",
+			wantCalls:  2,
+			wantKind:   models.ViolationSolutionCopy,
+			wantAction: models.ViolationActionRedacted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, db.Save(&models.CourseAIConfig{CourseID: course.ID, CodePolicy: tt.policy}).Error)
+			assert.NoError(t, db.Unscoped().Where("1 = 1").Delete(&models.AIViolation{}).Error)
+			mu.Lock()
+			replies = tt.replies
+			mu.Unlock()
+			before := len(fake.Requests())
+
+			answer, _, err := service.Chat(services.WithAIUser(context.Background(), user.ID), problem.ID, "", "这题怎么写？", "")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, answer)
+
+			requests := fake.Requests()[before:]
+			assert.Len(t, requests, tt.wantCalls)
+			if tt.wantCalls > 1 {
+				// 重新回答时提醒模型遵守代码策略
+				assert.True(t, strings.Contains(requests[1].User, "不允许提供的代码"))
+			}
+
+			var violations []models.AIViolation
+			assert.NoError(t, db.Find(&violations).Error)
+			if tt.wantKind == "" {
+				assert.Empty(t, violations)
+				return
+			}
+			assert.Len(t, violations, 1)
+			assert.Equal(t, tt.wantKind, violations[0].Kind)
+			assert.Equal(t, tt.wantAction, violations[0].Action)
+			assert.Equal(t, tt.policy, violations[0].Policy)
+			assert.Equal(t, course.ID, violations[0].CourseID)
+			assert.Equal(t, tt.want, violations[0].Final)
+		})
+	}
+}
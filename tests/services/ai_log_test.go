@@ -0,0 +1,133 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAILogRecordsCallsAndCacheHits(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	fake := useFakeLLM(t, replyByModel(map[string]string{"qwen": "qwen 的分析"}, "deepseek 的分析"))
+	config.LLM.CacheTTL = map[string]time.Duration{services.FeatureAnalyzeCode: time.Hour}
+	service := services.NewAIService(db)
+	usageService := services.NewAIUsageService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	record := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusFailed, TypedCode: "logged_code()", Language: "cpp"}
+	assert.NoError(t, db.Create(&record).Error)
+	ctx := services.WithAIUser(context.Background(), user.ID)
+
+	_, err := service.AnalyzeCode(ctx, record.ID, problem.ID, "cpp", "")
+	assert.NoError(t, err)
+	assert.Len(t, fake.Requests(), 2)
+
+	var calls []models.AILog
+	assert.NoError(t, db.Order("provider").Find(&calls).Error)
+	assert.Len(t, calls, 2)
+	for _, call := range calls {
+		assert.NotZero(t, call.UsageID)
+		assert.Zero(t, call.CacheID)
+		assert.Equal(t, user.ID, call.UserID)
+		assert.Equal(t, point.CourseID, call.CourseID)
+		assert.Equal(t, problem.ID, call.ProblemID)
+		assert.Equal(t, record.ID, call.RecordID)
+		assert.Equal(t, services.FeatureAnalyzeCode, call.Feature)
+		assert.Equal(t, 15, call.TotalTokens)
+		assert.True(t, strings.Contains(call.Messages, "logged_code()"))
+		assert.Empty(t, call.Error)
+	}
+	assert.Equal(t, "deepseek 的分析", calls[0].Response)
+	assert.Equal(t, "deepseek-test", calls[0].ModelName)
+	assert.Equal(t, "qwen 的分析", calls[1].Response)
+
+	// 命中缓存时不调用模型，但同样记录学生看到的输出和对应的缓存记录
+	_, err = service.AnalyzeCode(ctx, record.ID, problem.ID, "cpp", "")
+	assert.NoError(t, err)
+	assert.Len(t, fake.Requests(), 2)
+
+	var hits []models.AILog
+	assert.NoError(t, db.Where("cache_id <> 0").Order("provider").Find(&hits).Error)
+	assert.Len(t, hits, 2)
+	for _, hit := range hits {
+		assert.Zero(t, hit.UsageID)
+		assert.Equal(t, user.ID, hit.UserID)
+		assert.Equal(t, point.CourseID, hit.CourseID)
+		assert.Equal(t, record.ID, hit.RecordID)
+		assert.True(t, strings.Contains(hit.Messages, "logged_code()"))
+	}
+	assert.Equal(t, "deepseek 的分析", hits[0].Response)
+	assert.Equal(t, "qwen 的分析", hits[1].Response)
+
+	// 调用失败时记录错误信息
+	fake.Fail("deepseek-test")
+	_, err = service.AnalyzeCode(ctx, 0, problem.ID, "cpp", "other_code()")
+	assert.NoError(t, err)
+	var failed models.AILog
+	assert.NoError(t, db.Where("error <> ''").First(&failed).Error)
+	assert.Equal(t, "deepseek", failed.Provider)
+	assert.Empty(t, failed.Response)
+
+	// 列表不返回完整的消息和输出
+	list, err := usageService.ListAILogs(services.AILogFilter{RecordID: record.ID, PageSize: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), list["total"])
+	raw, err := json.Marshal(list["items"])
+	assert.NoError(t, err)
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &items))
+	assert.Len(t, items, 3)
+	assert.Equal(t, "student", items[0]["username"])
+	assert.Equal(t, "Two Sum", items[0]["problem_title"])
+	assert.NotContains(t, items[0], "messages")
+	assert.NotContains(t, items[0], "response")
+
+	list, err = usageService.ListAILogs(services.AILogFilter{UserID: user.ID, Feature: services.FeatureHint})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), list["total"])
+
+	detail, err := usageService.GetAILog(calls[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, calls[1].Messages, detail.Messages)
+	assert.Equal(t, "qwen 的分析", detail.Response)
+	_, err = usageService.GetAILog(9999)
+	assert.ErrorContains(t, err, "调用日志不存在")
+}
+
+func TestPurgeExpiredAILogs(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	saved := config.LLM
+	defer func() { config.LLM = saved }()
+
+	logs := []models.AILog{{Feature: services.FeatureHint}, {Feature: services.FeatureChat}}
+	assert.NoError(t, db.Create(&logs).Error)
+	assert.NoError(t, db.Model(&logs[0]).Update("created_at", time.Now().AddDate(0, 0, -31)).Error)
+
+	// 保留天数为 0 时不删除
+	config.LLM.LogRetentionDays = 0
+	deleted, err := services.PurgeExpiredAILogs(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	config.LLM.LogRetentionDays = 30
+	deleted, err = services.PurgeExpiredAILogs(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var remaining []models.AILog
+	assert.NoError(t, db.Find(&remaining).Error)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, logs[1].ID, remaining[0].ID)
+}
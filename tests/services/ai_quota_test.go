@@ -0,0 +1,174 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaResolutionPriority(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	useFakeLLM(t, func(model, system, user string) string { return "" })
+	service := services.NewAIUsageService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-02")
+	course, _, _ := seedProblem(t, db)
+
+	tests := []struct {
+		name       string
+		configured int
+		quotas     []models.AIQuota
+		want       int64
+		wantCourse int64
+	}{
+		{name: "config file only", configured: 1000, want: 1000},
+		{
+			name:       "all users overrides config",
+			configured: 1000,
+			quotas:     []models.AIQuota{{DailyTokens: 2000}},
+			want:       2000,
+		},
+		{
+			name:   "role over all users",
+			quotas: []models.AIQuota{{DailyTokens: 200}, {Role: models.RoleUser, DailyTokens: 300}},
+			want:   300,
+		},
+		{
+			name:   "class over role",
+			quotas: []models.AIQuota{{Role: models.RoleUser, DailyTokens: 300}, {ClassID: user.ClassID, DailyTokens: 800}},
+			want:   800,
+		},
+		{
+			name: "class and role over class",
+			quotas: []models.AIQuota{
+				{ClassID: user.ClassID, DailyTokens: 800},
+				{ClassID: user.ClassID, Role: models.RoleUser, DailyTokens: 50},
+			},
+			want: 50,
+		},
+		{
+			name:   "unlimited at higher priority",
+			quotas: []models.AIQuota{{Role: models.RoleUser, DailyTokens: 300}, {ClassID: user.ClassID, DailyTokens: 0}},
+			want:   0,
+		},
+		{
+			name: "other class and role ignored",
+			quotas: []models.AIQuota{
+				{DailyTokens: 100},
+				{ClassID: other.ClassID, DailyTokens: 5},
+				{Role: models.RoleAdmin, DailyTokens: 7},
+			},
+			want: 100,
+		},
+		{
+			name: "course quota is separate",
+			quotas: []models.AIQuota{
+				{Role: models.RoleUser, DailyTokens: 300},
+				{CourseID: course.ID, DailyTokens: 100},
+				{CourseID: course.ID, ClassID: user.ClassID, DailyTokens: 40},
+			},
+			want:       300,
+			wantCourse: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, db.Unscoped().Where("1 = 1").Delete(&models.AIQuota{}).Error)
+			config.LLM.DailyTokenQuota = map[string]int{string(models.RoleUser): tt.configured}
+			for i := range tt.quotas {
+				assert.NoError(t, service.CreateQuota(&tt.quotas[i]))
+			}
+
+			usage, err := service.GetUserUsage(user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, usage["daily_tokens"])
+
+			courses := usage["courses"].([]map[string]interface{})
+			if tt.wantCourse == 0 {
+				assert.Empty(t, courses)
+				return
+			}
+			assert.Len(t, courses, 1)
+			assert.Equal(t, course.ID, courses[0]["course_id"])
+			assert.Equal(t, tt.wantCourse, courses[0]["daily_tokens"])
+		})
+	}
+}
+
+func TestQuotaValidation(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewAIUsageService(db)
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+
+	assert.NoError(t, service.CreateQuota(&models.AIQuota{ClassID: user.ClassID, DailyTokens: 100}))
+
+	tests := []struct {
+		name    string
+		quota   models.AIQuota
+		wantErr string
+	}{
+		{name: "invalid role", quota: models.AIQuota{Role: "GUEST", DailyTokens: 1}, wantErr: "无效的角色"},
+		{name: "negative tokens", quota: models.AIQuota{DailyTokens: -1}, wantErr: "不能为负数"},
+		{name: "class not found", quota: models.AIQuota{ClassID: 9999, DailyTokens: 1}, wantErr: "班级不存在"},
+		{name: "course not found", quota: models.AIQuota{CourseID: 9999, DailyTokens: 1}, wantErr: "课程不存在"},
+		{name: "duplicate scope", quota: models.AIQuota{ClassID: user.ClassID, DailyTokens: 1}, wantErr: "已存在"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, service.CreateQuota(&tt.quota), tt.wantErr)
+		})
+	}
+}
+
+func TestQuotaEnforced(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	// 每次调用使用 15 tokens
+	useFakeLLM(t, func(model, system, user string) string { return "提示" })
+	aiService := services.NewAIService(db)
+	usageService := services.NewAIUsageService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	course, point, problem := seedProblem(t, db)
+	ctx := services.WithAIUser(context.Background(), user.ID)
+
+	assert.NoError(t, usageService.CreateQuota(&models.AIQuota{CourseID: course.ID, DailyTokens: 20}))
+
+	_, err := aiService.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+	_, err = aiService.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+
+	// 额度只在调用前检查，用量达到上限后拒绝新的调用
+	_, err = aiService.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.True(t, errors.Is(err, services.ErrAIQuotaExceeded))
+	assert.ErrorContains(t, err, "本课程")
+
+	var usages []models.AIUsage
+	assert.NoError(t, db.Where("user_id = ?", user.ID).Find(&usages).Error)
+	assert.Len(t, usages, 2)
+	for _, usage := range usages {
+		assert.Equal(t, course.ID, usage.CourseID)
+		assert.Equal(t, user.ClassID, usage.ClassID)
+		assert.Equal(t, services.FeatureHint, usage.Feature)
+		assert.Equal(t, 15, usage.TotalTokens)
+		assert.False(t, usage.Estimated)
+	}
+
+	// 没有用户的调用（如定时任务）不检查额度
+	_, err = aiService.RequestHint(context.Background(), user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+}
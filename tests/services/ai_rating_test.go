@@ -0,0 +1,131 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateValidation(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewAIRatingService(db)
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+
+	// 作答记录上只有 qwen 生成的修正代码
+	record := models.UserProblem{
+		UserID:                 user.ID,
+		ProblemID:              problem.ID,
+		KnowledgePointID:       point.ID,
+		Status:                 models.ProblemStatusFailed,
+		QwenCorrectedCode:      "fixed",
+		QwenCorrectedCodeModel: "qwen",
+	}
+	othersRecord := models.UserProblem{UserID: other.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, QwenCorrectedCodeModel: "qwen"}
+	assert.NoError(t, db.Create([]*models.UserProblem{&record, &othersRecord}).Error)
+
+	othersHint := models.HintRecord{UserID: other.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Level: 1, Provider: "qwen"}
+	assert.NoError(t, db.Create(&othersHint).Error)
+
+	session := models.ChatSession{UserID: user.ID, ProblemID: problem.ID, Provider: "deepseek"}
+	assert.NoError(t, db.Create(&session).Error)
+	question := models.ChatMessage{SessionID: session.ID, Role: models.ChatRoleUser, Content: "怎么做？"}
+	assert.NoError(t, db.Create(&question).Error)
+
+	tests := []struct {
+		name  string
+		input services.RatingInput
+	}{
+		{name: "unknown feature", input: services.RatingInput{Feature: "unknown", Provider: "qwen", RecordID: record.ID}},
+		{name: "hint without hint record", input: services.RatingInput{Feature: services.FeatureHint, Provider: "qwen", RecordID: record.ID}},
+		{name: "chat without message", input: services.RatingInput{Feature: services.FeatureChat, Provider: "qwen", RecordID: record.ID}},
+		{name: "missing provider", input: services.RatingInput{Feature: services.FeatureCorrectCode, RecordID: record.ID}},
+		{name: "missing record", input: services.RatingInput{Feature: services.FeatureCorrectCode, Provider: "qwen"}},
+		{name: "another student's record", input: services.RatingInput{Feature: services.FeatureCorrectCode, Provider: "qwen", RecordID: othersRecord.ID}},
+		{name: "model did not produce the output", input: services.RatingInput{Feature: services.FeatureCorrectCode, Provider: "deepseek", RecordID: record.ID}},
+		{name: "feature not on record", input: services.RatingInput{Feature: services.FeatureAnalyzeCode, Provider: "qwen", RecordID: record.ID}},
+		{name: "review without comments", input: services.RatingInput{Feature: services.FeatureReviewCode, Provider: "qwen", RecordID: record.ID}},
+		{name: "another student's hint", input: services.RatingInput{HintRecordID: othersHint.ID}},
+		{name: "student's own chat message", input: services.RatingInput{ChatMessageID: question.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Rate(user.ID, tt.input)
+			assert.True(t, errors.Is(err, services.ErrInvalidRating), "err: %v", err)
+		})
+	}
+
+	var count int64
+	db.Model(&models.AIRating{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRateUsesStoredOutput(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewAIRatingService(db)
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-01")
+	course, point, problem := seedProblem(t, db)
+
+	records := make([]models.UserProblem, 0, 2)
+	for _, student := range []models.User{user, other} {
+		records = append(records, models.UserProblem{
+			UserID:                                     student.ID,
+			ProblemID:                                  problem.ID,
+			KnowledgePointID:                           point.ID,
+			Status:                                     models.ProblemStatusFailed,
+			DeepseekWrongReasonAndAnalyze:              "analysis",
+			DeepseekWrongReasonAndAnalyzeModel:         "deepseek",
+			DeepseekWrongReasonAndAnalyzeTemplateID:    7,
+			DeepseekWrongReasonAndAnalyzePromptVersion: 3,
+		})
+	}
+	assert.NoError(t, db.Create(&records).Error)
+
+	input := services.RatingInput{Feature: services.FeatureAnalyzeCode, Provider: "deepseek", RecordID: records[0].ID, Helpful: true}
+	rating, err := service.Rate(user.ID, input)
+	assert.NoError(t, err)
+	assert.Equal(t, course.ID, rating.CourseID)
+	assert.Equal(t, uint(7), rating.TemplateID)
+	assert.Equal(t, 3, rating.PromptVersion)
+
+	// 重复评价同一输出时更新之前的评价
+	input.Helpful = false
+	input.Reason = "没有指出错误"
+	updated, err := service.Rate(user.ID, input)
+	assert.NoError(t, err)
+	assert.Equal(t, rating.ID, updated.ID)
+
+	_, err = service.Rate(other.ID, services.RatingInput{Feature: services.FeatureAnalyzeCode, Provider: "deepseek", RecordID: records[1].ID, Helpful: true})
+	assert.NoError(t, err)
+
+	// 分级提示的模型和提示词取自提示记录
+	hint := models.HintRecord{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, UserProblemID: records[0].ID, Level: 1, Provider: "qwen", TemplateID: 9, PromptVersion: 2}
+	assert.NoError(t, db.Create(&hint).Error)
+	hintRating, err := service.Rate(user.ID, services.RatingInput{Feature: services.FeatureChat, Provider: "deepseek", HintRecordID: hint.ID, Helpful: true})
+	assert.NoError(t, err)
+	assert.Equal(t, services.FeatureHint, hintRating.Feature)
+	assert.Equal(t, "qwen", hintRating.Provider)
+	assert.Equal(t, records[0].ID, hintRating.RecordID)
+	assert.Equal(t, uint(9), hintRating.TemplateID)
+	assert.Equal(t, course.ID, hintRating.CourseID)
+
+	report, err := service.Report(services.RatingFilter{CourseID: course.ID, Feature: services.FeatureAnalyzeCode})
+	assert.NoError(t, err)
+	assert.Len(t, report, 1)
+	assert.Equal(t, "deepseek", report[0]["provider"])
+	assert.Equal(t, uint(7), report[0]["template_id"])
+	assert.Equal(t, 3, report[0]["prompt_version"])
+	assert.Equal(t, int64(2), report[0]["total"])
+	assert.Equal(t, int64(1), report[0]["helpful"])
+	assert.Equal(t, float64(50), report[0]["helpful_rate"])
+}
@@ -0,0 +1,231 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/tests"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// setupAIDB 创建包含 AI 相关数据表的测试数据库，没有配置测试数据库时跳过
+func setupAIDB(t *testing.T) (*gorm.DB, func()) {
+	if _, err := os.Stat("../../.env"); err != nil {
+		t.Skip("未配置测试数据库（../../.env），跳过")
+	}
+	db, cleanup := tests.SetupTestDB()
+	err := db.AutoMigrate(
+		&models.Course{},
+		&models.Class{},
+		&models.KnowledgePoint{},
+		&models.KnowledgePointTag{},
+		&models.CourseClasses{},
+		&models.HintRecord{},
+		&models.ChatSession{},
+		&models.ChatMessage{},
+		&models.CourseAIConfig{},
+		&models.PromptTemplate{},
+		&models.PromptTemplateVersion{},
+		&models.AIResponseCache{},
+		&models.AIUsage{},
+		&models.AIQuota{},
+		&models.AIRating{},
+		&models.AIViolation{},
+		&models.ProblemDraft{},
+		&models.CodeError{},
+		&models.SyllabusPlan{},
+		&models.ReviewComment{},
+		&models.AILog{},
+		&models.AutoAnalysis{},
+		&models.CourseMaterial{},
+		&models.MaterialChunk{},
+	)
+	if err != nil {
+		cleanup()
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db, cleanup
+}
+
+// fakeLLM 兼容 OpenAI 接口的模型服务，按请求的模型名和提示词返回预设的回复
+type fakeLLM struct {
+	mu       sync.Mutex
+	reply    func(model, system, user string) string
+	requests []fakeLLMRequest
+	failing  map[string]bool
+}
+
+type fakeLLMRequest struct {
+	Model  string
+	System string
+	User   string
+}
+
+// Fail 使指定模型的请求返回错误，状态码为 400，客户端不会重试
+func (f *fakeLLM) Fail(model string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[model] = true
+}
+
+// Requests 返回收到的全部请求
+func (f *fakeLLM) Requests() []fakeLLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeLLMRequest(nil), f.requests...)
+}
+
+func (f *fakeLLM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := fakeLLMRequest{Model: body.Model}
+	for _, message := range body.Messages {
+		switch message.Role {
+		case "system":
+			req.System = messageText(message.Content)
+		case "user":
+			req.User = messageText(message.Content)
+		}
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	failing := f.failing[body.Model]
+	f.mu.Unlock()
+	if failing {
+		http.Error(w, `{"error":{"message":"model unavailable"}}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   body.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"finish_reason": "stop",
+			"message":       map[string]interface{}{"role": "assistant", "content": f.reply(req.Model, req.System, req.User)},
+		}},
+		"usage": map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+}
+
+// messageText 消息内容可能是字符串，也可能是文本片段的数组
+func messageText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(raw, &parts)
+	var builder strings.Builder
+	for _, part := range parts {
+		builder.WriteString(part.Text)
+	}
+	return builder.String()
+}
+
+// useFakeLLM 把 qwen 和 deepseek 指向本地的模型服务，所有功能都先用 qwen，
+// 对比功能同时使用两个模型。模型名分别为 qwen-test 和 deepseek-test
+func useFakeLLM(t *testing.T, reply func(model, system, user string) string) *fakeLLM {
+	fake := &fakeLLM{reply: reply, failing: make(map[string]bool)}
+	server := httptest.NewServer(fake)
+
+	saved := config.LLM
+	t.Cleanup(func() {
+		config.LLM = saved
+		server.Close()
+	})
+
+	config.LLM.Providers = []config.LLMProviderConfig{
+		{Name: "qwen", BaseURL: server.URL + "/v1/", Model: "qwen-test"},
+		{Name: "deepseek", BaseURL: server.URL + "/v1/", Model: "deepseek-test"},
+	}
+	config.LLM.Features = make(map[string][]string)
+	for _, feature := range []string{
+		services.FeatureHint, services.FeatureChat, services.FeatureChatSummary, services.FeatureSuggestTags,
+		services.FeatureJudge, services.FeatureDraftProblem, services.FeatureClassifyErrors,
+		services.FeatureMisconceptions, services.FeatureLearningReport, services.FeatureSyllabusPlan,
+		services.FeatureReviewCode,
+	} {
+		config.LLM.Features[feature] = []string{"qwen"}
+	}
+	config.LLM.Features[services.FeatureCorrectCode] = []string{"qwen", "deepseek"}
+	config.LLM.Features[services.FeatureAnalyzeCode] = []string{"qwen", "deepseek"}
+	config.LLM.CacheTTL = map[string]time.Duration{}
+	config.LLM.DailyTokenQuota = map[string]int{}
+	config.LLM.StructuredRetries = 1
+	return fake
+}
+
+// replyByModel 按模型名返回不同的回复，没有匹配时返回 fallback
+func replyByModel(replies map[string]string, fallback string) func(model, system, user string) string {
+	return func(model, system, user string) string {
+		for prefix, reply := range replies {
+			if strings.HasPrefix(model, prefix) {
+				return reply
+			}
+		}
+		return fallback
+	}
+}
+
+// seedUser 创建指定班级和角色的用户，班级不存在时一并创建
+func seedUser(t *testing.T, db *gorm.DB, username string, role models.Role, className string) models.User {
+	class := models.Class{Name: className}
+	if err := db.Where(models.Class{Name: className}).FirstOrCreate(&class).Error; err != nil {
+		t.Fatalf("创建班级失败: %v", err)
+	}
+	user := models.User{Username: username, Name: username, StudentID: username, Password: "password", Role: role, ClassID: class.ID}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// seedProblem 创建一门只有一个知识点、一道题目的课程
+func seedProblem(t *testing.T, db *gorm.DB) (models.Course, models.KnowledgePoint, models.Problem) {
+	problem := models.Problem{
+		LeetcodeID:      1,
+		Title:           "Two Sum",
+		TitleCn:         "两数之和",
+		TitleSlug:       "two-sum",
+		Difficulty:      models.ProblemDifficultyEasy,
+		Content:         "Given an array of integers nums and an integer target...",
+		SampleTestcases: "[2,7,11,15]\n9",
+	}
+	if err := db.Create(&problem).Error; err != nil {
+		t.Fatalf("创建题目失败: %v", err)
+	}
+	course := models.Course{Name: "数据结构"}
+	if err := db.Create(&course).Error; err != nil {
+		t.Fatalf("创建课程失败: %v", err)
+	}
+	point := models.KnowledgePoint{Name: "数组", CourseID: course.ID, Problems: []models.Problem{problem}}
+	if err := db.Create(&point).Error; err != nil {
+		t.Fatalf("创建知识点失败: %v", err)
+	}
+	return course, point, problem
+}
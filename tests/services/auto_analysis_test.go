@@ -0,0 +1,265 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// seedAutoAnalysis 创建一条 FAILED 的作答记录和已到执行时间的自动分析
+func seedAutoAnalysis(t *testing.T, db *gorm.DB, user models.User, point models.KnowledgePoint, problem models.Problem, code string) models.AutoAnalysis {
+	record := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusFailed, TypedCode: code, Language: "cpp"}
+	assert.NoError(t, db.Create(&record).Error)
+	analysis := models.AutoAnalysis{
+		RecordID:  record.ID,
+		UserID:    user.ID,
+		ProblemID: problem.ID,
+		CourseID:  point.CourseID,
+		Language:  "cpp",
+		TypedCode: code,
+		Status:    models.AutoAnalysisPending,
+		NextRunAt: time.Now().Add(-time.Second),
+	}
+	assert.NoError(t, db.Create(&analysis).Error)
+	return analysis
+}
+
+func TestAutoAnalysisEnqueuedOnFailedCheck(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"state": "FAILED", "status_msg": "Wrong Answer"}`))
+	}))
+	defer server.Close()
+	service := services.NewLeetCodeService(db)
+	service.Client.SetBaseURL(server.URL)
+	aiService := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	course, point, problem := seedProblem(t, db)
+	submit := func(submissionID float64, code string) models.UserProblem {
+		record := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusTried, SubmissionID: submissionID, TypedCode: code, Language: "cpp"}
+		assert.NoError(t, db.Create(&record).Error)
+		return record
+	}
+
+	// 课程未开启自动分析时不创建
+	submit(101, "first()")
+	result, err := service.Check(user.ID, "101", false)
+	assert.NoError(t, err)
+	assert.NotContains(t, result, "auto_analysis")
+
+	assert.NoError(t, db.Save(&models.CourseAIConfig{CourseID: course.ID, AutoAnalyzeEnabled: true}).Error)
+
+	// 只运行测试用例时不更新作答记录，也不创建自动分析
+	record := submit(102, "second()")
+	result, err = service.Check(user.ID, "102", true)
+	assert.NoError(t, err)
+	assert.NotContains(t, result, "auto_analysis")
+
+	result, err = service.Check(user.ID, "102", false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"record_id": record.ID, "status": models.AutoAnalysisPending}, result["auto_analysis"])
+
+	analysis, err := aiService.GetAutoAnalysis(user.ID, record.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, course.ID, analysis.CourseID)
+	assert.Equal(t, "second()", analysis.TypedCode)
+	assert.Equal(t, 0, analysis.Attempts)
+
+	// 同一次提交重复查询时不会再次排队
+	assert.NoError(t, db.Model(analysis).Updates(map[string]interface{}{"status": models.AutoAnalysisFailed, "attempts": 3, "last_error": "boom"}).Error)
+	result, err = service.Check(user.ID, "102", false)
+	assert.NoError(t, err)
+	assert.NotContains(t, result, "auto_analysis")
+
+	// 作答记录再次判定为 FAILED 时重置已有的分析，改为分析新提交的代码
+	assert.NoError(t, db.Model(&record).Updates(map[string]interface{}{"status": models.ProblemStatusTried, "submission_id": 103, "typed_code": "third()"}).Error)
+	_, err = service.Check(user.ID, "103", false)
+	assert.NoError(t, err)
+
+	var analyses []models.AutoAnalysis
+	assert.NoError(t, db.Where("record_id = ?", record.ID).Find(&analyses).Error)
+	assert.Len(t, analyses, 1)
+	assert.Equal(t, analysis.ID, analyses[0].ID)
+	assert.Equal(t, models.AutoAnalysisPending, analyses[0].Status)
+	assert.Equal(t, 0, analyses[0].Attempts)
+	assert.Equal(t, "third()", analyses[0].TypedCode)
+	assert.Empty(t, analyses[0].LastError)
+
+	_, err = aiService.GetAutoAnalysis(user.ID+1, record.ID)
+	assert.ErrorContains(t, err, "自动分析不存在")
+}
+
+func TestProcessAutoAnalysesRetry(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	fake := useFakeLLM(t, func(model, system, user string) string { return "分析" })
+	config.LLM.AutoAnalyzeMaxAttempts = 2
+	config.LLM.AutoAnalyzeRetryDelay = time.Minute
+	config.LLM.AutoAnalyzeConcurrency = 2
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	analysis := seedAutoAnalysis(t, db, user, point, problem, "broken()")
+
+	load := func() models.AutoAnalysis {
+		var current models.AutoAnalysis
+		assert.NoError(t, db.First(&current, analysis.ID).Error)
+		return current
+	}
+
+	// 执行失败后等待重试
+	fake.Fail("qwen-test")
+	fake.Fail("deepseek-test")
+	processed, err := service.ProcessAutoAnalyses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	current := load()
+	assert.Equal(t, models.AutoAnalysisPending, current.Status)
+	assert.Equal(t, 1, current.Attempts)
+	assert.NotEmpty(t, current.LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), current.NextRunAt, 10*time.Second)
+	assert.Nil(t, current.FinishedAt)
+
+	// 未到重试时间时不执行
+	processed, err = service.ProcessAutoAnalyses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processed)
+
+	// 次数用完后不再重试
+	assert.NoError(t, db.Model(&current).Update("next_run_at", time.Now().Add(-time.Second)).Error)
+	processed, err = service.ProcessAutoAnalyses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	current = load()
+	assert.Equal(t, models.AutoAnalysisFailed, current.Status)
+	assert.Equal(t, 2, current.Attempts)
+	assert.NotNil(t, current.FinishedAt)
+
+	processed, err = service.ProcessAutoAnalyses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processed)
+}
+
+func TestProcessAutoAnalysesStates(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var onCall func()
+	useFakeLLM(t, func(model, system, user string) string {
+		if onCall != nil {
+			onCall()
+		}
+		return "分析"
+	})
+	config.LLM.AutoAnalyzeMaxAttempts = 3
+	config.LLM.AutoAnalyzeRetryDelay = time.Minute
+	config.LLM.AutoAnalyzeConcurrency = 1
+	service := services.NewAIService(db)
+	usageService := services.NewAIUsageService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+
+	load := func(id uint) models.AutoAnalysis {
+		var current models.AutoAnalysis
+		assert.NoError(t, db.First(&current, id).Error)
+		return current
+	}
+	process := func(want int) {
+		processed, err := service.ProcessAutoAnalyses(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, want, processed)
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		analysis := seedAutoAnalysis(t, db, user, point, problem, "wrong()")
+		process(1)
+		current := load(analysis.ID)
+		assert.Equal(t, models.AutoAnalysisCompleted, current.Status)
+		assert.Equal(t, 1, current.Attempts)
+		assert.Equal(t, "分析", current.Result["qwen_wrong_reason_and_analyze"])
+		assert.NotNil(t, current.FinishedAt)
+
+		// 以作答记录所属学生的身份调用，分析结果写回作答记录
+		var usage models.AIUsage
+		assert.NoError(t, db.Where("feature = ?", services.FeatureAnalyzeCode).First(&usage).Error)
+		assert.Equal(t, user.ID, usage.UserID)
+		var record models.UserProblem
+		assert.NoError(t, db.First(&record, analysis.RecordID).Error)
+		assert.Equal(t, "分析", record.QwenWrongReasonAndAnalyze)
+
+		var events []string
+		err := service.WaitAutoAnalysis(context.Background(), user.ID, analysis.RecordID, func(event string, data map[string]interface{}) {
+			events = append(events, event)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"state", "result"}, events)
+	})
+
+	t.Run("stale running analysis is reclaimed", func(t *testing.T) {
+		stale := seedAutoAnalysis(t, db, user, point, problem, "stale()")
+		fresh := seedAutoAnalysis(t, db, user, point, problem, "fresh()")
+		assert.NoError(t, db.Model(&stale).UpdateColumns(map[string]interface{}{"status": models.AutoAnalysisRunning, "attempts": 1, "updated_at": time.Now().Add(-11 * time.Minute)}).Error)
+		assert.NoError(t, db.Model(&fresh).UpdateColumns(map[string]interface{}{"status": models.AutoAnalysisRunning, "attempts": 1}).Error)
+
+		process(1)
+		assert.Equal(t, models.AutoAnalysisCompleted, load(stale.ID).Status)
+		assert.Equal(t, 2, load(stale.ID).Attempts)
+		assert.Equal(t, models.AutoAnalysisRunning, load(fresh.ID).Status)
+		assert.NoError(t, db.Delete(&fresh).Error)
+	})
+
+	t.Run("requeued while running", func(t *testing.T) {
+		analysis := seedAutoAnalysis(t, db, user, point, problem, "old()")
+		// 执行期间作答记录再次判定为 FAILED，分析被重置为分析新代码
+		onCall = func() {
+			db.Model(&models.AutoAnalysis{}).Where("id = ?", analysis.ID).
+				Updates(map[string]interface{}{"status": models.AutoAnalysisPending, "attempts": 0, "typed_code": "new()", "next_run_at": time.Now().Add(time.Hour)})
+		}
+		defer func() { onCall = nil }()
+
+		process(1)
+		current := load(analysis.ID)
+		assert.Equal(t, models.AutoAnalysisPending, current.Status)
+		assert.Equal(t, 0, current.Attempts)
+		assert.Equal(t, "new()", current.TypedCode)
+		assert.Nil(t, current.Result)
+		assert.NoError(t, db.Delete(&current).Error)
+	})
+
+	t.Run("record deleted", func(t *testing.T) {
+		analysis := seedAutoAnalysis(t, db, user, point, problem, "deleted()")
+		assert.NoError(t, db.Delete(&models.UserProblem{}, analysis.RecordID).Error)
+		process(1)
+		current := load(analysis.ID)
+		assert.Equal(t, models.AutoAnalysisFailed, current.Status)
+		assert.Equal(t, 1, current.Attempts)
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		analysis := seedAutoAnalysis(t, db, other, point, problem, "quota()")
+		assert.NoError(t, usageService.CreateQuota(&models.AIQuota{DailyTokens: 10}))
+		assert.NoError(t, db.Create(&models.AIUsage{UserID: other.ID, Feature: services.FeatureHint, TotalTokens: 20}).Error)
+
+		process(1)
+		current := load(analysis.ID)
+		assert.Equal(t, models.AutoAnalysisFailed, current.Status)
+		assert.Equal(t, 1, current.Attempts)
+		assert.Contains(t, current.LastError, "每日上限")
+	})
+}
@@ -0,0 +1,121 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const reviewCode = "int main() {\n  int a;\n  return 0;\n}"
+
+func TestReviewCodeLineValidation(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var reply string
+	fake := useFakeLLM(t, func(model, system, user string) string { return reply })
+	service := services.NewAIService(db)
+	_, _, problem := seedProblem(t, db)
+
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr string
+	}{
+		{name: "not json", reply: "代码没有问题", wantErr: "不是有效的 JSON"},
+		{name: "missing comments", reply: `{"errors": []}`, wantErr: "缺少 comments 字段"},
+		{name: "line zero", reply: `{"comments": [{"start_line": 0, "end_line": 1, "severity": "error", "message": "m"}]}`, wantErr: "行号范围 0-1 无效"},
+		{name: "end before start", reply: `{"comments": [{"start_line": 3, "end_line": 2, "severity": "error", "message": "m"}]}`, wantErr: "行号范围 3-2 无效"},
+		{name: "beyond last line", reply: `{"comments": [{"start_line": 4, "end_line": 5, "severity": "error", "message": "m"}]}`, wantErr: "代码共 4 行"},
+		{name: "unknown severity", reply: `{"comments": [{"start_line": 1, "end_line": 1, "severity": "fatal", "message": "m"}]}`, wantErr: "严重程度"},
+		{name: "missing message", reply: `{"comments": [{"start_line": 1, "end_line": 1, "severity": "warning", "message": " "}]}`, wantErr: "缺少 message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply = tt.reply
+			before := len(fake.Requests())
+			_, err := service.ReviewCode(context.Background(), 0, 0, problem.ID, "cpp", reviewCode, "")
+			assert.ErrorContains(t, err, "格式无效")
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			// 输出无效时把错误原因发给模型重试一次
+			requests := fake.Requests()[before:]
+			assert.Len(t, requests, 2)
+			assert.True(t, strings.Contains(requests[1].User, tt.wantErr))
+		})
+	}
+
+	_, err := service.ReviewCode(context.Background(), 0, 0, problem.ID, "cpp", " \n", "")
+	assert.ErrorContains(t, err, "没有需要审阅的代码")
+}
+
+func TestReviewCodeSavesComments(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var first, retry string
+	fake := useFakeLLM(t, func(model, system, user string) string {
+		if strings.Contains(user, "不符合要求") {
+			return retry
+		}
+		return first
+	})
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	other := seedUser(t, db, "other", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	record := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusFailed, TypedCode: reviewCode, Language: "cpp"}
+	assert.NoError(t, db.Create(&record).Error)
+
+	// 第一次输出的行号超出代码范围，重试后得到有效批注
+	first = `{"comments": [{"start_line": 9, "end_line": 9, "severity": "error", "message": "越界"}]}`
+	retry = "```json\n" + `{"comments": [
+		{"start_line": 3, "end_line": 3, "severity": " Suggestion ", "message": "可以省略", "suggestion": "}\r\n"},
+		{"start_line": 2, "end_line": 2, "severity": "WARNING", "message": " 变量 a 未使用 "}
+	]}` + "\n```"
+	before := len(fake.Requests())
+	ctx := services.WithAIUser(context.Background(), user.ID)
+	result, err := service.ReviewCode(ctx, user.ID, record.ID, problem.ID, "python3", "changed()", "")
+	assert.NoError(t, err)
+
+	requests := fake.Requests()[before:]
+	assert.Len(t, requests, 2)
+	// 审阅作答记录中的代码，行号与发送给模型的代码一致
+	assert.True(t, strings.Contains(requests[0].User, "2:   int a;"))
+	assert.False(t, strings.Contains(requests[0].User, "changed()"))
+	assert.True(t, strings.Contains(requests[1].User, "行号范围 9-9 无效"))
+	assert.Equal(t, reviewCode, result["typed_code"])
+	assert.Equal(t, 4, result["line_count"])
+
+	comments, err := service.GetReviewComments(user.ID, record.ID)
+	assert.NoError(t, err)
+	assert.Len(t, comments, 2)
+	assert.Equal(t, 2, comments[0].StartLine)
+	assert.Equal(t, models.ReviewSeverityWarning, comments[0].Severity)
+	assert.Equal(t, "变量 a 未使用", comments[0].Message)
+	assert.Equal(t, 3, comments[1].StartLine)
+	assert.Equal(t, models.ReviewSeveritySuggestion, comments[1].Severity)
+	assert.Equal(t, "}", comments[1].Suggestion)
+	assert.Equal(t, user.ID, comments[1].UserID)
+	assert.Equal(t, "qwen", comments[1].Provider)
+
+	// 重新审阅时替换已有批注
+	first = `{"comments": []}`
+	_, err = service.ReviewCode(ctx, user.ID, record.ID, problem.ID, "cpp", "", "")
+	assert.NoError(t, err)
+	comments, err = service.GetReviewComments(user.ID, record.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+
+	// 不能审阅或查看其他学生的作答记录
+	_, err = service.ReviewCode(ctx, other.ID, record.ID, problem.ID, "cpp", reviewCode, "")
+	assert.ErrorContains(t, err, "作答记录不存在")
+	_, err = service.GetReviewComments(other.ID, record.ID)
+	assert.ErrorContains(t, err, "作答记录不存在")
+}
@@ -0,0 +1,100 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestHintLevels(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var calls int32
+	fake := useFakeLLM(t, func(model, system, user string) string {
+		return fmt.Sprintf("提示%d", atomic.AddInt32(&calls, 1))
+	})
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	ctx := services.WithAIUser(context.Background(), user.ID)
+
+	// 提示必须按顺序解锁
+	_, err := service.RequestHint(ctx, user.ID, problem.ID, point.ID, 2, "")
+	assert.ErrorContains(t, err, "请先查看第 1 级提示")
+
+	first, err := service.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.HintLevelNudge, first.Level)
+	assert.Equal(t, "提示1", first.Content)
+	assert.Equal(t, "qwen", first.Provider)
+
+	// 已解锁的等级返回之前生成的内容，不再调用模型
+	again, err := service.RequestHint(ctx, user.ID, problem.ID, point.ID, 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "提示1", again.Content)
+	assert.Len(t, fake.Requests(), 1)
+
+	// 更高等级的提示会带上学生已经看过的提示
+	second, err := service.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.HintLevelKeyIdea, second.Level)
+	assert.Contains(t, fake.Requests()[1].User, "提示1")
+
+	for level := models.HintLevelSteps; level <= models.HintLevelMax; level++ {
+		_, err = service.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+		assert.NoError(t, err)
+	}
+
+	// 全部解锁后继续请求返回最高等级
+	last, err := service.RequestHint(ctx, user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.HintLevelMax, last.Level)
+	assert.Len(t, fake.Requests(), models.HintLevelMax)
+
+	_, err = service.RequestHint(ctx, user.ID, problem.ID, point.ID, models.HintLevelMax+1, "")
+	assert.ErrorContains(t, err, "无效的提示等级")
+
+	hints, err := service.GetHints(user.ID, problem.ID, point.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HintLevelMax, hints["hint_level"])
+	assert.Equal(t, 0, hints["next_level"])
+	assert.Len(t, hints["hints"], models.HintLevelMax)
+
+	// 每次请求都有记录，作答记录上是已解锁的最高等级
+	var count int64
+	db.Model(&models.HintRecord{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(models.HintLevelMax+2), count)
+
+	var record models.UserProblem
+	assert.NoError(t, db.Where("user_id = ? AND problem_id = ?", user.ID, problem.ID).First(&record).Error)
+	assert.Equal(t, models.HintLevelMax, record.HintLevel)
+}
+
+func TestRequestHintKeepsSolvedRecord(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	useFakeLLM(t, func(model, system, user string) string { return "提示" })
+	service := services.NewAIService(db)
+
+	user := seedUser(t, db, "student", models.RoleUser, "CS-01")
+	_, point, problem := seedProblem(t, db)
+	solved := models.UserProblem{UserID: user.ID, ProblemID: problem.ID, KnowledgePointID: point.ID, Status: models.ProblemStatusSolved}
+	assert.NoError(t, db.Create(&solved).Error)
+
+	hint, err := service.RequestHint(services.WithAIUser(context.Background(), user.ID), user.ID, problem.ID, point.ID, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, solved.ID, hint.UserProblemID)
+
+	// 通过后查看提示不影响得分
+	var record models.UserProblem
+	assert.NoError(t, db.First(&record, solved.ID).Error)
+	assert.Equal(t, 0, record.HintLevel)
+}
@@ -0,0 +1,100 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"ai_teach_system/tests"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// setupReportDB 学习报告的统计依赖 MySQL 的查询语义，没有配置测试数据库时跳过
+func setupReportDB(t *testing.T) (*gorm.DB, func()) {
+	if _, err := os.Stat("../../.env"); err != nil {
+		t.Skip("未配置测试数据库（../../.env），跳过")
+	}
+	db, cleanup := tests.SetupTestDB()
+	if err := db.AutoMigrate(&models.Course{}, &models.KnowledgePoint{}); err != nil {
+		cleanup()
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db, cleanup
+}
+
+// seedReportCourse 创建一门两个知识点的课程：知识点 1 包含题目 1-3，知识点 2 包含题目 3-4。
+// 学生先失败后通过了题目 1，两次失败题目 2，尝试过题目 4，没有做过题目 3
+func seedReportCourse(t *testing.T, db *gorm.DB, userID uint) []models.Problem {
+	problems := make([]models.Problem, 4)
+	for i := range problems {
+		problems[i] = models.Problem{
+			Title:      []string{"p1", "p2", "p3", "p4"}[i],
+			TitleCn:    []string{"题目1", "题目2", "题目3", "题目4"}[i],
+			TitleSlug:  []string{"p1", "p2", "p3", "p4"}[i],
+			Difficulty: models.ProblemDifficultyEasy,
+			Content:    "content",
+		}
+	}
+	assert.NoError(t, db.Create(&problems).Error)
+
+	course := models.Course{Name: "数据结构"}
+	assert.NoError(t, db.Create(&course).Error)
+	points := []models.KnowledgePoint{
+		{Name: "数组", CourseID: course.ID, Problems: problems[:3]},
+		{Name: "链表", CourseID: course.ID, Problems: problems[2:]},
+	}
+	assert.NoError(t, db.Create(&points).Error)
+
+	records := []models.UserProblem{
+		{UserID: userID, ProblemID: problems[0].ID, KnowledgePointID: points[0].ID, Status: models.ProblemStatusFailed},
+		{UserID: userID, ProblemID: problems[0].ID, KnowledgePointID: points[0].ID, Status: models.ProblemStatusSolved},
+		{UserID: userID, ProblemID: problems[1].ID, KnowledgePointID: points[0].ID, Status: models.ProblemStatusFailed},
+		{UserID: userID, ProblemID: problems[1].ID, KnowledgePointID: points[0].ID, Status: models.ProblemStatusFailed},
+		{UserID: userID, ProblemID: problems[3].ID, KnowledgePointID: points[1].ID, Status: models.ProblemStatusTried},
+	}
+	assert.NoError(t, db.Create(&records).Error)
+	return problems
+}
+
+func TestReportCandidates(t *testing.T) {
+	db, cleanup := setupReportDB(t)
+	defer cleanup()
+
+	const userID = 1
+	problems := seedReportCourse(t, db, userID)
+	var course models.Course
+	assert.NoError(t, db.First(&course).Error)
+
+	candidates, err := services.NewAIService(db).ReportCandidates(userID, course.ID)
+	assert.NoError(t, err)
+
+	// 通过过的题目不推荐，多次失败或属于多个知识点的题目只出现一次，失败过的题目排在最前
+	ids := make([]uint, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.ProblemID)
+	}
+	assert.Equal(t, []uint{problems[1].ID, problems[2].ID, problems[3].ID}, ids)
+	assert.Equal(t, "题目2", candidates[0].Title)
+	assert.Equal(t, "数组", candidates[0].KnowledgePoint)
+}
+
+func TestKnowledgePointProgress(t *testing.T) {
+	db, cleanup := setupReportDB(t)
+	defer cleanup()
+
+	const userID = 1
+	seedReportCourse(t, db, userID)
+	var course models.Course
+	assert.NoError(t, db.First(&course).Error)
+
+	stats, err := services.NewAIService(db).KnowledgePointProgress(userID, course.ID)
+	assert.NoError(t, err)
+
+	// 题目 1 失败后又通过，不计入失败
+	assert.Equal(t, []services.KnowledgePointProgress{
+		{Name: "数组", Tried: 2, Solved: 1, Failed: 1, Total: 3},
+		{Name: "链表", Tried: 1, Solved: 0, Failed: 0, Total: 2},
+	}, stats)
+}
@@ -0,0 +1,40 @@
+package services_test
+
+import (
+	"ai_teach_system/services"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStreamRetriesTransientErrors(t *testing.T) {
+	// 第一次查询返回无法解析的响应，之后依次返回判题中和判题完成
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Write([]byte("{"))
+		case 2:
+			w.Write([]byte(`{"state":"STARTED"}`))
+		default:
+			w.Write([]byte(`{"state":"SUCCESS","status_msg":"Accepted"}`))
+		}
+	}))
+	defer server.Close()
+
+	service := services.NewLeetCodeService(nil)
+	service.Client.SetBaseURL(server.URL)
+
+	var events []string
+	err := service.CheckStream(context.Background(), 1, "runcode_1", true, func(event string, data map[string]interface{}) {
+		events = append(events, event)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"state", "state", "result"}, events)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
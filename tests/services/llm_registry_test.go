@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRegistry(t *testing.T) *services.LLMRegistry {
+	saved := config.LLM
+	t.Cleanup(func() { config.LLM = saved })
+
+	temperature := 0.2
+	config.LLM.Providers = []config.LLMProviderConfig{
+		{Name: "qwen", BaseURL: "http://qwen.local/v1", APIKey: "secret", Model: "qwen-test", Temperature: &temperature},
+		{Name: "local", BaseURL: "http://localhost:8000/v1", Model: "llama-test", MaxTokens: 512},
+	}
+	config.LLM.Features = map[string][]string{
+		services.FeatureAnalyzeCode: {"qwen", "local"},
+		services.FeatureChat:        {"local"},
+		services.FeatureHint:        {"qwen"},
+		services.FeatureJudge:       {"missing"},
+	}
+	return services.NewLLMRegistry()
+}
+
+func TestLLMRegistryResolve(t *testing.T) {
+	registry := setupRegistry(t)
+
+	tests := []struct {
+		name      string
+		feature   string
+		modelType string
+		want      string
+		wantErr   bool
+	}{
+		{name: "feature default", feature: services.FeatureChat, want: "local"},
+		{name: "first of compared models", feature: services.FeatureAnalyzeCode, want: "qwen"},
+		{name: "explicit model overrides feature", feature: services.FeatureChat, modelType: "qwen", want: "qwen"},
+		{name: "unknown explicit model", feature: services.FeatureChat, modelType: "gpt", wantErr: true},
+		{name: "feature not configured", feature: services.FeatureSyllabusPlan, wantErr: true},
+		{name: "feature uses unconfigured provider", feature: services.FeatureJudge, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := registry.Resolve(tt.feature, tt.modelType)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, provider.Name)
+		})
+	}
+}
+
+func TestLLMRegistryForFeature(t *testing.T) {
+	registry := setupRegistry(t)
+
+	providers, err := registry.ForFeature(services.FeatureAnalyzeCode)
+	assert.NoError(t, err)
+	assert.Len(t, providers, 2)
+	assert.Equal(t, "qwen", providers[0].Name)
+	assert.Equal(t, "qwen-test", providers[0].Model)
+	assert.Equal(t, "local", providers[1].Name)
+	assert.Equal(t, 512, providers[1].MaxTokens)
+}
+
+func TestLLMRegistryList(t *testing.T) {
+	registry := setupRegistry(t)
+
+	models := registry.List()
+	assert.Len(t, models, 2)
+
+	// 按配置顺序列出，功能名排序
+	assert.Equal(t, "qwen", models[0]["name"])
+	assert.Equal(t, []string{services.FeatureAnalyzeCode, services.FeatureHint}, models[0]["features"])
+	assert.Equal(t, "local", models[1]["name"])
+	assert.Equal(t, []string{services.FeatureAnalyzeCode, services.FeatureChat}, models[1]["features"])
+
+	// 不返回密钥和地址
+	for _, model := range models {
+		assert.NotContains(t, model, "api_key")
+		assert.NotContains(t, model, "base_url")
+	}
+}
@@ -0,0 +1,143 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const draftReply = "```json\n" + `{
+    "title": "Pair Sum",
+    "title_cn": "数对之和",
+    "content": "Find a pair...",
+    "content_cn": "找出一对数...",
+    "sample_testcases": "[1,2]\n3",
+    "test_cases": "[1,2]\n3\n[3,4]\n7",
+    "reference_solution": "class Solution {};",
+    "time_limit": 0,
+    "memory_limit": 256
+}` + "\n```"
+
+func TestDraftProblem(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var reply string
+	fake := useFakeLLM(t, func(model, system, user string) string { return reply })
+	service := services.NewAIService(db)
+
+	teacher := seedUser(t, db, "teacher", models.RoleAdmin, "CS-01")
+	_, point, _ := seedProblem(t, db)
+	tags := []models.Tag{{Name: "array", NameCn: "数组"}, {Name: "hash-table", NameCn: "哈希表"}}
+	assert.NoError(t, db.Create(&tags).Error)
+	assert.NoError(t, db.Model(&point).Association("Tags").Append(&tags))
+
+	reply = draftReply
+	draft, err := service.DraftProblem(context.Background(), teacher.ID, point.ID, models.ProblemDifficultyMedium, "使用哈希表", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProblemDraftStatusDraft, draft.Status)
+	assert.Equal(t, "数对之和", draft.TitleCn)
+	assert.Equal(t, models.ProblemDifficultyMedium, draft.Difficulty)
+	assert.Equal(t, "cpp", draft.ReferenceLanguage)
+	assert.Equal(t, "qwen", draft.Provider)
+	assert.Equal(t, teacher.ID, draft.CreatorID)
+	// 模型没有给出的限制使用默认值
+	assert.Equal(t, 1000, draft.TimeLimit)
+	assert.Equal(t, 256, draft.MemoryLimit)
+	// 草稿默认关联知识点的全部标签
+	assert.Equal(t, fmt.Sprintf("%d,%d", tags[0].ID, tags[1].ID), draft.TagIDs)
+
+	requests := fake.Requests()
+	assert.Len(t, requests, 1)
+	assert.True(t, strings.Contains(requests[0].User, "哈希表"))
+	assert.True(t, strings.Contains(requests[0].User, "使用哈希表"))
+	assert.True(t, strings.Contains(requests[0].User, "Medium"))
+
+	tests := []struct {
+		name    string
+		pointID uint
+		reply   string
+		wantErr string
+	}{
+		{name: "knowledge point not found", pointID: 9999, reply: draftReply, wantErr: "未找到知识点"},
+		{name: "invalid json", pointID: point.ID, reply: "这是一道题", wantErr: "解析题目草稿失败"},
+		{name: "missing title", pointID: point.ID, reply: `{"content_cn": "题面"}`, wantErr: "缺少标题或题面"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply = tt.reply
+			_, err := service.DraftProblem(context.Background(), teacher.ID, tt.pointID, models.ProblemDifficultyEasy, "", "python3", "")
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	var count int64
+	db.Model(&models.ProblemDraft{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPublishProblemDraft(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewProblemService(db)
+	_, point, _ := seedProblem(t, db)
+	tag := models.Tag{Name: "array", NameCn: "数组"}
+	assert.NoError(t, db.Create(&tag).Error)
+
+	draft := models.ProblemDraft{
+		KnowledgePointID: point.ID,
+		Status:           models.ProblemDraftStatusDraft,
+		Title:            "Pair Sum",
+		TitleCn:          "数对之和",
+		ContentCn:        "找出一对数...",
+		Difficulty:       models.ProblemDifficultyEasy,
+		TimeLimit:        1000,
+		MemoryLimit:      128,
+	}
+	assert.NoError(t, db.Create(&draft).Error)
+
+	// 缺少测试用例或标签时不能发布
+	_, err := service.PublishProblemDraft(draft.ID)
+	assert.ErrorContains(t, err, "测试用例")
+
+	_, err = service.UpdateProblemDraft(draft.ID, map[string]interface{}{"test_cases": "[1,2]\n3"}, nil)
+	assert.NoError(t, err)
+	_, err = service.PublishProblemDraft(draft.ID)
+	assert.ErrorContains(t, err, "至少为草稿选择一个标签")
+
+	_, err = service.UpdateProblemDraft(draft.ID, map[string]interface{}{}, []uint{tag.ID, 9999})
+	assert.ErrorContains(t, err, "部分标签不存在")
+	updated, err := service.UpdateProblemDraft(draft.ID, map[string]interface{}{}, []uint{tag.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(tag.ID), updated.TagIDs)
+
+	problem, err := service.PublishProblemDraft(draft.ID)
+	assert.NoError(t, err)
+	assert.True(t, problem.IsCustom)
+	assert.Equal(t, "数对之和", problem.TitleCn)
+
+	var problemTags int64
+	db.Model(&models.ProblemTag{}).Where("problem_id = ? AND tag_id = ?", problem.ID, tag.ID).Count(&problemTags)
+	assert.Equal(t, int64(1), problemTags)
+	var pointProblems int64
+	db.Model(&models.KnowledgePointProblem{}).Where("knowledge_point_id = ? AND problem_id = ?", point.ID, problem.ID).Count(&pointProblems)
+	assert.Equal(t, int64(1), pointProblems)
+
+	published, err := service.GetProblemDraft(draft.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProblemDraftStatusPublished, published.Status)
+	assert.Equal(t, problem.ID, published.ProblemID)
+
+	// 已发布的草稿不能再次发布或修改
+	_, err = service.PublishProblemDraft(draft.ID)
+	assert.ErrorContains(t, err, "草稿已发布")
+	_, err = service.UpdateProblemDraft(draft.ID, map[string]interface{}{"title_cn": "新标题"}, nil)
+	assert.ErrorContains(t, err, "草稿已发布")
+}
@@ -0,0 +1,113 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptTemplateValidation(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewPromptService(db)
+
+	tests := []struct {
+		name    string
+		key     string
+		content string
+		wantErr string
+	}{
+		{name: "unknown key", key: "unknown", content: "{{.Title}}", wantErr: "未知的提示词模板"},
+		{name: "empty content", key: services.PromptHintNudge, content: "", wantErr: "模板内容不能为空"},
+		{name: "syntax error", key: services.PromptHintNudge, content: "{{.Title", wantErr: ""},
+		{name: "unknown variable", key: services.PromptHintNudge, content: "{{.Answer}}", wantErr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateTemplate(1, tt.key, 0, "", tt.content, "")
+			assert.Error(t, err)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPromptTemplateVersions(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewPromptService(db)
+
+	tmpl, err := service.CreateTemplate(1, services.PromptHintNudge, 0, "", "v1 {{.Title}}", "初始版本")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tmpl.Version)
+
+	_, err = service.CreateTemplate(1, services.PromptHintNudge, 0, "", "again {{.Title}}", "")
+	assert.ErrorContains(t, err, "已存在")
+
+	tmpl, err = service.UpdateTemplate(2, tmpl.ID, "", "v2 {{.Title}}", "修改")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tmpl.Version)
+
+	// 回滚生成新版本，内容与目标版本相同
+	tmpl, err = service.RollbackTemplate(1, tmpl.ID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tmpl.Version)
+	assert.Equal(t, "v1 {{.Title}}", tmpl.Content)
+
+	_, err = service.RollbackTemplate(1, tmpl.ID, 9)
+	assert.ErrorContains(t, err, "版本不存在")
+
+	loaded, err := service.GetTemplate(tmpl.ID)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Versions, 3)
+	assert.Equal(t, 3, loaded.Versions[0].Version)
+	assert.Equal(t, uint(2), loaded.Versions[1].EditorID)
+}
+
+func TestPromptTemplateOverrideAndDelete(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewPromptService(db)
+	course, _, problem := seedProblem(t, db)
+
+	preview := func(courseID uint) map[string]interface{} {
+		result, err := service.Preview(services.PreviewRequest{Key: services.PromptHintNudge, CourseID: courseID, ProblemID: problem.ID})
+		assert.NoError(t, err)
+		return result
+	}
+
+	global, err := service.CreateTemplate(1, services.PromptHintNudge, 0, "", "全局 {{.Title}}", "")
+	assert.NoError(t, err)
+	override, err := service.CreateTemplate(1, services.PromptHintNudge, course.ID, "", "课程 {{.Title}}", "")
+	assert.NoError(t, err)
+
+	// 课程覆盖优先于全局模板
+	assert.Equal(t, "课程 Two Sum", preview(course.ID)["content"])
+	assert.Equal(t, "全局 Two Sum", preview(0)["content"])
+
+	// 删除课程覆盖后回退到全局模板，删除全局模板后回退到内置模板
+	assert.NoError(t, service.DeleteTemplate(override.ID))
+	assert.Equal(t, global.ID, preview(course.ID)["template_id"])
+	assert.NoError(t, service.DeleteTemplate(global.ID))
+	assert.Equal(t, uint(0), preview(course.ID)["template_id"])
+
+	assert.ErrorContains(t, service.DeleteTemplate(global.ID), "模板不存在")
+
+	// 删除后可以重新创建，旧模板的历史版本仍然保留
+	recreated, err := service.CreateTemplate(1, services.PromptHintNudge, course.ID, "", "新课程 {{.Title}}", "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, override.ID, recreated.ID)
+	assert.Equal(t, 1, recreated.Version)
+	assert.Equal(t, recreated.ID, preview(course.ID)["template_id"])
+
+	var versions int64
+	db.Model(&models.PromptTemplateVersion{}).Where("template_id IN ?", []uint{global.ID, override.ID}).Count(&versions)
+	assert.Equal(t, int64(2), versions)
+}
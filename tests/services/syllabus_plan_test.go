@@ -0,0 +1,195 @@
+package services_test
+
+import (
+	"ai_teach_system/models"
+	"ai_teach_system/services"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraftSyllabusPlan(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	var first, retry string
+	fake := useFakeLLM(t, func(model, system, user string) string {
+		if strings.Contains(user, "不符合要求") {
+			return retry
+		}
+		return first
+	})
+	service := services.NewAIService(db)
+
+	teacher := seedUser(t, db, "teacher", models.RoleAdmin, "CS-01")
+	course, _, _ := seedProblem(t, db)
+	tags := []models.Tag{{Name: "array", NameCn: "数组"}, {Name: "hash-table", NameCn: "哈希表"}, {Name: "tree", NameCn: "树"}}
+	assert.NoError(t, db.Create(&tags).Error)
+
+	// 与课程已有知识点重名时要求模型重新输出
+	first = `{"knowledge_points": [{"name": "数组", "tags": [1]}]}`
+	retry = "```json\n" + `{"knowledge_points": [
+		{"name": "哈希表", "description": " 哈希表的查找与冲突处理 ", "tags": [2, 1, 2]},
+		{"name": "二叉树", "tags": []}
+	]}` + "\n```"
+	before := len(fake.Requests())
+	plan, err := service.DraftSyllabusPlan(context.Background(), teacher.ID, course.ID, "", "第一章 数组\n第二章 哈希表\n第三章 二叉树")
+	assert.NoError(t, err)
+
+	requests := fake.Requests()[before:]
+	assert.Len(t, requests, 2)
+	assert.True(t, strings.Contains(requests[0].User, "1. 数组"))
+	assert.True(t, strings.Contains(requests[1].User, "重复"))
+
+	assert.Equal(t, models.SyllabusPlanStatusDraft, plan.Status)
+	assert.Equal(t, course.ID, plan.CourseID)
+	assert.Equal(t, "数据结构", plan.CourseName)
+	assert.Len(t, plan.Points, 2)
+	assert.Equal(t, 1, plan.Points[0].Position)
+	assert.Equal(t, "哈希表的查找与冲突处理", plan.Points[0].Description)
+	assert.Equal(t, []uint{tags[1].ID, tags[0].ID}, plan.Points[0].TagIDs)
+	assert.Equal(t, []string{"hash-table", "array"}, plan.Points[0].TagNames)
+	assert.Equal(t, 2, plan.Points[1].Position)
+	assert.Empty(t, plan.Points[1].TagIDs)
+
+	tests := []struct {
+		name       string
+		courseID   uint
+		courseName string
+		syllabus   string
+		reply      string
+		wantErr    string
+	}{
+		{name: "empty syllabus", courseName: "算法", syllabus: "  ", wantErr: "课程大纲不能为空"},
+		{name: "no course", syllabus: "大纲", wantErr: "请指定课程或填写新课程名称"},
+		{name: "course not found", courseID: 9999, syllabus: "大纲", wantErr: "课程不存在"},
+		{name: "tag out of range", courseName: "算法", syllabus: "大纲", reply: `{"knowledge_points": [{"name": "排序", "tags": [4]}]}`, wantErr: "格式无效"},
+		{name: "empty points", courseName: "算法", syllabus: "大纲", reply: `{"knowledge_points": []}`, wantErr: "格式无效"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, retry = tt.reply, tt.reply
+			_, err := service.DraftSyllabusPlan(context.Background(), teacher.ID, tt.courseID, tt.courseName, tt.syllabus)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	var count int64
+	db.Model(&models.SyllabusPlan{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestApplySyllabusPlan(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewCourseService(db)
+	course, point, _ := seedProblem(t, db)
+	assert.NoError(t, db.Model(&point).Update("position", 3).Error)
+	tag := models.Tag{Name: "hash-table", NameCn: "哈希表"}
+	assert.NoError(t, db.Create(&tag).Error)
+
+	plan := models.SyllabusPlan{
+		CourseID:   course.ID,
+		CourseName: course.Name,
+		Status:     models.SyllabusPlanStatusDraft,
+		Points:     []models.SyllabusPlanPoint{{Position: 1, Name: "数组"}},
+	}
+	assert.NoError(t, db.Create(&plan).Error)
+
+	// 与课程已有知识点重名时不能应用
+	_, err := service.ApplySyllabusPlan(plan.ID)
+	assert.ErrorContains(t, err, "课程中已存在知识点")
+
+	tests := []struct {
+		name    string
+		points  []models.SyllabusPlanPoint
+		wantErr string
+	}{
+		{name: "empty points", points: []models.SyllabusPlanPoint{}, wantErr: "知识点不能为空"},
+		{name: "empty name", points: []models.SyllabusPlanPoint{{Name: " "}}, wantErr: "名称不能为空"},
+		{name: "duplicate name", points: []models.SyllabusPlanPoint{{Name: "栈"}, {Name: "栈"}}, wantErr: "重复"},
+		{name: "tag not found", points: []models.SyllabusPlanPoint{{Name: "栈", TagIDs: []uint{9999}}}, wantErr: "标签 9999 不存在"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.UpdateSyllabusPlan(plan.ID, "", tt.points)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err = service.UpdateSyllabusPlan(plan.ID, "新课程", nil)
+	assert.ErrorContains(t, err, "不能修改课程名称")
+
+	// 按 Position 重新排序和编号，标签名称以标签ID为准
+	updated, err := service.UpdateSyllabusPlan(plan.ID, "", []models.SyllabusPlanPoint{
+		{Position: 5, Name: "队列"},
+		{Position: 2, Name: " 哈希表 ", Description: "查找", TagIDs: []uint{tag.ID}, TagNames: []string{"wrong"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "哈希表", updated.Points[0].Name)
+	assert.Equal(t, 1, updated.Points[0].Position)
+	assert.Equal(t, []string{"hash-table"}, updated.Points[0].TagNames)
+	assert.Equal(t, "队列", updated.Points[1].Name)
+	assert.Equal(t, 2, updated.Points[1].Position)
+
+	result, err := service.ApplySyllabusPlan(plan.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, course.ID, result["course_id"])
+
+	// 新知识点追加在已有知识点之后
+	var points []models.KnowledgePoint
+	assert.NoError(t, db.Where("course_id = ?", course.ID).Order("position").Find(&points).Error)
+	assert.Len(t, points, 3)
+	assert.Equal(t, "数组", points[0].Name)
+	assert.Equal(t, "哈希表", points[1].Name)
+	assert.Equal(t, 4, points[1].Position)
+	assert.Equal(t, "查找", points[1].Description)
+	assert.Equal(t, "队列", points[2].Name)
+	assert.Equal(t, 5, points[2].Position)
+
+	var pointTags int64
+	db.Model(&models.KnowledgePointTag{}).Where("knowledge_point_id = ? AND tag_id = ?", points[1].ID, tag.ID).Count(&pointTags)
+	assert.Equal(t, int64(1), pointTags)
+
+	applied, err := service.GetSyllabusPlan(plan.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SyllabusPlanStatusApplied, applied.Status)
+
+	// 已应用的计划不能再次应用或修改
+	_, err = service.ApplySyllabusPlan(plan.ID)
+	assert.ErrorContains(t, err, "计划已应用")
+	_, err = service.UpdateSyllabusPlan(plan.ID, "", []models.SyllabusPlanPoint{{Name: "图"}})
+	assert.ErrorContains(t, err, "计划已应用")
+}
+
+func TestApplySyllabusPlanCreatesCourse(t *testing.T) {
+	db, cleanup := setupAIDB(t)
+	defer cleanup()
+
+	service := services.NewCourseService(db)
+	seedProblem(t, db)
+
+	existing := models.SyllabusPlan{CourseName: "数据结构", Status: models.SyllabusPlanStatusDraft, Points: []models.SyllabusPlanPoint{{Position: 1, Name: "栈"}}}
+	plan := models.SyllabusPlan{CourseName: "算法设计", Status: models.SyllabusPlanStatusDraft, Points: []models.SyllabusPlanPoint{{Position: 1, Name: "贪心"}}}
+	assert.NoError(t, db.Create([]*models.SyllabusPlan{&existing, &plan}).Error)
+
+	_, err := service.ApplySyllabusPlan(existing.ID)
+	assert.ErrorContains(t, err, "已存在")
+
+	result, err := service.ApplySyllabusPlan(plan.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "算法设计", result["course_name"])
+
+	var course models.Course
+	assert.NoError(t, db.Where("name = ?", "算法设计").First(&course).Error)
+	assert.Equal(t, course.ID, result["course_id"])
+
+	applied, err := service.GetSyllabusPlan(plan.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, course.ID, applied.CourseID)
+}
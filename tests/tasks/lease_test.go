@@ -0,0 +1,184 @@
+package services_test
+
+import (
+	"ai_teach_system/config"
+	"ai_teach_system/models"
+	"ai_teach_system/tasks"
+	"ai_teach_system/tests"
+	"ai_teach_system/tests/mocks"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const leaseTestJob = "lease_test_job"
+
+// setupLeaseTest 创建只包含测试任务的调度器，内置任务全部禁用。没有配置测试数据库时跳过
+func setupLeaseTest(t *testing.T, ttl time.Duration) (*gorm.DB, *tasks.TasksManager, func()) {
+	if _, err := os.Stat("../../.env"); err != nil {
+		t.Skip("未配置测试数据库（../../.env），跳过")
+	}
+	db, cleanup := tests.SetupTestDB()
+	if err := db.AutoMigrate(&models.JobLease{}); err != nil {
+		cleanup()
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+
+	saved := config.Scheduler
+	disabled := false
+	jobs := make(map[string]config.JobConfig)
+	for _, name := range []string{
+		tasks.TaskTypeSyncLeetCodeProblems,
+		tasks.TaskTypePurgeAICache,
+		tasks.TaskTypeClusterMisconceptions,
+		tasks.TaskTypeWeeklyLearningReports,
+		tasks.TaskTypeBackfillProblemEmbeddings,
+		tasks.TaskTypePurgeAILogs,
+		tasks.TaskTypeProcessAutoAnalyses,
+	} {
+		jobs[name] = config.JobConfig{Enabled: &disabled}
+	}
+	config.Scheduler.InstanceID = "test-instance"
+	config.Scheduler.LeaseTTL = ttl
+	config.Scheduler.Jobs = jobs
+
+	manager := tasks.NewTasksManager(db, mocks.NewMockLeetCodeService())
+	return db, manager, func() {
+		manager.Stop()
+		config.Scheduler = saved
+		cleanup()
+	}
+}
+
+// startLeaseJob 注册一个从未执行过、启动时立即补跑的任务
+func startLeaseJob(manager *tasks.TasksManager, run func(ctx context.Context) error) {
+	manager.Register(tasks.Job{
+		Name:     leaseTestJob,
+		Schedule: "0 0 0 1 1 *",
+		Overlap:  tasks.OverlapSkip,
+		Missed:   tasks.MissedRunOnce,
+		Run:      run,
+	})
+	manager.Start()
+}
+
+func loadLease(t *testing.T, db *gorm.DB) models.JobLease {
+	var lease models.JobLease
+	assert.NoError(t, db.Where("name = ?", leaseTestJob).First(&lease).Error)
+	return lease
+}
+
+func TestLeaseAcquireAndRelease(t *testing.T) {
+	db, manager, cleanup := setupLeaseTest(t, time.Minute)
+	defer cleanup()
+
+	held := make(chan models.JobLease, 1)
+	startLeaseJob(manager, func(ctx context.Context) error {
+		held <- loadLease(t, db)
+		return errors.New("boom")
+	})
+
+	select {
+	case lease := <-held:
+		assert.Equal(t, "test-instance", lease.Owner)
+		assert.NotNil(t, lease.LeaseUntil)
+		assert.NotNil(t, lease.LastRunAt)
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务没有执行")
+	}
+
+	// 任务结束后释放租约并记录执行结果
+	assert.Eventually(t, func() bool {
+		return loadLease(t, db).LastFinishedAt != nil
+	}, 5*time.Second, 100*time.Millisecond)
+	lease := loadLease(t, db)
+	assert.Empty(t, lease.Owner)
+	assert.Nil(t, lease.LeaseUntil)
+	assert.Equal(t, "boom", lease.LastError)
+}
+
+func TestLeaseHeldByAnotherInstance(t *testing.T) {
+	db, manager, cleanup := setupLeaseTest(t, time.Minute)
+	defer cleanup()
+
+	leaseUntil := time.Now().Add(time.Hour)
+	assert.NoError(t, db.Create(&models.JobLease{Name: leaseTestJob, Owner: "other-instance", LeaseUntil: &leaseUntil}).Error)
+
+	ran := make(chan struct{}, 1)
+	startLeaseJob(manager, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-ran:
+		t.Fatal("租约被其他实例持有时不应执行任务")
+	case <-time.After(2 * time.Second):
+	}
+	assert.Equal(t, "other-instance", loadLease(t, db).Owner)
+}
+
+func TestLeaseStealExpired(t *testing.T) {
+	db, manager, cleanup := setupLeaseTest(t, time.Minute)
+	defer cleanup()
+
+	// 持有租约的实例已退出，租约过期
+	leaseUntil := time.Now().Add(-time.Hour)
+	assert.NoError(t, db.Create(&models.JobLease{Name: leaseTestJob, Owner: "crashed-instance", LeaseUntil: &leaseUntil}).Error)
+
+	held := make(chan string, 1)
+	startLeaseJob(manager, func(ctx context.Context) error {
+		held <- loadLease(t, db).Owner
+		return nil
+	})
+
+	select {
+	case owner := <-held:
+		assert.Equal(t, "test-instance", owner)
+	case <-time.After(5 * time.Second):
+		t.Fatal("过期的租约应当可以被接管")
+	}
+}
+
+func TestLeaseRenewAndLoss(t *testing.T) {
+	db, manager, cleanup := setupLeaseTest(t, 3*time.Second)
+	defer cleanup()
+
+	canceled := make(chan struct{})
+	startLeaseJob(manager, func(ctx context.Context) error {
+		first := loadLease(t, db).LeaseUntil
+
+		// 每秒续约一次，有效期随之延长
+		renewed := assert.Eventually(t, func() bool {
+			until := loadLease(t, db).LeaseUntil
+			return until != nil && first != nil && until.After(*first)
+		}, 5*time.Second, 200*time.Millisecond)
+		if !renewed {
+			return nil
+		}
+
+		// 租约被其他实例接管后，续约失败并取消任务
+		assert.NoError(t, db.Model(&models.JobLease{}).Where("name = ?", leaseTestJob).Update("owner", "other-instance").Error)
+		select {
+		case <-ctx.Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	})
+
+	select {
+	case <-canceled:
+	case <-time.After(15 * time.Second):
+		t.Fatal("租约丢失后任务应当被取消")
+	}
+
+	// 释放时不能清除其他实例的租约
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, "other-instance", loadLease(t, db).Owner)
+}
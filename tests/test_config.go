@@ -37,9 +37,20 @@ func SetupTestDB() (*gorm.DB, func()) {
 		log.Fatal("Failed to create test database:", err)
 	}
 
-	// 切换到测试数据库
-	if err := db.Exec(fmt.Sprintf("USE %s", dbName)).Error; err != nil {
-		log.Fatal("Failed to switch to test database:", err)
+	// 重新连接到测试数据库。USE 只对连接池中的一个连接生效，后台任务等并发查询会拿到未选择数据库的连接
+	sqlDB, err := db.DB()
+	if err == nil {
+		sqlDB.Close()
+	}
+	db, err = gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		dbName,
+	)), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect to test database:", err)
 	}
 
 	// 自动迁移数据库结构
@@ -0,0 +1,76 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []utils.TextChunk
+	}{
+		{
+			name: "split by headings",
+			text: "# 第一章 线性表\n顺序表\n\n## 1.1 链表\n单链表的插入\n\n# 第二章 栈\n后进先出",
+			size: 100,
+			want: []utils.TextChunk{
+				{Heading: "第一章 线性表", Content: "顺序表"},
+				{Heading: "第一章 线性表 / 1.1 链表", Content: "单链表的插入"},
+				{Heading: "第二章 栈", Content: "后进先出"},
+			},
+		},
+		{
+			name: "merge paragraphs up to size",
+			text: "aaaa\n\nbbbb\n\ncccc",
+			size: 10,
+			want: []utils.TextChunk{
+				{Content: "aaaa\n\nbbbb"},
+				{Content: "cccc"},
+			},
+		},
+		{
+			name:    "long paragraph with overlap",
+			text:    "abcdefghij",
+			size:    4,
+			overlap: 1,
+			want: []utils.TextChunk{
+				{Content: "abcd"},
+				{Content: "defg"},
+				{Content: "ghij"},
+			},
+		},
+		{
+			name: "hash without space is not a heading",
+			text: "#include <stdio.h>\nint main()",
+			size: 100,
+			want: []utils.TextChunk{
+				{Content: "#include <stdio.h>\nint main()"},
+			},
+		},
+		{
+			name: "empty",
+			text: "\n\n",
+			size: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utils.ChunkText(tt.text, tt.size, tt.overlap))
+		})
+	}
+}
+
+func TestChunkTextSize(t *testing.T) {
+	text := strings.Repeat("栈是一种后进先出的数据结构。", 50)
+	for _, chunk := range utils.ChunkText(text, 100, 20) {
+		assert.LessOrEqual(t, len([]rune(chunk.Content)), 100)
+	}
+}
@@ -0,0 +1,101 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		want     string
+	}{
+		{
+			name:     "cpp comments and whitespace",
+			code:     "int  main() {\r\n    // comment\n\n    return 0; /* block\n comment */\n}\n",
+			language: "cpp",
+			want:     "int main() {\nreturn 0;\n}",
+		},
+		{
+			name:     "comment markers inside strings",
+			code:     "s = \"http://a.com\" // tail\nc = '/'",
+			language: "java",
+			want:     "s = \"http://a.com\"\nc = '/'",
+		},
+		{
+			name:     "whitespace inside literals",
+			code:     "s  =  \"a  b\";\nc = ' ';",
+			language: "java",
+			want:     "s = \"a  b\";\nc = ' ';",
+		},
+		{
+			name:     "multi-line raw string",
+			code:     "s := `a  b\n\n  c`\n\nx  :=  1",
+			language: "golang",
+			want:     "s := `a  b\n\n  c`\nx := 1",
+		},
+		{
+			name:     "python keeps indentation",
+			code:     "def f(x):\n\t# comment\n\tif x:  # trailing\n\t    return '#'\n",
+			language: "python3",
+			want:     "def f(x):\n    if x:\n        return '#'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utils.NormalizeCode(tt.code, tt.language))
+		})
+	}
+}
+
+func TestCodeHash(t *testing.T) {
+	a := utils.CodeHash("int a = 1; // x\n", "cpp")
+	b := utils.CodeHash("int  a = 1;\n\n/* y */", "cpp")
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, utils.CodeHash("int a = 2;", "cpp"))
+	assert.NotEqual(t, a, utils.CodeHash("int a = 1;", "java"))
+	assert.NotEqual(t, utils.CodeHash(`s = "a  b";`, "cpp"), utils.CodeHash(`s = "a b";`, "cpp"))
+}
+
+func TestFindCodeBlocks(t *testing.T) {
+	text := "思路如下：\n```cpp\nint a = 1;\n```\n然后\n~~~\nfor i in range(n)\n"
+	blocks := utils.FindCodeBlocks(text)
+
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, "cpp", blocks[0].Language)
+	assert.Equal(t, "int a = 1;\n", blocks[0].Code)
+	assert.Equal(t, "```cpp\nint a = 1;\n```\n", text[blocks[0].Start:blocks[0].End])
+	assert.Equal(t, "", blocks[1].Language)
+	assert.Equal(t, "for i in range(n)\n", blocks[1].Code)
+	assert.Equal(t, len(text), blocks[1].End)
+}
+
+func TestCodeContainment(t *testing.T) {
+	reference := "int sum(vector<int>& nums) {\n    int s = 0;\n    for (int x : nums) s += x;\n    return s;\n}"
+
+	copied := "你可以这样写：\nint sum(vector<int>& nums) { // 求和\n  int s = 0;\n  for (int x : nums) s += x;\n  return s;\n}"
+	assert.InDelta(t, 1.0, utils.CodeContainment(copied, reference, "cpp"), 0.001)
+
+	unrelated := "先遍历数组，用一个变量累加每个元素，最后返回结果。"
+	assert.Equal(t, 0.0, utils.CodeContainment(unrelated, reference, "cpp"))
+	assert.Equal(t, 0.0, utils.CodeContainment(copied, "", "cpp"))
+}
+
+func TestCodeSimilarity(t *testing.T) {
+	a := "int sum(vector<int>& nums) {\n    int s = 0;\n    for (int x : nums) s += x;\n    return s;\n}"
+	reformatted := "int sum(vector<int>& nums) { // 求和\n  int s = 0;\n  for (int x : nums) s += x;\n  return s;\n}"
+	assert.InDelta(t, 1.0, utils.CodeSimilarity(a, reformatted, "cpp"), 0.001)
+
+	changed := "int sum(vector<int>& nums) {\n    int s = 0;\n    for (int i = 0; i <= nums.size(); i++) s += nums[i];\n    return s;\n}"
+	similarity := utils.CodeSimilarity(a, changed, "cpp")
+	assert.Greater(t, similarity, 0.0)
+	assert.Less(t, similarity, 1.0)
+	assert.InDelta(t, similarity, utils.CodeSimilarity(changed, a, "cpp"), 0.001)
+
+	assert.Equal(t, 0.0, utils.CodeSimilarity(a, "", "cpp"))
+}
@@ -0,0 +1,70 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []utils.DiffLine
+	}{
+		{
+			name: "identical",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: []utils.DiffLine{{Op: " ", Text: "a"}, {Op: " ", Text: "b"}},
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc",
+			want: []utils.DiffLine{{Op: " ", Text: "a"}, {Op: "-", Text: "b"}, {Op: "+", Text: "x"}, {Op: " ", Text: "c"}},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a",
+			want: []utils.DiffLine{{Op: "+", Text: "a"}},
+		},
+		{
+			name: "to empty",
+			a:    "a\r\nb",
+			b:    "",
+			want: []utils.DiffLine{{Op: "-", Text: "a"}, {Op: "-", Text: "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utils.DiffLines(tt.a, tt.b))
+		})
+	}
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	// 两段各 5000 行且首尾相同，中间完全不同，超过逐行对齐的上限后整体替换中间部分
+	var a, b []string
+	a = append(a, "head")
+	b = append(b, "head")
+	for i := 0; i < 5000; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append(a, "tail")
+	b = append(b, "tail")
+
+	diff := utils.DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	assert.Len(t, diff, 10002)
+	assert.Equal(t, utils.DiffLine{Op: " ", Text: "head"}, diff[0])
+	assert.Equal(t, utils.DiffLine{Op: "-", Text: "a0"}, diff[1])
+	assert.Equal(t, utils.DiffLine{Op: "+", Text: "b0"}, diff[5001])
+	assert.Equal(t, utils.DiffLine{Op: " ", Text: "tail"}, diff[10001])
+}
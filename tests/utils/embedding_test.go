@@ -0,0 +1,61 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"tags and entities", "<p>Given an array <code>nums</code> &amp; a target,</p>\n<p>return&nbsp;indices.</p>", "Given an array nums & a target, return indices."},
+		{"plain text", "两数之和", "两数之和"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utils.StripHTML(tt.html))
+		})
+	}
+}
+
+func TestHashEmbedding(t *testing.T) {
+	query := utils.HashEmbedding("two pointers on sorted array", 256)
+	related := utils.HashEmbedding("Given a sorted array, use two pointer technique to find the pair", 256)
+	unrelated := utils.HashEmbedding("Implement a trie with insert and search", 256)
+
+	assert.Len(t, query, 256)
+	assert.InDelta(t, 1, utils.CosineSimilarity(query, query), 1e-9)
+	assert.Greater(t, utils.CosineSimilarity(query, related), utils.CosineSimilarity(query, unrelated))
+
+	zh := utils.HashEmbedding("有序数组 双指针", 256)
+	assert.Greater(t, utils.CosineSimilarity(zh, utils.HashEmbedding("在有序数组中使用双指针", 256)), 0.5)
+
+	assert.Equal(t, make([]float64, 8), utils.HashEmbedding("", 8))
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"same direction", []float64{1, 2}, []float64{2, 4}, 1},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0},
+		{"opposite", []float64{1, 0}, []float64{-1, 0}, -1},
+		{"dimension mismatch", []float64{1}, []float64{1, 0}, 0},
+		{"zero vector", []float64{0, 0}, []float64{1, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, utils.CosineSimilarity(tt.a, tt.b), 1e-9)
+		})
+	}
+}
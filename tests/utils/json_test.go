@@ -0,0 +1,27 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", `{"a": 1}`, `{"a": 1}`},
+		{"fenced", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"surrounding text", "结果如下：\n{\"a\": {\"b\": \"}\"}}\n以上。", `{"a": {"b": "}"}}`},
+		{"code fence inside value", "```json\n{\"code\": \"```cpp\\nint a;\\n```\"}\n```", "{\"code\": \"```cpp\\nint a;\\n```\"}"},
+		{"no object", "  没有结果  ", "没有结果"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utils.ExtractJSON(tt.content))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package utils_test
+
+import (
+	"ai_teach_system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, utils.EstimateTokens(""))
+	assert.Equal(t, 1, utils.EstimateTokens("abc"))
+	assert.Equal(t, 2, utils.EstimateTokens("abcdefgh"))
+	assert.Equal(t, 4, utils.EstimateTokens("动态规划"))
+	assert.Equal(t, 4, utils.EstimateTokens("二分 search"))
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TextChunk 切分后的一段文本，Heading 为所在章节的标题（多级标题以 " / " 连接）
+type TextChunk struct {
+	Heading string
+	Content string
+}
+
+// ChunkText 按 Markdown 标题切分章节，章节内按段落合并为不超过 size 个字符的片段，
+// 单个段落超过 size 时按字符切分，相邻片段重叠 overlap 个字符
+func ChunkText(text string, size, overlap int) []TextChunk {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []TextChunk
+	var headings []string
+	var paragraph []string
+	var current strings.Builder
+
+	flushChunk := func() {
+		content := strings.TrimSpace(current.String())
+		current.Reset()
+		if content != "" {
+			chunks = append(chunks, TextChunk{Heading: strings.Join(headings, " / "), Content: content})
+		}
+	}
+	addParagraph := func() {
+		content := strings.TrimSpace(strings.Join(paragraph, "\n"))
+		paragraph = paragraph[:0]
+		if content == "" {
+			return
+		}
+
+		length := utf8.RuneCountInString(current.String())
+		if length > 0 && length+utf8.RuneCountInString(content)+2 > size {
+			flushChunk()
+		}
+		if utf8.RuneCountInString(content) <= size {
+			if current.Len() > 0 {
+				current.WriteString("\n\n")
+			}
+			current.WriteString(content)
+			return
+		}
+
+		runes := []rune(content)
+		for start := 0; start < len(runes); start += size - overlap {
+			end := start + size
+			if end > len(runes) {
+				end = len(runes)
+			}
+			current.WriteString(string(runes[start:end]))
+			flushChunk()
+			if end == len(runes) {
+				break
+			}
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if level := headingLevel(trimmed); level > 0 {
+			addParagraph()
+			flushChunk()
+			if level <= len(headings) {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, strings.TrimSpace(trimmed[level:]))
+			continue
+		}
+		if trimmed == "" {
+			addParagraph()
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	addParagraph()
+	flushChunk()
+	return chunks
+}
+
+// headingLevel 返回 Markdown 标题的级别，不是标题时返回 0
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// 使用 # 作为单行注释的语言，其余语言按 // 和 /* */ 处理
+var hashCommentLanguages = map[string]bool{
+	"python":  true,
+	"python3": true,
+	"ruby":    true,
+	"elixir":  true,
+}
+
+// 缩进有语义的语言，规范化时保留行首缩进
+var indentSensitiveLanguages = map[string]bool{
+	"python":  true,
+	"python3": true,
+}
+
+// NormalizeCode 去掉代码中的注释、空行和多余的空白，使只有格式或注释不同的代码得到相同的结果。
+// 字符串字面量中的内容（包括其中的空白和跨行的反引号字符串）保持不变
+func NormalizeCode(code, language string) string {
+	language = strings.ToLower(language)
+	code = stripComments(strings.ReplaceAll(code, "\r\n", "\n"), hashCommentLanguages[language])
+
+	var lines []string
+	for _, line := range splitCodeLines(code) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var indent string
+		if indentSensitiveLanguages[language] {
+			trimmed := strings.TrimLeft(line, " \t")
+			indent = strings.ReplaceAll(line[:len(line)-len(trimmed)], "\t", "    ")
+		}
+		lines = append(lines, indent+collapseSpaces(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CodeHash 计算规范化后代码的哈希，语言不同的代码哈希不同
+func CodeHash(code, language string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(language) + "\n" + NormalizeCode(code, language)))
+	return hex.EncodeToString(sum[:])
+}
+
+// scanLiteral 返回从 runes[i] 开始的字符串字面量最后一个字符的位置。
+// 字符串未闭合时在行尾结束（如 Rust 的生命周期标注），只有反引号字符串可以跨行
+func scanLiteral(runes []rune, i int) int {
+	quote := runes[i]
+	for j := i + 1; j < len(runes); j++ {
+		switch {
+		case runes[j] == '\\' && j+1 < len(runes):
+			j++
+		case runes[j] == quote:
+			return j
+		case runes[j] == '\n' && quote != '`':
+			return j - 1
+		}
+	}
+	return len(runes) - 1
+}
+
+func isQuote(c rune) bool {
+	return c == '"' || c == '\'' || c == '`'
+}
+
+// splitCodeLines 按字面量之外的换行拆分代码
+func splitCodeLines(code string) []string {
+	runes := []rune(code)
+	var lines []string
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case isQuote(runes[i]):
+			i = scanLiteral(runes, i)
+		case runes[i] == '\n':
+			lines = append(lines, string(runes[start:i]))
+			start = i + 1
+		}
+	}
+	return append(lines, string(runes[start:]))
+}
+
+// collapseSpaces 去掉首尾空白，并将字面量之外的连续空白合并为一个空格
+func collapseSpaces(line string) string {
+	runes := []rune(strings.TrimSpace(line))
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case isQuote(c):
+			end := scanLiteral(runes, i)
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+		case unicode.IsSpace(c):
+			for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				i++
+			}
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func stripComments(code string, hashComment bool) string {
+	runes := []rune(code)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case isQuote(c):
+			// 原样保留字符串字面量
+			end := scanLiteral(runes, i)
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+		case hashComment && c == '#', !hashComment && c == '/' && next == '/':
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case !hashComment && c == '/' && next == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				// 保留注释中的换行，避免注释前后两行被拼接
+				if runes[i] == '\n' {
+					b.WriteRune('\n')
+				}
+				i++
+			}
+			i++
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// CodeBlock 文本中以 ``` 或 ~~~ 包围的代码块，Start / End 为整个代码块（包括围栏）在文本中的字节位置
+type CodeBlock struct {
+	Start    int
+	End      int
+	Language string
+	Code     string
+}
+
+// FindCodeBlocks 查找 Markdown 文本中的围栏代码块，未闭合的代码块延续到文本末尾
+func FindCodeBlocks(text string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var fence string
+	var body strings.Builder
+
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case current == nil && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+			current = &CodeBlock{Start: offset, Language: strings.ToLower(strings.TrimSpace(trimmed[3:]))}
+			body.Reset()
+		case current != nil && strings.HasPrefix(trimmed, fence):
+			current.End = offset + len(line)
+			current.Code = body.String()
+			blocks = append(blocks, *current)
+			current = nil
+		case current != nil:
+			body.WriteString(line)
+		}
+		offset += len(line)
+	}
+	if current != nil {
+		current.End = len(text)
+		current.Code = body.String()
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// 计算代码相似度时使用的连续 token 数
+const codeShingleSize = 5
+
+// CodeContainment 计算 reference 中有多大比例的代码片段（连续 token）出现在 text 中，
+// 返回 0 到 1 之间的值，忽略注释、空白和格式差异
+func CodeContainment(text, reference, language string) float64 {
+	refShingles := codeShingles(NormalizeCode(reference, language))
+	if len(refShingles) == 0 {
+		return 0
+	}
+	textShingles := codeShingles(NormalizeCode(text, language))
+
+	matched := 0
+	for shingle := range refShingles {
+		if textShingles[shingle] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(refShingles))
+}
+
+// CodeSimilarity 计算两段代码的相似度（代码片段集合的 Jaccard 系数），返回 0 到 1 之间的值，
+// 忽略注释、空白和格式差异
+func CodeSimilarity(a, b, language string) float64 {
+	aShingles := codeShingles(NormalizeCode(a, language))
+	bShingles := codeShingles(NormalizeCode(b, language))
+	if len(aShingles) == 0 || len(bShingles) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for shingle := range aShingles {
+		if bShingles[shingle] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(aShingles)+len(bShingles)-shared)
+}
+
+func codeShingles(code string) map[string]bool {
+	tokens := codeTokens(code)
+	shingles := make(map[string]bool)
+	for i := 0; i+codeShingleSize <= len(tokens); i++ {
+		shingles[strings.Join(tokens[i:i+codeShingleSize], " ")] = true
+	}
+	return shingles
+}
+
+// codeTokens 将代码拆分为标识符、数字和单个符号
+func codeTokens(code string) []string {
+	var tokens []string
+	runes := []rune(code)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
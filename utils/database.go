@@ -38,6 +38,30 @@ func InitDB() *gorm.DB {
 		&models.UserProblem{},
 		&models.KnowledgePointTag{},
 		&models.CourseClasses{},
+		&models.JobLease{},
+		&models.ProblemRevision{},
+		&models.ChatSession{},
+		&models.ChatMessage{},
+		&models.HintRecord{},
+		&models.CourseAIConfig{},
+		&models.PromptTemplate{},
+		&models.PromptTemplateVersion{},
+		&models.AIResponseCache{},
+		&models.AIUsage{},
+		&models.AIQuota{},
+		&models.AIRating{},
+		&models.AIViolation{},
+		&models.ProblemDraft{},
+		&models.CodeError{},
+		&models.MisconceptionCluster{},
+		&models.LearningReport{},
+		&models.SyllabusPlan{},
+		&models.ProblemEmbedding{},
+		&models.CourseMaterial{},
+		&models.MaterialChunk{},
+		&models.ReviewComment{},
+		&models.AILog{},
+		&models.AutoAnalysis{},
 	)
 	if err != nil {
 		log.Fatal("数据库迁移失败：", err)
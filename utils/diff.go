@@ -0,0 +1,101 @@
+package utils
+
+import "strings"
+
+// DiffLine 行级 diff 的一行，Op 为 "+"（新增）、"-"（删除）或 " "（未变化）
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells 最长公共子序列矩阵的最大单元格数，超过时不再逐行对齐
+const maxDiffCells = 1 << 20
+
+// DiffLines 基于最长公共子序列计算两段文本的行级差异。
+// 先去掉首尾相同的行，剩余部分过大时整体视为删除后新增，避免超长文本占用过多内存
+func DiffLines(a, b string) []DiffLine {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]DiffLine, 0, len(linesA)+len(linesB))
+	for _, line := range linesA[:prefix] {
+		result = append(result, DiffLine{Op: " ", Text: line})
+	}
+	result = append(result, diffMiddle(linesA[prefix:len(linesA)-suffix], linesB[prefix:len(linesB)-suffix])...)
+	for _, line := range linesA[len(linesA)-suffix:] {
+		result = append(result, DiffLine{Op: " ", Text: line})
+	}
+	return result
+}
+
+// diffMiddle 对去掉相同首尾后的部分逐行对齐
+func diffMiddle(linesA, linesB []string) []DiffLine {
+	n, m := len(linesA), len(linesB)
+	result := make([]DiffLine, 0, n+m)
+	if (n+1)*(m+1) > maxDiffCells {
+		for _, line := range linesA {
+			result = append(result, DiffLine{Op: "-", Text: line})
+		}
+		for _, line := range linesB {
+			result = append(result, DiffLine{Op: "+", Text: line})
+		}
+		return result
+	}
+
+	// lcs[i][j] 表示 linesA[i:] 与 linesB[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case linesA[i] == linesB[j]:
+			result = append(result, DiffLine{Op: " ", Text: linesA[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{Op: "-", Text: linesA[i]})
+			i++
+		default:
+			result = append(result, DiffLine{Op: "+", Text: linesB[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		result = append(result, DiffLine{Op: "-", Text: linesA[i]})
+	}
+	for ; j < m; j++ {
+		result = append(result, DiffLine{Op: "+", Text: linesB[j]})
+	}
+	return result
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"hash/fnv"
+	"html"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// StripHTML 去掉 HTML 标签并还原转义字符，合并多余的空白
+func StripHTML(s string) string {
+	text := html.UnescapeString(htmlTagPattern.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// HashEmbedding 不依赖模型的文本向量：英文单词和数字按词、中文按单字和相邻两字，
+// 通过哈希映射到 dims 维并做 L2 归一化。用于没有配置向量模型的开发环境
+func HashEmbedding(text string, dims int) []float64 {
+	vector := make([]float64, dims)
+	if dims <= 0 {
+		return vector
+	}
+
+	counts := make(map[string]int)
+	for _, token := range embeddingTokens(strings.ToLower(text)) {
+		counts[token]++
+	}
+	for token, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		weight := 1 + math.Log(float64(count))
+		// 用哈希的最高位决定符号，减少哈希冲突带来的偏差
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(dims)] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// embeddingTokens 将文本拆分为英文单词、数字、中文单字和中文相邻两字
+func embeddingTokens(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.Is(unicode.Han, c):
+			tokens = append(tokens, string(c))
+			if i+1 < len(runes) && unicode.Is(unicode.Han, runes[i+1]) {
+				tokens = append(tokens, string(runes[i:i+2]))
+			}
+			i++
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(runes) && !unicode.Is(unicode.Han, runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			word := string(runes[i:j])
+			// 简单去掉英文复数，使 pointers 和 pointer 命中同一个词
+			if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+				word = word[:len(word)-1]
+			}
+			tokens = append(tokens, word)
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，维度不同或存在零向量时返回 0
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,15 @@
+package utils
+
+import "strings"
+
+// ExtractJSON 从模型回复中取出 JSON 对象：去掉 markdown 代码块标记和 JSON 前后的说明文字。
+// 没有找到 JSON 对象时返回去掉首尾空白的原始内容
+func ExtractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
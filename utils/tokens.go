@@ -0,0 +1,16 @@
+package utils
+
+import "unicode"
+
+// EstimateTokens 粗略估算文本的 token 数：中日韩字符按每字 1 个 token，其余字符按每 4 个 1 个 token
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}